@@ -0,0 +1,45 @@
+package main
+
+import "sync/atomic"
+
+// aimd is an adaptive concurrency limit, additive increase and multiplicative
+// decrease: it starts at one cluster at a time, goes up by one after every
+// limit's worth of clusters that finish without throttling, and halves when
+// there's been throttling since the last one finished. It's only used from
+// the dispatch loop, so it needs no locking.
+type aimd struct {
+	limit, max int
+	clean      int
+}
+
+func newAIMD(max int) *aimd {
+	return &aimd{limit: 1, max: max}
+}
+
+// finished adjusts the limit for a cluster that's finished, reporting whether
+// it changed.
+func (a *aimd) finished(throttled bool) bool {
+	if throttled {
+		a.clean = 0
+		if a.limit == 1 {
+			return false
+		}
+		a.limit /= 2
+		return true
+	}
+
+	a.clean++
+	if a.clean < a.limit || a.limit == a.max {
+		return false
+	}
+	a.clean = 0
+	a.limit++
+	return true
+}
+
+// throttledSince reports whether any snapshot has been throttled since the
+// count was seen, and what it is now.
+func (b *BackupManager) throttledSince(seen int64) (bool, int64) {
+	now := atomic.LoadInt64(&b.stats.throttled)
+	return now > seen, now
+}
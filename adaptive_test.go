@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAIMD(t *testing.T) {
+	a := newAIMD(4)
+	limits := make([]int, 0)
+	for _, throttled := range []bool{false, false, false, false, false, false, true, false, true, true, false} {
+		a.finished(throttled)
+		limits = append(limits, a.limit)
+	}
+	// up by one for each limit's worth of clean finishes, never past the
+	// max, and halved by throttling, never below one
+	assert.Equal(t, []int{2, 2, 3, 3, 3, 4, 2, 2, 1, 1, 2}, limits)
+}
+
+// limitedSnapshotTaker throttles snapshot requests while more than limit are
+// already being made, like an account's API rate limit. Each request takes a
+// little while, so they overlap.
+type limitedSnapshotTaker struct {
+	*fakeSnapshotTaker
+	limit int
+
+	mu        sync.Mutex
+	inFlight  int
+	peak      int
+	throttles int
+}
+
+func (l *limitedSnapshotTaker) CreateDBClusterSnapshot(ctx context.Context, in *rds.CreateDBClusterSnapshotInput, optFns ...func(*rds.Options)) (*rds.CreateDBClusterSnapshotOutput, error) {
+	l.mu.Lock()
+	if l.inFlight >= l.limit {
+		l.throttles++
+		l.mu.Unlock()
+		return nil, &smithy.GenericAPIError{Code: "Throttling", Message: "Rate exceeded"}
+	}
+	l.inFlight++
+	if l.inFlight > l.peak {
+		l.peak = l.inFlight
+	}
+	l.mu.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+	out, err := l.fakeSnapshotTaker.CreateDBClusterSnapshot(ctx, in, optFns...)
+
+	l.mu.Lock()
+	l.inFlight--
+	l.mu.Unlock()
+	return out, err
+}
+
+func TestAutoConcurrency(t *testing.T) {
+	st := &limitedSnapshotTaker{fakeSnapshotTaker: NewFakeSnapshotTaker(), limit: 3}
+	bm := NewBackupManager(st, WithAutoConcurrency(8), WithRetries(50))
+	bm.sleep = func(ctx context.Context, d time.Duration) error {
+		return sleepContext(ctx, time.Millisecond)
+	}
+
+	clusters := make([]string, 40)
+	for i := range clusters {
+		clusters[i] = fmt.Sprintf("my-cluster-%d", i)
+	}
+	results, err := bm.TriggerSnapshots(context.TODO(), clusters...)
+	assert.Nil(t, err)
+	assert.Len(t, results, len(clusters))
+	assert.Equal(t, RunStats{Created: int64(len(clusters)), Retried: int64(st.throttles)}, bm.Stats())
+
+	// it ramped up past one at a time and ran into the limit, but backed off
+	// rather than hammering it with all eight workers
+	assert.Greater(t, st.peak, 1)
+	assert.LessOrEqual(t, st.peak, st.limit)
+	assert.Greater(t, st.throttles, 0)
+	assert.Less(t, st.throttles, len(clusters))
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+)
+
+const (
+	ErrNotAClusterARN  BackupManagerError = "ARN doesn't refer to an RDS cluster"
+	ErrNotASnapshotARN BackupManagerError = "ARN doesn't refer to an RDS cluster snapshot"
+	ErrInvalidRegion   BackupManagerError = "region must look like us-east-1"
+	ErrNoAccountID     BackupManagerError = "building a snapshot ARN needs the account ID"
+)
+
+// regionPattern matches region names like us-east-1, us-gov-west-1 and
+// cn-north-1.
+var regionPattern = regexp.MustCompile(`^[a-z]{2}(-gov|-iso[a-z]?)?-[a-z]+-[0-9]+$`)
+
+// validateRegion checks that a region at least looks like one.
+func validateRegion(region string) error {
+	if !regionPattern.MatchString(region) {
+		return fmt.Errorf("'%s': %w", region, ErrInvalidRegion)
+	}
+	return nil
+}
+
+// partitionFor returns the partition a region belongs to, for building ARNs.
+func partitionFor(region string) string {
+	switch {
+	case strings.HasPrefix(region, "cn-"):
+		return "aws-cn"
+	case strings.HasPrefix(region, "us-gov-"):
+		return "aws-us-gov"
+	case strings.HasPrefix(region, "us-iso-"):
+		return "aws-iso"
+	case strings.HasPrefix(region, "us-isob-"):
+		return "aws-iso-b"
+	}
+	return "aws"
+}
+
+// clusterSnapshotARN builds the ARN of a cluster snapshot in a given region
+// and account.
+func clusterSnapshotARN(region, accountID, snapshotID string) (string, error) {
+	if err := validateRegion(region); err != nil {
+		return "", err
+	}
+	if accountID == "" {
+		return "", fmt.Errorf("'%s' in %s: %w", snapshotID, region, ErrNoAccountID)
+	}
+	return arn.ARN{
+		Partition: partitionFor(region),
+		Service:   "rds",
+		Region:    region,
+		AccountID: accountID,
+		Resource:  "cluster-snapshot:" + snapshotID,
+	}.String(), nil
+}
+
+// parseSnapshotIdentifier accepts either a bare snapshot identifier or a
+// cluster snapshot ARN and returns the bare identifier.
+func parseSnapshotIdentifier(s string) (string, error) {
+	if !strings.HasPrefix(s, "arn:") {
+		return s, nil
+	}
+
+	parsed, err := arn.Parse(s)
+	if err != nil {
+		return "", fmt.Errorf("parsing '%s': %w", s, err)
+	}
+	identifier := strings.TrimPrefix(parsed.Resource, "cluster-snapshot:")
+	if parsed.Service != "rds" || identifier == parsed.Resource || identifier == "" {
+		return "", fmt.Errorf("'%s': %w", s, ErrNotASnapshotARN)
+	}
+	return identifier, nil
+}
+
+// parseClusterIdentifier accepts either a bare cluster identifier or a
+// cluster ARN (arn:aws:rds:<region>:<account>:cluster:<identifier>) and
+// returns the bare identifier.
+func parseClusterIdentifier(s string) (string, error) {
+	if !strings.HasPrefix(s, "arn:") {
+		return s, nil
+	}
+
+	parsed, err := arn.Parse(s)
+	if err != nil {
+		return "", fmt.Errorf("parsing '%s': %w", s, err)
+	}
+	if parsed.Service != "rds" || !strings.HasPrefix(parsed.Resource, "cluster:") {
+		return "", fmt.Errorf("'%s': %w", s, ErrNotAClusterARN)
+	}
+
+	identifier := strings.TrimPrefix(parsed.Resource, "cluster:")
+	if identifier == "" {
+		return "", fmt.Errorf("'%s': %w", s, ErrNotAClusterARN)
+	}
+	return identifier, nil
+}
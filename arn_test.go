@@ -0,0 +1,130 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseClusterIdentifier(t *testing.T) {
+	type testCase struct {
+		input         string
+		result        string
+		expectError   bool
+		expectedError error
+	}
+
+	testCases := map[string]testCase{
+		"bare identifier is unchanged": {
+			input:  "my-cluster-1",
+			result: "my-cluster-1",
+		},
+		"cluster ARN": {
+			input:  "arn:aws:rds:us-east-1:123456789012:cluster:my-cluster-1",
+			result: "my-cluster-1",
+		},
+		"cluster ARN in another partition": {
+			input:  "arn:aws-cn:rds:cn-north-1:123456789012:cluster:my-cluster-1",
+			result: "my-cluster-1",
+		},
+		"instance ARN": {
+			input:         "arn:aws:rds:us-east-1:123456789012:db:my-instance-1",
+			expectError:   true,
+			expectedError: ErrNotAClusterARN,
+		},
+		"ARN for another service": {
+			input:         "arn:aws:docdb:us-east-1:123456789012:cluster:my-cluster-1",
+			expectError:   true,
+			expectedError: ErrNotAClusterARN,
+		},
+		"ARN without an identifier": {
+			input:         "arn:aws:rds:us-east-1:123456789012:cluster:",
+			expectError:   true,
+			expectedError: ErrNotAClusterARN,
+		},
+		"malformed ARN": {
+			input:       "arn:aws:rds",
+			expectError: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			result, err := parseClusterIdentifier(tc.input)
+			if !tc.expectError {
+				assert.Nil(t, err)
+				assert.Equal(t, tc.result, result)
+				return
+			}
+			assert.Error(t, err)
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestClusterSnapshotARN(t *testing.T) {
+	type testCase struct {
+		region        string
+		accountID     string
+		expected      string
+		expectedError error
+	}
+
+	testCases := map[string]testCase{
+		"commercial region": {
+			region:    "eu-west-1",
+			accountID: "123456789012",
+			expected:  "arn:aws:rds:eu-west-1:123456789012:cluster-snapshot:testing-my-cluster-1",
+		},
+		"china": {
+			region:    "cn-north-1",
+			accountID: "123456789012",
+			expected:  "arn:aws-cn:rds:cn-north-1:123456789012:cluster-snapshot:testing-my-cluster-1",
+		},
+		"GovCloud": {
+			region:    "us-gov-west-1",
+			accountID: "123456789012",
+			expected:  "arn:aws-us-gov:rds:us-gov-west-1:123456789012:cluster-snapshot:testing-my-cluster-1",
+		},
+		"not a region": {
+			region:        "US East (N. Virginia)",
+			accountID:     "123456789012",
+			expectedError: ErrInvalidRegion,
+		},
+		"missing the number": {
+			region:        "us-east",
+			accountID:     "123456789012",
+			expectedError: ErrInvalidRegion,
+		},
+		"no account": {
+			region:        "us-east-1",
+			expectedError: ErrNoAccountID,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			result, err := clusterSnapshotARN(tc.region, tc.accountID, "testing-my-cluster-1")
+			assert.ErrorIs(t, err, tc.expectedError)
+			assert.Equal(t, tc.expected, result)
+			if tc.expectedError == nil {
+				identifier, err := parseSnapshotIdentifier(result)
+				assert.Nil(t, err)
+				assert.Equal(t, "testing-my-cluster-1", identifier)
+			}
+		})
+	}
+}
+
+func TestParseSnapshotIdentifier(t *testing.T) {
+	identifier, err := parseSnapshotIdentifier("testing-my-cluster-1")
+	assert.Nil(t, err)
+	assert.Equal(t, "testing-my-cluster-1", identifier)
+
+	_, err = parseSnapshotIdentifier("arn:aws:rds:us-east-1:123456789012:cluster:my-cluster-1")
+	assert.ErrorIs(t, err, ErrNotASnapshotARN)
+	_, err = parseSnapshotIdentifier("arn:aws:rds:us-east-1:123456789012:cluster-snapshot:")
+	assert.ErrorIs(t, err, ErrNotASnapshotARN)
+}
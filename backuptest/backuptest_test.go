@@ -0,0 +1,45 @@
+package backuptest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/stretchr/testify/assert"
+)
+
+func snapshotInput(clusterID string) *rds.CreateDBClusterSnapshotInput {
+	return &rds.CreateDBClusterSnapshotInput{
+		DBClusterIdentifier:         aws.String(clusterID),
+		DBClusterSnapshotIdentifier: aws.String("testing-" + clusterID),
+	}
+}
+
+func TestCancellableSnapshotTakerFinishes(t *testing.T) {
+	f := &CancellableSnapshotTaker{Delay: time.Millisecond}
+
+	out, err := f.CreateDBClusterSnapshot(context.TODO(), snapshotInput("my-cluster-1"))
+	assert.Nil(t, err)
+	assert.Equal(t, "testing-my-cluster-1", aws.ToString(out.DBClusterSnapshot.DBClusterSnapshotIdentifier))
+	assert.Equal(t, []Call{{DBClusterIdentifier: "my-cluster-1", DBClusterSnapshotIdentifier: "testing-my-cluster-1"}}, f.Calls())
+	assert.Equal(t, 0, f.Cancelled())
+}
+
+func TestCancellableSnapshotTakerCancelled(t *testing.T) {
+	f := &CancellableSnapshotTaker{Delays: map[string]time.Duration{"my-cluster-1": time.Hour}}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	_, err := f.CreateDBClusterSnapshot(ctx, snapshotInput("my-cluster-2"))
+	assert.Nil(t, err)
+
+	go cancel()
+	_, err = f.CreateDBClusterSnapshot(ctx, snapshotInput("my-cluster-1"))
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, []Call{
+		{DBClusterIdentifier: "my-cluster-2", DBClusterSnapshotIdentifier: "testing-my-cluster-2"},
+		{DBClusterIdentifier: "my-cluster-1", DBClusterSnapshotIdentifier: "testing-my-cluster-1", Cancelled: true},
+	}, f.Calls())
+	assert.Equal(t, 1, f.Cancelled())
+}
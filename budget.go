@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+)
+
+const ErrAPIBudgetExceeded BackupManagerError = "the run has made as many RDS calls as its APICallBudget allows"
+
+// meterAPICalls puts meteredRDS in front of every RDS client the manager
+// has, if there's a budget. It's done as the manager's made, so every call
+// it ever makes is counted. SourceDescriber is metered as it's used, by
+// sourceDescriber.
+func (b *BackupManager) meterAPICalls() {
+	if b.APICallBudget <= 0 {
+		return
+	}
+	b.apiCalls = new(int64)
+
+	m := &meteredRDS{b: b, st: b.st, ist: b.ist, isd: b.isd, sd: b.sd, del: b.del, cp: b.cp, cd: b.cd, gcd: b.gcd, tl: b.tl, ta: b.ta, rs: b.rs, ic: b.ic, cs: b.cs}
+	b.st = m
+	if b.ist != nil {
+		b.ist = m
+	}
+	if b.isd != nil {
+		b.isd = m
+	}
+	if b.sd != nil {
+		b.sd = m
+	}
+	if b.del != nil {
+		b.del = m
+	}
+	if b.cp != nil {
+		b.cp = m
+	}
+	if b.cd != nil {
+		b.cd = m
+	}
+	if b.gcd != nil {
+		b.gcd = m
+	}
+	if b.tl != nil {
+		b.tl = m
+	}
+	if b.ta != nil {
+		b.ta = m
+	}
+	if b.rs != nil {
+		b.rs = m
+	}
+	if b.ic != nil {
+		b.ic = m
+	}
+	if b.cs != nil {
+		b.cs = m
+	}
+}
+
+// spendAPICall counts one RDS call against the APICallBudget, failing once
+// it's spent. Without a budget, there's always one to spend.
+func (b *BackupManager) spendAPICall() error {
+	if b.APICallBudget <= 0 || b.apiCalls == nil {
+		return nil
+	}
+	if atomic.AddInt64(b.apiCalls, 1) > int64(b.APICallBudget) {
+		return ErrAPIBudgetExceeded
+	}
+	return nil
+}
+
+// APICalls is how many RDS calls the manager has made, or tried to make past
+// its APICallBudget. They're only counted with a budget.
+func (b *BackupManager) APICalls() int64 {
+	if b.apiCalls == nil {
+		return 0
+	}
+	return atomic.LoadInt64(b.apiCalls)
+}
+
+// meteredRDS counts each call against its manager's APICallBudget before
+// passing it on, and turns it down without passing it on once the budget's
+// spent.
+type meteredRDS struct {
+	b   *BackupManager
+	st  SnapshotTaker
+	ist InstanceSnapshotTaker
+	isd InstanceSnapshotDescriber
+	sd  SnapshotDescriber
+	del SnapshotDeleter
+	cp  SnapshotCopier
+	cd  ClusterDescriber
+	gcd GlobalClusterDescriber
+	tl  TagLister
+	ta  TagAdder
+	rs  ClusterRestorer
+	ic  InstanceCreator
+	cs  ClusterStarter
+}
+
+var _ RDSAPI = (*meteredRDS)(nil)
+
+func (m *meteredRDS) CreateDBClusterSnapshot(ctx context.Context, in *rds.CreateDBClusterSnapshotInput, optFns ...func(*rds.Options)) (*rds.CreateDBClusterSnapshotOutput, error) {
+	if err := m.b.spendAPICall(); err != nil {
+		return nil, err
+	}
+	return m.st.CreateDBClusterSnapshot(ctx, in, optFns...)
+}
+
+func (m *meteredRDS) CreateDBSnapshot(ctx context.Context, in *rds.CreateDBSnapshotInput, optFns ...func(*rds.Options)) (*rds.CreateDBSnapshotOutput, error) {
+	if err := m.b.spendAPICall(); err != nil {
+		return nil, err
+	}
+	return m.ist.CreateDBSnapshot(ctx, in, optFns...)
+}
+
+func (m *meteredRDS) DescribeDBSnapshots(ctx context.Context, in *rds.DescribeDBSnapshotsInput, optFns ...func(*rds.Options)) (*rds.DescribeDBSnapshotsOutput, error) {
+	if err := m.b.spendAPICall(); err != nil {
+		return nil, err
+	}
+	return m.isd.DescribeDBSnapshots(ctx, in, optFns...)
+}
+
+func (m *meteredRDS) DescribeDBClusterSnapshots(ctx context.Context, in *rds.DescribeDBClusterSnapshotsInput, optFns ...func(*rds.Options)) (*rds.DescribeDBClusterSnapshotsOutput, error) {
+	if err := m.b.spendAPICall(); err != nil {
+		return nil, err
+	}
+	return m.sd.DescribeDBClusterSnapshots(ctx, in, optFns...)
+}
+
+func (m *meteredRDS) DeleteDBClusterSnapshot(ctx context.Context, in *rds.DeleteDBClusterSnapshotInput, optFns ...func(*rds.Options)) (*rds.DeleteDBClusterSnapshotOutput, error) {
+	if err := m.b.spendAPICall(); err != nil {
+		return nil, err
+	}
+	return m.del.DeleteDBClusterSnapshot(ctx, in, optFns...)
+}
+
+func (m *meteredRDS) CopyDBClusterSnapshot(ctx context.Context, in *rds.CopyDBClusterSnapshotInput, optFns ...func(*rds.Options)) (*rds.CopyDBClusterSnapshotOutput, error) {
+	if err := m.b.spendAPICall(); err != nil {
+		return nil, err
+	}
+	return m.cp.CopyDBClusterSnapshot(ctx, in, optFns...)
+}
+
+func (m *meteredRDS) DescribeDBClusters(ctx context.Context, in *rds.DescribeDBClustersInput, optFns ...func(*rds.Options)) (*rds.DescribeDBClustersOutput, error) {
+	if err := m.b.spendAPICall(); err != nil {
+		return nil, err
+	}
+	return m.cd.DescribeDBClusters(ctx, in, optFns...)
+}
+
+func (m *meteredRDS) DescribeGlobalClusters(ctx context.Context, in *rds.DescribeGlobalClustersInput, optFns ...func(*rds.Options)) (*rds.DescribeGlobalClustersOutput, error) {
+	if err := m.b.spendAPICall(); err != nil {
+		return nil, err
+	}
+	return m.gcd.DescribeGlobalClusters(ctx, in, optFns...)
+}
+
+func (m *meteredRDS) ListTagsForResource(ctx context.Context, in *rds.ListTagsForResourceInput, optFns ...func(*rds.Options)) (*rds.ListTagsForResourceOutput, error) {
+	if err := m.b.spendAPICall(); err != nil {
+		return nil, err
+	}
+	return m.tl.ListTagsForResource(ctx, in, optFns...)
+}
+
+func (m *meteredRDS) AddTagsToResource(ctx context.Context, in *rds.AddTagsToResourceInput, optFns ...func(*rds.Options)) (*rds.AddTagsToResourceOutput, error) {
+	if err := m.b.spendAPICall(); err != nil {
+		return nil, err
+	}
+	return m.ta.AddTagsToResource(ctx, in, optFns...)
+}
+
+func (m *meteredRDS) RestoreDBClusterFromSnapshot(ctx context.Context, in *rds.RestoreDBClusterFromSnapshotInput, optFns ...func(*rds.Options)) (*rds.RestoreDBClusterFromSnapshotOutput, error) {
+	if err := m.b.spendAPICall(); err != nil {
+		return nil, err
+	}
+	return m.rs.RestoreDBClusterFromSnapshot(ctx, in, optFns...)
+}
+
+func (m *meteredRDS) CreateDBInstance(ctx context.Context, in *rds.CreateDBInstanceInput, optFns ...func(*rds.Options)) (*rds.CreateDBInstanceOutput, error) {
+	if err := m.b.spendAPICall(); err != nil {
+		return nil, err
+	}
+	return m.ic.CreateDBInstance(ctx, in, optFns...)
+}
+
+func (m *meteredRDS) DescribeDBInstances(ctx context.Context, in *rds.DescribeDBInstancesInput, optFns ...func(*rds.Options)) (*rds.DescribeDBInstancesOutput, error) {
+	if err := m.b.spendAPICall(); err != nil {
+		return nil, err
+	}
+	return m.ic.DescribeDBInstances(ctx, in, optFns...)
+}
+
+func (m *meteredRDS) StartDBCluster(ctx context.Context, in *rds.StartDBClusterInput, optFns ...func(*rds.Options)) (*rds.StartDBClusterOutput, error) {
+	if err := m.b.spendAPICall(); err != nil {
+		return nil, err
+	}
+	return m.cs.StartDBCluster(ctx, in, optFns...)
+}
+
+func (m *meteredRDS) StopDBCluster(ctx context.Context, in *rds.StopDBClusterInput, optFns ...func(*rds.Options)) (*rds.StopDBClusterOutput, error) {
+	if err := m.b.spendAPICall(); err != nil {
+		return nil, err
+	}
+	return m.cs.StopDBCluster(ctx, in, optFns...)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPICallBudget(t *testing.T) {
+	clusterIDs := make([]string, 0, 30)
+	for i := 0; i < 30; i++ {
+		clusterIDs = append(clusterIDs, fmt.Sprintf("my-cluster-%d", i))
+	}
+	st := NewFakeSnapshotTaker()
+	bm := NewBackupManager(st, WithPrefix("testing"), WithConcurrency(8), WithContinueOnError(true), WithAPICallBudget(10))
+
+	results, err := bm.TriggerSnapshots(context.TODO(), clusterIDs...)
+	assert.ErrorIs(t, err, ErrSnapshotsFailed)
+	assert.Len(t, results, 30)
+	// however the workers raced, only the budget's worth got through
+	assert.Len(t, st.GetJournal(), 10)
+	assert.Equal(t, RunStats{Created: 10, Failed: 20}, bm.Stats())
+	for _, result := range results {
+		if result.Status == StatusFailed {
+			assert.ErrorIs(t, result.Err, ErrAPIBudgetExceeded)
+		}
+	}
+	assert.Equal(t, int64(30), bm.APICalls())
+}
+
+func TestAPICallBudgetCoversEveryCall(t *testing.T) {
+	st := NewFakeSnapshotTaker()
+	st.clusters = []types.DBCluster{existingCluster("my-cluster-1"), existingCluster("my-cluster-2")}
+	bm := NewBackupManager(st, WithPrefix("testing"), WithSkipIfRecentWithin(time.Hour), WithContinueOnError(true), WithAPICallBudget(5))
+
+	// discovery's describes come out of the same budget as the run's calls
+	clusters, err := bm.DiscoverClusters(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), bm.APICalls())
+
+	// a describe and a create for the first cluster, then a describe for the
+	// second, which leaves nothing for its create
+	results, err := bm.TriggerSnapshots(context.TODO(), clusterIdentifiers(clusters)...)
+	assert.ErrorIs(t, err, ErrSnapshotsFailed)
+	assert.Equal(t, StatusCreated, results[0].Status)
+	assert.Equal(t, StatusFailed, results[1].Status)
+	assert.ErrorIs(t, results[1].Err, ErrAPIBudgetExceeded)
+	assert.Equal(t, []snapshotCreationRecord{{"my-cluster-1", "testing-my-cluster-1"}}, st.GetJournal())
+}
+
+func TestAPICallBudgetAcrossRuns(t *testing.T) {
+	st := NewFakeSnapshotTaker()
+	bm := NewBackupManager(st, WithPrefix("testing"), WithAPICallBudget(1))
+
+	_, err := bm.TriggerSnapshots(context.TODO(), "my-cluster-1")
+	assert.Nil(t, err)
+
+	// the second run doesn't get the budget back
+	_, err = bm.TriggerSnapshots(context.TODO(), "my-cluster-2")
+	assert.ErrorIs(t, err, ErrAPIBudgetExceeded)
+	assert.Equal(t, int64(2), bm.APICalls())
+	assert.Len(t, st.GetJournal(), 1)
+}
+
+func TestNoAPICallBudget(t *testing.T) {
+	st := NewFakeSnapshotTaker()
+	bm := NewBackupManager(st, WithPrefix("testing"), WithAPICallBudget(0))
+	assert.Equal(t, st, bm.st)
+
+	_, err := bm.TriggerSnapshots(context.TODO(), "my-cluster-1", "my-cluster-2")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), bm.APICalls())
+}
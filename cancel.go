@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+)
+
+const (
+	ErrSnapshotAvailable  BackupManagerError = "refusing to cancel a snapshot that's already available without -force"
+	ErrCantCancelSnapshot BackupManagerError = "snapshot can't be cancelled"
+)
+
+// CancelSnapshot stops a snapshot that's still being created. RDS has no
+// cancel for cluster snapshots, but deleting one while it's creating amounts
+// to the same thing. A snapshot that's already available is a real backup,
+// so it's only deleted with Force. One that's already being deleted is left
+// to it.
+func (b *BackupManager) CancelSnapshot(ctx context.Context, snapshotID string) error {
+	if b.sd == nil {
+		return ErrNoSnapshotDescriber
+	}
+	if b.del == nil {
+		return ErrNoSnapshotDeleter
+	}
+
+	status, found, err := b.snapshotStatus(ctx, snapshotID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("'%s': %w", snapshotID, &types.DBClusterSnapshotNotFoundFault{})
+	}
+	switch status {
+	case "creating":
+	case "available":
+		if !b.Force {
+			return fmt.Errorf("'%s': %w", snapshotID, ErrSnapshotAvailable)
+		}
+	case "deleting":
+		b.logf("Snapshot '%s' is already being deleted.", snapshotID)
+		return nil
+	default:
+		return fmt.Errorf("'%s' is %s: %w", snapshotID, status, ErrCantCancelSnapshot)
+	}
+
+	_, err = b.del.DeleteDBClusterSnapshot(ctx, &rds.DeleteDBClusterSnapshotInput{
+		DBClusterSnapshotIdentifier: aws.String(snapshotID),
+	})
+	if err != nil {
+		return err
+	}
+	b.logf("Cancelled snapshot '%s', which was %s.", snapshotID, status)
+	return nil
+}
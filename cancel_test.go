@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCancelSnapshot(t *testing.T) {
+	type testCase struct {
+		status          string
+		force           bool
+		expectedError   error
+		expectedDeleted []string
+	}
+
+	testCases := map[string]testCase{
+		"still creating": {
+			status:          "creating",
+			expectedDeleted: []string{"testing-my-cluster-1"},
+		},
+		"already available": {
+			status:          "available",
+			expectedError:   ErrSnapshotAvailable,
+			expectedDeleted: []string{},
+		},
+		"already available, with force": {
+			status:          "available",
+			force:           true,
+			expectedDeleted: []string{"testing-my-cluster-1"},
+		},
+		"already being deleted": {
+			status:          "deleting",
+			expectedDeleted: []string{},
+		},
+		"being copied": {
+			status:          "copying",
+			expectedError:   ErrCantCancelSnapshot,
+			expectedDeleted: []string{},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			snapshot := existingSnapshot("my-cluster-1", "testing-my-cluster-1", testNow)
+			snapshot.Status = aws.String(tc.status)
+			st := NewFakeSnapshotTakerWithSnapshots(snapshot)
+			st.deleted = []string{}
+			bm := NewBackupManager(st, WithForce(tc.force))
+
+			err := bm.CancelSnapshot(context.TODO(), "testing-my-cluster-1")
+			assert.ErrorIs(t, err, tc.expectedError)
+			assert.Equal(t, tc.expectedDeleted, st.deleted)
+		})
+	}
+}
+
+func TestCancelSnapshotNotFound(t *testing.T) {
+	bm := NewBackupManager(NewFakeSnapshotTaker())
+	err := bm.CancelSnapshot(context.TODO(), "testing-my-cluster-1")
+	var nfErr *types.DBClusterSnapshotNotFoundFault
+	assert.ErrorAs(t, err, &nfErr)
+}
+
+func TestCancelSnapshotWithoutDeleter(t *testing.T) {
+	bm := &BackupManager{sd: NewFakeSnapshotTaker()}
+	err := bm.CancelSnapshot(context.TODO(), "testing-my-cluster-1")
+	assert.ErrorIs(t, err, ErrNoSnapshotDeleter)
+}
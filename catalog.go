@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Catalog keeps a record of created snapshots outside of RDS, e.g. in a
+// backup inventory.
+type Catalog interface {
+	Record(context.Context, SnapshotResult) error
+}
+
+// ItemPutter writes DynamoDB items. *dynamodb.Client implements it.
+type ItemPutter interface {
+	PutItem(context.Context, *dynamodb.PutItemInput, ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+}
+
+// DynamoDBCatalog records each snapshot as an item in a DynamoDB table keyed
+// by SnapshotIdentifier.
+type DynamoDBCatalog struct {
+	client ItemPutter
+	table  string
+	now    func() time.Time
+}
+
+func NewDynamoDBCatalog(client ItemPutter, table string) *DynamoDBCatalog {
+	return &DynamoDBCatalog{
+		client: client,
+		table:  table,
+		now:    time.Now,
+	}
+}
+
+func (c *DynamoDBCatalog) Record(ctx context.Context, result SnapshotResult) error {
+	_, err := c.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(c.table),
+		Item: map[string]types.AttributeValue{
+			"SnapshotIdentifier": &types.AttributeValueMemberS{Value: result.SnapshotIdentifier},
+			"ClusterIdentifier":  &types.AttributeValueMemberS{Value: result.ClusterIdentifier},
+			"SnapshotArn":        &types.AttributeValueMemberS{Value: result.SnapshotArn},
+			"CreatedAt":          &types.AttributeValueMemberS{Value: c.now().UTC().Format(time.RFC3339)},
+		},
+	})
+	return err
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	rdstypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeItemPutter struct {
+	inputs []*dynamodb.PutItemInput
+}
+
+func (f *fakeItemPutter) PutItem(ctx context.Context, in *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	f.inputs = append(f.inputs, in)
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+type fakeCatalog struct {
+	mu      sync.Mutex
+	records []SnapshotResult
+	err     error
+}
+
+func (f *fakeCatalog) Record(ctx context.Context, result SnapshotResult) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.records = append(f.records, result)
+	return f.err
+}
+
+func TestDynamoDBCatalogRecord(t *testing.T) {
+	putter := &fakeItemPutter{}
+	catalog := NewDynamoDBCatalog(putter, "backup-catalog")
+	catalog.now = func() time.Time { return testNow }
+
+	err := catalog.Record(context.TODO(), SnapshotResult{
+		ClusterIdentifier:  "my-cluster-1",
+		SnapshotIdentifier: "testing-my-cluster-1",
+		SnapshotArn:        "arn:aws:rds:us-east-1:123456789012:cluster-snapshot:testing-my-cluster-1",
+		Status:             StatusCreated,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []*dynamodb.PutItemInput{
+		{
+			TableName: aws.String("backup-catalog"),
+			Item: map[string]types.AttributeValue{
+				"SnapshotIdentifier": &types.AttributeValueMemberS{Value: "testing-my-cluster-1"},
+				"ClusterIdentifier":  &types.AttributeValueMemberS{Value: "my-cluster-1"},
+				"SnapshotArn":        &types.AttributeValueMemberS{Value: "arn:aws:rds:us-east-1:123456789012:cluster-snapshot:testing-my-cluster-1"},
+				"CreatedAt":          &types.AttributeValueMemberS{Value: "2022-03-15T12:00:00Z"},
+			},
+		},
+	}, putter.inputs)
+}
+
+func TestTriggerSnapshotsRecordsToCatalog(t *testing.T) {
+	type testCase struct {
+		catalogErr error
+	}
+
+	testCases := map[string]testCase{
+		"records created snapshots only": {},
+		"catalog errors don't fail the backup": {
+			catalogErr: errors.New("table not found"),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			catalog := &fakeCatalog{err: tc.catalogErr}
+			st := NewFlakySnapshotTaker("my-cluster-2", &rdstypes.DBClusterNotFoundFault{})
+			bm := NewBackupManager(st, WithPrefix("testing"), WithCatalog(catalog))
+
+			results, err := bm.TriggerSnapshots(context.TODO(), "my-cluster-1", "my-cluster-2", "my-cluster-3")
+			assert.Nil(t, err)
+			assert.Equal(t, []SnapshotResult{results[0], results[2]}, catalog.records)
+		})
+	}
+}
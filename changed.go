@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+)
+
+// unchangedSince guesses whether a cluster has changed since its newest
+// snapshot from this tool, by checking whether its latest restorable time
+// has moved past that snapshot's creation. It's only a heuristic: RDS
+// doesn't say when a cluster was last written to. Without a snapshot or a
+// restorable time to compare, the cluster counts as changed.
+func (b *BackupManager) unchangedSince(ctx context.Context, clusterIdentifier string) (newest *types.DBClusterSnapshot, latestRestorable time.Time, unchanged bool, err error) {
+	out, err := b.cd.DescribeDBClusters(ctx, &rds.DescribeDBClustersInput{
+		DBClusterIdentifier: aws.String(clusterIdentifier),
+	})
+	if err != nil {
+		return nil, time.Time{}, false, err
+	}
+	if len(out.DBClusters) == 0 || out.DBClusters[0].LatestRestorableTime == nil {
+		return nil, time.Time{}, false, nil
+	}
+	latestRestorable = *out.DBClusters[0].LatestRestorableTime
+
+	snapshots, err := b.describeOwnSnapshots(ctx, clusterIdentifier)
+	if err != nil {
+		return nil, time.Time{}, false, err
+	}
+	newest, _ = mostRecentSnapshot(snapshots, b.clock())
+	if newest == nil {
+		return nil, latestRestorable, false, nil
+	}
+	// a snapshot still being created has no time yet, but it's as new as it
+	// gets
+	createdAt := snapshotCreatedAt(*newest)
+	unchanged = createdAt == nil || !latestRestorable.After(*createdAt)
+	return newest, latestRestorable, unchanged, nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTriggerSnapshotsOnlyIfChanged(t *testing.T) {
+	type testCase struct {
+		latestRestorable *time.Time
+		snapshots        []types.DBClusterSnapshot
+		expectedStatus   SnapshotStatus
+		expectedSnapshot string
+	}
+
+	testCases := map[string]testCase{
+		"changed since the last snapshot": {
+			latestRestorable: aws.Time(testNow.Add(-time.Hour)),
+			snapshots:        []types.DBClusterSnapshot{existingSnapshot("my-cluster-1", "testing-old", testNow.Add(-2*time.Hour))},
+			expectedStatus:   StatusCreated,
+			expectedSnapshot: "testing-my-cluster-1",
+		},
+		"unchanged since the last snapshot": {
+			latestRestorable: aws.Time(testNow.Add(-2 * time.Hour)),
+			snapshots: []types.DBClusterSnapshot{
+				existingSnapshot("my-cluster-1", "testing-older", testNow.Add(-48*time.Hour)),
+				existingSnapshot("my-cluster-1", "testing-old", testNow.Add(-time.Hour)),
+			},
+			expectedStatus:   StatusSkippedUnchanged,
+			expectedSnapshot: "testing-old",
+		},
+		"no snapshot to compare with": {
+			latestRestorable: aws.Time(testNow.Add(-2 * time.Hour)),
+			expectedStatus:   StatusCreated,
+			expectedSnapshot: "testing-my-cluster-1",
+		},
+		"no restorable time to compare with": {
+			snapshots:        []types.DBClusterSnapshot{existingSnapshot("my-cluster-1", "testing-old", testNow.Add(-time.Hour))},
+			expectedStatus:   StatusCreated,
+			expectedSnapshot: "testing-my-cluster-1",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			st := NewFakeSnapshotTakerWithSnapshots(tc.snapshots...)
+			cluster := existingCluster("my-cluster-1")
+			cluster.LatestRestorableTime = tc.latestRestorable
+			st.clusters = []types.DBCluster{cluster}
+			bm := NewBackupManager(st, WithPrefix("testing"), WithOnlyIfChanged(true))
+			bm.now = func() time.Time { return testNow }
+
+			results, err := bm.TriggerSnapshots(context.TODO(), "my-cluster-1")
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedStatus, results[0].Status)
+			assert.Equal(t, tc.expectedSnapshot, results[0].SnapshotIdentifier)
+		})
+	}
+}
+
+func TestTriggerSnapshotsOnlyIfChangedWithoutDescribers(t *testing.T) {
+	bm := &BackupManager{st: NewFakeSnapshotTaker(), sd: NewFakeSnapshotTaker(), prefix: "testing", OnlyIfChanged: true}
+	_, err := bm.TriggerSnapshots(context.TODO(), "my-cluster-1")
+	assert.ErrorIs(t, err, ErrNoClusterDescriber)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+)
+
+const ErrNameCollision BackupManagerError = "snapshot names collide"
+
+// findCollisions works out what each cluster's snapshot will be called and
+// deals with any name another cluster earlier in the list already has: with
+// DisambiguateNames it's renamed, otherwise it's marked to fail. Clusters
+// whose names can't be worked out are left to fail on their own later.
+func (b *BackupManager) findCollisions(clusterIDs []string) {
+	b.renamed = make(map[string]string)
+	b.collisions = make(map[string]string)
+
+	owners := make(map[string]string, len(clusterIDs))
+	for _, clusterID := range clusterIDs {
+		clusterIdentifier, err := parseClusterIdentifier(clusterID)
+		if err != nil {
+			continue
+		}
+		prefix, err := b.prefixFor(clusterIdentifier)
+		if err != nil {
+			continue
+		}
+		name := b.snapshotIdentifier(prefix, clusterIdentifier)
+		owner, taken := owners[name]
+		if !taken || owner == clusterIdentifier {
+			owners[name] = clusterIdentifier
+			continue
+		}
+
+		if !b.DisambiguateNames {
+			b.logf("Snapshots of '%s' and '%s' would both be called '%s'.", owner, clusterIdentifier, name)
+			b.collisions[clusterIdentifier] = owner
+			continue
+		}
+		renamed := b.snapshotIdentifierWith(prefix, clusterIdentifier, clusterHash(clusterIdentifier))
+		b.logf("Calling the snapshot of '%s' '%s', since '%s' is already '%s'.", clusterIdentifier, renamed, name, owner)
+		b.renamed[clusterIdentifier] = renamed
+		owners[renamed] = clusterIdentifier
+	}
+}
+
+// clusterHash is six hex digits that tell apart clusters whose names have
+// been cut short.
+func clusterHash(clusterIdentifier string) string {
+	h := fnv.New32a()
+	h.Write([]byte(clusterIdentifier))
+	return fmt.Sprintf("%06x", h.Sum32()&0xffffff)
+}
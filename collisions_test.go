@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNameCollisions(t *testing.T) {
+	// the same for the first 63 bytes of the name, once the prefix is on
+	long := "a-cluster-whose-name-goes-on-and-on-for-far-too-long-to-fit-"
+	first, second := long+"primary", long+"replica"
+
+	bm := NewBackupManager(NewFakeSnapshotTaker(), WithPrefix("testing"), WithDisambiguateNames(true))
+	bm.now = func() time.Time { return testNow }
+	results, err := bm.TriggerSnapshots(context.TODO(), first, second, "my-cluster-1")
+	assert.Nil(t, err)
+	assert.Equal(t, "testing-a-cluster-whose-name-goes-on-and-on-for-far-too-long-to", results[0].SnapshotIdentifier)
+	renamed := results[1].SnapshotIdentifier
+	assert.Equal(t, "testing-a-cluster-whose-name-goes-on-and-on-for-far-too-"+clusterHash(second), renamed)
+	assert.LessOrEqual(t, len(renamed), maxSnapshotIdentifierLen)
+	assert.Equal(t, "testing-my-cluster-1", results[2].SnapshotIdentifier)
+	assert.Equal(t, RunStats{Created: 3}, bm.Stats())
+
+	// the hash goes before a suffix, which is kept whole
+	bm.Suffix = "pre-upgrade"
+	results, err = bm.TriggerSnapshots(context.TODO(), first, second)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasSuffix(results[1].SnapshotIdentifier, "-"+clusterHash(second)+"-pre-upgrade"), results[1].SnapshotIdentifier)
+	assert.NotEqual(t, results[0].SnapshotIdentifier, results[1].SnapshotIdentifier)
+	assert.LessOrEqual(t, len(results[1].SnapshotIdentifier), maxSnapshotIdentifierLen)
+}
+
+func TestNameCollisionsFail(t *testing.T) {
+	long := "a-cluster-whose-name-goes-on-and-on-for-far-too-long-to-fit-"
+	st := NewFakeSnapshotTaker()
+	bm := NewBackupManager(st, WithPrefix("testing"), WithContinueOnError(true))
+	bm.now = func() time.Time { return testNow }
+
+	results, err := bm.TriggerSnapshots(context.TODO(), long+"primary", long+"replica")
+	assert.ErrorIs(t, err, ErrSnapshotsFailed)
+	assert.Equal(t, StatusCreated, results[0].Status)
+	assert.Equal(t, StatusFailed, results[1].Status)
+	assert.ErrorIs(t, results[1].Err, ErrNameCollision)
+	assert.Len(t, st.journal, 1)
+}
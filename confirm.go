@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	ErrConfirmationRequired BackupManagerError = "refusing to run destructive operation non-interactively without -yes"
+	ErrNotConfirmed         BackupManagerError = "destructive operation not confirmed"
+)
+
+// confirmDeletion asks the user to confirm deleting n snapshots. If assumeYes
+// is set the prompt is skipped. When not attached to a terminal there's nobody
+// to ask, so we refuse rather than guess.
+func confirmDeletion(in io.Reader, out io.Writer, interactive, assumeYes bool, n int) error {
+	if assumeYes {
+		return nil
+	}
+	if !interactive {
+		return ErrConfirmationRequired
+	}
+
+	fmt.Fprintf(out, "About to delete %d snapshot(s). Continue? [y/N] ", n)
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	}
+	return ErrNotConfirmed
+}
+
+// isTerminal reports whether f is attached to a character device, i.e. a TTY.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
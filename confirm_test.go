@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfirmDeletion(t *testing.T) {
+	type testCase struct {
+		input         string
+		interactive   bool
+		assumeYes     bool
+		expectedError error
+		expectPrompt  bool
+	}
+
+	testCases := map[string]testCase{
+		"assume yes skips the prompt": {
+			assumeYes: true,
+		},
+		"assume yes works non-interactively": {
+			interactive: false,
+			assumeYes:   true,
+		},
+		"non-interactive without yes refuses": {
+			expectedError: ErrConfirmationRequired,
+		},
+		"user answers y": {
+			input:        "y\n",
+			interactive:  true,
+			expectPrompt: true,
+		},
+		"user answers YES": {
+			input:        "YES\n",
+			interactive:  true,
+			expectPrompt: true,
+		},
+		"user answers n": {
+			input:         "n\n",
+			interactive:   true,
+			expectedError: ErrNotConfirmed,
+			expectPrompt:  true,
+		},
+		"user just hits enter": {
+			input:         "\n",
+			interactive:   true,
+			expectedError: ErrNotConfirmed,
+			expectPrompt:  true,
+		},
+		"stdin closed without an answer": {
+			interactive:   true,
+			expectedError: ErrNotConfirmed,
+			expectPrompt:  true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			err := confirmDeletion(strings.NewReader(tc.input), out, tc.interactive, tc.assumeYes, 3)
+			assert.ErrorIs(t, err, tc.expectedError)
+			if tc.expectPrompt {
+				assert.Contains(t, out.String(), "delete 3 snapshot(s)")
+			} else {
+				assert.Empty(t, out.String())
+			}
+		})
+	}
+}
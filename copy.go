@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+)
+
+// SnapshotCopier copies cluster snapshots. *rds.Client implements it.
+type SnapshotCopier interface {
+	CopyDBClusterSnapshot(context.Context, *rds.CopyDBClusterSnapshotInput, ...func(*rds.Options)) (*rds.CopyDBClusterSnapshotOutput, error)
+}
+
+const (
+	ErrNoSnapshotCopier BackupManagerError = "copying snapshots requires a SnapshotCopier"
+	ErrCopiesFailed     BackupManagerError = "failed to copy some snapshots"
+
+	ErrCopyNeedsKMSKey BackupManagerError = "copying an encrypted snapshot to another region needs a KMS key from the destination region"
+	ErrCopyCantEncrypt BackupManagerError = "an unencrypted cluster snapshot can't be encrypted by copying it; restore it to an encrypted cluster and snapshot that instead"
+)
+
+// copySuffix ends the identifier of every copy.
+const copySuffix = "copy"
+
+// KMSKeyError is returned when a copy needs a KMS key the caller isn't
+// allowed to use, which is nearly always down to the key policy.
+type KMSKeyError struct {
+	KmsKeyID           string
+	SnapshotIdentifier string
+	Err                error
+}
+
+func (e *KMSKeyError) Error() string {
+	return fmt.Sprintf("can't use KMS key '%s' to copy snapshot '%s'; check that the key policy grants this account kms:CreateGrant and kms:DescribeKey: %v",
+		e.KmsKeyID, e.SnapshotIdentifier, e.Err)
+}
+
+func (e *KMSKeyError) Unwrap() error {
+	return e.Err
+}
+
+// CopyResult records the outcome of copying a single snapshot.
+type CopyResult struct {
+	SourceSnapshotIdentifier string
+	TargetSnapshotIdentifier string
+	TargetSnapshotArn        string
+	Err                      error
+}
+
+// CopySnapshots copies each of the given snapshots, given as bare
+// identifiers or ARNs, encrypting the copies with kmsKeyID if it's set. With
+// SourceRegion, the snapshots are copied from there into the manager's own
+// region. Like TriggerSnapshots, it stops at the first failure unless
+// ContinueOnError is set, and returns results for every snapshot it got to.
+func (b *BackupManager) CopySnapshots(ctx context.Context, kmsKeyID string, snapshotIdentifiers ...string) ([]CopyResult, error) {
+	if b.cp == nil {
+		return nil, ErrNoSnapshotCopier
+	}
+	if len(snapshotIdentifiers) == 0 {
+		return nil, ErrNoIdentifiersSpecified
+	}
+	if b.SourceRegion != "" {
+		if err := validateRegion(b.SourceRegion); err != nil {
+			return nil, err
+		}
+	}
+
+	results := make([]CopyResult, 0, len(snapshotIdentifiers))
+	failed := 0
+	for _, snapshotID := range snapshotIdentifiers {
+		result := b.copySnapshot(ctx, kmsKeyID, snapshotID)
+		results = append(results, result)
+		if result.Err == nil {
+			continue
+		}
+		if !b.ContinueOnError {
+			return results, result.Err
+		}
+		b.logf("Failed to copy '%s', continuing: %v", snapshotID, result.Err)
+		failed++
+	}
+
+	if failed > 0 {
+		return results, fmt.Errorf("%d of %d snapshots: %w", failed, len(snapshotIdentifiers), ErrCopiesFailed)
+	}
+	return results, nil
+}
+
+// copyIdentifier names the copy of a snapshot. Like a suffix, copySuffix is
+// kept whole, and the source's identifier gives way to it, so a copy never
+// takes the name of its source.
+func (b *BackupManager) copyIdentifier(identifier string) string {
+	return trimSeparator(cutTo(identifier, b.maxIdentifierLen()-len(copySuffix)-1), "-") + "-" + copySuffix
+}
+
+func (b *BackupManager) copySnapshot(ctx context.Context, kmsKeyID, snapshotID string) CopyResult {
+	result := CopyResult{SourceSnapshotIdentifier: snapshotID}
+	identifier, err := parseSnapshotIdentifier(snapshotID)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.TargetSnapshotIdentifier = b.copyIdentifier(identifier)
+
+	source, err := b.copySource(snapshotID)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	if err := b.checkCopyEncryption(ctx, kmsKeyID, identifier); err != nil {
+		result.Err = fmt.Errorf("'%s': %w", snapshotID, err)
+		return result
+	}
+	input := &rds.CopyDBClusterSnapshotInput{
+		SourceDBClusterSnapshotIdentifier: aws.String(source),
+		TargetDBClusterSnapshotIdentifier: aws.String(result.TargetSnapshotIdentifier),
+		CopyTags:                          aws.Bool(true),
+	}
+	if kmsKeyID != "" {
+		input.KmsKeyId = aws.String(kmsKeyID)
+	}
+	// the SDK uses this to presign the request for the source region
+	if b.SourceRegion != "" {
+		input.SourceRegion = aws.String(b.SourceRegion)
+	}
+
+	// a snapshot in another region can't be waited for from here
+	var out *rds.CopyDBClusterSnapshotOutput
+	err = b.whenStable(ctx, identifier, "copy", b.SourceRegion == "", func() error {
+		out, err = b.cp.CopyDBClusterSnapshot(ctx, input)
+		return err
+	})
+	if err != nil {
+		var kmsErr *types.KMSKeyNotAccessibleFault
+		if errors.As(err, &kmsErr) {
+			err = &KMSKeyError{KmsKeyID: kmsKeyID, SnapshotIdentifier: snapshotID, Err: err}
+		}
+		result.Err = err
+		return result
+	}
+
+	if out.DBClusterSnapshot != nil {
+		result.TargetSnapshotArn = aws.ToString(out.DBClusterSnapshot.DBClusterSnapshotArn)
+	}
+	b.logf("Copied snapshot '%s' to '%s'.", result.SourceSnapshotIdentifier, result.TargetSnapshotIdentifier)
+	return result
+}
+
+// copySource works out what to name the source of a copy as. A copy from
+// another region has to name its source by ARN, built from SourceRegion and
+// AccountID if it wasn't given as one.
+func (b *BackupManager) copySource(snapshotID string) (string, error) {
+	if b.SourceRegion == "" || strings.HasPrefix(snapshotID, "arn:") {
+		return snapshotID, nil
+	}
+	return clusterSnapshotARN(b.SourceRegion, b.AccountID, snapshotID)
+}
+
+// checkCopyEncryption catches the combinations of source encryption and KMS
+// key that RDS would turn down, with a clearer error than it gives. An
+// encrypted source keeps its key unless told otherwise, but its key can't
+// leave its region. An unencrypted source can only be copied as it is.
+// Without a describer for the source's region, it's left to RDS.
+func (b *BackupManager) checkCopyEncryption(ctx context.Context, kmsKeyID, snapshotID string) error {
+	sd := b.sourceDescriber()
+	if sd == nil {
+		return nil
+	}
+	out, err := sd.DescribeDBClusterSnapshots(ctx, &rds.DescribeDBClusterSnapshotsInput{
+		DBClusterSnapshotIdentifier: aws.String(snapshotID),
+	})
+	if err != nil {
+		return err
+	}
+	if len(out.DBClusterSnapshots) == 0 {
+		return &types.DBClusterSnapshotNotFoundFault{}
+	}
+
+	encrypted := out.DBClusterSnapshots[0].StorageEncrypted
+	switch {
+	case encrypted && kmsKeyID == "" && b.SourceRegion != "":
+		return ErrCopyNeedsKMSKey
+	case !encrypted && kmsKeyID != "":
+		return ErrCopyCantEncrypt
+	}
+	return nil
+}
+
+// sourceDescriber is what describes snapshots where copies come from: the
+// manager's own describer, or SourceDescriber for copies from another region.
+// It's set after the manager's made, so it's metered for the APICallBudget
+// here rather than along with the rest.
+func (b *BackupManager) sourceDescriber() SnapshotDescriber {
+	if b.SourceRegion != "" {
+		if b.APICallBudget > 0 && b.SourceDescriber != nil {
+			return &meteredRDS{b: b, sd: b.SourceDescriber}
+		}
+		return b.SourceDescriber
+	}
+	return b.sd
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// kmsDeniedCopier refuses to copy one snapshot, the way RDS does when the
+// caller can't use the KMS key.
+type kmsDeniedCopier struct {
+	*fakeSnapshotTaker
+	deniedSnapshotID string
+}
+
+func (f *kmsDeniedCopier) CopyDBClusterSnapshot(ctx context.Context, in *rds.CopyDBClusterSnapshotInput, optFns ...func(*rds.Options)) (*rds.CopyDBClusterSnapshotOutput, error) {
+	if *in.SourceDBClusterSnapshotIdentifier == f.deniedSnapshotID {
+		return nil, &types.KMSKeyNotAccessibleFault{Message: aws.String("The specified KMS key is not accessible")}
+	}
+	return f.fakeSnapshotTaker.CopyDBClusterSnapshot(ctx, in, optFns...)
+}
+
+func encrypted(snapshot types.DBClusterSnapshot) types.DBClusterSnapshot {
+	snapshot.StorageEncrypted = true
+	return snapshot
+}
+
+func TestCopySnapshots(t *testing.T) {
+	st := NewFakeSnapshotTakerWithSnapshots(
+		encrypted(existingSnapshot("my-cluster-1", "testing-my-cluster-1", testNow.Add(-time.Hour))),
+	)
+	bm := NewBackupManager(st)
+
+	results, err := bm.CopySnapshots(context.TODO(), "alias/backups", "testing-my-cluster-1")
+	assert.Nil(t, err)
+	assert.Equal(t, []CopyResult{{
+		SourceSnapshotIdentifier: "testing-my-cluster-1",
+		TargetSnapshotIdentifier: "testing-my-cluster-1-copy",
+		TargetSnapshotArn:        "arn:aws:rds:us-east-1:123456789012:cluster-snapshot:testing-my-cluster-1-copy",
+	}}, results)
+	if assert.Len(t, st.copies, 1) {
+		assert.Equal(t, "alias/backups", aws.ToString(st.copies[0].KmsKeyId))
+		assert.True(t, aws.ToBool(st.copies[0].CopyTags))
+	}
+}
+
+func TestCopySnapshotsLongIdentifier(t *testing.T) {
+	source := "testing-my-cluster-1-" + strings.Repeat("1", 42)
+	st := NewFakeSnapshotTakerWithSnapshots(
+		encrypted(existingSnapshot("my-cluster-1", source, testNow.Add(-time.Hour))),
+	)
+	bm := NewBackupManager(st)
+
+	results, err := bm.CopySnapshots(context.TODO(), "alias/backups", source)
+	assert.Nil(t, err)
+	if assert.Len(t, results, 1) {
+		// the suffix is kept whole, so the copy can't take the source's name
+		assert.Equal(t, source[:58]+"-copy", results[0].TargetSnapshotIdentifier)
+	}
+}
+
+func TestCopySnapshotsKMSKeyNotAccessible(t *testing.T) {
+	type testCase struct {
+		continueOnError bool
+		expectedError   error
+		expectedCopied  []string
+	}
+
+	testCases := map[string]testCase{
+		"stops at the inaccessible key": {
+			expectedCopied: []string{"testing-my-cluster-1-copy"},
+		},
+		"records the failure and keeps going with continue on error": {
+			continueOnError: true,
+			expectedError:   ErrCopiesFailed,
+			expectedCopied:  []string{"testing-my-cluster-1-copy", "testing-my-cluster-3-copy"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			st := &kmsDeniedCopier{
+				fakeSnapshotTaker: NewFakeSnapshotTakerWithSnapshots(
+					encrypted(existingSnapshot("my-cluster-1", "testing-my-cluster-1", testNow)),
+					encrypted(existingSnapshot("my-cluster-2", "testing-my-cluster-2", testNow)),
+					encrypted(existingSnapshot("my-cluster-3", "testing-my-cluster-3", testNow)),
+				),
+				deniedSnapshotID: "testing-my-cluster-2",
+			}
+			bm := NewBackupManager(st, WithContinueOnError(tc.continueOnError))
+
+			results, err := bm.CopySnapshots(context.TODO(), "alias/not-ours", "testing-my-cluster-1", "testing-my-cluster-2", "testing-my-cluster-3")
+
+			var kmsErr *KMSKeyError
+			assert.ErrorAs(t, results[1].Err, &kmsErr)
+			assert.Equal(t, "alias/not-ours", kmsErr.KmsKeyID)
+			assert.Equal(t, "testing-my-cluster-2", kmsErr.SnapshotIdentifier)
+			assert.Contains(t, kmsErr.Error(), "key policy")
+			var faultErr *types.KMSKeyNotAccessibleFault
+			assert.ErrorAs(t, results[1].Err, &faultErr)
+
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+			} else {
+				assert.ErrorAs(t, err, &kmsErr)
+			}
+			copied := make([]string, 0)
+			for _, in := range st.copies {
+				copied = append(copied, *in.TargetDBClusterSnapshotIdentifier)
+			}
+			assert.Equal(t, tc.expectedCopied, copied)
+		})
+	}
+}
+
+func TestCopySnapshotsFromSourceRegion(t *testing.T) {
+	st := NewFakeSnapshotTakerWithSnapshots(
+		existingSnapshot("my-cluster-1", "testing-my-cluster-1", testNow.Add(-time.Hour)),
+		existingSnapshot("my-cluster-2", "testing-my-cluster-2", testNow.Add(-time.Hour)),
+	)
+	// the caller's default region doesn't come into it
+	bm := NewBackupManager(st, WithSourceRegion("us-east-1", "123456789012"))
+
+	results, err := bm.CopySnapshots(context.TODO(), "",
+		"testing-my-cluster-1",
+		"arn:aws:rds:us-east-1:123456789012:cluster-snapshot:testing-my-cluster-2",
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"testing-my-cluster-1-copy", "testing-my-cluster-2-copy"},
+		[]string{results[0].TargetSnapshotIdentifier, results[1].TargetSnapshotIdentifier})
+	if assert.Len(t, st.copies, 2) {
+		assert.Equal(t, "arn:aws:rds:us-east-1:123456789012:cluster-snapshot:testing-my-cluster-1", aws.ToString(st.copies[0].SourceDBClusterSnapshotIdentifier))
+		assert.Equal(t, "arn:aws:rds:us-east-1:123456789012:cluster-snapshot:testing-my-cluster-2", aws.ToString(st.copies[1].SourceDBClusterSnapshotIdentifier))
+		assert.Equal(t, "us-east-1", aws.ToString(st.copies[0].SourceRegion))
+	}
+
+	bm.SourceRegion = "virginia"
+	_, err = bm.CopySnapshots(context.TODO(), "", "testing-my-cluster-1")
+	assert.ErrorIs(t, err, ErrInvalidRegion)
+
+	bm.SourceRegion, bm.AccountID = "us-east-1", ""
+	results, err = bm.CopySnapshots(context.TODO(), "", "testing-my-cluster-1")
+	assert.ErrorIs(t, err, ErrNoAccountID)
+	assert.Len(t, results, 1)
+}
+
+func TestCopySnapshotsEncryption(t *testing.T) {
+	type testCase struct {
+		encrypted     bool
+		sourceRegion  string
+		kmsKeyID      string
+		expectedError error
+	}
+
+	testCases := map[string]testCase{
+		"encrypted keeps its key": {
+			encrypted: true,
+		},
+		"encrypted with a new key": {
+			encrypted: true,
+			kmsKeyID:  "alias/backups",
+		},
+		"encrypted from another region needs a key": {
+			encrypted:     true,
+			sourceRegion:  "us-west-2",
+			expectedError: ErrCopyNeedsKMSKey,
+		},
+		"encrypted from another region with a key": {
+			encrypted:    true,
+			sourceRegion: "us-west-2",
+			kmsKeyID:     "alias/backups",
+		},
+		"unencrypted as it is": {},
+		"unencrypted from another region": {
+			sourceRegion: "us-west-2",
+		},
+		"unencrypted can't be encrypted": {
+			kmsKeyID:      "alias/backups",
+			expectedError: ErrCopyCantEncrypt,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			snapshot := existingSnapshot("my-cluster-1", "testing-my-cluster-1", testNow)
+			snapshot.StorageEncrypted = tc.encrypted
+			snapshot.DBClusterSnapshotArn = aws.String("arn:aws:rds:us-west-2:123456789012:cluster-snapshot:testing-my-cluster-1")
+			st := NewFakeSnapshotTakerWithSnapshots(snapshot)
+			bm := NewBackupManager(st, WithSourceRegion(tc.sourceRegion, "123456789012"))
+			// the same fake stands in for the source region
+			bm.SourceDescriber = st
+
+			_, err := bm.CopySnapshots(context.TODO(), tc.kmsKeyID, "testing-my-cluster-1")
+			assert.ErrorIs(t, err, tc.expectedError)
+			if tc.expectedError != nil {
+				assert.Empty(t, st.copies)
+			} else {
+				assert.Len(t, st.copies, 1)
+			}
+		})
+	}
+}
+
+func TestCopySnapshotsWithoutCopier(t *testing.T) {
+	bm := &BackupManager{st: NewFakeSnapshotTaker()}
+	_, err := bm.CopySnapshots(context.TODO(), "", "testing-my-cluster-1")
+	assert.ErrorIs(t, err, ErrNoSnapshotCopier)
+}
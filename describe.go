@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/aws/smithy-go"
+)
+
+// SnapshotDescriber looks up existing cluster snapshots. Like SnapshotTaker,
+// *rds.Client satisfies it without any extra work.
+type SnapshotDescriber interface {
+	DescribeDBClusterSnapshots(context.Context, *rds.DescribeDBClusterSnapshotsInput, ...func(*rds.Options)) (*rds.DescribeDBClusterSnapshotsOutput, error)
+}
+
+const ErrNoSnapshotDescriber BackupManagerError = "looking up existing snapshots requires a SnapshotDescriber"
+
+// maxListingRestarts bounds how many times a listing starts over after its
+// pagination token goes stale.
+const maxListingRestarts = 3
+
+// describeOwnSnapshots returns the manual snapshots of a cluster that were
+// created by this tool, which we recognize by the read prefixes and tag
+// selector. An empty clusterIdentifier returns snapshots for every cluster.
+func (b *BackupManager) describeOwnSnapshots(ctx context.Context, clusterIdentifier string) ([]types.DBClusterSnapshot, error) {
+	if b.sd == nil {
+		return nil, ErrNoSnapshotDescriber
+	}
+	if b.SelectByTagsOnly && len(b.tagSelector()) == 0 {
+		return nil, ErrEmptyTagSelector
+	}
+
+	input := &rds.DescribeDBClusterSnapshotsInput{
+		SnapshotType: aws.String("manual"),
+	}
+	if clusterIdentifier != "" {
+		input.DBClusterIdentifier = aws.String(clusterIdentifier)
+	}
+
+	// a long listing can outlive its pagination token; when that happens,
+	// start over and keep what we already had
+	var snapshots []types.DBClusterSnapshot
+	for restarts := 0; ; restarts++ {
+		listed, pages, err := b.listOwnSnapshots(ctx, input)
+		snapshots = mergeSnapshots(snapshots, listed)
+		if err == nil {
+			return snapshots, nil
+		}
+		if pages == 0 || !isStaleMarker(err) || restarts == maxListingRestarts {
+			return nil, err
+		}
+		b.logf("Snapshot listing token went stale after %d page(s), starting over: %v", pages, err)
+	}
+}
+
+// listOwnSnapshots makes one pass over the listing, returning what it
+// collected and how many pages it got through, even if it fails partway.
+func (b *BackupManager) listOwnSnapshots(ctx context.Context, input *rds.DescribeDBClusterSnapshotsInput) (snapshots []types.DBClusterSnapshot, pages int, err error) {
+	paginator := rds.NewDescribeDBClusterSnapshotsPaginator(b.sd, input)
+
+	snapshots = make([]types.DBClusterSnapshot, 0)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return snapshots, pages, err
+		}
+		pages++
+		for _, snapshot := range page.DBClusterSnapshots {
+			own, err := b.isOwnSnapshot(ctx, snapshot)
+			if err != nil {
+				return snapshots, pages, err
+			}
+			if own {
+				snapshots = append(snapshots, snapshot)
+			}
+		}
+	}
+	return snapshots, pages, nil
+}
+
+// isStaleMarker reports whether a describe call was turned down over its
+// marker. RDS doesn't have a dedicated fault for it, so it's only a safe
+// guess for calls after the first page, which are the ones with a marker.
+func isStaleMarker(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "InvalidParameterValue"
+}
+
+// mergeSnapshots adds the snapshots in more that aren't in have yet, going by
+// identifier, keeping the order they were listed in.
+func mergeSnapshots(have, more []types.DBClusterSnapshot) []types.DBClusterSnapshot {
+	seen := make(map[string]bool, len(have))
+	for _, snapshot := range have {
+		seen[aws.ToString(snapshot.DBClusterSnapshotIdentifier)] = true
+	}
+	for _, snapshot := range more {
+		id := aws.ToString(snapshot.DBClusterSnapshotIdentifier)
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		have = append(have, snapshot)
+	}
+	return have
+}
+
+// readPrefixes returns the prefixes that identify snapshots from this tool.
+// Unless told otherwise, that's just the prefix we write with, or without
+// one, the default prefixes', plus any that discovered clusters' PrefixTags
+// give them.
+func (b *BackupManager) readPrefixes() []string {
+	prefixes := b.ReadPrefixes
+	if len(prefixes) == 0 && b.prefix == "" {
+		prefixes = []string{defaultReadPrefix}
+	} else if len(prefixes) == 0 {
+		prefixes = []string{b.prefix}
+	}
+	if tagged := b.tagPrefixes(); len(tagged) > 0 {
+		prefixes = append(append([]string{}, prefixes...), tagged...)
+	}
+	return prefixes
+}
+
+// isOwnSnapshot checks a snapshot against the read prefixes, unless
+// SelectByTagsOnly is set, and the tag selector, if there is one.
+func (b *BackupManager) isOwnSnapshot(ctx context.Context, snapshot types.DBClusterSnapshot) (bool, error) {
+	if !b.SelectByTagsOnly && !b.hasReadPrefix(aws.ToString(snapshot.DBClusterSnapshotIdentifier)) {
+		return false, nil
+	}
+	selector := b.tagSelector()
+	if len(selector) == 0 {
+		return true, nil
+	}
+
+	tags, err := b.snapshotTagsFor(ctx, snapshot)
+	if err != nil {
+		return false, err
+	}
+	return matchesTags(tags, selector), nil
+}
+
+// tagSelector returns TagSelector, plus the created-by tag if OnlyCreatedBy
+// is set. A created-by in TagSelector wins.
+func (b *BackupManager) tagSelector() map[string]string {
+	if !b.OnlyCreatedBy {
+		return b.TagSelector
+	}
+	selector := make(map[string]string, len(b.TagSelector)+1)
+	selector[createdByTagKey] = b.createdBy()
+	for key, value := range b.TagSelector {
+		selector[key] = value
+	}
+	return selector
+}
+
+// hasReadPrefix reports whether a snapshot's identifier starts with one of
+// the read prefixes. Without PreserveCase, a prefix matches in any case, just
+// as it's lowercased in new identifiers.
+func (b *BackupManager) hasReadPrefix(snapshotID string) bool {
+	snapshotID = b.normalizeCase(snapshotID)
+	for _, prefix := range b.readPrefixes() {
+		if strings.HasPrefix(snapshotID, b.normalizeCase(prefix+b.separator())) {
+			return true
+		}
+	}
+	return false
+}
+
+// mostRecentSnapshot picks the newest snapshot and reports its age. Snapshots
+// that are still being created may not have a creation time yet; they're as
+// recent as it gets, so they're treated as zero age.
+func mostRecentSnapshot(snapshots []types.DBClusterSnapshot, now time.Time) (newest *types.DBClusterSnapshot, age time.Duration) {
+	for i := range snapshots {
+		snapshotAge := time.Duration(0)
+		if created := snapshotCreatedAt(snapshots[i]); created != nil {
+			snapshotAge = now.Sub(*created)
+		}
+		if newest == nil || snapshotAge < age {
+			newest = &snapshots[i]
+			age = snapshotAge
+		}
+	}
+	return
+}
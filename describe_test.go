@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMostRecentSnapshot(t *testing.T) {
+	type testCase struct {
+		snapshots   []types.DBClusterSnapshot
+		expectedID  string
+		expectedAge time.Duration
+	}
+
+	testCases := map[string]testCase{
+		"no snapshots": {},
+		"picks the newest": {
+			snapshots: []types.DBClusterSnapshot{
+				existingSnapshot("my-cluster-1", "testing-old", testNow.Add(-48*time.Hour)),
+				existingSnapshot("my-cluster-1", "testing-new", testNow.Add(-time.Hour)),
+				existingSnapshot("my-cluster-1", "testing-older", testNow.Add(-72*time.Hour)),
+			},
+			expectedID:  "testing-new",
+			expectedAge: time.Hour,
+		},
+		"in-progress snapshots count as brand new": {
+			snapshots: []types.DBClusterSnapshot{
+				existingSnapshot("my-cluster-1", "testing-old", testNow.Add(-time.Hour)),
+				{DBClusterSnapshotIdentifier: aws.String("testing-creating")},
+			},
+			expectedID: "testing-creating",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			newest, age := mostRecentSnapshot(tc.snapshots, testNow)
+			if tc.expectedID == "" {
+				assert.Nil(t, newest)
+				return
+			}
+			assert.Equal(t, tc.expectedID, aws.ToString(newest.DBClusterSnapshotIdentifier))
+			assert.Equal(t, tc.expectedAge, age)
+		})
+	}
+}
+
+func TestMergeSnapshots(t *testing.T) {
+	type testCase struct {
+		have        []types.DBClusterSnapshot
+		more        []types.DBClusterSnapshot
+		expectedIDs []string
+	}
+
+	testCases := map[string]testCase{
+		"nothing yet": {
+			more: []types.DBClusterSnapshot{
+				existingSnapshot("my-cluster-1", "testing-a", testNow),
+				existingSnapshot("my-cluster-1", "testing-b", testNow),
+			},
+			expectedIDs: []string{"testing-a", "testing-b"},
+		},
+		"drops snapshots already collected": {
+			have: []types.DBClusterSnapshot{
+				existingSnapshot("my-cluster-1", "testing-a", testNow),
+				existingSnapshot("my-cluster-1", "testing-b", testNow),
+			},
+			more: []types.DBClusterSnapshot{
+				existingSnapshot("my-cluster-1", "testing-a", testNow),
+				existingSnapshot("my-cluster-1", "testing-b", testNow),
+				existingSnapshot("my-cluster-1", "testing-c", testNow),
+			},
+			expectedIDs: []string{"testing-a", "testing-b", "testing-c"},
+		},
+		"drops duplicates within a listing": {
+			more: []types.DBClusterSnapshot{
+				existingSnapshot("my-cluster-1", "testing-a", testNow),
+				existingSnapshot("my-cluster-1", "testing-a", testNow),
+			},
+			expectedIDs: []string{"testing-a"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedIDs, snapshotIDs(mergeSnapshots(tc.have, tc.more)))
+		})
+	}
+}
+
+// pagedSnapshotDescriber serves snapshots one per page, turning down the
+// marker for page staleAt the first staleTimes times it's asked for.
+type pagedSnapshotDescriber struct {
+	snapshots  []types.DBClusterSnapshot
+	staleAt    int
+	staleTimes int
+	calls      int
+}
+
+func (p *pagedSnapshotDescriber) DescribeDBClusterSnapshots(ctx context.Context, in *rds.DescribeDBClusterSnapshotsInput, optFns ...func(*rds.Options)) (*rds.DescribeDBClusterSnapshotsOutput, error) {
+	p.calls++
+	page := 0
+	if in.Marker != nil {
+		page, _ = strconv.Atoi(*in.Marker)
+	}
+	if page == p.staleAt && p.staleTimes > 0 {
+		p.staleTimes--
+		return nil, &smithy.GenericAPIError{Code: "InvalidParameterValue", Message: "invalid marker"}
+	}
+
+	out := &rds.DescribeDBClusterSnapshotsOutput{
+		DBClusterSnapshots: p.snapshots[page : page+1],
+	}
+	if page+1 < len(p.snapshots) {
+		out.Marker = aws.String(strconv.Itoa(page + 1))
+	}
+	return out, nil
+}
+
+func TestListSnapshotsStaleMarker(t *testing.T) {
+	type testCase struct {
+		staleAt       int
+		staleTimes    int
+		expectedError bool
+		expectedCalls int
+	}
+
+	testCases := map[string]testCase{
+		"starts over and keeps going": {
+			staleAt:       2,
+			staleTimes:    1,
+			expectedCalls: 6,
+		},
+		"gives up after enough restarts": {
+			staleAt:       1,
+			staleTimes:    maxListingRestarts + 1,
+			expectedError: true,
+			expectedCalls: 2 * (maxListingRestarts + 1),
+		},
+		"the first page isn't a stale marker": {
+			staleAt:       0,
+			staleTimes:    1,
+			expectedError: true,
+			expectedCalls: 1,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			sd := &pagedSnapshotDescriber{
+				snapshots: []types.DBClusterSnapshot{
+					existingSnapshot("my-cluster-1", "testing-a", testNow),
+					existingSnapshot("my-cluster-1", "testing-b", testNow),
+					existingSnapshot("my-cluster-1", "testing-c", testNow),
+				},
+				staleAt:    tc.staleAt,
+				staleTimes: tc.staleTimes,
+			}
+			bm := &BackupManager{sd: sd, prefix: "testing"}
+
+			snapshots, err := bm.ListSnapshots(context.TODO())
+			assert.Equal(t, tc.expectedCalls, sd.calls)
+			if tc.expectedError {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, []string{"testing-a", "testing-b", "testing-c"}, snapshotIDs(snapshots))
+		})
+	}
+}
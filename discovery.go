@@ -0,0 +1,351 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+)
+
+// ClusterDescriber looks up clusters. *rds.Client implements it.
+type ClusterDescriber interface {
+	DescribeDBClusters(context.Context, *rds.DescribeDBClustersInput, ...func(*rds.Options)) (*rds.DescribeDBClustersOutput, error)
+}
+
+// GlobalClusterDescriber looks up global databases. *rds.Client implements it.
+type GlobalClusterDescriber interface {
+	DescribeGlobalClusters(context.Context, *rds.DescribeGlobalClustersInput, ...func(*rds.Options)) (*rds.DescribeGlobalClustersOutput, error)
+}
+
+const (
+	ErrNoClusterDescriber BackupManagerError = "discovering clusters requires a ClusterDescriber"
+
+	// ErrNoClustersDiscovered is discovery coming up empty with no clusters
+	// named either, which is down to the filters rather than a mistake like
+	// ErrNoIdentifiersSpecified.
+	ErrNoClustersDiscovered BackupManagerError = "discovery found no clusters matching the filters"
+)
+
+// ClusterInfo is what discovery learned about a cluster that affects how we
+// treat it and its snapshots.
+type ClusterInfo struct {
+	DeletionProtection      bool
+	GlobalClusterIdentifier string
+
+	// Prefix is the value of the cluster's PrefixTag, if it has one.
+	Prefix string
+
+	// AllocatedStorage is the cluster's storage in GiB, for WeightBudget.
+	AllocatedStorage int32
+
+	// OptedOut is whether the cluster has the opt-out tag. Discovery leaves
+	// those clusters out, so it's only ever set by a describe.
+	OptedOut bool
+
+	// Stopped is whether the cluster was stopped when it was looked up.
+	Stopped bool
+}
+
+// DiscoverClusters returns every cluster visible to the manager, except
+// those running an engine older than MinEngineVersions allows, those opted
+// out with the opt-out tag, those whose tags don't match ClusterTags, with
+// AvoidMaintenance, those in their maintenance window and, with
+// SinceLastRun, those that haven't changed since the last complete run.
+// Each one is annotated so that later snapshots and deletions know about
+// it. Clusters come back sorted by identifier, whatever order the pages
+// came in, so that anything that only gets through some of them gets
+// through the same ones each run.
+func (b *BackupManager) DiscoverClusters(ctx context.Context) ([]types.DBCluster, error) {
+	if b.cd == nil {
+		return nil, ErrNoClusterDescriber
+	}
+
+	var lastRun time.Time
+	sinceLastRun := false
+	if b.SinceLastRun {
+		b.discoveredAt = b.clock()
+		var err error
+		if lastRun, sinceLastRun, err = b.loadLastRun(); err != nil {
+			return nil, err
+		}
+	}
+
+	globalClusters, err := b.describeGlobalMemberships(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	clusters := make([]types.DBCluster, 0)
+	paginator := rds.NewDescribeDBClustersPaginator(b.cd, &rds.DescribeDBClustersInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, cluster := range page.DBClusters {
+			if ok, minimum := b.meetsMinEngineVersion(cluster); !ok {
+				b.logf("Not backing up '%s', %s %s is older than the minimum of %s.",
+					aws.ToString(cluster.DBClusterIdentifier), aws.ToString(cluster.Engine), aws.ToString(cluster.EngineVersion), minimum)
+				continue
+			}
+			if sinceLastRun && cluster.LatestRestorableTime != nil && !cluster.LatestRestorableTime.After(lastRun) {
+				b.logf("Not backing up '%s', it doesn't look to have changed since the last run at %s.",
+					aws.ToString(cluster.DBClusterIdentifier), lastRun.Format(time.RFC3339))
+				continue
+			}
+			if b.optedOut(cluster.TagList) {
+				b.logf("Not backing up '%s', it's opted out with %s=%s.", aws.ToString(cluster.DBClusterIdentifier), b.OptOutTagKey, b.OptOutTagValue)
+				continue
+			}
+			if b.ClusterTags != nil && !b.ClusterTags.Match(tagsToMap(cluster.TagList)) {
+				b.logf("Not backing up '%s', its tags don't match %s.", aws.ToString(cluster.DBClusterIdentifier), b.ClusterTags)
+				continue
+			}
+			if b.AvoidMaintenance && b.inMaintenance(cluster) {
+				b.logf("Not backing up '%s', it's in its maintenance window (%s).",
+					aws.ToString(cluster.DBClusterIdentifier), aws.ToString(cluster.PreferredMaintenanceWindow))
+				continue
+			}
+			clusters = append(clusters, cluster)
+		}
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		return aws.ToString(clusters[i].DBClusterIdentifier) < aws.ToString(clusters[j].DBClusterIdentifier)
+	})
+
+	for _, cluster := range clusters {
+		info := ClusterInfo{
+			DeletionProtection:      aws.ToBool(cluster.DeletionProtection),
+			GlobalClusterIdentifier: globalClusters[aws.ToString(cluster.DBClusterArn)],
+			AllocatedStorage:        aws.ToInt32(cluster.AllocatedStorage),
+			Stopped:                 aws.ToString(cluster.Status) == clusterStatusStopped,
+		}
+		if b.PrefixTag != "" {
+			info.Prefix = tagsToMap(cluster.TagList)[b.PrefixTag]
+		}
+		b.annotateCluster(aws.ToString(cluster.DBClusterIdentifier), info)
+		b.debugf("Discovered cluster '%s' (deletion protection: %t, global cluster: '%s').",
+			aws.ToString(cluster.DBClusterIdentifier), info.DeletionProtection, info.GlobalClusterIdentifier)
+	}
+	return clusters, nil
+}
+
+// describeGlobalMemberships maps member cluster ARNs to the global database
+// they belong to. Without a GlobalClusterDescriber the map is empty.
+func (b *BackupManager) describeGlobalMemberships(ctx context.Context) (map[string]string, error) {
+	memberships := make(map[string]string)
+	if b.gcd == nil {
+		return memberships, nil
+	}
+
+	paginator := rds.NewDescribeGlobalClustersPaginator(b.gcd, &rds.DescribeGlobalClustersInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, global := range page.GlobalClusters {
+			for _, member := range global.GlobalClusterMembers {
+				memberships[aws.ToString(member.DBClusterArn)] = aws.ToString(global.GlobalClusterIdentifier)
+			}
+		}
+	}
+	return memberships, nil
+}
+
+// precheckClusters finds which of the clusters about to be snapshotted don't
+// exist, with a describe call per hundred clusters, and logs them all in one
+// line. It notes which are stopped while it's at it. It does nothing unless
+// PrecheckClusters is set.
+func (b *BackupManager) precheckClusters(ctx context.Context, clusterIDs []string) error {
+	b.missing = nil
+	b.stopped = nil
+	if !b.PrecheckClusters || len(clusterIDs) == 0 {
+		return nil
+	}
+	if b.cd == nil {
+		return ErrNoClusterDescriber
+	}
+
+	identifiers := make([]string, 0, len(clusterIDs))
+	for _, clusterID := range clusterIDs {
+		// a bad ARN fails when it's snapshotted, with a better error
+		if identifier, err := parseClusterIdentifier(clusterID); err == nil {
+			identifiers = append(identifiers, identifier)
+		}
+	}
+
+	existing := make(map[string]bool, len(identifiers))
+	for start := 0; start < len(identifiers); start += maxFilterValues {
+		end := start + maxFilterValues
+		if end > len(identifiers) {
+			end = len(identifiers)
+		}
+		paginator := rds.NewDescribeDBClustersPaginator(b.cd, &rds.DescribeDBClustersInput{
+			Filters: []types.Filter{{Name: aws.String("db-cluster-id"), Values: identifiers[start:end]}},
+		})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return fmt.Errorf("checking clusters exist: %w", err)
+			}
+			for _, cluster := range page.DBClusters {
+				existing[aws.ToString(cluster.DBClusterIdentifier)] = true
+				if aws.ToString(cluster.Status) == clusterStatusStopped {
+					if b.stopped == nil {
+						b.stopped = make(map[string]bool)
+					}
+					b.stopped[aws.ToString(cluster.DBClusterIdentifier)] = true
+				}
+			}
+		}
+	}
+
+	missing := make([]string, 0)
+	for _, identifier := range identifiers {
+		if !existing[identifier] {
+			missing = append(missing, identifier)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	b.missing = make(map[string]bool, len(missing))
+	for _, identifier := range missing {
+		b.missing[identifier] = true
+	}
+	b.logf("Not backing up %d cluster(s) that don't exist: %s.", len(missing), strings.Join(missing, ", "))
+	return nil
+}
+
+func (b *BackupManager) annotateCluster(clusterIdentifier string, info ClusterInfo) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.clusters == nil {
+		b.clusters = make(map[string]ClusterInfo)
+	}
+	b.clusters[clusterIdentifier] = info
+}
+
+func (b *BackupManager) annotation(clusterIdentifier string) (ClusterInfo, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	info, ok := b.clusters[clusterIdentifier]
+	return info, ok
+}
+
+// prefixFor returns the prefix for a cluster's snapshots: the value of its
+// PrefixTag if discovery found one, followed by when the run started, so runs
+// don't collide on the same names; otherwise the prefix. A prefix from a tag
+// has to be fit for an identifier; with SanitizeName, it's made fit first.
+func (b *BackupManager) prefixFor(clusterIdentifier string) (string, error) {
+	info, ok := b.annotation(clusterIdentifier)
+	if !ok || info.Prefix == "" {
+		return b.writePrefix(), nil
+	}
+	prefix := b.tagPrefix(info)
+	if err := validatePrefix(prefix); err != nil {
+		return "", fmt.Errorf("the %s tag of '%s': %w", b.PrefixTag, clusterIdentifier, err)
+	}
+	return fmt.Sprintf("%s%s%d", prefix, b.separator(), b.startedAt.Unix()), nil
+}
+
+// tagPrefix is the prefix a cluster's PrefixTag gives it, without the time.
+func (b *BackupManager) tagPrefix(info ClusterInfo) string {
+	if b.SanitizeName {
+		return sanitizeIdentifier(info.Prefix)
+	}
+	return info.Prefix
+}
+
+// tagPrefixes returns the prefixes discovered clusters' PrefixTags give them,
+// which are read back along with the read prefixes.
+func (b *BackupManager) tagPrefixes() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	seen := make(map[string]bool)
+	prefixes := make([]string, 0)
+	for _, info := range b.clusters {
+		if info.Prefix == "" {
+			continue
+		}
+		prefix := b.tagPrefix(info)
+		if !seen[prefix] {
+			seen[prefix] = true
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	sort.Strings(prefixes)
+	return prefixes
+}
+
+// clusterInfo returns what we know about a cluster, describing it if it
+// wasn't discovered. A cluster that no longer exists has no protections.
+func (b *BackupManager) clusterInfo(ctx context.Context, clusterIdentifier string) (ClusterInfo, error) {
+	if info, ok := b.annotation(clusterIdentifier); ok {
+		return info, nil
+	}
+	if b.cd == nil {
+		return ClusterInfo{}, ErrNoClusterDescriber
+	}
+
+	out, err := b.cd.DescribeDBClusters(ctx, &rds.DescribeDBClustersInput{
+		DBClusterIdentifier: aws.String(clusterIdentifier),
+	})
+	var cnfErr *types.DBClusterNotFoundFault
+	if errors.As(err, &cnfErr) {
+		return ClusterInfo{}, nil
+	}
+	if err != nil {
+		return ClusterInfo{}, err
+	}
+
+	info := ClusterInfo{}
+	if len(out.DBClusters) > 0 {
+		info.DeletionProtection = aws.ToBool(out.DBClusters[0].DeletionProtection)
+		info.OptedOut = b.optedOut(out.DBClusters[0].TagList)
+		info.AllocatedStorage = aws.ToInt32(out.DBClusters[0].AllocatedStorage)
+		info.Stopped = aws.ToString(out.DBClusters[0].Status) == clusterStatusStopped
+	}
+	b.annotateCluster(clusterIdentifier, info)
+	return info, nil
+}
+
+// optedOut reports whether a cluster's tags opt it out of backups.
+func (b *BackupManager) optedOut(tags []types.Tag) bool {
+	if b.OptOutTagKey == "" {
+		return false
+	}
+	value, ok := tagsToMap(tags)[b.OptOutTagKey]
+	return ok && strings.EqualFold(value, b.OptOutTagValue)
+}
+
+// inMaintenance reports whether a cluster is in its maintenance window right
+// now. If we can't make sense of the window, the cluster is given the benefit
+// of the doubt.
+func (b *BackupManager) inMaintenance(cluster types.DBCluster) bool {
+	window := aws.ToString(cluster.PreferredMaintenanceWindow)
+	if window == "" {
+		return false
+	}
+	in, err := inMaintenanceWindow(window, b.clock())
+	if err != nil {
+		b.logf("Can't tell if '%s' is in maintenance: %v", aws.ToString(cluster.DBClusterIdentifier), err)
+		return false
+	}
+	return in
+}
+
+func clusterIdentifiers(clusters []types.DBCluster) []string {
+	ids := make([]string, 0, len(clusters))
+	for _, cluster := range clusters {
+		ids = append(ids, aws.ToString(cluster.DBClusterIdentifier))
+	}
+	return ids
+}
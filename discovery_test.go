@@ -0,0 +1,346 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func protectedCluster(clusterID string) types.DBCluster {
+	cluster := existingCluster(clusterID)
+	cluster.DeletionProtection = aws.Bool(true)
+	return cluster
+}
+
+func TestDiscoverClusters(t *testing.T) {
+	st := NewFakeSnapshotTaker()
+	st.clusters = []types.DBCluster{
+		existingCluster("my-cluster-1"),
+		protectedCluster("my-cluster-2"),
+		existingCluster("my-cluster-3"),
+	}
+	st.globalClusters = []types.GlobalCluster{
+		{
+			GlobalClusterIdentifier: aws.String("my-global-1"),
+			GlobalClusterMembers: []types.GlobalClusterMember{
+				{DBClusterArn: st.clusters[2].DBClusterArn, IsWriter: true},
+			},
+		},
+	}
+	bm := NewBackupManager(st, WithPrefix("testing"))
+	bm.now = func() time.Time { return testNow }
+
+	clusters, err := bm.DiscoverClusters(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"my-cluster-1", "my-cluster-2", "my-cluster-3"}, clusterIdentifiers(clusters))
+
+	results, err := bm.TriggerSnapshots(context.TODO(), clusterIdentifiers(clusters)...)
+	assert.Nil(t, err)
+	assert.Equal(t, []SnapshotResult{
+		{ClusterIdentifier: "my-cluster-1", SnapshotIdentifier: "testing-my-cluster-1", Status: StatusCreated},
+		{ClusterIdentifier: "my-cluster-2", SnapshotIdentifier: "testing-my-cluster-2", Status: StatusCreated, DeletionProtection: true},
+		{ClusterIdentifier: "my-cluster-3", SnapshotIdentifier: "testing-my-cluster-3", Status: StatusCreated, GlobalClusterIdentifier: "my-global-1"},
+	}, results)
+}
+
+func TestDiscoverClustersOrder(t *testing.T) {
+	expected := []string{"alpha", "my-cluster-1", "my-cluster-10", "my-cluster-2", "zulu"}
+	rng := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 5; i++ {
+		st := NewFakeSnapshotTaker()
+		for _, j := range rng.Perm(len(expected)) {
+			st.clusters = append(st.clusters, existingCluster(expected[j]))
+		}
+		bm := NewBackupManager(st)
+
+		clusters, err := bm.DiscoverClusters(context.TODO())
+		assert.Nil(t, err)
+		assert.Equal(t, expected, clusterIdentifiers(clusters), "given %v", clusterIdentifiers(st.clusters))
+	}
+}
+
+func TestDiscoverClustersByTags(t *testing.T) {
+	st := NewFakeSnapshotTaker()
+	st.clusters = []types.DBCluster{
+		existingCluster("payments-prod"),
+		existingCluster("payments-scratch"),
+		existingCluster("search-prod"),
+		existingCluster("untagged"),
+	}
+	st.clusters[0].TagList = []types.Tag{{Key: aws.String("env"), Value: aws.String("prod")}, {Key: aws.String("team"), Value: aws.String("payments")}}
+	st.clusters[1].TagList = append(st.clusters[0].TagList, types.Tag{Key: aws.String("temporary"), Value: aws.String("true")})
+	st.clusters[2].TagList = []types.Tag{{Key: aws.String("env"), Value: aws.String("prod")}, {Key: aws.String("team"), Value: aws.String("search")}}
+
+	expr, err := ParseTagExpr("env=prod AND team=payments AND NOT temporary=true")
+	assert.Nil(t, err)
+	bm := NewBackupManager(st, WithClusterTags(expr))
+
+	clusters, err := bm.DiscoverClusters(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"payments-prod"}, clusterIdentifiers(clusters))
+}
+
+func TestOptOutTag(t *testing.T) {
+	st := NewFakeSnapshotTaker()
+	st.clusters = []types.DBCluster{
+		existingCluster("payments"),
+		existingCluster("scratch"),
+		existingCluster("search"),
+	}
+	st.clusters[1].TagList = []types.Tag{{Key: aws.String("backup"), Value: aws.String("False")}}
+	st.clusters[2].TagList = []types.Tag{{Key: aws.String("backup"), Value: aws.String("true")}}
+
+	bm := NewBackupManager(st, WithPrefix("testing"), WithOptOutTag("backup", "false", false))
+	bm.now = func() time.Time { return testNow }
+	clusters, err := bm.DiscoverClusters(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"payments", "search"}, clusterIdentifiers(clusters))
+
+	// named clusters only get looked up when asked to
+	results, err := bm.TriggerSnapshots(context.TODO(), "scratch")
+	assert.Nil(t, err)
+	assert.Equal(t, StatusCreated, results[0].Status)
+
+	bm = NewBackupManager(st, WithPrefix("testing"), WithOptOutTag("backup", "false", true))
+	bm.now = func() time.Time { return testNow }
+	results, err = bm.TriggerSnapshots(context.TODO(), "payments", "scratch")
+	assert.Nil(t, err)
+	assert.Equal(t, StatusCreated, results[0].Status)
+	assert.Equal(t, SnapshotResult{ClusterIdentifier: "scratch", SnapshotIdentifier: "testing-scratch", Status: StatusSkippedOptOut}, results[1])
+	assert.Equal(t, RunStats{Created: 1, Skipped: 1}, bm.Stats())
+}
+
+func TestPrefixTag(t *testing.T) {
+	env := func(cluster types.DBCluster, value string) types.DBCluster {
+		cluster.TagList = []types.Tag{{Key: aws.String("env"), Value: aws.String(value)}}
+		return cluster
+	}
+	st := NewFakeSnapshotTaker()
+	st.clusters = []types.DBCluster{
+		env(existingCluster("payments"), "prod"),
+		env(existingCluster("search"), "staging"),
+		existingCluster("untagged"),
+		env(existingCluster("scratch"), "Dev Sandbox"),
+		env(existingCluster("a-cluster-whose-name-goes-on-and-on-for-far-too-long"), "production-eu-west"),
+	}
+	bm := NewBackupManager(st, WithPrefix("testing"), WithPrefixTag("env"))
+	bm.now = func() time.Time { return testNow }
+
+	clusters, err := bm.DiscoverClusters(context.TODO())
+	assert.Nil(t, err)
+	bm.ContinueOnError = true
+	results, err := bm.TriggerSnapshots(context.TODO(), clusterIdentifiers(clusters)...)
+	assert.ErrorIs(t, err, ErrSnapshotsFailed)
+	// discovery sorts them by identifier
+	assert.Equal(t, []string{
+		"production-eu-west-1647345600-a-cluster-whose-name-goes-on-and",
+		"prod-1647345600-payments",
+		"",
+		"staging-1647345600-search",
+		"testing-untagged",
+	}, []string{
+		results[0].SnapshotIdentifier,
+		results[1].SnapshotIdentifier,
+		results[2].SnapshotIdentifier,
+		results[3].SnapshotIdentifier,
+		results[4].SnapshotIdentifier,
+	})
+	assert.ErrorIs(t, results[2].Err, ErrInvalidPrefix)
+
+	// sanitizing fixes up the tag's value too
+	bm.SanitizeName = true
+	result, err := bm.TriggerSnapshot(context.TODO(), "scratch")
+	assert.Nil(t, err)
+	assert.Equal(t, "dev-sandbox-1647345600-scratch", result.SnapshotIdentifier)
+}
+
+func TestPrefixTagNextRun(t *testing.T) {
+	st := &uniqueSnapshotTaker{NewFakeSnapshotTaker()}
+	st.clusters = []types.DBCluster{existingCluster("payments")}
+	st.clusters[0].TagList = []types.Tag{{Key: aws.String("env"), Value: aws.String("prod")}}
+	run := func(now time.Time) *BackupManager {
+		bm := NewBackupManager(st, WithPrefix("testing"), WithPrefixTag("env"))
+		bm.now = func() time.Time { return now }
+		clusters, err := bm.DiscoverClusters(context.TODO())
+		assert.Nil(t, err)
+		_, err = bm.TriggerSnapshots(context.TODO(), clusterIdentifiers(clusters)...)
+		assert.Nil(t, err)
+		return bm
+	}
+
+	run(testNow.Add(-48 * time.Hour))
+	// the fake doesn't list what it creates, so put the first run's snapshot
+	// where the next run can find it
+	first := st.GetJournal()[0].DBClusterSnapshotIdentifier
+	st.snapshots = []types.DBClusterSnapshot{existingSnapshot("payments", first, testNow.Add(-48*time.Hour))}
+
+	bm := run(testNow)
+	journal := st.GetJournal()
+	assert.Len(t, journal, 2)
+	assert.NotEqual(t, journal[0].DBClusterSnapshotIdentifier, journal[1].DBClusterSnapshotIdentifier)
+
+	candidates, err := bm.PruneCandidates(context.TODO(), 24*time.Hour)
+	assert.Nil(t, err)
+	assert.Equal(t, st.snapshots, candidates)
+}
+
+func TestPrintClusters(t *testing.T) {
+	st := NewFakeSnapshotTaker()
+	st.clusters = []types.DBCluster{existingCluster("my-cluster-1"), protectedCluster("my-cluster-2")}
+	st.clusters[0].Engine, st.clusters[0].EngineVersion = aws.String("aurora-postgresql"), aws.String("13.7")
+	st.clusters[1].Engine, st.clusters[1].EngineVersion = aws.String("aurora-mysql"), aws.String("8.0.mysql_aurora.3.02.0")
+	st.globalClusters = []types.GlobalCluster{
+		{
+			GlobalClusterIdentifier: aws.String("my-global-1"),
+			GlobalClusterMembers:    []types.GlobalClusterMember{{DBClusterArn: st.clusters[1].DBClusterArn}},
+		},
+	}
+	bm := NewBackupManager(st)
+
+	clusters, err := bm.DiscoverClusters(context.TODO())
+	assert.Nil(t, err)
+	var buf bytes.Buffer
+	printClusters(&buf, bm, clusters)
+	assert.Equal(t, ""+
+		"CLUSTER       ENGINE             VERSION                  STATUS     PROTECTED  GLOBAL\n"+
+		"my-cluster-1  aurora-postgresql  13.7                     available  false      -\n"+
+		"my-cluster-2  aurora-mysql       8.0.mysql_aurora.3.02.0  available  true       my-global-1\n",
+		buf.String())
+}
+
+func TestDiscoverClustersWithoutDescriber(t *testing.T) {
+	bm := &BackupManager{st: NewFakeSnapshotTaker()}
+	_, err := bm.DiscoverClusters(context.TODO())
+	assert.ErrorIs(t, err, ErrNoClusterDescriber)
+}
+
+// countingClusterDescriber counts describe calls on top of the fake.
+type countingClusterDescriber struct {
+	*fakeSnapshotTaker
+	describes int
+}
+
+func (c *countingClusterDescriber) DescribeDBClusters(ctx context.Context, in *rds.DescribeDBClustersInput, optFns ...func(*rds.Options)) (*rds.DescribeDBClustersOutput, error) {
+	c.describes++
+	return c.fakeSnapshotTaker.DescribeDBClusters(ctx, in, optFns...)
+}
+
+func TestTriggerSnapshotsPrecheck(t *testing.T) {
+	st := &countingClusterDescriber{fakeSnapshotTaker: NewFakeSnapshotTaker()}
+	st.clusters = []types.DBCluster{existingCluster("my-cluster-1"), existingCluster("my-cluster-3")}
+	bm := NewBackupManager(st, WithPrefix("testing"), WithPrecheckClusters(true))
+	bm.now = func() time.Time { return testNow }
+
+	results, err := bm.TriggerSnapshots(context.TODO(), "my-cluster-1", "my-cluster-2", "arn:aws:rds:us-east-1:123456789012:cluster:my-cluster-3", "my-cluster-4")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, st.describes)
+	assert.Equal(t, []SnapshotResult{
+		{ClusterIdentifier: "my-cluster-1", SnapshotIdentifier: "testing-my-cluster-1", Status: StatusCreated},
+		{ClusterIdentifier: "my-cluster-2", SnapshotIdentifier: "testing-my-cluster-2", Status: StatusSkippedNotFound},
+		{ClusterIdentifier: "my-cluster-3", SnapshotIdentifier: "testing-my-cluster-3", Status: StatusCreated},
+		{ClusterIdentifier: "my-cluster-4", SnapshotIdentifier: "testing-my-cluster-4", Status: StatusSkippedNotFound},
+	}, results)
+	// the missing clusters were never tried
+	assert.Equal(t, []snapshotCreationRecord{
+		{"my-cluster-1", "testing-my-cluster-1"},
+		{"my-cluster-3", "testing-my-cluster-3"},
+	}, st.GetJournal())
+
+	// every run checks afresh
+	st.clusters = append(st.clusters, existingCluster("my-cluster-2"))
+	results, err = bm.TriggerSnapshots(context.TODO(), "my-cluster-2")
+	assert.Nil(t, err)
+	assert.Equal(t, StatusCreated, results[0].Status)
+}
+
+func TestTriggerSnapshotsPrecheckWithoutDescriber(t *testing.T) {
+	bm := &BackupManager{st: NewFakeSnapshotTaker(), prefix: "testing", PrecheckClusters: true}
+	_, err := bm.TriggerSnapshots(context.TODO(), "my-cluster-1")
+	assert.ErrorIs(t, err, ErrNoClusterDescriber)
+}
+
+func TestDeleteSnapshotsDeletionProtection(t *testing.T) {
+	type testCase struct {
+		force             bool
+		expectedError     error
+		expectedRemaining []string
+	}
+
+	testCases := map[string]testCase{
+		"refuses to delete anything without force": {
+			expectedError:     ErrDeletionProtected,
+			expectedRemaining: []string{"testing-my-cluster-1", "testing-my-cluster-2", "testing-gone-cluster"},
+		},
+		"deletes with force": {
+			force:             true,
+			expectedRemaining: []string{},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			st := NewFakeSnapshotTakerWithSnapshots(
+				existingSnapshot("my-cluster-1", "testing-my-cluster-1", testNow.Add(-48*time.Hour)),
+				existingSnapshot("my-cluster-2", "testing-my-cluster-2", testNow.Add(-48*time.Hour)),
+				existingSnapshot("gone-cluster", "testing-gone-cluster", testNow.Add(-48*time.Hour)),
+			)
+			st.clusters = []types.DBCluster{existingCluster("my-cluster-1"), protectedCluster("my-cluster-2")}
+			bm := NewBackupManager(st, WithPrefix("testing"), WithForce(tc.force))
+			bm.now = func() time.Time { return testNow }
+
+			_, err := bm.PruneSnapshots(context.TODO(), time.Hour)
+			assert.ErrorIs(t, err, tc.expectedError)
+			assert.Equal(t, tc.expectedRemaining, snapshotIDs(st.snapshots))
+		})
+	}
+}
+
+func TestRunBackupNothingDiscovered(t *testing.T) {
+	type testCase struct {
+		clusterIDs    []string
+		discover      bool
+		expectedErr   error
+		expectedCount int
+	}
+
+	testCases := map[string]testCase{
+		"discovery found nothing": {
+			discover:    true,
+			expectedErr: ErrNoClustersDiscovered,
+		},
+		"named clusters go ahead anyway": {
+			clusterIDs:    []string{"my-cluster-1"},
+			discover:      true,
+			expectedCount: 1,
+		},
+		"nothing named or discovered": {
+			expectedErr: ErrNoIdentifiersSpecified,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			st := NewFakeSnapshotTaker()
+			st.clusters = []types.DBCluster{existingCluster("scratch")}
+			st.clusters[0].TagList = []types.Tag{{Key: aws.String("backup"), Value: aws.String("false")}}
+			bm := NewBackupManager(st, WithPrefix("testing"), WithOptOutTag("backup", "false", false))
+
+			results, err := runBackup(context.TODO(), bm, tc.clusterIDs, tc.discover, nil)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+			} else {
+				assert.Nil(t, err)
+			}
+			assert.Len(t, results, tc.expectedCount)
+			assert.Len(t, st.GetJournal(), tc.expectedCount)
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+const ErrDryRunInstances BackupManagerError = "dry runs don't cover instance snapshots"
+
+// planSnapshot stands in for creating a snapshot in a dry run. With
+// CompareExisting, the result says what the cluster's newest snapshot from
+// this tool is, so it's clear what the run would add to.
+func (b *BackupManager) planSnapshot(ctx context.Context, result SnapshotResult) SnapshotResult {
+	b.debugf("Would create snapshot '%s' of '%s'.", result.SnapshotIdentifier, result.ClusterIdentifier)
+	result.Status = StatusPlanned
+	if !b.CompareExisting {
+		return result
+	}
+
+	snapshots, err := b.describeOwnSnapshots(ctx, result.ClusterIdentifier)
+	if err != nil {
+		result.Status = StatusFailed
+		result.Err = err
+		return result
+	}
+	if newest, age := mostRecentSnapshot(snapshots, b.clock()); newest != nil {
+		result.ExistingSnapshotIdentifier = aws.ToString(newest.DBClusterSnapshotIdentifier)
+		result.ExistingSnapshotAge = age
+	}
+	return result
+}
+
+// PlanFormatter lays out a dry run as a table: what would happen to each
+// cluster, under what name, and the newest snapshot it already has, if the
+// run looked.
+type PlanFormatter struct{}
+
+func (f PlanFormatter) Format(w io.Writer, results []SnapshotResult) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "CLUSTER\tPLAN\tSNAPSHOT\tEXISTING\tAGE")
+	for _, result := range results {
+		plan := string(result.Status)
+		if result.Status == StatusPlanned {
+			plan = "create"
+		}
+		snapshot, existing, age := "-", "-", "-"
+		if result.SnapshotIdentifier != "" {
+			snapshot = result.SnapshotIdentifier
+		}
+		if result.ExistingSnapshotIdentifier != "" {
+			existing = result.ExistingSnapshotIdentifier
+			age = result.ExistingSnapshotAge.Round(time.Minute).String()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", qualifiedCluster(result), plan, snapshot, existing, age)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDryRun(t *testing.T) {
+	st := NewFakeSnapshotTaker()
+	st.snapshots = []types.DBClusterSnapshot{
+		existingSnapshot("my-cluster-1", "testing-my-cluster-1-old", testNow.Add(-30*time.Minute)),
+		existingSnapshot("my-cluster-2", "testing-my-cluster-2-old", testNow.Add(-50*time.Hour)),
+		existingSnapshot("my-cluster-2", "testing-my-cluster-2-older", testNow.Add(-80*time.Hour)),
+		existingSnapshot("my-cluster-3", "someone-elses", testNow.Add(-time.Hour)),
+	}
+	bm := NewBackupManager(st,
+		WithPrefix("testing"),
+		WithSkipIfRecentWithin(time.Hour),
+		WithDryRun(true, true),
+	)
+	bm.now = func() time.Time { return testNow }
+
+	results, err := bm.TriggerSnapshots(context.TODO(), "my-cluster-1", "my-cluster-2", "my-cluster-3")
+	assert.Nil(t, err)
+	assert.Empty(t, st.journal)
+	assert.Equal(t, []SnapshotResult{
+		{
+			ClusterIdentifier:  "my-cluster-1",
+			SnapshotIdentifier: "testing-my-cluster-1-old",
+			SnapshotArn:        "arn:aws:rds:us-east-1:123456789012:cluster-snapshot:testing-my-cluster-1-old",
+			Status:             StatusSkippedRecent,
+		},
+		{
+			ClusterIdentifier:          "my-cluster-2",
+			SnapshotIdentifier:         "testing-my-cluster-2",
+			Status:                     StatusPlanned,
+			ExistingSnapshotIdentifier: "testing-my-cluster-2-old",
+			ExistingSnapshotAge:        50 * time.Hour,
+		},
+		{ClusterIdentifier: "my-cluster-3", SnapshotIdentifier: "testing-my-cluster-3", Status: StatusPlanned},
+	}, results)
+	assert.Equal(t, RunStats{Skipped: 1}, bm.Stats())
+
+	var buf bytes.Buffer
+	assert.Nil(t, PlanFormatter{}.Format(&buf, results))
+	assert.Equal(t, ""+
+		"CLUSTER       PLAN            SNAPSHOT                  EXISTING                  AGE\n"+
+		"my-cluster-1  skipped-recent  testing-my-cluster-1-old  -                         -\n"+
+		"my-cluster-2  create          testing-my-cluster-2      testing-my-cluster-2-old  50h0m0s\n"+
+		"my-cluster-3  create          testing-my-cluster-3      -                         -\n",
+		buf.String())
+}
+
+func TestDryRunWithoutComparing(t *testing.T) {
+	// without a describer to look anything up with
+	st := NewFakeSnapshotTaker()
+	bm := NewBackupManager(st, WithPrefix("testing"), WithDryRun(true, false))
+	bm.sd = nil
+	bm.now = func() time.Time { return testNow }
+
+	results, err := bm.TriggerSnapshots(context.TODO(), "my-cluster-1")
+	assert.Nil(t, err)
+	assert.Empty(t, st.journal)
+	assert.Equal(t, []SnapshotResult{
+		{ClusterIdentifier: "my-cluster-1", SnapshotIdentifier: "testing-my-cluster-1", Status: StatusPlanned},
+	}, results)
+
+	bm.CompareExisting = true
+	_, err = bm.TriggerSnapshots(context.TODO(), "my-cluster-1")
+	assert.ErrorIs(t, err, ErrNoSnapshotDescriber)
+}
+
+func TestDryRunInstances(t *testing.T) {
+	bm := NewBackupManager(NewFakeSnapshotTaker(), WithDryRun(true, false))
+	_, err := bm.TriggerInstanceSnapshots(context.TODO(), "my-cluster-1")
+	assert.ErrorIs(t, err, ErrDryRunInstances)
+}
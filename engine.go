@@ -0,0 +1,87 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+)
+
+// compareEngineVersions compares two RDS engine version strings, returning
+// -1, 0 or 1. RDS versions aren't semver: Aurora MySQL looks like
+// "5.7.mysql_aurora.2.10.2", older engines have letter suffixes like
+// "5.6.10a", and leading zeros turn up in parts like "3.02.0". So versions are
+// split on '.', '_' and '-', numeric parts are compared as numbers with any
+// suffix compared as text, and missing trailing parts count as zero.
+func compareEngineVersions(a, b string) int {
+	aParts, bParts := versionParts(a), versionParts(b)
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		aPart, bPart := "0", "0"
+		if i < len(aParts) {
+			aPart = aParts[i]
+		}
+		if i < len(bParts) {
+			bPart = bParts[i]
+		}
+		if c := compareVersionPart(aPart, bPart); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func versionParts(version string) []string {
+	return strings.FieldsFunc(strings.ToLower(version), func(r rune) bool {
+		return r == '.' || r == '_' || r == '-'
+	})
+}
+
+// compareVersionPart compares a leading number numerically, then whatever
+// follows it as text. Parts without a number are compared as text, and sort
+// before parts with one.
+func compareVersionPart(a, b string) int {
+	aNum, aRest := splitLeadingDigits(a)
+	bNum, bRest := splitLeadingDigits(b)
+	switch {
+	case aNum == "" && bNum != "":
+		return -1
+	case aNum != "" && bNum == "":
+		return 1
+	}
+	if c := compareDigits(aNum, bNum); c != 0 {
+		return c
+	}
+	return strings.Compare(aRest, bRest)
+}
+
+func splitLeadingDigits(s string) (digits, rest string) {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+// compareDigits compares two strings of decimal digits by value, without
+// parsing them, so arbitrarily long parts can't overflow.
+func compareDigits(a, b string) int {
+	a, b = strings.TrimLeft(a, "0"), strings.TrimLeft(b, "0")
+	switch {
+	case len(a) < len(b):
+		return -1
+	case len(a) > len(b):
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// meetsMinEngineVersion reports whether a cluster's engine version is at
+// least the minimum configured for its engine, and what that minimum was.
+// Engines without a minimum always pass.
+func (b *BackupManager) meetsMinEngineVersion(cluster types.DBCluster) (ok bool, minimum string) {
+	minimum, found := b.MinEngineVersions[aws.ToString(cluster.Engine)]
+	if !found {
+		return true, ""
+	}
+	return compareEngineVersions(aws.ToString(cluster.EngineVersion), minimum) >= 0, minimum
+}
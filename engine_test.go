@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareEngineVersions(t *testing.T) {
+	type testCase struct {
+		a, b     string
+		expected int
+	}
+
+	testCases := map[string]testCase{
+		"equal":                         {"13.7", "13.7", 0},
+		"minor is numeric, not textual": {"10.14", "10.9", 1},
+		"major is numeric, not textual": {"9.6", "10.1", -1},
+		"missing parts count as zero":   {"5.7", "5.7.0", 0},
+		"extra nonzero part is newer":   {"13", "13.1", -1},
+		"leading zeros are ignored":     {"8.0.mysql_aurora.3.02.0", "8.0.mysql_aurora.3.2.0", 0},
+		"aurora mysql patch":            {"5.7.mysql_aurora.2.10.2", "5.7.mysql_aurora.2.11.1", -1},
+		"aurora mysql across majors":    {"8.0.mysql_aurora.3.01.0", "5.7.mysql_aurora.2.11.1", 1},
+		"letter suffix is newer":        {"5.6.10a", "5.6.10", 1},
+		"letter suffixes compare":       {"5.6.10a", "5.6.10b", -1},
+		"number in part beats suffix":   {"5.6.9z", "5.6.10", -1},
+		"case doesn't matter":           {"5.6.10A", "5.6.10a", 0},
+		"words sort before numbers":     {"1.beta", "1.0", -1},
+		"hyphen separated":              {"1.2-3", "1.2.3", 0},
+		"long parts don't overflow":     {"1.99999999999999999999", "1.99999999999999999998", 1},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, compareEngineVersions(tc.a, tc.b))
+			assert.Equal(t, -tc.expected, compareEngineVersions(tc.b, tc.a))
+		})
+	}
+}
+
+func engineCluster(clusterID, engine, version string) types.DBCluster {
+	cluster := existingCluster(clusterID)
+	cluster.Engine = aws.String(engine)
+	cluster.EngineVersion = aws.String(version)
+	return cluster
+}
+
+func TestDiscoverClustersMinEngineVersion(t *testing.T) {
+	st := NewFakeSnapshotTaker()
+	st.clusters = []types.DBCluster{
+		engineCluster("old-postgres", "aurora-postgresql", "10.14"),
+		engineCluster("new-postgres", "aurora-postgresql", "13.7"),
+		engineCluster("old-mysql", "aurora-mysql", "5.7.mysql_aurora.2.07.2"),
+		engineCluster("new-mysql", "aurora-mysql", "5.7.mysql_aurora.2.10.2"),
+		engineCluster("other-engine", "aurora", "5.6.10a"),
+	}
+	bm := NewBackupManager(st, WithMinEngineVersions(map[string]string{
+		"aurora-postgresql": "11.9",
+		"aurora-mysql":      "5.7.mysql_aurora.2.10.0",
+	}))
+
+	clusters, err := bm.DiscoverClusters(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"new-mysql", "new-postgres", "other-engine"}, clusterIdentifiers(clusters))
+}
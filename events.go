@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// EventType is what an Event is about.
+type EventType string
+
+const (
+	EventStarted  EventType = "started"
+	EventCreated  EventType = "created"
+	EventSkipped  EventType = "skipped"
+	EventPlanned  EventType = "planned"
+	EventFailed   EventType = "failed"
+	EventFinished EventType = "finished"
+)
+
+// Event is one line of the EventSink stream. Started and finished events are
+// about the run as a whole; the rest are about one cluster each.
+type Event struct {
+	Type  EventType `json:"event"`
+	Time  string    `json:"time"`
+	RunID string    `json:"runId"`
+
+	// Clusters is how many clusters a started run has to get through.
+	Clusters int `json:"clusters,omitempty"`
+
+	Cluster    string         `json:"cluster,omitempty"`
+	SnapshotID string         `json:"snapshotID,omitempty"`
+	Arn        string         `json:"arn,omitempty"`
+	Status     SnapshotStatus `json:"status,omitempty"`
+	Error      string         `json:"error,omitempty"`
+
+	// Stats are a finished run's counts.
+	Stats *RunStats `json:"stats,omitempty"`
+}
+
+func resultEventType(status SnapshotStatus) EventType {
+	switch {
+	case status == StatusCreated:
+		return EventCreated
+	case status == StatusPlanned:
+		return EventPlanned
+	case status == StatusFailed:
+		return EventFailed
+	case strings.HasPrefix(string(status), "skipped-"):
+		return EventSkipped
+	}
+	return EventType(status)
+}
+
+// emit writes an event to the EventSink as a line of JSON. Workers emit
+// concurrently, and each line is written whole, in one Write. If the sink
+// fails, that's logged and the rest of the run's events are dropped, since a
+// consumer that's gone away shouldn't hold up the backups.
+func (b *BackupManager) emit(event Event) {
+	b.eventMu.Lock()
+	defer b.eventMu.Unlock()
+	if b.EventSink == nil || b.sinkBroken {
+		return
+	}
+
+	event.Time = b.clock().UTC().Format(time.RFC3339Nano)
+	event.RunID = b.RunID
+	line, err := json.Marshal(event)
+	if err == nil {
+		_, err = b.EventSink.Write(append(line, '\n'))
+	}
+	if err != nil {
+		b.sinkBroken = true
+		b.logf("Couldn't write to the event sink, dropping the rest of the run's events: %v", err)
+	}
+}
+
+func (b *BackupManager) emitResult(result SnapshotResult) {
+	if b.EventSink == nil {
+		return
+	}
+	event := Event{
+		Type:       resultEventType(result.Status),
+		Cluster:    result.ClusterIdentifier,
+		SnapshotID: result.SnapshotIdentifier,
+		Arn:        result.SnapshotArn,
+		Status:     result.Status,
+	}
+	if result.Err != nil {
+		event.Error = result.Err.Error()
+	}
+	b.emit(event)
+}
+
+func (b *BackupManager) emitStarted(clusters int) {
+	if b.EventSink == nil {
+		return
+	}
+	b.eventMu.Lock()
+	b.sinkBroken = false
+	b.eventMu.Unlock()
+	b.emit(Event{Type: EventStarted, Clusters: clusters})
+}
+
+func (b *BackupManager) emitFinished() {
+	if b.EventSink == nil {
+		return
+	}
+	stats := b.Stats()
+	b.emit(Event{Type: EventFinished, Stats: &stats})
+}
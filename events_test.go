@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeRecorder keeps each Write it's given separately, to check lines
+// aren't split or interleaved.
+type writeRecorder struct {
+	mu     sync.Mutex
+	writes []string
+}
+
+func (w *writeRecorder) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.writes = append(w.writes, string(p))
+	return len(p), nil
+}
+
+func TestEventSink(t *testing.T) {
+	clusterIDs := make([]string, 0, 20)
+	for i := 0; i < 20; i++ {
+		clusterIDs = append(clusterIDs, fmt.Sprintf("my-cluster-%d", i))
+	}
+	st := NewFlakySnapshotTaker("my-cluster-7", &types.SnapshotQuotaExceededFault{})
+	sink := &writeRecorder{}
+	bm := NewBackupManager(st, WithPrefix("testing"), WithConcurrency(4), WithContinueOnError(true), WithRunID("run-1"))
+	bm.EventSink = sink
+	bm.now = func() time.Time { return testNow }
+
+	_, err := bm.TriggerSnapshots(context.TODO(), clusterIDs...)
+	assert.ErrorIs(t, err, ErrSnapshotsFailed)
+
+	events := make([]Event, 0, len(sink.writes))
+	for _, write := range sink.writes {
+		assert.True(t, strings.HasSuffix(write, "\n"), write)
+		assert.Equal(t, 1, strings.Count(write, "\n"), write)
+		var event Event
+		assert.Nil(t, json.Unmarshal([]byte(write), &event), write)
+		assert.Equal(t, "run-1", event.RunID)
+		assert.Equal(t, "2022-03-15T12:00:00Z", event.Time)
+		events = append(events, event)
+	}
+	assert.Len(t, events, 22)
+	assert.Equal(t, Event{Type: EventStarted, Time: "2022-03-15T12:00:00Z", RunID: "run-1", Clusters: 20}, events[0])
+	assert.Equal(t, Event{Type: EventFinished, Time: "2022-03-15T12:00:00Z", RunID: "run-1", Stats: &RunStats{Created: 19, Failed: 1}}, events[21])
+
+	counts := make(map[EventType]int)
+	for _, event := range events[1:21] {
+		counts[event.Type]++
+		if event.Type == EventFailed {
+			assert.Equal(t, "my-cluster-7", event.Cluster)
+			assert.Equal(t, StatusFailed, event.Status)
+			assert.NotEmpty(t, event.Error)
+		}
+	}
+	assert.Equal(t, map[EventType]int{EventCreated: 19, EventFailed: 1}, counts)
+}
+
+// brokenWriter fails every write, like a pipe with nobody reading.
+type brokenWriter struct {
+	writes int
+}
+
+func (w *brokenWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return 0, errors.New("broken pipe")
+}
+
+func TestEventSinkBroken(t *testing.T) {
+	var buf bytes.Buffer
+	sink := &brokenWriter{}
+	bm := NewBackupManager(NewFakeSnapshotTaker(), WithPrefix("testing"), WithLogger(log.New(&buf, "", 0)), WithRunID("run-1"))
+	bm.EventSink = sink
+
+	// the backups go ahead, and the sink's only tried once a run
+	results, err := bm.TriggerSnapshots(context.TODO(), "my-cluster-1", "my-cluster-2")
+	assert.Nil(t, err)
+	assert.Len(t, results, 2)
+	assert.Equal(t, 1, sink.writes)
+	assert.Equal(t, ""+
+		"Starting run 'run-1' for 2 cluster(s).\n"+
+		"Couldn't write to the event sink, dropping the rest of the run's events: broken pipe\n",
+		buf.String())
+
+	_, err = bm.TriggerSnapshot(context.TODO(), "my-cluster-3")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, sink.writes)
+}
+
+func TestResultEventType(t *testing.T) {
+	assert.Equal(t, EventCreated, resultEventType(StatusCreated))
+	assert.Equal(t, EventPlanned, resultEventType(StatusPlanned))
+	assert.Equal(t, EventFailed, resultEventType(StatusFailed))
+	for _, status := range []SnapshotStatus{StatusSkippedNotFound, StatusSkippedRecent, StatusSkippedDone, StatusSkippedUnchanged, StatusSkippedOptOut, StatusSkippedExisting} {
+		assert.Equal(t, EventSkipped, resultEventType(status), status)
+	}
+}
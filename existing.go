@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+const ErrSnapshotNameTaken BackupManagerError = "snapshot name is already another cluster's"
+
+// skipExisting deals with a snapshot that couldn't be created because one by
+// the same name is already there: it's looked up so the result carries its
+// ARN, as though this run had created it. One belonging to a different
+// cluster is a failure, since it's no backup of this one.
+func (b *BackupManager) skipExisting(ctx context.Context, result SnapshotResult) SnapshotResult {
+	out, err := b.describeSnapshot(ctx, result.SnapshotIdentifier)
+	if err == nil && len(out.DBClusterSnapshots) == 0 {
+		err = fmt.Errorf("'%s': snapshot already exists, but describing it found nothing", result.SnapshotIdentifier)
+	}
+	if err != nil {
+		result.Status = StatusFailed
+		result.Err = err
+		return result
+	}
+
+	existing := out.DBClusterSnapshots[0]
+	if owner := aws.ToString(existing.DBClusterIdentifier); owner != result.ClusterIdentifier {
+		result.Status = StatusFailed
+		result.Err = fmt.Errorf("'%s' is a snapshot of '%s': %w", result.SnapshotIdentifier, owner, ErrSnapshotNameTaken)
+		return result
+	}
+
+	b.logf("Not backing up '%s', snapshot '%s' already exists.", result.ClusterIdentifier, result.SnapshotIdentifier)
+	result.Status = StatusSkippedExisting
+	result.SnapshotArn = aws.ToString(existing.DBClusterSnapshotArn)
+	b.markDone(result.ClusterIdentifier)
+	return result
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSkipExisting(t *testing.T) {
+	type testCase struct {
+		snapshots      []types.DBClusterSnapshot
+		skipExisting   bool
+		expected       SnapshotResult
+		expectedErr    error
+		expectAnyError bool
+		expectedStats  RunStats
+	}
+
+	testCases := map[string]testCase{
+		"reports the existing snapshot": {
+			snapshots:    []types.DBClusterSnapshot{existingSnapshot("my-cluster-2", "testing-my-cluster-2", testNow.Add(-time.Hour))},
+			skipExisting: true,
+			expected: SnapshotResult{
+				ClusterIdentifier:  "my-cluster-2",
+				SnapshotIdentifier: "testing-my-cluster-2",
+				SnapshotArn:        "arn:aws:rds:us-east-1:123456789012:cluster-snapshot:testing-my-cluster-2",
+				Status:             StatusSkippedExisting,
+			},
+			expectedStats: RunStats{Created: 1, Skipped: 1},
+		},
+		"another cluster's snapshot": {
+			snapshots:    []types.DBClusterSnapshot{existingSnapshot("my-cluster-9", "testing-my-cluster-2", testNow.Add(-time.Hour))},
+			skipExisting: true,
+			expected: SnapshotResult{
+				ClusterIdentifier:  "my-cluster-2",
+				SnapshotIdentifier: "testing-my-cluster-2",
+				Status:             StatusFailed,
+			},
+			expectedErr:   ErrSnapshotNameTaken,
+			expectedStats: RunStats{Created: 1, Failed: 1},
+		},
+		"without SkipExisting it's a failure": {
+			snapshots: []types.DBClusterSnapshot{existingSnapshot("my-cluster-2", "testing-my-cluster-2", testNow.Add(-time.Hour))},
+			expected: SnapshotResult{
+				ClusterIdentifier:  "my-cluster-2",
+				SnapshotIdentifier: "testing-my-cluster-2",
+				Status:             StatusFailed,
+			},
+			expectAnyError: true,
+			expectedStats:  RunStats{Created: 1, Failed: 1},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			st := NewFlakySnapshotTaker("my-cluster-2", &types.DBClusterSnapshotAlreadyExistsFault{})
+			st.snapshots = tc.snapshots
+			bm := NewBackupManager(st, WithPrefix("testing"), WithSkipExisting(tc.skipExisting), WithContinueOnError(true))
+			bm.now = func() time.Time { return testNow }
+
+			results, _ := bm.TriggerSnapshots(context.TODO(), "my-cluster-1", "my-cluster-2")
+			assert.Len(t, results, 2)
+			result := results[1]
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, result.Err, tc.expectedErr)
+			} else if tc.expectAnyError {
+				assert.Error(t, result.Err)
+			} else {
+				assert.Nil(t, result.Err)
+			}
+			result.Err = nil
+			assert.Equal(t, tc.expected, result)
+			assert.Equal(t, tc.expectedStats, bm.Stats())
+		})
+	}
+}
+
+func TestSkipExistingNeedsDescriber(t *testing.T) {
+	bm := NewBackupManager(NewFakeSnapshotTaker(), WithSkipExisting(true))
+	bm.sd = nil
+	_, err := bm.TriggerSnapshots(context.TODO(), "my-cluster-1")
+	assert.ErrorIs(t, err, ErrNoSnapshotDescriber)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// tagFlag collects repeated key=value flags, like -tag.
+type tagFlag map[string]string
+
+func (t tagFlag) String() string {
+	pairs := make([]string, 0, len(t))
+	for key, value := range t {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (t tagFlag) Set(s string) error {
+	i := strings.Index(s, "=")
+	if i < 1 {
+		return fmt.Errorf("'%s' must look like key=value", s)
+	}
+	t[s[:i]] = s[i+1:]
+	return nil
+}
+
+// tagExprFlag parses a tag expression, like -cluster-tags. It's unset until
+// it's given.
+type tagExprFlag struct {
+	expr TagExpr
+}
+
+func (t *tagExprFlag) String() string {
+	if t.expr == nil {
+		return ""
+	}
+	return t.expr.String()
+}
+
+func (t *tagExprFlag) Set(s string) error {
+	expr, err := ParseTagExpr(s)
+	if err != nil {
+		return err
+	}
+	t.expr = expr
+	return nil
+}
+
+// optOutFlag is a single key=value tag, like -optout-tag. An empty value
+// turns it off.
+type optOutFlag struct {
+	key, value string
+}
+
+func (o *optOutFlag) String() string {
+	if o.key == "" {
+		return ""
+	}
+	return o.key + "=" + o.value
+}
+
+func (o *optOutFlag) Set(s string) error {
+	if s == "" {
+		o.key, o.value = "", ""
+		return nil
+	}
+	i := strings.Index(s, "=")
+	if i < 1 {
+		return fmt.Errorf("'%s' must look like key=value", s)
+	}
+	o.key, o.value = s[:i], s[i+1:]
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTagFlag(t *testing.T) {
+	type testCase struct {
+		args        []string
+		expected    tagFlag
+		expectError bool
+	}
+
+	testCases := map[string]testCase{
+		"single tag": {
+			args:     []string{"env=prod"},
+			expected: tagFlag{"env": "prod"},
+		},
+		"repeated tags, last one wins": {
+			args:     []string{"env=prod", "team=payments", "env=staging"},
+			expected: tagFlag{"env": "staging", "team": "payments"},
+		},
+		"empty value is allowed": {
+			args:     []string{"temporary="},
+			expected: tagFlag{"temporary": ""},
+		},
+		"value may contain equals": {
+			args:     []string{"query=a=b"},
+			expected: tagFlag{"query": "a=b"},
+		},
+		"missing equals": {
+			args:        []string{"env"},
+			expectError: true,
+		},
+		"missing key": {
+			args:        []string{"=prod"},
+			expectError: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			tags := tagFlag{}
+			var err error
+			for _, arg := range tc.args {
+				if err = tags.Set(arg); err != nil {
+					break
+				}
+			}
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, tags)
+		})
+	}
+}
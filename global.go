@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+)
+
+const ErrNoGlobalClusterDescriber BackupManagerError = "backing up a global database requires a GlobalClusterDescriber"
+
+// GlobalClusterMembers returns the ARNs of the clusters in a global
+// database, primary and secondaries alike. The ARNs say which region each
+// one lives in.
+func (b *BackupManager) GlobalClusterMembers(ctx context.Context, globalClusterIdentifier string) ([]string, error) {
+	if b.gcd == nil {
+		return nil, ErrNoGlobalClusterDescriber
+	}
+
+	out, err := b.gcd.DescribeGlobalClusters(ctx, &rds.DescribeGlobalClustersInput{
+		GlobalClusterIdentifier: aws.String(globalClusterIdentifier),
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, global := range out.GlobalClusters {
+		if aws.ToString(global.GlobalClusterIdentifier) != globalClusterIdentifier {
+			continue
+		}
+		members := make([]string, 0, len(global.GlobalClusterMembers))
+		for _, member := range global.GlobalClusterMembers {
+			members = append(members, aws.ToString(member.DBClusterArn))
+		}
+		return members, nil
+	}
+	return nil, fmt.Errorf("global cluster '%s': %w", globalClusterIdentifier, &types.GlobalClusterNotFoundFault{})
+}
+
+// runGlobalBackup snapshots every member of a global database in its own
+// region, like runBackupByRegion does for ARNs given on the command line.
+// Each member's results name the global database it belongs to. That's filled
+// in afterwards rather than annotated up front, which would stop the regional
+// managers describing the members for their tags, size and status.
+func runGlobalBackup(ctx context.Context, bm *BackupManager, globalClusterIdentifier, defaultRegion string, maxPerRegion int, managerFor func(region string) *BackupManager) ([]SnapshotResult, error) {
+	members, err := bm.GlobalClusterMembers(ctx, globalClusterIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	bm.logf("Global cluster '%s' has %d member(s).", globalClusterIdentifier, len(members))
+
+	results, err := runBackupByRegion(ctx, members, defaultRegion, false, maxPerRegion, managerFor)
+	for i := range results {
+		results[i].GlobalClusterIdentifier = globalClusterIdentifier
+	}
+	return results, err
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func globalDatabase(globalClusterIdentifier string, memberArns ...string) types.GlobalCluster {
+	global := types.GlobalCluster{GlobalClusterIdentifier: aws.String(globalClusterIdentifier)}
+	for i, memberArn := range memberArns {
+		global.GlobalClusterMembers = append(global.GlobalClusterMembers, types.GlobalClusterMember{
+			DBClusterArn: aws.String(memberArn),
+			IsWriter:     i == 0,
+		})
+	}
+	return global
+}
+
+func TestGlobalClusterMembers(t *testing.T) {
+	st := NewFakeSnapshotTaker()
+	st.globalClusters = []types.GlobalCluster{
+		globalDatabase("other-global", "arn:aws:rds:us-east-1:123456789012:cluster:other"),
+		globalDatabase("my-global",
+			"arn:aws:rds:us-east-1:123456789012:cluster:my-primary",
+			"arn:aws:rds:eu-west-1:123456789012:cluster:my-secondary",
+		),
+	}
+	bm := NewBackupManager(st)
+
+	members, err := bm.GlobalClusterMembers(context.TODO(), "my-global")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"arn:aws:rds:us-east-1:123456789012:cluster:my-primary",
+		"arn:aws:rds:eu-west-1:123456789012:cluster:my-secondary",
+	}, members)
+
+	_, err = bm.GlobalClusterMembers(context.TODO(), "missing-global")
+	var nfErr *types.GlobalClusterNotFoundFault
+	assert.ErrorAs(t, err, &nfErr)
+
+	_, err = (&BackupManager{}).GlobalClusterMembers(context.TODO(), "my-global")
+	assert.ErrorIs(t, err, ErrNoGlobalClusterDescriber)
+}
+
+func TestRunGlobalBackup(t *testing.T) {
+	primary := NewFakeSnapshotTaker()
+	primary.globalClusters = []types.GlobalCluster{
+		globalDatabase("my-global",
+			"arn:aws:rds:us-east-1:123456789012:cluster:my-primary",
+			"arn:aws:rds:eu-west-1:123456789012:cluster:my-secondary",
+		),
+	}
+	takers := map[string]*fakeSnapshotTaker{"us-east-1": primary, "eu-west-1": NewFakeSnapshotTaker()}
+	managerFor := func(region string) *BackupManager {
+		bm := NewBackupManager(takers[region], WithPrefix("testing"))
+		bm.now = func() time.Time { return testNow }
+		return bm
+	}
+
+	results, err := runGlobalBackup(context.TODO(), managerFor("us-east-1"), "my-global", "us-east-1", 0, managerFor)
+	assert.Nil(t, err)
+	assert.Equal(t, []SnapshotResult{
+		{ClusterIdentifier: "my-secondary", SnapshotIdentifier: "testing-my-secondary", GlobalClusterIdentifier: "my-global", Status: StatusCreated},
+		{ClusterIdentifier: "my-primary", SnapshotIdentifier: "testing-my-primary", GlobalClusterIdentifier: "my-global", Status: StatusCreated},
+	}, results)
+	assert.Equal(t, []snapshotCreationRecord{{"my-primary", "testing-my-primary"}}, takers["us-east-1"].GetJournal())
+	assert.Equal(t, []snapshotCreationRecord{{"my-secondary", "testing-my-secondary"}}, takers["eu-west-1"].GetJournal())
+}
+
+func TestRunGlobalBackupOptedOutMember(t *testing.T) {
+	primary := NewFakeSnapshotTaker()
+	primary.globalClusters = []types.GlobalCluster{
+		globalDatabase("my-global",
+			"arn:aws:rds:us-east-1:123456789012:cluster:my-primary",
+			"arn:aws:rds:eu-west-1:123456789012:cluster:my-secondary",
+		),
+	}
+	primary.clusters = []types.DBCluster{existingCluster("my-primary")}
+	secondary := NewFakeSnapshotTaker()
+	secondary.clusters = []types.DBCluster{existingCluster("my-secondary")}
+	secondary.clusters[0].TagList = []types.Tag{{Key: aws.String("backup"), Value: aws.String("false")}}
+	takers := map[string]*fakeSnapshotTaker{"us-east-1": primary, "eu-west-1": secondary}
+	managerFor := func(region string) *BackupManager {
+		bm := NewBackupManager(takers[region], WithPrefix("testing"), WithOptOutTag("backup", "false", true))
+		bm.now = func() time.Time { return testNow }
+		return bm
+	}
+
+	results, err := runGlobalBackup(context.TODO(), managerFor("us-east-1"), "my-global", "us-east-1", 0, managerFor)
+	assert.Nil(t, err)
+	assert.Equal(t, []SnapshotResult{
+		{ClusterIdentifier: "my-secondary", SnapshotIdentifier: "testing-my-secondary", GlobalClusterIdentifier: "my-global", Status: StatusSkippedOptOut},
+		{ClusterIdentifier: "my-primary", SnapshotIdentifier: "testing-my-primary", GlobalClusterIdentifier: "my-global", Status: StatusCreated},
+	}, results)
+	assert.Empty(t, secondary.GetJournal())
+}
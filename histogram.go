@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+)
+
+const day = 24 * time.Hour
+
+// ageBucket counts snapshots whose age falls in [Min, Max). A zero Max means
+// the bucket is unbounded.
+type ageBucket struct {
+	Label string
+	Min   time.Duration
+	Max   time.Duration
+	Count int
+}
+
+// ageHistogram buckets creation times by how long before now they were.
+func ageHistogram(created []time.Time, now time.Time) []ageBucket {
+	buckets := []ageBucket{
+		{Label: "<1d", Max: day},
+		{Label: "1-7d", Min: day, Max: 7 * day},
+		{Label: "7-30d", Min: 7 * day, Max: 30 * day},
+		{Label: ">30d", Min: 30 * day},
+	}
+	for _, t := range created {
+		age := now.Sub(t)
+		if age < 0 {
+			// clock skew, treat it as brand new
+			age = 0
+		}
+		for i := range buckets {
+			if age >= buckets[i].Min && (buckets[i].Max == 0 || age < buckets[i].Max) {
+				buckets[i].Count++
+				break
+			}
+		}
+	}
+	return buckets
+}
+
+// snapshotCreationTimes pulls creation times out of snapshots for
+// ageHistogram. Snapshots still being created count as created now.
+func snapshotCreationTimes(snapshots []types.DBClusterSnapshot, now time.Time) []time.Time {
+	created := make([]time.Time, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		createdAt := snapshotCreatedAt(snapshot)
+		if createdAt == nil {
+			created = append(created, now)
+			continue
+		}
+		created = append(created, *createdAt)
+	}
+	return created
+}
+
+func printAgeHistogram(w io.Writer, buckets []ageBucket) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "AGE\tCOUNT")
+	for _, bucket := range buckets {
+		fmt.Fprintf(tw, "%s\t%d\n", bucket.Label, bucket.Count)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAgeHistogram(t *testing.T) {
+	type testCase struct {
+		ages     []time.Duration
+		expected []int
+	}
+
+	testCases := map[string]testCase{
+		"no snapshots": {
+			expected: []int{0, 0, 0, 0},
+		},
+		"one in each bucket": {
+			ages:     []time.Duration{time.Hour, 3 * day, 10 * day, 90 * day},
+			expected: []int{1, 1, 1, 1},
+		},
+		"boundaries fall into the older bucket": {
+			ages:     []time.Duration{0, day, 7 * day, 30 * day},
+			expected: []int{1, 1, 1, 1},
+		},
+		"just under the boundaries": {
+			ages:     []time.Duration{day - time.Second, 7*day - time.Second, 30*day - time.Second},
+			expected: []int{1, 1, 1, 0},
+		},
+		"future timestamps count as new": {
+			ages:     []time.Duration{-time.Hour},
+			expected: []int{1, 0, 0, 0},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			created := make([]time.Time, 0, len(tc.ages))
+			for _, age := range tc.ages {
+				created = append(created, testNow.Add(-age))
+			}
+
+			buckets := ageHistogram(created, testNow)
+			counts := make([]int, 0, len(buckets))
+			for _, bucket := range buckets {
+				counts = append(counts, bucket.Count)
+			}
+			assert.Equal(t, tc.expected, counts)
+		})
+	}
+}
+
+func TestSnapshotCreationTimes(t *testing.T) {
+	snapshots := []types.DBClusterSnapshot{
+		existingSnapshot("my-cluster-1", "testing-my-cluster-1", testNow.Add(-day)),
+		{DBClusterSnapshotIdentifier: aws.String("testing-creating")},
+	}
+	assert.Equal(t, []time.Time{testNow.Add(-day), testNow}, snapshotCreationTimes(snapshots, testNow))
+}
+
+func TestPrintAgeHistogram(t *testing.T) {
+	out := &bytes.Buffer{}
+	printAgeHistogram(out, ageHistogram([]time.Time{testNow, testNow.Add(-40 * day)}, testNow))
+	assert.Equal(t, "AGE    COUNT\n<1d    1\n1-7d   0\n7-30d  0\n>30d   1\n", out.String())
+}
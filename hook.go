@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// postHookTimeout bounds the post-hook. Like publishing metrics, it gets its
+// own context, so a run cut short by -max-runtime still runs its hook.
+const postHookTimeout = 5 * time.Minute
+
+// CommandRunner runs a shell command, feeding it stdin.
+type CommandRunner interface {
+	Run(ctx context.Context, command string, stdin io.Reader) error
+}
+
+// shellRunner runs commands with sh. Their output goes to stderr, so it
+// can't get mixed up with anything we print on stdout.
+type shellRunner struct{}
+
+func (shellRunner) Run(ctx context.Context, command string, stdin io.Reader) error {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdin = stdin
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// SummaryFormatter reports a run's counts and the outcome for each cluster
+// as JSON.
+type SummaryFormatter struct {
+	RunID string
+}
+
+type summary struct {
+	RunID   string          `json:"runId"`
+	Created int64           `json:"created"`
+	Skipped int64           `json:"skipped"`
+	Failed  int64           `json:"failed"`
+	Results []summaryResult `json:"results"`
+}
+
+type summaryResult struct {
+	Profile    string `json:"profile,omitempty"`
+	Cluster    string `json:"cluster"`
+	Instance   string `json:"instance,omitempty"`
+	SnapshotID string `json:"snapshotID"`
+	Arn        string `json:"arn,omitempty"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"durationMs,omitempty"`
+}
+
+func (f SummaryFormatter) Format(w io.Writer, results []SnapshotResult) error {
+	var counts runCounters
+	s := summary{RunID: f.RunID, Results: make([]summaryResult, 0, len(results))}
+	for _, result := range results {
+		counts.record(result.Status)
+		r := summaryResult{
+			Profile:    result.Profile,
+			Cluster:    result.ClusterIdentifier,
+			Instance:   result.InstanceIdentifier,
+			SnapshotID: result.SnapshotIdentifier,
+			Arn:        result.SnapshotArn,
+			Status:     string(result.Status),
+			DurationMs: result.Duration.Milliseconds(),
+		}
+		if result.Err != nil {
+			r.Error = result.Err.Error()
+		}
+		s.Results = append(s.Results, r)
+	}
+	s.Created, s.Skipped, s.Failed = counts.created, counts.skipped, counts.failed
+
+	return json.NewEncoder(w).Encode(s)
+}
+
+// runPostHook runs command with the formatted results on its stdin.
+func runPostHook(runner CommandRunner, command string, f Formatter, results []SnapshotResult) error {
+	var stdin bytes.Buffer
+	if err := f.Format(&stdin, results); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), postHookTimeout)
+	defer cancel()
+	if err := runner.Run(ctx, command, &stdin); err != nil {
+		return fmt.Errorf("post-hook '%s': %w", command, err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeRunner struct {
+	err     error
+	command string
+	stdin   string
+	// ctxErr and deadline are the hook's context's, as it ran
+	ctxErr   error
+	deadline time.Time
+}
+
+func (f *fakeRunner) Run(ctx context.Context, command string, stdin io.Reader) error {
+	f.command = command
+	f.ctxErr = ctx.Err()
+	f.deadline, _ = ctx.Deadline()
+	b, err := ioutil.ReadAll(stdin)
+	if err != nil {
+		return err
+	}
+	f.stdin = string(b)
+	return f.err
+}
+
+func TestRunPostHook(t *testing.T) {
+	results := []SnapshotResult{
+		{ClusterIdentifier: "my-cluster-1", SnapshotIdentifier: "testing-my-cluster-1", Status: StatusCreated},
+		{ClusterIdentifier: "my-cluster-2", SnapshotIdentifier: "testing-my-cluster-2", Status: StatusSkippedRecent},
+		{ClusterIdentifier: "my-cluster-3", SnapshotIdentifier: "testing-my-cluster-3", Status: StatusFailed, Err: errors.New("boom")},
+	}
+
+	runner := &fakeRunner{}
+	err := runPostHook(runner, "notify.sh", SummaryFormatter{RunID: "abc123"}, results)
+	assert.Nil(t, err)
+	assert.Equal(t, "notify.sh", runner.command)
+	assert.JSONEq(t, `{
+		"runId": "abc123",
+		"created": 1,
+		"skipped": 1,
+		"failed": 1,
+		"results": [
+			{"cluster": "my-cluster-1", "snapshotID": "testing-my-cluster-1", "status": "created"},
+			{"cluster": "my-cluster-2", "snapshotID": "testing-my-cluster-2", "status": "skipped-recent"},
+			{"cluster": "my-cluster-3", "snapshotID": "testing-my-cluster-3", "status": "failed", "error": "boom"}
+		]
+	}`, runner.stdin)
+}
+
+func TestRunPostHookFails(t *testing.T) {
+	hookErr := errors.New("exit status 1")
+	err := runPostHook(&fakeRunner{err: hookErr}, "notify.sh", SummaryFormatter{}, nil)
+	assert.ErrorIs(t, err, hookErr)
+	assert.EqualError(t, err, "post-hook 'notify.sh': exit status 1")
+}
+
+func TestRunPostHookAfterDeadline(t *testing.T) {
+	// by the time the hook runs, -max-runtime may have run out, so the hook
+	// gets a context of its own rather than the run's
+	runner := &fakeRunner{}
+	err := runPostHook(runner, "notify.sh", SummaryFormatter{}, nil)
+	assert.Nil(t, err)
+	assert.Nil(t, runner.ctxErr)
+	assert.WithinDuration(t, time.Now().Add(postHookTimeout), runner.deadline, time.Minute)
+}
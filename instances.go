@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+)
+
+// InstanceSnapshotTaker creates snapshots of individual DB instances.
+// *rds.Client implements it.
+type InstanceSnapshotTaker interface {
+	CreateDBSnapshot(context.Context, *rds.CreateDBSnapshotInput, ...func(*rds.Options)) (*rds.CreateDBSnapshotOutput, error)
+}
+
+const ErrNoInstanceSnapshotTaker BackupManagerError = "snapshotting instances requires an InstanceSnapshotTaker"
+
+// InstanceSnapshotDescriber looks up existing snapshots of DB instances.
+// *rds.Client implements it.
+type InstanceSnapshotDescriber interface {
+	DescribeDBSnapshots(context.Context, *rds.DescribeDBSnapshotsInput, ...func(*rds.Options)) (*rds.DescribeDBSnapshotsOutput, error)
+}
+
+const ErrNoInstanceSnapshotDescriber BackupManagerError = "skipping recently snapshotted instances requires an InstanceSnapshotDescriber"
+
+// TriggerInstanceSnapshots snapshots the member instances of each of the
+// given clusters, rather than the clusters themselves: the writer, plus the
+// readers when IncludeReaders is set. Snapshots are named and truncated just
+// like cluster snapshots, after the instance, and instances with a snapshot
+// younger than SkipIfRecentWithin are skipped. Clusters or instances that
+// don't exist are skipped, and other failures are retried and handled as in
+// TriggerSnapshots, which the run is set up just like. Up to Concurrency
+// clusters have their instances snapshotted at once; the results are in the
+// order the clusters were given, unless they're to be sorted.
+func (b *BackupManager) TriggerInstanceSnapshots(ctx context.Context, clusterIdentifers ...string) ([]SnapshotResult, error) {
+	clusterIdentifers, err := b.checkIdentifiers(clusterIdentifers)
+	if err != nil {
+		return nil, err
+	}
+	if b.DryRun {
+		return nil, ErrDryRunInstances
+	}
+	if b.ist == nil {
+		return nil, ErrNoInstanceSnapshotTaker
+	}
+	if b.cd == nil {
+		return nil, ErrNoClusterDescriber
+	}
+	if b.SkipIfRecentWithin > 0 && b.isd == nil {
+		return nil, ErrNoInstanceSnapshotDescriber
+	}
+	if err := b.beginRun(ctx, clusterIdentifers); err != nil {
+		return nil, err
+	}
+
+	// A failure without ContinueOnError cancels the batch so no new clusters
+	// are started. Anything already in flight is allowed to finish.
+	batchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu         sync.Mutex
+		wg         sync.WaitGroup
+		firstErr   error
+		failed     int
+		perCluster = make([][]SnapshotResult, len(clusterIdentifers))
+		jobs       = make(chan int)
+	)
+	workers := b.workerCount(len(clusterIdentifers))
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if batchCtx.Err() != nil {
+					continue
+				}
+				clusterResults := b.snapshotClusterInstances(batchCtx, clusterIdentifers[i])
+
+				mu.Lock()
+				perCluster[i] = clusterResults
+				for _, result := range clusterResults {
+					b.stats.record(result.Status)
+					b.emitResult(result)
+					if result.Status != StatusFailed {
+						continue
+					}
+					if b.ContinueOnError {
+						b.logf("Failed to back up '%s' in '%s', continuing: %v", result.InstanceIdentifier, result.ClusterIdentifier, result.Err)
+						failed++
+					} else if firstErr == nil {
+						firstErr = result.Err
+						cancel()
+					}
+				}
+				mu.Unlock()
+				if b.Results != nil {
+					for _, result := range clusterResults {
+						b.Results <- result
+					}
+				}
+			}
+		}()
+	}
+
+dispatch:
+	for i := range clusterIdentifers {
+		select {
+		case jobs <- i:
+		case <-batchCtx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	b.publishMetrics()
+	b.emitFinished()
+
+	results := make([]SnapshotResult, 0, len(clusterIdentifers))
+	for _, clusterResults := range perCluster {
+		results = append(results, clusterResults...)
+	}
+	results = orderResults(results, b.OutputOrder)
+
+	if firstErr != nil {
+		return results, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return results, err
+	}
+	if failed > 0 {
+		return results, fmt.Errorf("%d instances: %w", failed, ErrSnapshotsFailed)
+	}
+	b.recordLastRun()
+	b.clearDone()
+	return results, nil
+}
+
+// snapshotClusterInstances snapshots the members of one cluster, given as a
+// bare identifier or ARN, writer first.
+func (b *BackupManager) snapshotClusterInstances(ctx context.Context, clusterID string) []SnapshotResult {
+	clusterIdentifer, err := parseClusterIdentifier(clusterID)
+	if err != nil {
+		return []SnapshotResult{{ClusterIdentifier: clusterID, Status: StatusFailed, Err: err}}
+	}
+	if b.done[clusterIdentifer] {
+		b.logf("Not backing up instances of '%s', it's already done according to the state.", clusterIdentifer)
+		return []SnapshotResult{{ClusterIdentifier: clusterIdentifer, Status: StatusSkippedDone}}
+	}
+	// the precheck already logged these
+	if b.missing[clusterIdentifer] {
+		return []SnapshotResult{b.notFound(SnapshotResult{ClusterIdentifier: clusterIdentifer})}
+	}
+
+	out, err := b.cd.DescribeDBClusters(ctx, &rds.DescribeDBClustersInput{
+		DBClusterIdentifier: aws.String(clusterIdentifer),
+	})
+	var cnfErr *types.DBClusterNotFoundFault
+	if errors.As(err, &cnfErr) || (err == nil && len(out.DBClusters) == 0) {
+		b.logf("Not backing up instances of '%s', cluster not found.", clusterIdentifer)
+		return []SnapshotResult{b.notFound(SnapshotResult{ClusterIdentifier: clusterIdentifer})}
+	}
+	if err != nil {
+		return []SnapshotResult{{ClusterIdentifier: clusterIdentifer, Status: StatusFailed, Err: err}}
+	}
+
+	members := snapshotMembers(out.DBClusters[0].DBClusterMembers, b.IncludeReaders)
+	results := make([]SnapshotResult, 0, len(members))
+	for _, instanceIdentifier := range members {
+		results = append(results, b.snapshotInstance(ctx, clusterIdentifer, instanceIdentifier))
+	}
+	// the cluster's only done once all of its instances are
+	for _, result := range results {
+		if result.Status == StatusFailed {
+			return results
+		}
+	}
+	b.markDone(clusterIdentifer)
+	return results
+}
+
+// snapshotMembers picks the instances to snapshot out of a cluster's
+// members: the writer first, then the readers in order if they're wanted.
+func snapshotMembers(members []types.DBClusterMember, includeReaders bool) []string {
+	instances := make([]string, 0, len(members))
+	for _, member := range members {
+		if member.IsClusterWriter {
+			instances = append(instances, aws.ToString(member.DBInstanceIdentifier))
+		}
+	}
+	if !includeReaders {
+		return instances
+	}
+	for _, member := range members {
+		if !member.IsClusterWriter {
+			instances = append(instances, aws.ToString(member.DBInstanceIdentifier))
+		}
+	}
+	return instances
+}
+
+func (b *BackupManager) snapshotInstance(ctx context.Context, clusterIdentifier, instanceIdentifier string) SnapshotResult {
+	snapshotName := b.formSnapshotIdentifier(instanceIdentifier)
+	result := SnapshotResult{
+		ClusterIdentifier:  clusterIdentifier,
+		InstanceIdentifier: instanceIdentifier,
+		SnapshotIdentifier: snapshotName,
+	}
+
+	if b.SkipIfRecentWithin > 0 {
+		snapshots, err := b.describeOwnInstanceSnapshots(ctx, instanceIdentifier)
+		if err != nil {
+			result.Status = StatusFailed
+			result.Err = err
+			return result
+		}
+		if b.skipIfRecent(&result, instanceIdentifier, snapshots) {
+			return result
+		}
+	}
+
+	out, err := b.createInstanceSnapshot(ctx, clusterIdentifier, &rds.CreateDBSnapshotInput{
+		DBInstanceIdentifier: aws.String(instanceIdentifier),
+		DBSnapshotIdentifier: aws.String(snapshotName),
+		Tags:                 b.snapshotTags(),
+	})
+	if err != nil {
+		var infErr *types.DBInstanceNotFoundFault
+		if errors.As(err, &infErr) {
+			b.logf("Not backing up '%s', instance not found.", instanceIdentifier)
+			result.Status = StatusSkippedNotFound
+			return result
+		}
+		result.Status = StatusFailed
+		result.Err = err
+		return result
+	}
+
+	result.Status = StatusCreated
+	if out.DBSnapshot != nil {
+		result.SnapshotArn = aws.ToString(out.DBSnapshot.DBSnapshotArn)
+	}
+	return result
+}
+
+// describeOwnInstanceSnapshots returns the manual snapshots of an instance
+// that were created by this tool, recognized just like cluster snapshots.
+// They're returned as cluster snapshots, with only what that takes filled
+// in, so they can be picked over the same way.
+func (b *BackupManager) describeOwnInstanceSnapshots(ctx context.Context, instanceIdentifier string) ([]types.DBClusterSnapshot, error) {
+	if b.SelectByTagsOnly && len(b.tagSelector()) == 0 {
+		return nil, ErrEmptyTagSelector
+	}
+
+	paginator := rds.NewDescribeDBSnapshotsPaginator(b.isd, &rds.DescribeDBSnapshotsInput{
+		DBInstanceIdentifier: aws.String(instanceIdentifier),
+		SnapshotType:         aws.String("manual"),
+	})
+	snapshots := make([]types.DBClusterSnapshot, 0)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, instanceSnapshot := range page.DBSnapshots {
+			snapshot := types.DBClusterSnapshot{
+				DBClusterSnapshotIdentifier: instanceSnapshot.DBSnapshotIdentifier,
+				DBClusterSnapshotArn:        instanceSnapshot.DBSnapshotArn,
+				SnapshotCreateTime:          instanceSnapshot.SnapshotCreateTime,
+				Status:                      instanceSnapshot.Status,
+				TagList:                     instanceSnapshot.TagList,
+			}
+			own, err := b.isOwnSnapshot(ctx, snapshot)
+			if err != nil {
+				return nil, err
+			}
+			if own {
+				snapshots = append(snapshots, snapshot)
+			}
+		}
+	}
+	return snapshots, nil
+}
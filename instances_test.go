@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func clusterWithMembers(clusterID, writer string, readers ...string) types.DBCluster {
+	cluster := existingCluster(clusterID)
+	// readers first, to make sure the writer is still snapshotted first
+	for _, reader := range readers {
+		cluster.DBClusterMembers = append(cluster.DBClusterMembers, types.DBClusterMember{DBInstanceIdentifier: aws.String(reader)})
+	}
+	cluster.DBClusterMembers = append(cluster.DBClusterMembers, types.DBClusterMember{DBInstanceIdentifier: aws.String(writer), IsClusterWriter: true})
+	return cluster
+}
+
+func TestTriggerInstanceSnapshots(t *testing.T) {
+	type testCase struct {
+		includeReaders  bool
+		expectedJournal []instanceSnapshotRecord
+		expectedResults []SnapshotResult
+	}
+
+	testCases := map[string]testCase{
+		"writers only": {
+			expectedJournal: []instanceSnapshotRecord{
+				{"my-cluster-1-writer", "testing-my-cluster-1-writer"},
+				{"my-cluster-2-writer", "testing-my-cluster-2-writer"},
+			},
+			expectedResults: []SnapshotResult{
+				{
+					ClusterIdentifier:  "my-cluster-1",
+					InstanceIdentifier: "my-cluster-1-writer",
+					SnapshotIdentifier: "testing-my-cluster-1-writer",
+					SnapshotArn:        "arn:aws:rds:us-east-1:123456789012:snapshot:testing-my-cluster-1-writer",
+					Status:             StatusCreated,
+				},
+				{ClusterIdentifier: "gone-cluster", Status: StatusSkippedNotFound},
+				{
+					ClusterIdentifier:  "my-cluster-2",
+					InstanceIdentifier: "my-cluster-2-writer",
+					SnapshotIdentifier: "testing-my-cluster-2-writer",
+					SnapshotArn:        "arn:aws:rds:us-east-1:123456789012:snapshot:testing-my-cluster-2-writer",
+					Status:             StatusCreated,
+				},
+			},
+		},
+		"with readers": {
+			includeReaders: true,
+			expectedJournal: []instanceSnapshotRecord{
+				{"my-cluster-1-writer", "testing-my-cluster-1-writer"},
+				{"my-cluster-1-reader-1", "testing-my-cluster-1-reader-1"},
+				{"my-cluster-1-reader-2", "testing-my-cluster-1-reader-2"},
+				{"my-cluster-2-writer", "testing-my-cluster-2-writer"},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			st := NewFakeSnapshotTaker()
+			st.clusters = []types.DBCluster{
+				clusterWithMembers("my-cluster-1", "my-cluster-1-writer", "my-cluster-1-reader-1", "my-cluster-1-reader-2"),
+				clusterWithMembers("my-cluster-2", "my-cluster-2-writer"),
+			}
+			bm := NewBackupManager(st, WithPrefix("testing"), WithIncludeReaders(tc.includeReaders))
+
+			results, err := bm.TriggerInstanceSnapshots(context.TODO(), "my-cluster-1", "gone-cluster", "arn:aws:rds:us-east-1:123456789012:cluster:my-cluster-2")
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedJournal, st.instanceJournal)
+			if tc.expectedResults != nil {
+				assert.Equal(t, tc.expectedResults, results)
+			}
+			assert.Equal(t, int64(len(tc.expectedJournal)), bm.Stats().Created)
+			assert.Equal(t, int64(1), bm.Stats().Skipped)
+			assert.Empty(t, st.GetJournal(), "no cluster snapshots")
+		})
+	}
+}
+
+func TestTriggerInstanceSnapshotsTruncatesNames(t *testing.T) {
+	writer := "my-very-long-writer-instance-name-that-goes-on-and-on-and-on-forever"
+	st := NewFakeSnapshotTaker()
+	st.clusters = []types.DBCluster{clusterWithMembers("my-cluster-1", writer)}
+	bm := NewBackupManager(st, WithPrefix("testing"))
+
+	results, err := bm.TriggerInstanceSnapshots(context.TODO(), "my-cluster-1")
+	assert.Nil(t, err)
+	assert.Equal(t, bm.formSnapshotIdentifier(writer), results[0].SnapshotIdentifier)
+	assert.LessOrEqual(t, len(results[0].SnapshotIdentifier), maxSnapshotIdentifierLen)
+}
+
+func TestTriggerInstanceSnapshotsMissingInterfaces(t *testing.T) {
+	bm := &BackupManager{st: NewFakeSnapshotTaker()}
+	_, err := bm.TriggerInstanceSnapshots(context.TODO(), "my-cluster-1")
+	assert.ErrorIs(t, err, ErrNoInstanceSnapshotTaker)
+
+	st := NewFakeSnapshotTaker()
+	bm = &BackupManager{st: st, ist: st}
+	_, err = bm.TriggerInstanceSnapshots(context.TODO(), "my-cluster-1")
+	assert.ErrorIs(t, err, ErrNoClusterDescriber)
+
+	bm = &BackupManager{st: st, ist: st, cd: st, SkipIfRecentWithin: time.Hour}
+	_, err = bm.TriggerInstanceSnapshots(context.TODO(), "my-cluster-1")
+	assert.ErrorIs(t, err, ErrNoInstanceSnapshotDescriber)
+}
+
+func TestTriggerInstanceSnapshotsSkipsRecent(t *testing.T) {
+	st := NewFakeSnapshotTaker()
+	st.clusters = []types.DBCluster{
+		clusterWithMembers("my-cluster-1", "my-cluster-1-writer"),
+		clusterWithMembers("my-cluster-2", "my-cluster-2-writer"),
+	}
+	st.instanceSnapshots = []types.DBSnapshot{
+		{
+			DBInstanceIdentifier: aws.String("my-cluster-1-writer"),
+			DBSnapshotIdentifier: aws.String("testing-my-cluster-1-writer-recent"),
+			DBSnapshotArn:        aws.String("arn:aws:rds:us-east-1:123456789012:snapshot:testing-my-cluster-1-writer-recent"),
+			SnapshotCreateTime:   aws.Time(testNow.Add(-time.Hour)),
+		},
+		{
+			DBInstanceIdentifier: aws.String("my-cluster-2-writer"),
+			DBSnapshotIdentifier: aws.String("testing-my-cluster-2-writer-old"),
+			SnapshotCreateTime:   aws.Time(testNow.Add(-48 * time.Hour)),
+		},
+		// recent, but not one of ours
+		{
+			DBInstanceIdentifier: aws.String("my-cluster-2-writer"),
+			DBSnapshotIdentifier: aws.String("someone-elses-snapshot"),
+			SnapshotCreateTime:   aws.Time(testNow.Add(-time.Minute)),
+		},
+	}
+	bm := NewBackupManager(st, WithPrefix("testing"), WithSkipIfRecentWithin(24*time.Hour), WithConcurrency(2))
+	bm.now = func() time.Time { return testNow }
+
+	results, err := bm.TriggerInstanceSnapshots(context.TODO(), "my-cluster-1", "my-cluster-2")
+	assert.Nil(t, err)
+	assert.Equal(t, []SnapshotResult{
+		{
+			ClusterIdentifier:  "my-cluster-1",
+			InstanceIdentifier: "my-cluster-1-writer",
+			SnapshotIdentifier: "testing-my-cluster-1-writer-recent",
+			SnapshotArn:        "arn:aws:rds:us-east-1:123456789012:snapshot:testing-my-cluster-1-writer-recent",
+			Status:             StatusSkippedRecent,
+		},
+		{
+			ClusterIdentifier:  "my-cluster-2",
+			InstanceIdentifier: "my-cluster-2-writer",
+			SnapshotIdentifier: "testing-my-cluster-2-writer",
+			SnapshotArn:        "arn:aws:rds:us-east-1:123456789012:snapshot:testing-my-cluster-2-writer",
+			Status:             StatusCreated,
+		},
+	}, results)
+	assert.Equal(t, []instanceSnapshotRecord{{"my-cluster-2-writer", "testing-my-cluster-2-writer"}}, st.instanceJournal)
+}
+
+// throttledInstanceTaker throttles the first few instance snapshots, and
+// keeps the tags each one asked for.
+type throttledInstanceTaker struct {
+	*fakeSnapshotTaker
+	throttles int
+	tags      map[string][]types.Tag
+}
+
+func (f *throttledInstanceTaker) CreateDBSnapshot(ctx context.Context, in *rds.CreateDBSnapshotInput, optFns ...func(*rds.Options)) (*rds.CreateDBSnapshotOutput, error) {
+	f.mu.Lock()
+	if f.throttles > 0 {
+		f.throttles--
+		f.mu.Unlock()
+		return nil, &smithy.GenericAPIError{Code: "Throttling", Message: "Rate exceeded"}
+	}
+	f.tags[*in.DBSnapshotIdentifier] = in.Tags
+	f.mu.Unlock()
+	return f.fakeSnapshotTaker.CreateDBSnapshot(ctx, in, optFns...)
+}
+
+func TestTriggerInstanceSnapshotsRunLikeClusterSnapshots(t *testing.T) {
+	st := NewFakeSnapshotTaker()
+	st.clusters = []types.DBCluster{clusterWithMembers("my-cluster-1", "my-cluster-1-writer")}
+	taker := &throttledInstanceTaker{fakeSnapshotTaker: st, throttles: 1, tags: make(map[string][]types.Tag)}
+	bm := NewBackupManager(st, WithPrefix("testing"))
+	bm.ist = taker
+	bm.sleep = noSleep
+
+	results, err := bm.TriggerInstanceSnapshots(context.TODO(), "my-cluster-1")
+	assert.Nil(t, err)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, StatusCreated, results[0].Status)
+	}
+	assert.Equal(t, int64(1), bm.Stats().Retried)
+
+	assert.NotEmpty(t, bm.RunID)
+	assert.Contains(t, taker.tags["testing-my-cluster-1-writer"], types.Tag{Key: aws.String("run-id"), Value: aws.String(bm.RunID)})
+}
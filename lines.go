@@ -0,0 +1,24 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// readLines reads a list kept one entry to a line, like a file of regions.
+// Anything from a # on is a comment, and lines left blank are skipped.
+func readLines(r io.Reader) ([]string, error) {
+	lines := make([]string, 0)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
@@ -0,0 +1,14 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadLines(t *testing.T) {
+	lines, err := readLines(strings.NewReader("first\n# a comment\n\n  second  \nthird # and why\n#\nlast"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"first", "second", "third", "last"}, lines)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// consoleTimeFormat matches the standard logger's date and time flags.
+const consoleTimeFormat = "2006/01/02 15:04:05 "
+
+// logEntry is one line of a JSON log file. The field names are the ones log
+// shippers are used to from Go's structured loggers.
+type logEntry struct {
+	Time  string `json:"time"`
+	Level string `json:"level"`
+	Msg   string `json:"msg"`
+}
+
+// jsonTee takes each message from a logger with no flags set and writes it
+// to the console as the standard logger would, and to a file as a line of
+// JSON. A log.Logger makes one Write per message, so that's what a message
+// is.
+type jsonTee struct {
+	mu      sync.Mutex
+	console io.Writer
+	file    io.Writer
+	now     func() time.Time
+}
+
+func newJSONTee(console, file io.Writer, now func() time.Time) *jsonTee {
+	return &jsonTee{console: console, file: file, now: now}
+}
+
+func (t *jsonTee) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	msg := strings.TrimSuffix(string(p), "\n")
+	if _, err := io.WriteString(t.console, now.Format(consoleTimeFormat)+msg+"\n"); err != nil {
+		return 0, err
+	}
+
+	line, err := json.Marshal(logEntry{Time: now.Format(time.RFC3339Nano), Level: "INFO", Msg: msg})
+	if err != nil {
+		return 0, err
+	}
+	if _, err := t.file.Write(append(line, '\n')); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// teeLogsTo sends the standard logger to stderr and, as JSON lines, to the
+// file at path, which is appended to. The returned function puts the logger
+// back and closes the file.
+func teeLogsTo(path string) (func() error, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	output, flags := log.Writer(), log.Flags()
+	log.SetFlags(0)
+	log.SetOutput(newJSONTee(os.Stderr, f, time.Now))
+	return func() error {
+		log.SetOutput(output)
+		log.SetFlags(flags)
+		return f.Close()
+	}, nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONTee(t *testing.T) {
+	var console, file bytes.Buffer
+	logger := log.New(newJSONTee(&console, &file, func() time.Time { return testNow }), "", 0)
+
+	logger.Printf("Starting run '%s' for %d cluster(s).", "abc123", 2)
+	logger.Printf("Failed to back up 'my-cluster-2', continuing: \"quoted\"\nand a second line")
+
+	assert.Equal(t, ""+
+		"2022/03/15 12:00:00 Starting run 'abc123' for 2 cluster(s).\n"+
+		"2022/03/15 12:00:00 Failed to back up 'my-cluster-2', continuing: \"quoted\"\nand a second line\n",
+		console.String())
+
+	lines := strings.Split(strings.TrimSuffix(file.String(), "\n"), "\n")
+	if assert.Len(t, lines, 2) {
+		var entry logEntry
+		assert.Nil(t, json.Unmarshal([]byte(lines[1]), &entry))
+		assert.Equal(t, logEntry{
+			Time:  "2022-03-15T12:00:00Z",
+			Level: "INFO",
+			Msg:   "Failed to back up 'my-cluster-2', continuing: \"quoted\"\nand a second line",
+		}, entry)
+	}
+}
+
+func TestTeeLogsTo(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backup.log")
+	closeLog, err := teeLogsTo(path)
+	assert.Nil(t, err)
+	log.Print("hello")
+	assert.Nil(t, closeLog())
+
+	// the logger's back to how it was, and the file has the message
+	assert.Equal(t, log.LstdFlags, log.Flags())
+	contents, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Contains(t, string(contents), `"msg":"hello"`)
+}
@@ -2,23 +2,390 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
 	"time"
+	"unicode/utf8"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/rds"
 	"github.com/aws/aws-sdk-go-v2/service/rds/types"
 )
 
 // BackupManager
 type BackupManager struct {
+	// the atomic counters come first so they're 64-bit aligned on 32-bit
+	// platforms, which sync/atomic needs
+	stats       runCounters
+	retriesLeft int64
+
 	st     SnapshotTaker
+	ist    InstanceSnapshotTaker
+	isd    InstanceSnapshotDescriber
+	sd     SnapshotDescriber
+	del    SnapshotDeleter
+	cp     SnapshotCopier
+	cd     ClusterDescriber
+	gcd    GlobalClusterDescriber
+	tl     TagLister
+	ta     TagAdder
+	rs     ClusterRestorer
+	ic     InstanceCreator
+	cs     ClusterStarter
 	prefix string
+	logger *log.Logger
+	now    func() time.Time
+	sleep  func(context.Context, time.Duration) error
+
+	// prefixSet tells an explicitly empty prefix from one that was never
+	// set. Either way, a run falls back to defaultPrefix, the default prefix
+	// for the day it started.
+	prefixSet     bool
+	defaultPrefix string
+
+	// apiCalls counts the RDS calls made against the APICallBudget. Managers
+	// made for the same run of the tool share one.
+	apiCalls *int64
+
+	// eventMu keeps lines written to the EventSink whole, and sinkBroken
+	// stops a run writing to one that's failed.
+	eventMu    sync.Mutex
+	sinkBroken bool
+
+	// sizes tracks the background lookups of new snapshots' sizes for
+	// Metrics. It's made on first use, unless it's shared between managers.
+	sizes *sync.WaitGroup
+
+	mu       sync.Mutex
+	clusters map[string]ClusterInfo
+
+	// done is the clusters State says are already snapshotted, read at the
+	// start of each run
+	done map[string]bool
+
+	// missing is the clusters PrecheckClusters found don't exist
+	missing map[string]bool
+
+	// stopped is the clusters PrecheckClusters found stopped
+	stopped map[string]bool
+
+	// startedAt is when the current run started, which snapshot names
+	// from a PrefixTag carry
+	startedAt time.Time
+
+	// discoveredAt is when DiscoverClusters last ran, which is what a
+	// complete run records for SinceLastRun
+	discoveredAt time.Time
+
+	// renamed and collisions are the clusters whose snapshot names collided
+	// with another's in this run: the name each was given instead, or the
+	// cluster each collided with
+	renamed    map[string]string
+	collisions map[string]string
+
+	// ReadPrefixes are matched when listing or pruning snapshots, so that
+	// snapshots written under older prefixes are still recognized. When empty,
+	// only prefix is matched.
+	ReadPrefixes []string
+
+	// TagSelector, when set, restricts listing and pruning to snapshots
+	// carrying every one of these tags. SelectByTagsOnly uses it instead of
+	// the read prefixes rather than as well as them.
+	TagSelector      map[string]string
+	SelectByTagsOnly bool
+
+	// TreatNotFoundAsFailure counts clusters that don't exist as failures
+	// rather than skipping them, so a stale list of clusters gets noticed.
+	// With ContinueOnError the rest of the batch still goes ahead, and the
+	// run fails at the end with the not-found clusters in its count.
+	TreatNotFoundAsFailure bool
+
+	// StrictIdentifiers makes an empty cluster identifier an error. Otherwise
+	// empty identifiers are ignored with a warning.
+	StrictIdentifiers bool
+
+	// SkipIfRecentWithin skips clusters, or with TriggerInstanceSnapshots,
+	// instances, that already have a snapshot from this tool younger than the
+	// given window. Zero disables the check.
+	SkipIfRecentWithin time.Duration
+
+	// SkipExisting skips clusters whose new snapshot's name is already
+	// taken by one of theirs, as when a run is repeated with the same
+	// prefix, rather than failing them. The existing snapshot is described
+	// so the result has its ARN, which needs a SnapshotDescriber.
+	SkipExisting bool
+
+	// RenameOnConflict gives a snapshot whose name is already taken, as
+	// when a run is repeated within the same second, a fresh name with a
+	// few random hex digits added, and tries again, up to
+	// maxRenameAttempts times. SkipExisting takes precedence.
+	RenameOnConflict bool
+
+	// OnlyIfChanged skips clusters that don't look to have changed since
+	// their newest snapshot from this tool, going by the cluster's latest
+	// restorable time. It's a heuristic, and needs a ClusterDescriber and a
+	// SnapshotDescriber.
+	OnlyIfChanged bool
+
+	// PrecheckClusters looks up every requested cluster in one go at the
+	// start of a run and skips the ones that don't exist, rather than
+	// finding out one failed snapshot at a time. It needs a
+	// ClusterDescriber.
+	PrecheckClusters bool
+
+	// StartStopped starts clusters that discovery or PrecheckClusters found
+	// stopped, waits for them to be available, snapshots them and stops them
+	// again. Without it, they're skipped. It needs a ClusterStarter and a
+	// ClusterDescriber.
+	StartStopped bool
+
+	// MaxRetries is how many times to retry a snapshot when the cluster is in
+	// a transient state. Zero means the default of three, negative disables
+	// retries.
+	MaxRetries int
+
+	// MaxAttempts caps how many times a snapshot is tried in all, counting
+	// the first try. It takes over from MaxRetries when set; zero leaves it
+	// to MaxRetries.
+	MaxAttempts int
+
+	// MaxBackoff caps any single wait between retries, including waits a
+	// throttled response asks for. Zero means the default of five minutes.
+	MaxBackoff time.Duration
+
+	// RetryBudget caps the retries across a whole TriggerSnapshots run, on
+	// top of MaxRetries per cluster, so a bad day can't balloon into
+	// unbounded API calls. Once it's spent, transient errors fail straight
+	// away. Zero means no budget.
+	RetryBudget int
+
+	// APICallBudget caps the RDS calls the manager makes, every kind of call
+	// across every goroutine, discovery and listing included, for a run of
+	// the tool that's promised to stay within so many. Once it's spent,
+	// calls fail with ErrAPIBudgetExceeded without being made. It's spent
+	// over the manager's life, not each run's, and only counts with a
+	// manager from NewBackupManager. Zero means no budget.
+	APICallBudget int
+
+	// OutputOrder is the order TriggerSnapshots returns results in: the order
+	// the clusters were given, which is the default, or sorted by cluster
+	// identifier. Results and events are still streamed as they finish.
+	OutputOrder OutputOrder
+
+	// ContinueOnError records unexpected errors against the cluster and moves
+	// on to the next one, rather than aborting the whole batch.
+	ContinueOnError bool
+
+	// WaitForStable waits for a snapshot that RDS won't delete or copy yet,
+	// because it's still being created, to become available and then tries
+	// again. Without it, that's a SnapshotStateError.
+	WaitForStable bool
+
+	// IncludeReaders snapshots reader instances, as well as the writer, in
+	// TriggerInstanceSnapshots.
+	IncludeReaders bool
+
+	// Concurrency is how many clusters are snapshotted at once. Zero or one
+	// means one at a time, unless there's a WeightBudget.
+	Concurrency int
+
+	// WeightBudget, if set, limits the clusters being snapshotted at once by
+	// their total allocated storage in GiB, so a few big clusters run
+	// together where many small ones would. A cluster bigger than the whole
+	// budget runs on its own. Concurrency still caps the count; without it,
+	// the budget is the only limit.
+	WeightBudget int
+
+	// AutoConcurrency, if set, replaces Concurrency with a limit that adapts
+	// to RDS's: it starts at one cluster at a time and ramps up while no
+	// snapshot is throttled, as far as AutoConcurrency, then halves whenever
+	// one is.
+	AutoConcurrency int
+
+	// ChunkSize, if set, splits a batch into chunks of that many clusters,
+	// each finished before the next is started, with ChunkPause in between
+	// to give the account's I/O a chance to settle.
+	ChunkSize  int
+	ChunkPause time.Duration
+
+	// Results, if set, is sent each cluster's result from TriggerSnapshots
+	// as soon as it's finished, in whatever order that is. The sends block,
+	// so something has to be reading; the channel is never closed, and
+	// nothing more is sent once TriggerSnapshots returns.
+	Results chan<- SnapshotResult
+
+	// EventSink, if set, is written a line of JSON for each Event as
+	// TriggerSnapshots and TriggerSnapshot go: when the run starts, as each
+	// cluster finishes and when the run's over, for something like a
+	// sidecar to follow along live. Each line is a single Write.
+	EventSink io.Writer
+
+	// PrefixTag names a cluster tag, like env, whose value, followed by the
+	// time the run started, starts the identifiers of the cluster's snapshots
+	// in place of the prefix. Those are read back as well as the prefix. It's
+	// read in discovery, so it only applies to discovered clusters; the rest,
+	// and clusters without the tag, get the prefix as usual.
+	PrefixTag string
+
+	// Suffix, if set, ends every new snapshot identifier, e.g. "pre-upgrade"
+	// for an ad-hoc backup before a risky change. When the identifier is too
+	// long, the rest is truncated rather than the suffix.
+	Suffix string
+
+	// Separator joins the parts of new snapshot identifiers: the prefix, the
+	// cluster and any suffix. Nil means a hyphen. It's held to the same
+	// characters as Suffix, and can't start or end with a hyphen unless
+	// it's a hyphen. A truncated identifier never ends with the separator;
+	// an empty one is left as it is.
+	Separator *string
+
+	// SanitizeName replaces characters RDS doesn't allow in snapshot
+	// identifiers, like underscores and dots, with hyphens. It's off by
+	// default, so that names are never changed behind anyone's back.
+	SanitizeName bool
+
+	// PreserveCase keeps new snapshot identifiers in the case they were
+	// given in. By default they're lowercased, since that's how RDS stores
+	// them whatever they were created as, and matching read prefixes then
+	// ignores case too.
+	PreserveCase bool
+
+	// MaxIdentifierLen, if set, replaces maxSnapshotIdentifierLen as the
+	// length new snapshot identifiers are cut to, for tests or should RDS
+	// ever change its limit.
+	MaxIdentifierLen int
+
+	// DisambiguateNames gives clusters whose snapshot names come out the
+	// same as an earlier cluster's in the run, usually because long
+	// identifiers were cut short, a name ending in a short hash of the
+	// cluster identifier instead. Without it, those clusters fail.
+	DisambiguateNames bool
+
+	// SequenceNames numbers each new snapshot of a cluster, as
+	// prefix-cluster-001, prefix-cluster-002 and so on, going one past the
+	// cluster's snapshots from this tool. It needs a SnapshotDescriber; if
+	// the snapshots can't be listed, a timestamp takes the number's place.
+	SequenceNames bool
+
+	// DryRun works out what a run would do, skips and all, without creating
+	// any snapshots. The clusters that would be snapshotted are reported as
+	// planned. It doesn't cover instance snapshots.
+	DryRun bool
+
+	// CompareExisting makes a dry run look up each planned cluster's newest
+	// snapshot from this tool. It needs a SnapshotDescriber.
+	CompareExisting bool
+
+	// SourceRegion, if set, is where CopySnapshots copies snapshots from,
+	// into the manager's own region. Snapshots given by identifier are
+	// found by ARN in that region, under AccountID.
+	SourceRegion string
+	AccountID    string
+
+	// SourceDescriber describes snapshots in SourceRegion, so copies from
+	// there can be checked before they're attempted.
+	SourceDescriber SnapshotDescriber
+
+	// Tags are applied to every snapshot created.
+	Tags map[string]string
+
+	// TagAfterCreate creates snapshots untagged and tags them once they
+	// exist, for setups that don't honor tags at creation. It needs to be
+	// able to describe snapshots, to know when they can be tagged.
+	TagAfterCreate bool
+
+	// RunID correlates the snapshots and log lines from one run, and is
+	// applied to every snapshot created as the run-id tag. If it's empty,
+	// TriggerSnapshots generates one and keeps it.
+	RunID string
+
+	// CreatedBy names the tool on every snapshot it creates, as the
+	// created-by tag, so its snapshots can be told apart from anyone else's.
+	// Empty means the program name. CreatedByInName puts it in new snapshot
+	// identifiers too, straight after the prefix.
+	CreatedBy       string
+	CreatedByInName bool
+
+	// OnlyCreatedBy restricts listing and pruning to snapshots whose
+	// created-by tag is CreatedBy, on top of any TagSelector.
+	OnlyCreatedBy bool
+
+	// Catalog, if set, is told about every snapshot created.
+	Catalog Catalog
+
+	// State, if set, remembers the clusters that have been snapshotted, and
+	// they're skipped until a run gets through without failures, when a
+	// DoneClearer forgets them. That makes a crashed run safe to restart.
+	State StateStore
+
+	// SinceLastRun leaves clusters that don't look to have changed since the
+	// last complete run out of discovery, going by their latest restorable
+	// time. State has to be a LastRunStore; a run that discovers its
+	// clusters and snapshots them all records itself there. With no run
+	// recorded yet, everything is discovered.
+	SinceLastRun bool
+
+	// Metrics, if set, is sent the run's stats after each TriggerSnapshots,
+	// and the sizes of the snapshots it created once they're available,
+	// which WaitForSnapshotSizes waits for.
+	Metrics MetricsPublisher
+
+	// MinEngineVersions maps an engine (e.g. "aurora-mysql") to the oldest
+	// version of it that discovery will return. Engines that aren't listed
+	// have no minimum.
+	MinEngineVersions map[string]string
+
+	// AvoidMaintenance leaves clusters out of discovery while they're in
+	// their preferred maintenance window, where snapshots can fail or
+	// conflict.
+	AvoidMaintenance bool
+
+	// ClusterTags, if set, leaves clusters whose tags don't match it out of
+	// discovery.
+	ClusterTags TagExpr
+
+	// OptOutTagKey and OptOutTagValue, if set, make a tag that opts a
+	// cluster out of backups, like backup=false. The value is matched
+	// ignoring case. Opted-out clusters are left out of discovery and, with
+	// RespectOptOut, skipped when they're asked for by name, which takes a
+	// describe call for each one.
+	OptOutTagKey   string
+	OptOutTagValue string
+	RespectOptOut  bool
+
+	// Verbose logs extra detail about what the manager is doing.
+	Verbose bool
+
+	// RedactKeys hides the values of tags whose keys contain any of them,
+	// ignoring case, in verbose logs. It defaults to password, secret and
+	// token.
+	RedactKeys []string
+
+	// Force allows deleting snapshots of clusters with deletion protection,
+	// and lets CancelSnapshot delete snapshots that are already available.
+	Force bool
+
+	// WaitTimeout bounds how long the waiters block, and PollInterval is how
+	// often they check. Zero values use the defaults of 30 minutes and 30
+	// seconds.
+	WaitTimeout  time.Duration
+	PollInterval time.Duration
 }
 
 type SnapshotTaker interface {
@@ -31,58 +398,1289 @@ func (b BackupManagerError) Error() string {
 	return string(b)
 }
 
-const ErrNoIdentifiersSpecified BackupManagerError = "recieved no cluster identifiers"
+const (
+	ErrNoIdentifiersSpecified BackupManagerError = "recieved no cluster identifiers"
+	ErrSnapshotsFailed        BackupManagerError = "failed to snapshot some clusters"
+	ErrEmptyIdentifier        BackupManagerError = "cluster identifier is empty"
+	ErrClusterNotFound        BackupManagerError = "cluster not found"
+	ErrInvalidSuffix          BackupManagerError = "suffix may only contain letters, digits and single hyphens, up to 32 characters"
+	ErrInvalidPrefix          BackupManagerError = "prefix must start with a letter and may only contain letters, digits and single hyphens, up to 32 characters"
+	ErrInvalidSeparator       BackupManagerError = "separator must be a hyphen, or letters, digits and single hyphens, not starting or ending with one, up to 32 characters"
+)
 
-func (b *BackupManager) TriggerSnapshots(clusterIdentifers ...string) error {
-	if len(clusterIdentifers) == 0 {
-		return ErrNoIdentifiersSpecified
+// SnapshotStatus describes what happened to a single cluster during a run.
+type SnapshotStatus string
+
+const (
+	StatusCreated          SnapshotStatus = "created"
+	StatusSkippedNotFound  SnapshotStatus = "skipped-not-found"
+	StatusSkippedRecent    SnapshotStatus = "skipped-recent"
+	StatusSkippedDone      SnapshotStatus = "skipped-done"
+	StatusSkippedUnchanged SnapshotStatus = "skipped-unchanged"
+	StatusSkippedOptOut    SnapshotStatus = "skipped-opt-out"
+	StatusSkippedExisting  SnapshotStatus = "skipped-existing"
+	StatusSkippedStopped   SnapshotStatus = "skipped-stopped"
+	StatusPlanned          SnapshotStatus = "planned"
+	StatusFailed           SnapshotStatus = "failed"
+)
+
+// SnapshotResult records the outcome of snapshotting a single cluster.
+type SnapshotResult struct {
+	ClusterIdentifier  string
+	InstanceIdentifier string // only set for instance snapshots
+	SnapshotIdentifier string
+	SnapshotArn        string
+	Status             SnapshotStatus
+	Err                error
+
+	// Duration is how long creating the snapshot took, retries and all. It's
+	// zero for clusters that were skipped before getting that far.
+	Duration time.Duration
+
+	// ExistingSnapshotIdentifier and ExistingSnapshotAge describe the
+	// cluster's newest snapshot from this tool, when a dry run with
+	// CompareExisting found one.
+	ExistingSnapshotIdentifier string
+	ExistingSnapshotAge        time.Duration
+
+	// annotations from discovery, when the cluster was discovered
+	DeletionProtection      bool
+	GlobalClusterIdentifier string
+
+	// Profile is the shared config profile the cluster was backed up with,
+	// when a run covers several.
+	Profile string
+}
+
+// TriggerSnapshots creates a snapshot for each of the given clusters. The
+// returned results cover every cluster that was processed, even when an
+// error cuts the run short, so callers can see what was already created.
+// They're in the order the clusters were given, however many run at once.
+func (b *BackupManager) TriggerSnapshots(ctx context.Context, clusterIdentifers ...string) ([]SnapshotResult, error) {
+	clusterIdentifers, err := b.checkIdentifiers(clusterIdentifers)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.startRun(ctx, clusterIdentifers); err != nil {
+		return nil, err
 	}
 
-	for _, clusterIdentifer := range clusterIdentifers {
-		snapshotName := b.formSnapshotIdentifier(clusterIdentifer)
-		_, err := b.st.CreateDBClusterSnapshot(
-			context.TODO(),
-			&rds.CreateDBClusterSnapshotInput{
-				DBClusterIdentifier:         aws.String(clusterIdentifer),
-				DBClusterSnapshotIdentifier: aws.String(snapshotName),
-			},
-		)
-		if err != nil {
-			var cnfErr *types.DBClusterNotFoundFault
-			if errors.As(err, &cnfErr) {
-				log.Printf("Not backing up '%s', cluster not found.", clusterIdentifer)
-				continue
+	workers := b.workerCount(len(clusterIdentifers))
+	var auto *aimd
+	if b.AutoConcurrency > 0 {
+		auto = newAIMD(b.AutoConcurrency)
+	}
+	weights := b.clusterWeights(ctx, clusterIdentifers)
+
+	// A failure without ContinueOnError cancels the batch so no new clusters
+	// are started. Anything already in flight is allowed to finish.
+	batchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		firstErr  error
+		failed    int
+		processed = make([]bool, len(clusterIdentifers))
+		results   = make([]SnapshotResult, len(clusterIdentifers))
+		jobs      = make(chan int)
+		freed     = make(chan int, len(clusterIdentifers))
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if batchCtx.Err() != nil {
+					freed <- weights[i]
+					continue
+				}
+				result := b.snapshotCluster(batchCtx, clusterIdentifers[i])
+				freed <- weights[i]
+				b.stats.record(result.Status)
+				b.emitResult(result)
+
+				mu.Lock()
+				results[i] = result
+				processed[i] = true
+				if result.Status == StatusFailed {
+					if b.ContinueOnError {
+						b.logf("Failed to back up '%s', continuing: %v", clusterIdentifers[i], result.Err)
+						failed++
+					} else if firstErr == nil {
+						firstErr = result.Err
+						cancel()
+					}
+				}
+				mu.Unlock()
+				if b.Results != nil {
+					b.Results <- result
+				}
+			}
+		}()
+	}
+
+	pending := make([]int, len(clusterIdentifers))
+	for i := range pending {
+		pending[i] = i
+	}
+	inFlight, running, chunk := 0, 0, 0
+	var throttles int64
+dispatch:
+	for len(pending) > 0 {
+		// a whole chunk has to finish, then the pause, before the next starts
+		chunkDone := b.ChunkSize > 0 && chunk == b.ChunkSize
+		if chunkDone && running == 0 {
+			b.logf("Pausing for %s after a chunk of %d cluster(s), %d to go.", b.ChunkPause, chunk, len(pending))
+			if err := b.wait(batchCtx, b.ChunkPause); err != nil {
+				break dispatch
+			}
+			chunk = 0
+			continue
+		}
+
+		// with nothing that fits, wait for something to finish
+		next, ok := pickWeighted(pending, weights, inFlight, b.WeightBudget)
+		send := jobs
+		if !ok || chunkDone || auto != nil && running >= auto.limit {
+			send = nil
+		}
+		select {
+		case send <- pending[next]:
+			inFlight += weights[pending[next]]
+			running++
+			chunk++
+			pending = append(pending[:next], pending[next+1:]...)
+		case weight := <-freed:
+			inFlight -= weight
+			running--
+			if auto != nil {
+				var throttled bool
+				throttled, throttles = b.throttledSince(throttles)
+				if auto.finished(throttled) {
+					b.debugf("Snapshotting up to %d cluster(s) at once.", auto.limit)
+				}
 			}
-			return err
+		case <-batchCtx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	b.publishMetrics()
+	b.emitFinished()
+	b.recordSnapshotSizesLater(results)
+
+	// results are kept in input order, whatever order they finished in,
+	// unless they're to be sorted
+	finished := make([]SnapshotResult, 0, len(results))
+	for i, result := range results {
+		if processed[i] {
+			finished = append(finished, result)
+		}
+	}
+	finished = orderResults(finished, b.OutputOrder)
+	b.logTimings(finished)
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return finished, newDeadlineError(clusterIdentifers, processed, results)
+	}
+	if firstErr != nil {
+		return finished, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return finished, err
+	}
+	if failed > 0 {
+		return finished, fmt.Errorf("%d of %d clusters: %w", failed, len(clusterIdentifers), ErrSnapshotsFailed)
+	}
+	b.recordLastRun()
+	b.clearDone()
+	return finished, nil
+}
+
+// workerCount is how many workers a batch of n clusters gets: Concurrency,
+// or AutoConcurrency's most, or with neither, one, or one per cluster for
+// WeightBudget to share out. It's never more than there are clusters, since
+// the rest would sit idle.
+func (b *BackupManager) workerCount(n int) int {
+	workers := b.Concurrency
+	if b.AutoConcurrency > 0 {
+		workers = b.AutoConcurrency
+	} else if workers < 1 && b.WeightBudget > 0 {
+		workers = n
+	} else if workers < 1 {
+		workers = 1
+	}
+	if workers > n {
+		b.debugf("Only starting %d worker(s) for %d cluster(s), not %d.", n, n, workers)
+		workers = n
+	}
+	return workers
+}
+
+// TriggerSnapshot snapshots a single cluster, given as a bare identifier or
+// ARN, and returns its result. It's a run of its own, just like
+// TriggerSnapshots with one cluster, and the error is the result's when the
+// snapshot failed.
+func (b *BackupManager) TriggerSnapshot(ctx context.Context, clusterID string) (SnapshotResult, error) {
+	if _, err := b.checkIdentifiers([]string{clusterID}); err != nil {
+		return SnapshotResult{ClusterIdentifier: clusterID, Status: StatusFailed, Err: err}, err
+	}
+	if err := b.startRun(ctx, []string{clusterID}); err != nil {
+		return SnapshotResult{ClusterIdentifier: clusterID, Status: StatusFailed, Err: err}, err
+	}
+	result := b.snapshotCluster(ctx, clusterID)
+	b.stats.record(result.Status)
+	b.emitResult(result)
+	b.publishMetrics()
+	b.emitFinished()
+	b.recordSnapshotSizesLater([]SnapshotResult{result})
+	return result, result.Err
+}
+
+// checkIdentifiers deals with empty cluster identifiers before they get as
+// far as the API: with StrictIdentifiers they're an error, otherwise they're
+// dropped with a warning. Either way, there has to be at least one left.
+func (b *BackupManager) checkIdentifiers(clusterIdentifers []string) ([]string, error) {
+	if len(clusterIdentifers) == 0 {
+		return nil, ErrNoIdentifiersSpecified
+	}
+
+	nonEmpty := make([]string, 0, len(clusterIdentifers))
+	for i, clusterIdentifer := range clusterIdentifers {
+		if clusterIdentifer != "" {
+			nonEmpty = append(nonEmpty, clusterIdentifer)
+			continue
+		}
+		if b.StrictIdentifiers {
+			return nil, fmt.Errorf("identifier %d: %w", i, ErrEmptyIdentifier)
 		}
+		b.logf("Ignoring empty cluster identifier at %d.", i)
+	}
+	if len(nonEmpty) == 0 {
+		return nil, ErrNoIdentifiersSpecified
+	}
+	return nonEmpty, nil
+}
+
+// startRun checks the manager is set up to take cluster snapshots, then
+// starts the run.
+func (b *BackupManager) startRun(ctx context.Context, clusterIdentifers []string) error {
+	if (b.SkipIfRecentWithin > 0 || b.SkipExisting || b.TagAfterCreate || b.SequenceNames || b.OnlyIfChanged || b.DryRun && b.CompareExisting) && b.sd == nil {
+		return ErrNoSnapshotDescriber
+	}
+	if (b.OnlyIfChanged || b.RespectOptOut && b.OptOutTagKey != "" || b.WeightBudget > 0 || b.StartStopped) && b.cd == nil {
+		return ErrNoClusterDescriber
+	}
+	if b.TagAfterCreate && b.ta == nil {
+		return ErrNoTagAdder
+	}
+	if b.StartStopped && b.cs == nil {
+		return ErrNoClusterStarter
+	}
+	return b.beginRun(ctx, clusterIdentifers)
+}
+
+// beginRun is what every run does first, whatever it snapshots: it checks
+// the naming options, reads the state, runs the precheck and resets
+// everything that's per run, then announces the run.
+func (b *BackupManager) beginRun(ctx context.Context, clusterIdentifers []string) error {
+	if err := validateSuffix(b.Suffix); err != nil {
+		return err
 	}
+	if err := validateSeparator(b.separator()); err != nil {
+		return err
+	}
+	if err := validateOutputOrder(b.OutputOrder); err != nil {
+		return err
+	}
+	b.applyDefaultPrefix()
+	if err := b.loadState(); err != nil {
+		return err
+	}
+	if err := b.precheckClusters(ctx, clusterIdentifers); err != nil {
+		return err
+	}
+
+	b.stats.reset()
+	b.startedAt = b.clock()
+	b.resetRetryBudget()
+	if b.RunID == "" {
+		b.RunID = newRunID()
+	}
+	if b.DryRun {
+		b.logf("Starting dry run '%s' for %d cluster(s).", b.RunID, len(clusterIdentifers))
+	} else {
+		b.logf("Starting run '%s' for %d cluster(s).", b.RunID, len(clusterIdentifers))
+	}
+	b.emitStarted(len(clusterIdentifers))
+	b.findCollisions(clusterIdentifers)
 	return nil
 }
 
+// DeadlineError is returned when the batch context's deadline passes before
+// every cluster is done. Clusters that were in flight when it passed count as
+// pending.
+type DeadlineError struct {
+	Completed []string
+	Pending   []string
+}
+
+func newDeadlineError(clusterIdentifers []string, processed []bool, results []SnapshotResult) *DeadlineError {
+	e := &DeadlineError{Completed: make([]string, 0), Pending: make([]string, 0)}
+	for i, clusterIdentifer := range clusterIdentifers {
+		if processed[i] && !errors.Is(results[i].Err, context.DeadlineExceeded) {
+			e.Completed = append(e.Completed, clusterIdentifer)
+			continue
+		}
+		e.Pending = append(e.Pending, clusterIdentifer)
+	}
+	return e
+}
+
+func (e *DeadlineError) Error() string {
+	return fmt.Sprintf("ran out of time with %d cluster(s) done and %d pending", len(e.Completed), len(e.Pending))
+}
+
+func (e *DeadlineError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// snapshotCluster snapshots one cluster, given as a bare identifier or ARN.
+func (b *BackupManager) snapshotCluster(ctx context.Context, clusterID string) SnapshotResult {
+	clusterIdentifer, err := parseClusterIdentifier(clusterID)
+	if err != nil {
+		return SnapshotResult{ClusterIdentifier: clusterID, Status: StatusFailed, Err: err}
+	}
+
+	prefix, err := b.prefixFor(clusterIdentifer)
+	if err != nil {
+		return SnapshotResult{ClusterIdentifier: clusterIdentifer, Status: StatusFailed, Err: err}
+	}
+	snapshotName := b.snapshotIdentifier(prefix, clusterIdentifer)
+	if other, ok := b.collisions[clusterIdentifer]; ok {
+		err := fmt.Errorf("'%s' and '%s' would both be '%s': %w", other, clusterIdentifer, snapshotName, ErrNameCollision)
+		return SnapshotResult{ClusterIdentifier: clusterIdentifer, SnapshotIdentifier: snapshotName, Status: StatusFailed, Err: err}
+	}
+	if renamed, ok := b.renamed[clusterIdentifer]; ok {
+		snapshotName = renamed
+	}
+	result := SnapshotResult{
+		ClusterIdentifier:  clusterIdentifer,
+		SnapshotIdentifier: snapshotName,
+	}
+	if info, ok := b.annotation(clusterIdentifer); ok {
+		result.DeletionProtection = info.DeletionProtection
+		result.GlobalClusterIdentifier = info.GlobalClusterIdentifier
+	}
+
+	if b.done[clusterIdentifer] {
+		b.logf("Not backing up '%s', it's already done according to the state.", clusterIdentifer)
+		result.Status = StatusSkippedDone
+		return result
+	}
+	// the precheck already logged these
+	if b.missing[clusterIdentifer] {
+		return b.notFound(result)
+	}
+	stopped := b.isStopped(clusterIdentifer)
+	if stopped && !b.StartStopped {
+		b.logf("Not backing up '%s', it's stopped.", clusterIdentifer)
+		result.Status = StatusSkippedStopped
+		return result
+	}
+	if b.RespectOptOut && b.OptOutTagKey != "" {
+		info, err := b.clusterInfo(ctx, clusterIdentifer)
+		if err != nil {
+			result.Status = StatusFailed
+			result.Err = err
+			return result
+		}
+		if info.OptedOut {
+			b.logf("Not backing up '%s', it's opted out with %s=%s.", clusterIdentifer, b.OptOutTagKey, b.OptOutTagValue)
+			result.Status = StatusSkippedOptOut
+			return result
+		}
+	}
+
+	if b.SkipIfRecentWithin > 0 {
+		snapshots, err := b.describeOwnSnapshots(ctx, clusterIdentifer)
+		if err != nil {
+			result.Status = StatusFailed
+			result.Err = err
+			return result
+		}
+		if b.skipIfRecent(&result, clusterIdentifer, snapshots) {
+			return result
+		}
+	}
+
+	if b.OnlyIfChanged {
+		newest, latestRestorable, unchanged, err := b.unchangedSince(ctx, clusterIdentifer)
+		if err != nil {
+			result.Status = StatusFailed
+			result.Err = err
+			return result
+		}
+		if unchanged {
+			b.logf("Not backing up '%s', it doesn't look to have changed since snapshot '%s' (a guess from its latest restorable time, %s).",
+				clusterIdentifer, aws.ToString(newest.DBClusterSnapshotIdentifier), latestRestorable.Format(time.RFC3339))
+			result.Status = StatusSkippedUnchanged
+			result.SnapshotIdentifier = aws.ToString(newest.DBClusterSnapshotIdentifier)
+			result.SnapshotArn = aws.ToString(newest.DBClusterSnapshotArn)
+			return result
+		}
+	}
+
+	if b.SequenceNames {
+		tag := ""
+		if _, ok := b.renamed[clusterIdentifer]; ok {
+			tag = clusterHash(clusterIdentifer)
+		}
+		snapshotName = b.sequencedIdentifier(ctx, prefix, clusterIdentifer, tag)
+		result.SnapshotIdentifier = snapshotName
+	}
+
+	if b.DryRun {
+		return b.planSnapshot(ctx, result)
+	}
+
+	if stopped {
+		if err := b.startCluster(ctx, clusterIdentifer); err != nil {
+			result.Status = StatusFailed
+			result.Err = err
+			return result
+		}
+		defer b.stopClusterAgain(clusterIdentifer)
+	}
+
+	// the tags are worked out now either way, so created-at is when we asked
+	tags := b.snapshotTags()
+	input := &rds.CreateDBClusterSnapshotInput{
+		DBClusterIdentifier:         aws.String(clusterIdentifer),
+		DBClusterSnapshotIdentifier: aws.String(snapshotName),
+		Tags:                        tags,
+	}
+	if b.TagAfterCreate {
+		input.Tags = nil
+	}
+	b.debugf("Creating snapshot: %s", formatCreateInput(input, b.redactKeys()))
+	started := b.clock()
+	out, err := b.createSnapshot(ctx, input)
+	for attempt := 0; b.RenameOnConflict && !b.SkipExisting && isAlreadyExists(err) && attempt < maxRenameAttempts; attempt++ {
+		renamed := b.withConflictTag(snapshotName, conflictTag())
+		b.logf("Snapshot '%s' already exists, calling the snapshot of '%s' '%s' instead.", snapshotName, clusterIdentifer, renamed)
+		snapshotName = renamed
+		result.SnapshotIdentifier = renamed
+		input.DBClusterSnapshotIdentifier = aws.String(renamed)
+		out, err = b.createSnapshot(ctx, input)
+	}
+	result.Duration = b.clock().Sub(started)
+	if err != nil {
+		var cnfErr *types.DBClusterNotFoundFault
+		if errors.As(err, &cnfErr) {
+			b.logf("Not backing up '%s', cluster not found.", clusterIdentifer)
+			return b.notFound(result)
+		}
+		if b.SkipExisting && isAlreadyExists(err) {
+			return b.skipExisting(ctx, result)
+		}
+		if isAccessDenied(err) {
+			err = deniedSnapshot(clusterIdentifer, len(input.Tags) > 0, err)
+			b.logf("Can't back up '%s': %v", clusterIdentifer, err)
+		}
+		result.Status = StatusFailed
+		result.Err = err
+		return result
+	}
+
+	result.Status = StatusCreated
+	if out.DBClusterSnapshot != nil {
+		result.SnapshotArn = aws.ToString(out.DBClusterSnapshot.DBClusterSnapshotArn)
+	}
+	if b.TagAfterCreate {
+		// an untagged snapshot won't be found by tag selectors later, so
+		// this counts as a failure even though the snapshot exists
+		if err := b.tagAfterCreate(ctx, snapshotName, result.SnapshotArn, tags); err != nil {
+			result.Status = StatusFailed
+			result.Err = err
+			return result
+		}
+	}
+	b.markDone(clusterIdentifer)
+
+	// the snapshot exists whether or not the catalog hears about it, so a
+	// catalog failure is worth a warning but not a failed backup
+	if b.Catalog != nil {
+		if err := b.Catalog.Record(ctx, result); err != nil {
+			b.logf("Created snapshot '%s' but couldn't record it in the catalog: %v", snapshotName, err)
+		}
+	}
+	return result
+}
+
+const (
+	// runIDTagKey is the tag that records which run created a snapshot.
+	runIDTagKey = "run-id"
+	// createdAtTagKey records when we asked for a snapshot, which can be well
+	// before AWS gets around to setting SnapshotCreateTime.
+	createdAtTagKey = "created-at"
+	// createdByTagKey records which tool created a snapshot.
+	createdByTagKey = "created-by"
+)
+
+// programName is what CreatedBy falls back to.
+const programName = "example-rds-backup"
+
+func (b *BackupManager) createdBy() string {
+	if b.CreatedBy == "" {
+		return programName
+	}
+	return b.CreatedBy
+}
+
+// newRunID returns a random identifier for a run.
+func newRunID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		// the clock is unique enough if the system can't give us randomness
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(buf)
+}
+
+// skipIfRecent marks result skipped if the newest of snapshots, which were
+// taken of name, is younger than SkipIfRecentWithin, and reports whether it
+// did.
+func (b *BackupManager) skipIfRecent(result *SnapshotResult, name string, snapshots []types.DBClusterSnapshot) bool {
+	newest, age := mostRecentSnapshot(snapshots, b.clock())
+	if newest == nil || age >= b.SkipIfRecentWithin {
+		return false
+	}
+	b.logf("Not backing up '%s', snapshot '%s' is only %s old.", name, aws.ToString(newest.DBClusterSnapshotIdentifier), age.Round(time.Second))
+	result.Status = StatusSkippedRecent
+	result.SnapshotIdentifier = aws.ToString(newest.DBClusterSnapshotIdentifier)
+	result.SnapshotArn = aws.ToString(newest.DBClusterSnapshotArn)
+	return true
+}
+
+// notFound is the result for a cluster that doesn't exist: skipped, or with
+// TreatNotFoundAsFailure, failed.
+func (b *BackupManager) notFound(result SnapshotResult) SnapshotResult {
+	if b.TreatNotFoundAsFailure {
+		result.Status = StatusFailed
+		result.Err = fmt.Errorf("'%s': %w", result.ClusterIdentifier, ErrClusterNotFound)
+		return result
+	}
+	result.Status = StatusSkippedNotFound
+	return result
+}
+
+// snapshotTags converts Tags, plus the run-id, created-at and created-by tags,
+// to the SDK's form, sorted by key so requests are deterministic. Explicit
+// Tags win over the automatic ones.
+func (b *BackupManager) snapshotTags() []types.Tag {
+	all := make(map[string]string, len(b.Tags)+3)
+	all[createdAtTagKey] = b.clock().UTC().Format(time.RFC3339)
+	all[createdByTagKey] = b.createdBy()
+	if b.RunID != "" {
+		all[runIDTagKey] = b.RunID
+	}
+	for key, value := range b.Tags {
+		all[key] = value
+	}
+	return sortedTags(all)
+}
+
+func (b *BackupManager) logf(format string, v ...interface{}) {
+	if b.logger == nil {
+		log.Printf(format, v...)
+		return
+	}
+	b.logger.Printf(format, v...)
+}
+
+func (b *BackupManager) debugf(format string, v ...interface{}) {
+	if b.Verbose {
+		b.logf(format, v...)
+	}
+}
+
+func (b *BackupManager) clock() time.Time {
+	if b.now == nil {
+		return time.Now()
+	}
+	return b.now()
+}
+
 func (b *BackupManager) formSnapshotIdentifier(clusterIdentifer string) (snapshotID string) {
-	snapshotID = strings.Join([]string{b.prefix, clusterIdentifer}, "-")
-	// truncate to 64 characters
-	if len(snapshotID) >= 64 {
-		snapshotID = snapshotID[:64]
+	return b.snapshotIdentifier(b.writePrefix(), clusterIdentifer)
+}
+
+func (b *BackupManager) snapshotIdentifier(prefix, clusterIdentifer string) string {
+	return b.snapshotIdentifierWith(prefix, clusterIdentifer, "")
+}
+
+// snapshotIdentifierWith is snapshotIdentifier with tag, if it's set, going
+// at the end but before the suffix. Like the suffix, it's kept whole.
+func (b *BackupManager) snapshotIdentifierWith(prefix, clusterIdentifer, tag string) (snapshotID string) {
+	sep := b.separator()
+	parts := []string{prefix, clusterIdentifer}
+	if b.CreatedByInName {
+		parts = []string{prefix, b.createdBy(), clusterIdentifer}
+	}
+	snapshotID = strings.Join(parts, sep)
+	if b.SanitizeName {
+		snapshotID = sanitizeIdentifier(snapshotID)
 	}
-	// remove the hyphen
-	snapshotID = strings.TrimSuffix(snapshotID, "-")
-	return
+	snapshotID = b.normalizeCase(snapshotID)
+
+	limit := b.maxIdentifierLen()
+	suffix := strings.Trim(b.Suffix, "-")
+	if tag != "" && suffix != "" {
+		suffix = tag + sep + suffix
+	} else if tag != "" {
+		suffix = tag
+	}
+	suffix = b.normalizeCase(suffix)
+	if suffix == "" {
+		return trimSeparator(cutTo(snapshotID, limit), sep)
+	}
+	// the suffix is there for a reason, so the rest gives way to it
+	return trimSeparator(cutTo(snapshotID, limit-len(suffix)-len(sep)), sep) + sep + suffix
+}
+
+func (b *BackupManager) separator() string {
+	if b.Separator == nil {
+		return "-"
+	}
+	return *b.Separator
 }
 
+// trimSeparator drops any trailing separators, so one that's about to be
+// joined on isn't doubled, unless there's no separator.
+func trimSeparator(s, sep string) string {
+	if sep == "" {
+		return s
+	}
+	for strings.HasSuffix(s, sep) {
+		s = strings.TrimSuffix(s, sep)
+	}
+	return s
+}
+
+// maxSnapshotIdentifierLen is the longest snapshot identifier RDS accepts:
+// 63 characters. Identifiers are cut to it in bytes, which also keeps names
+// with multibyte characters in them below it.
+const maxSnapshotIdentifierLen = 63
+
+// maxIdentifierLen is MaxIdentifierLen if it's set, otherwise the RDS limit.
+func (b *BackupManager) maxIdentifierLen() int {
+	if b.MaxIdentifierLen > 0 {
+		return b.MaxIdentifierLen
+	}
+	return maxSnapshotIdentifierLen
+}
+
+// cutTo cuts s down to n bytes, backing up to a rune boundary so a multibyte
+// character isn't split in half.
+func cutTo(s string, n int) string {
+	if len(s) > n {
+		cut := n
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		s = s[:cut]
+	}
+	return s
+}
+
+// snapshotPrefix starts the name of every snapshot this tool creates.
+const snapshotPrefix = "run"
+
+var (
+	// assumeYes skips the confirmation prompt for destructive operations.
+	assumeYes = flag.Bool("yes", false, "don't prompt for confirmation before destructive operations")
+	olderThan = flag.Duration("older-than", 30*24*time.Hour, "prune snapshots older than this")
+
+	pruneFirst = flag.Bool("prune-first", false, "prune snapshots older than -prune-age before backing up, to make room under the quota")
+	pruneAge   = flag.Duration("prune-age", 30*24*time.Hour, "with -prune-first, prune snapshots older than this")
+
+	continueOnError = flag.Bool("continue-on-error", false, "keep going when a cluster can't be snapshotted")
+	concurrency     = flag.Int("concurrency", 1, "how many clusters to snapshot at once")
+	sinceLastRun    = flag.Bool("since-last-run", false, "with -discover and -state-file, only snapshot clusters that look to have changed since the last complete run")
+	waitForStable   = flag.Bool("wait-for-stable", false, "when a snapshot to delete or copy is still being created, wait for it instead of failing")
+	chunkSize       = flag.Int("chunk-size", 0, "snapshot clusters in chunks of this many, each finished before the next starts")
+	chunkPause      = flag.Duration("chunk-pause", time.Minute, "with -chunk-size, how long to pause between chunks")
+	autoConcurrency = flag.Int("concurrency-auto", 0, "instead of -concurrency, start at one cluster at a time and ramp up to this many while RDS doesn't throttle, backing off when it does")
+	weightBudget    = flag.Int("concurrency-per-cluster-size", 0, "also limit clusters snapshotted at once to this many GiB of allocated storage in total; -concurrency 0 leaves only this limit")
+	tags            = tagFlag{}
+	userAgent       = flag.String("user-agent", defaultUserAgent(), "added to the User-Agent of every RDS call, so AWS can attribute the traffic; \"\" for none")
+	verifyPerms     = flag.Bool("verify-permissions", false, "before doing anything, check the caller is allowed the RDS calls a backup makes")
+	tagsFile        = flag.String("tags-file", "", "JSON file of tags to apply to new snapshots, as {\"key\": \"value\"}; -tag wins over it")
+	catalogTable    = flag.String("catalog-table", "", "DynamoDB table to record created snapshots in")
+	stateFile       = flag.String("state-file", "", "skip clusters listed in this file, and add each one snapshotted, so a failed run can be resumed; cleared once a run has no failures")
+	failIfNone      = flag.Bool("fail-if-none-discovered", false, "with -discover and no clusters named, fail when discovery finds nothing instead of exiting cleanly")
+	notFoundFails   = flag.Bool("fail-not-found", false, "count clusters that don't exist as failures instead of skipping them; with -continue-on-error, the rest still run")
+	skipExisting    = flag.Bool("skip-existing", false, "skip clusters whose snapshot already exists under the new name, reporting its ARN, instead of failing them")
+	renameConflict  = flag.Bool("rename-on-conflict", false, "when the new snapshot's name is already taken, try again under a fresh one instead of failing")
+	avoidMaint      = flag.Bool("avoid-maintenance", false, "don't discover clusters that are in their maintenance window")
+	tagAfterCreate  = flag.Bool("tag-after-create", false, "create snapshots untagged and tag them afterwards")
+	metricsNS       = flag.String("metrics-namespace", "", "CloudWatch namespace to publish run metrics to, and the size of each new snapshot once it's available, which is waited for after the run's been reported")
+	discover        = flag.Bool("discover", false, "snapshot every cluster in the region, as well as any given")
+	verbose         = flag.Bool("verbose", false, "log more detail")
+	redactKeys      = flag.String("redact-keys", strings.Join(defaultRedactKeys, ","), "with -verbose, hide the values of tags whose keys contain any of these, comma-separated")
+	force           = flag.Bool("force", false, "delete snapshots even if their cluster has deletion protection, and cancel snapshots that are already available")
+	selfTest        = flag.Bool("self-test", false, "run against an in-process fake instead of AWS and check the results")
+	selectTags      = tagFlag{}
+	selectTagsOnly  = flag.Bool("select-by-tags-only", false, "list and prune by -select-tag alone, ignoring snapshot prefixes")
+	maxRuntime      = flag.Duration("max-runtime", 0, "give up on the whole run after this long (0 means no limit)")
+	minEngines      = tagFlag{}
+	retryBudget     = flag.Int("retry-budget", 0, "most retries to make across the whole run (0 means no limit)")
+	apiCallBudget   = flag.Int("api-call-budget", 0, "most RDS calls the tool makes, across every region and discovery included, after which the rest fail (0 means no limit); with -schedule, each scheduled run gets its own")
+	sanitizeNames   = flag.Bool("sanitize-names", false, "replace characters RDS doesn't allow in snapshot names with hyphens")
+	preserveCase    = flag.Bool("preserve-case", false, "keep new snapshot names in the case they're given in, rather than lowercasing them as RDS stores them")
+	schedule        = flag.String("schedule", "", "keep running, and back up at each tick of this cron expression, e.g. '0 */6 * * *', until interrupted")
+	matchSubstring  = flag.Bool("match-substring", false, "back up every discovered cluster whose identifier contains one of the given names, ignoring case, failing if a name matches none")
+	profiles        = flag.String("profiles", "", "discover and back up clusters with each of these comma-separated shared config profiles, all at once; the catalog and metrics still use the default one, and more than one can't be used with -state-file")
+	regionFromARN   = flag.Bool("region-from-cluster-arn", false, "snapshot clusters given as ARNs in the region each ARN names")
+	regionsFile     = flag.String("regions-file", "", "discover and snapshot clusters in each region listed in this file, one to a line, with # for comments; clusters given as ARNs go to the region they name")
+	perRegion       = flag.Int("max-concurrent-per-region", 0, "with -region-from-cluster-arn, -regions-file or -global-cluster, how many clusters to snapshot at once in each region (replaces -concurrency)")
+	globalCluster   = flag.String("global-cluster", "", "snapshot every member of this global database, each in its own region")
+	junitReport     = flag.String("junit-report", "", "write a JUnit XML report of the backup to this file")
+	manifestPath    = flag.String("manifest", "", "write a JSON manifest of the snapshots created to this file")
+	instanceClass   = flag.String("instance-class", "", "with restore, the class of instances to add to the new cluster")
+	restoreCount    = flag.Int("restore-instances", 0, "with restore, how many instances to add to the new cluster")
+	kmsKeyID        = flag.String("kms-key-id", "", "KMS key to encrypt snapshot copies with")
+	sourceRegion    = flag.String("source-region", "", "with copy, the region to copy snapshots from into this one")
+	accountID       = flag.String("account-id", "", "with -source-region, the account the snapshots are in, unless they're given as ARNs")
+	instances       = flag.Bool("instances", false, "snapshot each cluster's writer instance instead of the cluster")
+	includeReaders  = flag.Bool("include-readers", false, "with -instances, snapshot reader instances too")
+	onlyIfChanged   = flag.Bool("only-if-changed", false, "skip clusters whose latest restorable time hasn't moved since their newest snapshot (a heuristic)")
+	startStopped    = flag.Bool("start-stopped", false, "start clusters that discovery or -precheck finds stopped, snapshot them and stop them again, instead of skipping them")
+	precheck        = flag.Bool("precheck", false, "look up every cluster first and skip the ones that don't exist")
+	separator       = flag.String("separator", "-", "join the parts of new snapshot names with this: a hyphen, nothing, or letters, digits and single hyphens that don't start or end it")
+	prefixTag       = flag.String("prefix-tag", "", "with -discover, start snapshot names with the value of this cluster tag, e.g. env, and the time, instead of run-<time>")
+	suffix          = flag.String("suffix", "", "end new snapshot names with this, e.g. pre-upgrade")
+	postHook        = flag.String("post-hook", "", "run this command after the backup, with a JSON summary on its stdin; not run with -dry-run")
+	postHookMust    = flag.Bool("post-hook-must-succeed", true, "fail the run if the -post-hook command fails")
+	slackWebhook    = flag.String("slack-webhook", "", "Slack incoming webhook URL to post the run's results to; not posted to with -dry-run")
+	printIDs        = flag.Bool("print-ids", false, "print only the identifiers of created snapshots, or with -dry-run planned ones, to stdout, one per line")
+	showProgress    = flag.Bool("progress", false, "show progress as snapshots finish: a bar when stdout is a terminal, log lines otherwise")
+	createdBy       = flag.String("created-by", programName, "tag new snapshots as created by this")
+	createdByInName = flag.Bool("created-by-in-name", false, "put -created-by in new snapshot names, after the prefix")
+	onlyCreatedBy   = flag.Bool("only-created-by", false, "only list and prune snapshots tagged as created by -created-by")
+	clusterTags     = tagExprFlag{}
+	optOutTag       = optOutFlag{key: "backup", value: "false"}
+	outputOrder     = OrderInput
+	sequenceNames   = flag.Bool("sequence-names", false, "number each cluster's snapshots, as <prefix>-<cluster>-001 and up, instead of relying on the prefix to tell them apart")
+	disambiguate    = flag.Bool("disambiguate-names", true, "give clusters whose snapshot names would collide, once cut to length, a name with a short hash in it")
+	respectOptOut   = flag.Bool("respect-optout", false, "skip named clusters with the -optout-tag too, at a describe call each")
+	dryRun          = flag.Bool("dry-run", false, "show what a backup would create and skip, or what prune would delete and free up, without changing anything")
+	dryRunDiff      = flag.Bool("dry-run-diff", false, "with -dry-run, show each cluster's newest existing snapshot and its age")
+	failThreshold   = flag.Float64("fail-threshold", 0, "with -continue-on-error, only fail the run if more than this percentage of clusters fail")
+	logFile         = flag.String("log-file", "", "also append logs to this file, as JSON lines")
+	eventsFile      = flag.String("events-file", "", "append an event to this file, or a named pipe, as a line of JSON as the run starts, as each cluster finishes and as it ends")
+	runID           = flag.String("run-id", "", "tag new snapshots with this run-id instead of a generated one")
+)
+
 func main() {
-	cfg, err := config.LoadDefaultConfig(context.TODO())
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] cluster-id...\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s [flags] list|prune|list-clusters\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s [flags] copy snapshot-id...\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s [flags] snapshot-and-copy region cluster-id...\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s [flags] restore snapshot-id new-cluster-id\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s [flags] cancel snapshot-id\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s [flags] -tag key=value... retag\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s [flags] -schedule cron cluster-id...\n", os.Args[0])
+		flag.PrintDefaults()
+		fmt.Fprintf(flag.CommandLine.Output(), "\nA backup always ends with a line on stderr for monitoring, like\n  created=12 skipped=2 failed=0 duration=1m3s\n")
+	}
+	flag.Var(tags, "tag", "tag applied to new snapshots, as key=value (repeatable)")
+	flag.Var(selectTags, "select-tag", "only list and prune snapshots with this tag, as key=value (repeatable)")
+	flag.Var(&optOutTag, "optout-tag", "leave clusters with this key=value tag out of discovery, or \"\" for none")
+	flag.Var(&clusterTags, "cluster-tags", "only discover clusters whose tags match this, e.g. 'env=prod AND NOT temporary=true'")
+	flag.Var(minEngines, "min-engine-version", "don't discover clusters of an engine older than this, as engine=version (repeatable)")
+	flag.Var(&outputOrder, "output-order", "order results are reported in: input, as the clusters were given, or sorted, by cluster identifier")
+	flag.Parse()
+	if *failThreshold < 0 || *failThreshold > 100 {
+		fmt.Fprintf(flag.CommandLine.Output(), "-fail-threshold must be a percentage from 0 to 100, not %g\n", *failThreshold)
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *tagsFile != "" {
+		fileTags, err := loadTagsFile(*tagsFile)
+		if err != nil {
+			panic(err)
+		}
+		tags = mergeTags(fileTags, tags)
+	}
+	if err := validateTags(tags); err != nil {
+		panic(err)
+	}
+	if *logFile != "" {
+		closeLog, err := teeLogsTo(*logFile)
+		if err != nil {
+			panic(err)
+		}
+		// deferred calls run as a panic unwinds too, so the file is closed
+		// however main ends
+		defer func() {
+			if err := closeLog(); err != nil {
+				fmt.Fprintf(os.Stderr, "closing log file: %v\n", err)
+			}
+		}()
+	}
+
+	var events *os.File
+	if *eventsFile != "" {
+		f, err := os.OpenFile(*eventsFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			panic(err)
+		}
+		defer f.Close()
+		events = f
+	}
+
+	if *selfTest {
+		if err := runSelfTest(os.Stdout); err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	ctx := context.Background()
+	if *maxRuntime > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *maxRuntime)
+		defer cancel()
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx)
 	if err != nil {
 		panic(err)
 	}
 
-	rdsClient := rds.NewFromConfig(cfg)
-	bm := &BackupManager{
-		st:     rdsClient,
-		prefix: fmt.Sprintf("run-%d", time.Now().Unix()),
+	// every RDS client is one of these, so all of its calls carry the
+	// User-Agent
+	newRDS := func(optFns ...func(*rds.Options)) *rds.Client {
+		return rds.NewFromConfig(cfg, append(optFns, withUserAgent(*userAgent))...)
 	}
 
-	if err := bm.TriggerSnapshots(os.Args[1:]...); err != nil {
+	// every region's manager shares a run ID, so the whole invocation can be
+	// found with one tag
+	id := *runID
+	if id == "" {
+		id = newRunID()
+	}
+	// and the one state file, which each region's manager keeps its own
+	// lines in
+	var state *FileStateStore
+	if *stateFile != "" {
+		state = NewFileStateStore(*stateFile)
+	}
+	// every manager's snapshot sizes are waited for before exiting
+	var sizes sync.WaitGroup
+	// and share the -api-call-budget, which each scheduled run gets afresh
+	calls := new(int64)
+	newManager := func(rdsClient RDSAPI) *BackupManager {
+		bm := NewBackupManager(rdsClient,
+			WithPrefix(fmt.Sprintf("%s-%d", snapshotPrefix, time.Now().Unix())),
+			WithReadPrefixes(snapshotPrefix),
+			WithContinueOnError(*continueOnError),
+			WithConcurrency(*concurrency),
+			WithWeightBudget(*weightBudget),
+			WithAutoConcurrency(*autoConcurrency),
+			WithChunks(*chunkSize, *chunkPause),
+			WithWaitForStable(*waitForStable),
+			WithSinceLastRun(*sinceLastRun),
+			WithTags(tags),
+			WithSkipExisting(*skipExisting),
+			WithRenameOnConflict(*renameConflict),
+			WithTreatNotFoundAsFailure(*notFoundFails),
+			WithVerbose(*verbose),
+			WithRedactKeys(strings.Split(*redactKeys, ",")...),
+			WithForce(*force),
+			WithTagSelector(selectTags, *selectTagsOnly),
+			WithMinEngineVersions(minEngines),
+			WithAvoidMaintenance(*avoidMaint),
+			WithClusterTags(clusterTags.expr),
+			WithOptOutTag(optOutTag.key, optOutTag.value, *respectOptOut),
+			WithDisambiguateNames(*disambiguate),
+			WithSequenceNames(*sequenceNames),
+			WithRunID(id),
+			WithCreatedBy(*createdBy, *createdByInName),
+			WithOnlyCreatedBy(*onlyCreatedBy),
+			WithRetryBudget(*retryBudget),
+			WithAPICallBudget(*apiCallBudget),
+			WithOutputOrder(outputOrder),
+			WithSanitizeName(*sanitizeNames),
+			WithPreserveCase(*preserveCase),
+			WithSuffix(*suffix),
+			WithPrefixTag(*prefixTag),
+			WithSeparator(*separator),
+			WithPrecheckClusters(*precheck),
+			WithStartStopped(*startStopped),
+			WithOnlyIfChanged(*onlyIfChanged),
+			WithTagAfterCreate(*tagAfterCreate),
+			WithIncludeReaders(*includeReaders),
+			WithSourceRegion(*sourceRegion, *accountID),
+			WithDryRun(*dryRun, *dryRunDiff),
+		)
+		if *sourceRegion != "" {
+			bm.SourceDescriber = newRDS(withRegion(*sourceRegion))
+		}
+		if *catalogTable != "" {
+			bm.Catalog = NewDynamoDBCatalog(dynamodb.NewFromConfig(cfg), *catalogTable)
+		}
+		if state != nil {
+			bm.State = state
+		}
+		if events != nil {
+			bm.EventSink = events
+		}
+		if *metricsNS != "" {
+			bm.Metrics = NewCloudWatchMetrics(cloudwatch.NewFromConfig(cfg), *metricsNS)
+			bm.sizes = &sizes
+		}
+		bm.apiCalls = calls
+		return bm
+	}
+	bm := newManager(newRDS())
+	if *verifyPerms {
+		if err := bm.VerifyPermissions(ctx); err != nil {
+			panic(err)
+		}
+	}
+
+	args := flag.Args()
+	var (
+		results []SnapshotResult
+		backup  bool
+		started = time.Now()
+	)
+	switch {
+	case len(args) == 1 && args[0] == "list":
+		err = runList(ctx, bm)
+	case len(args) == 1 && args[0] == "list-clusters":
+		err = runListClusters(ctx, bm)
+	case len(args) == 1 && args[0] == "retag":
+		err = bm.RetagSnapshots(ctx, tags)
+	case len(args) == 1 && args[0] == "prune":
+		err = runPrune(ctx, bm, *olderThan)
+	case len(args) > 1 && args[0] == "copy":
+		_, err = bm.CopySnapshots(ctx, *kmsKeyID, args[1:]...)
+	case len(args) > 1 && args[0] == "snapshot-and-copy":
+		dest := newManager(newRDS(withRegion(args[1])))
+		dest.SourceRegion, dest.AccountID = cfg.Region, *accountID
+		dest.SourceDescriber = newRDS()
+		backup = true
+		results, err = runSnapshotAndCopy(ctx, bm, dest, *kmsKeyID, args[2:], *discover)
+	case len(args) == 2 && args[0] == "cancel":
+		err = bm.CancelSnapshot(ctx, args[1])
+	case len(args) == 3 && args[0] == "restore":
+		_, err = bm.RestoreSnapshot(ctx, args[1], RestoreOptions{
+			ClusterIdentifier: args[2],
+			Instances:         *restoreCount,
+			DBInstanceClass:   *instanceClass,
+		})
+	case *globalCluster != "":
+		backup = true
+		results, err = runGlobalBackup(ctx, bm, *globalCluster, cfg.Region, *perRegion, func(region string) *BackupManager {
+			return newManager(newRDS(withRegion(region)))
+		})
+	case *profiles != "":
+		backup = true
+		results, err = runProfiles(ctx, *profiles, args, func(profile string) (*BackupManager, error) {
+			client, _, err := newProfileClient(ctx, profile, nil, withUserAgent(*userAgent))
+			if err != nil {
+				return nil, err
+			}
+			return newManager(client), nil
+		})
+	case *regionsFile != "":
+		backup = true
+		var regions []string
+		if regions, err = loadRegionsFile(*regionsFile); err != nil {
+			break
+		}
+		results, err = runBackupInRegions(ctx, args, regions, cfg.Region, *perRegion, func(region string) *BackupManager {
+			return newManager(newRDS(withRegion(region)))
+		})
+	case *regionFromARN:
+		backup = true
+		results, err = runBackupByRegion(ctx, args, cfg.Region, *discover, *perRegion, func(region string) *BackupManager {
+			return newManager(newRDS(withRegion(region)))
+		})
+	case *schedule != "":
+		err = runScheduledBackups(ctx, *schedule, args, *discover, func() *BackupManager {
+			calls = new(int64)
+			return newManager(newRDS())
+		})
+	case *instances:
+		backup = true
+		results, err = bm.TriggerInstanceSnapshots(ctx, args...)
+	default:
+		var progress func(int) func()
+		if *showProgress {
+			// stdout is the identifiers' with -print-ids, so no bar there
+			var bar io.Writer
+			if isTerminal(os.Stdout) && !*printIDs {
+				bar = os.Stdout
+			}
+			progress = func(total int) func() { return followProgress(bm, total, bar) }
+		}
+		backup = true
+		clusterIDs, discoverAll := args, *discover
+		if *matchSubstring {
+			discoverAll = false
+			if clusterIDs, err = resolveFragments(ctx, bm, args); err != nil {
+				break
+			}
+		}
+		run := func() ([]SnapshotResult, error) { return runBackup(ctx, bm, clusterIDs, discoverAll, progress) }
+		if *pruneFirst {
+			results, err = runPruneFirst(bm.ContinueOnError, func() error { return runPrune(ctx, bm, *pruneAge) }, run)
+		} else {
+			results, err = run()
+		}
+	}
+	// results from several managers at once, like one per region, are only
+	// in order within each of them
+	results = orderResults(results, outputOrder)
+	// the report matters most when something failed, so write it regardless
+	if *junitReport != "" && results != nil {
+		if reportErr := writeReport(*junitReport, JUnitFormatter{}, results); reportErr != nil && err == nil {
+			err = reportErr
+		}
+	}
+	if *manifestPath != "" && results != nil {
+		f := ManifestFormatter{RunID: id, Region: cfg.Region, Timestamp: time.Now(), Synthetic: *dryRun}
+		if manifestErr := writeReport(*manifestPath, f, results); manifestErr != nil && err == nil {
+			err = manifestErr
+		}
+	}
+	// a dry run didn't do anything for a hook or Slack to hear about
+	if *postHook != "" && results != nil && !*dryRun {
+		if hookErr := runPostHook(shellRunner{}, *postHook, SummaryFormatter{RunID: id}, results); hookErr != nil {
+			if !*postHookMust {
+				log.Printf("Ignoring failed post-hook: %v", hookErr)
+			} else if err == nil {
+				err = hookErr
+			}
+		}
+	}
+	if *slackWebhook != "" && results != nil && !*dryRun {
+		notify(NewSlackNotifier(&http.Client{Timeout: notifyTimeout}, *slackWebhook), id, results)
+	}
+	// logs already go to stderr, so stdout is left to the identifiers
+	if *printIDs && results != nil {
+		if printErr := (IDsFormatter{DryRun: *dryRun}).Format(os.Stdout, results); printErr != nil && err == nil {
+			err = printErr
+		}
+	} else if *dryRun && results != nil {
+		if printErr := (PlanFormatter{}).Format(os.Stdout, results); printErr != nil && err == nil {
+			err = printErr
+		}
+	}
+	// some failures are tolerable in a big enough batch; they've been logged
+	// as they happened, but they're worth repeating in one place
+	if errors.Is(err, ErrSnapshotsFailed) && !overFailThreshold(results, *failThreshold) {
+		failed, percent := failureRate(results)
+		log.Printf("%d of %d cluster(s) failed (%.1f%%), within the -fail-threshold of %g%%:", failed, len(results), percent, *failThreshold)
+		for _, result := range results {
+			if result.Status == StatusFailed {
+				log.Printf("  %s: %v", result.ClusterIdentifier, result.Err)
+			}
+		}
+		err = nil
+	}
+	// nothing matching is a quiet day for a scheduled run, not a failure,
+	// unless it's meant to be
+	if errors.Is(err, ErrNoClustersDiscovered) && !*failIfNone {
+		log.Printf("Nothing to back up: %v.", err)
+		err = nil
+	}
+	// as late as possible, whatever happened, for monitoring to find
+	if backup {
+		fmt.Fprintln(os.Stderr, summaryLine(resultStats(results), time.Since(started)))
+	}
+	sizes.Wait()
+	if err != nil {
 		panic(err)
 	}
 }
+
+func runBackup(ctx context.Context, bm *BackupManager, clusterIDs []string, discover bool, progress func(total int) (stop func())) ([]SnapshotResult, error) {
+	if discover {
+		clusters, err := bm.DiscoverClusters(ctx)
+		if err != nil {
+			return nil, err
+		}
+		clusterIDs = append(clusterIDs, clusterIdentifiers(clusters)...)
+		if len(clusterIDs) == 0 {
+			return nil, ErrNoClustersDiscovered
+		}
+	}
+	if progress != nil {
+		defer progress(len(clusterIDs))()
+	}
+	results, err := bm.TriggerSnapshots(ctx, clusterIDs...)
+	var deadlineErr *DeadlineError
+	if errors.As(err, &deadlineErr) {
+		log.Printf("Completed: %s", strings.Join(deadlineErr.Completed, ", "))
+		log.Printf("Pending: %s", strings.Join(deadlineErr.Pending, ", "))
+	}
+	return results, err
+}
+
+// runScheduledBackups backs up on a cron schedule until the process is
+// interrupted or terminated.
+func runScheduledBackups(ctx context.Context, expr string, clusterIDs []string, discover bool, managerFor func() *BackupManager) error {
+	schedule, err := parseCron(expr)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return runSchedule(ctx, schedule, time.Now, sleepContext, scheduledBackup(clusterIDs, discover, managerFor))
+}
+
+// scheduledBackup is one scheduled run. Each gets a manager of its own from
+// managerFor, so it has its own prefix, run ID and counters rather than
+// reusing the last run's names and spent budgets.
+func scheduledBackup(clusterIDs []string, discover bool, managerFor func() *BackupManager) func(context.Context) ([]SnapshotResult, error) {
+	return func(ctx context.Context) ([]SnapshotResult, error) {
+		bm := managerFor()
+		bm.RunID = newRunID()
+		results, err := runBackup(ctx, bm, clusterIDs, discover, nil)
+		// a quiet tick is nothing to worry about
+		if errors.Is(err, ErrNoClustersDiscovered) {
+			log.Printf("Nothing to back up: %v.", err)
+			err = nil
+		}
+		return results, err
+	}
+}
+
+// runPruneFirst prunes before backing up, to make room under the snapshot
+// quota for what the backup creates. A failed prune stops the backup from
+// starting, unless continueOnError is set, when it's logged and the backup
+// goes ahead regardless.
+func runPruneFirst(continueOnError bool, prune func() error, backup func() ([]SnapshotResult, error)) ([]SnapshotResult, error) {
+	if err := prune(); err != nil {
+		if !continueOnError {
+			return nil, fmt.Errorf("pruning before the backup: %w", err)
+		}
+		log.Printf("Pruning failed, backing up anyway: %v", err)
+	}
+	return backup()
+}
+
+// runSnapshotAndCopy snapshots the clusters and copies each snapshot with
+// dest, returning the snapshots' results for the reports.
+func runSnapshotAndCopy(ctx context.Context, bm, dest *BackupManager, kmsKeyID string, clusterIDs []string, discover bool) ([]SnapshotResult, error) {
+	if discover {
+		clusters, err := bm.DiscoverClusters(ctx)
+		if err != nil {
+			return nil, err
+		}
+		clusterIDs = append(clusterIDs, clusterIdentifiers(clusters)...)
+		if len(clusterIDs) == 0 {
+			return nil, ErrNoClustersDiscovered
+		}
+	}
+	pipelines, err := bm.SnapshotAndCopy(ctx, dest, kmsKeyID, clusterIDs...)
+	results := make([]SnapshotResult, 0, len(pipelines))
+	for _, p := range pipelines {
+		results = append(results, p.Snapshot)
+	}
+	return results, err
+}
+
+func runList(ctx context.Context, bm *BackupManager) error {
+	snapshots, err := bm.ListSnapshots(ctx)
+	if err != nil {
+		return err
+	}
+	printSnapshots(os.Stdout, snapshots)
+	fmt.Fprintln(os.Stdout)
+	now := time.Now()
+	printAgeHistogram(os.Stdout, ageHistogram(snapshotCreationTimes(snapshots, now), now))
+	return nil
+}
+
+// runListClusters shows what -discover would back up, filters and all.
+func runListClusters(ctx context.Context, bm *BackupManager) error {
+	clusters, err := bm.DiscoverClusters(ctx)
+	if err != nil {
+		return err
+	}
+	printClusters(os.Stdout, bm, clusters)
+	return nil
+}
+
+func runPrune(ctx context.Context, bm *BackupManager, olderThan time.Duration) error {
+	candidates, err := bm.PruneCandidates(ctx, olderThan)
+	if err != nil {
+		return err
+	}
+	if len(candidates) == 0 {
+		log.Printf("No snapshots older than %s to prune.", olderThan)
+		return nil
+	}
+
+	printSnapshots(os.Stderr, candidates)
+	// the same as EstimatePruneSavings, without listing everything again
+	log.Printf("Pruning %d snapshot(s) would free up about %d GB.", len(candidates), snapshotStorage(candidates))
+	if bm.DryRun {
+		return nil
+	}
+	if err := confirmDeletion(os.Stdin, os.Stderr, isTerminal(os.Stdin), *assumeYes, len(candidates)); err != nil {
+		return err
+	}
+	_, err = bm.DeleteSnapshots(ctx, candidates...)
+	return err
+}
+
+func printClusters(w io.Writer, bm *BackupManager, clusters []types.DBCluster) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "CLUSTER\tENGINE\tVERSION\tSTATUS\tPROTECTED\tGLOBAL")
+	for _, cluster := range clusters {
+		global := "-"
+		if info, ok := bm.annotation(aws.ToString(cluster.DBClusterIdentifier)); ok && info.GlobalClusterIdentifier != "" {
+			global = info.GlobalClusterIdentifier
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%t\t%s\n",
+			aws.ToString(cluster.DBClusterIdentifier),
+			aws.ToString(cluster.Engine),
+			aws.ToString(cluster.EngineVersion),
+			aws.ToString(cluster.Status),
+			aws.ToBool(cluster.DeletionProtection),
+			global,
+		)
+	}
+	tw.Flush()
+}
+
+func printSnapshots(w io.Writer, snapshots []types.DBClusterSnapshot) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SNAPSHOT\tCLUSTER\tSTATUS\tCREATED")
+	for _, snapshot := range snapshots {
+		created := "-"
+		if createdAt := snapshotCreatedAt(snapshot); createdAt != nil {
+			created = createdAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n",
+			aws.ToString(snapshot.DBClusterSnapshotIdentifier),
+			aws.ToString(snapshot.DBClusterIdentifier),
+			aws.ToString(snapshot.Status),
+			created,
+		)
+	}
+	tw.Flush()
+}
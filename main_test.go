@@ -2,10 +2,21 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+	"unicode/utf8"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/service/rds"
 	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/dishbreak/example-rds-backup/backuptest"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -14,12 +25,40 @@ type snapshotCreationRecord struct {
 	DBClusterSnapshotIdentifier string
 }
 
+type instanceSnapshotRecord struct {
+	DBInstanceIdentifier string
+	DBSnapshotIdentifier string
+}
+
 type fakeSnapshotTaker struct {
-	journal []snapshotCreationRecord
+	mu        sync.Mutex
+	journal   []snapshotCreationRecord
+	tags      map[string][]types.Tag
+	snapshots []types.DBClusterSnapshot
+	deleted   []string
+	copies    []*rds.CopyDBClusterSnapshotInput
+
+	instanceJournal   []instanceSnapshotRecord
+	instanceSnapshots []types.DBSnapshot
+
+	clusters       []types.DBCluster
+	globalClusters []types.GlobalCluster
+	instances      []types.DBInstance
+
+	addedTags map[string][]types.Tag
+	restores  []*rds.RestoreDBClusterFromSnapshotInput
 }
 
+// the fake stands in for all of RDS
+var _ RDSAPI = (*fakeSnapshotTaker)(nil)
+
 func (f *fakeSnapshotTaker) CreateDBClusterSnapshot(ctx context.Context, in *rds.CreateDBClusterSnapshotInput, optFns ...func(*rds.Options)) (*rds.CreateDBClusterSnapshotOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	f.journal = append(f.journal, snapshotCreationRecord{*in.DBClusterIdentifier, *in.DBClusterSnapshotIdentifier})
+	if len(in.Tags) > 0 {
+		f.tags[*in.DBClusterSnapshotIdentifier] = in.Tags
+	}
 	return &rds.CreateDBClusterSnapshotOutput{
 		DBClusterSnapshot: &types.DBClusterSnapshot{
 			DBClusterIdentifier:         in.DBClusterIdentifier,
@@ -28,16 +67,254 @@ func (f *fakeSnapshotTaker) CreateDBClusterSnapshot(ctx context.Context, in *rds
 	}, nil
 }
 
+func (f *fakeSnapshotTaker) CreateDBSnapshot(ctx context.Context, in *rds.CreateDBSnapshotInput, optFns ...func(*rds.Options)) (*rds.CreateDBSnapshotOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, cluster := range f.clusters {
+		for _, member := range cluster.DBClusterMembers {
+			if aws.ToString(member.DBInstanceIdentifier) != *in.DBInstanceIdentifier {
+				continue
+			}
+			f.instanceJournal = append(f.instanceJournal, instanceSnapshotRecord{*in.DBInstanceIdentifier, *in.DBSnapshotIdentifier})
+			return &rds.CreateDBSnapshotOutput{
+				DBSnapshot: &types.DBSnapshot{
+					DBInstanceIdentifier: in.DBInstanceIdentifier,
+					DBSnapshotIdentifier: in.DBSnapshotIdentifier,
+					DBSnapshotArn:        aws.String("arn:aws:rds:us-east-1:123456789012:snapshot:" + *in.DBSnapshotIdentifier),
+				},
+			}, nil
+		}
+	}
+	return nil, &types.DBInstanceNotFoundFault{}
+}
+
+func (f *fakeSnapshotTaker) DescribeDBSnapshots(ctx context.Context, in *rds.DescribeDBSnapshotsInput, optFns ...func(*rds.Options)) (*rds.DescribeDBSnapshotsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := &rds.DescribeDBSnapshotsOutput{}
+	for _, snapshot := range f.instanceSnapshots {
+		if in.DBInstanceIdentifier != nil && *in.DBInstanceIdentifier != aws.ToString(snapshot.DBInstanceIdentifier) {
+			continue
+		}
+		out.DBSnapshots = append(out.DBSnapshots, snapshot)
+	}
+	return out, nil
+}
+
+func (f *fakeSnapshotTaker) DescribeDBClusterSnapshots(ctx context.Context, in *rds.DescribeDBClusterSnapshotsInput, optFns ...func(*rds.Options)) (*rds.DescribeDBClusterSnapshotsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := &rds.DescribeDBClusterSnapshotsOutput{}
+	for _, snapshot := range f.snapshots {
+		if in.DBClusterIdentifier != nil && *in.DBClusterIdentifier != aws.ToString(snapshot.DBClusterIdentifier) {
+			continue
+		}
+		if in.DBClusterSnapshotIdentifier != nil && *in.DBClusterSnapshotIdentifier != aws.ToString(snapshot.DBClusterSnapshotIdentifier) {
+			continue
+		}
+		if !matchesSnapshotFilters(in.Filters, snapshot) {
+			continue
+		}
+		out.DBClusterSnapshots = append(out.DBClusterSnapshots, snapshot)
+	}
+	// like RDS, asking for a specific snapshot that isn't there is an error
+	if in.DBClusterSnapshotIdentifier != nil && len(out.DBClusterSnapshots) == 0 {
+		return nil, &types.DBClusterSnapshotNotFoundFault{}
+	}
+	return out, nil
+}
+
+// matchesSnapshotFilters handles the one describe filter we use,
+// db-cluster-snapshot-id.
+func matchesSnapshotFilters(filters []types.Filter, snapshot types.DBClusterSnapshot) bool {
+	for _, filter := range filters {
+		if aws.ToString(filter.Name) != "db-cluster-snapshot-id" {
+			continue
+		}
+		matched := false
+		for _, value := range filter.Values {
+			if value == aws.ToString(snapshot.DBClusterSnapshotIdentifier) {
+				matched = true
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *fakeSnapshotTaker) DeleteDBClusterSnapshot(ctx context.Context, in *rds.DeleteDBClusterSnapshotInput, optFns ...func(*rds.Options)) (*rds.DeleteDBClusterSnapshotOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, snapshot := range f.snapshots {
+		if aws.ToString(snapshot.DBClusterSnapshotIdentifier) == *in.DBClusterSnapshotIdentifier {
+			f.snapshots = append(f.snapshots[:i], f.snapshots[i+1:]...)
+			f.deleted = append(f.deleted, *in.DBClusterSnapshotIdentifier)
+			return &rds.DeleteDBClusterSnapshotOutput{DBClusterSnapshot: &snapshot}, nil
+		}
+	}
+	return nil, &types.DBClusterSnapshotNotFoundFault{}
+}
+
+func (f *fakeSnapshotTaker) CopyDBClusterSnapshot(ctx context.Context, in *rds.CopyDBClusterSnapshotInput, optFns ...func(*rds.Options)) (*rds.CopyDBClusterSnapshotOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, snapshot := range f.snapshots {
+		source := *in.SourceDBClusterSnapshotIdentifier
+		if aws.ToString(snapshot.DBClusterSnapshotIdentifier) == source || aws.ToString(snapshot.DBClusterSnapshotArn) == source {
+			snapshot.DBClusterSnapshotIdentifier = in.TargetDBClusterSnapshotIdentifier
+			snapshot.DBClusterSnapshotArn = aws.String("arn:aws:rds:us-east-1:123456789012:cluster-snapshot:" + *in.TargetDBClusterSnapshotIdentifier)
+			snapshot.Status = aws.String("copying")
+			f.snapshots = append(f.snapshots, snapshot)
+			f.copies = append(f.copies, in)
+			return &rds.CopyDBClusterSnapshotOutput{DBClusterSnapshot: &snapshot}, nil
+		}
+	}
+	return nil, &types.DBClusterSnapshotNotFoundFault{}
+}
+
+func (f *fakeSnapshotTaker) DescribeDBClusters(ctx context.Context, in *rds.DescribeDBClustersInput, optFns ...func(*rds.Options)) (*rds.DescribeDBClustersOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if in.DBClusterIdentifier == nil {
+		return &rds.DescribeDBClustersOutput{DBClusters: append([]types.DBCluster{}, f.clusters...)}, nil
+	}
+	for _, cluster := range f.clusters {
+		if aws.ToString(cluster.DBClusterIdentifier) == *in.DBClusterIdentifier {
+			return &rds.DescribeDBClustersOutput{DBClusters: []types.DBCluster{cluster}}, nil
+		}
+	}
+	return nil, &types.DBClusterNotFoundFault{}
+}
+
+func (f *fakeSnapshotTaker) DescribeGlobalClusters(ctx context.Context, in *rds.DescribeGlobalClustersInput, optFns ...func(*rds.Options)) (*rds.DescribeGlobalClustersOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return &rds.DescribeGlobalClustersOutput{GlobalClusters: append([]types.GlobalCluster{}, f.globalClusters...)}, nil
+}
+
+func (f *fakeSnapshotTaker) ListTagsForResource(ctx context.Context, in *rds.ListTagsForResourceInput, optFns ...func(*rds.Options)) (*rds.ListTagsForResourceOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, snapshot := range f.snapshots {
+		if aws.ToString(snapshot.DBClusterSnapshotArn) == *in.ResourceName {
+			return &rds.ListTagsForResourceOutput{TagList: snapshot.TagList}, nil
+		}
+	}
+	return nil, &types.DBClusterSnapshotNotFoundFault{}
+}
+
+func (f *fakeSnapshotTaker) AddTagsToResource(ctx context.Context, in *rds.AddTagsToResourceInput, optFns ...func(*rds.Options)) (*rds.AddTagsToResourceOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.addedTags[*in.ResourceName] = append(f.addedTags[*in.ResourceName], in.Tags...)
+	return &rds.AddTagsToResourceOutput{}, nil
+}
+
+// RestoreDBClusterFromSnapshot adds the new cluster, available straight away.
+func (f *fakeSnapshotTaker) RestoreDBClusterFromSnapshot(ctx context.Context, in *rds.RestoreDBClusterFromSnapshotInput, optFns ...func(*rds.Options)) (*rds.RestoreDBClusterFromSnapshotOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	cluster := existingCluster(*in.DBClusterIdentifier)
+	cluster.Engine = in.Engine
+	f.clusters = append(f.clusters, cluster)
+	f.restores = append(f.restores, in)
+	return &rds.RestoreDBClusterFromSnapshotOutput{DBCluster: &cluster}, nil
+}
+
+// CreateDBInstance adds the new instance, available straight away.
+func (f *fakeSnapshotTaker) CreateDBInstance(ctx context.Context, in *rds.CreateDBInstanceInput, optFns ...func(*rds.Options)) (*rds.CreateDBInstanceOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	instance := types.DBInstance{
+		DBInstanceIdentifier: in.DBInstanceIdentifier,
+		DBClusterIdentifier:  in.DBClusterIdentifier,
+		DBInstanceClass:      in.DBInstanceClass,
+		DBInstanceStatus:     aws.String("available"),
+	}
+	f.instances = append(f.instances, instance)
+	return &rds.CreateDBInstanceOutput{DBInstance: &instance}, nil
+}
+
+func (f *fakeSnapshotTaker) DescribeDBInstances(ctx context.Context, in *rds.DescribeDBInstancesInput, optFns ...func(*rds.Options)) (*rds.DescribeDBInstancesOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, instance := range f.instances {
+		if in.DBInstanceIdentifier == nil || *in.DBInstanceIdentifier == aws.ToString(instance.DBInstanceIdentifier) {
+			return &rds.DescribeDBInstancesOutput{DBInstances: []types.DBInstance{instance}}, nil
+		}
+	}
+	return nil, &types.DBInstanceNotFoundFault{}
+}
+
+// StartDBCluster makes a cluster available straight away.
+func (f *fakeSnapshotTaker) StartDBCluster(ctx context.Context, in *rds.StartDBClusterInput, optFns ...func(*rds.Options)) (*rds.StartDBClusterOutput, error) {
+	return &rds.StartDBClusterOutput{}, f.setClusterStatus(*in.DBClusterIdentifier, "available")
+}
+
+// StopDBCluster stops a cluster straight away.
+func (f *fakeSnapshotTaker) StopDBCluster(ctx context.Context, in *rds.StopDBClusterInput, optFns ...func(*rds.Options)) (*rds.StopDBClusterOutput, error) {
+	return &rds.StopDBClusterOutput{}, f.setClusterStatus(*in.DBClusterIdentifier, "stopped")
+}
+
+func (f *fakeSnapshotTaker) setClusterStatus(clusterID, status string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := range f.clusters {
+		if aws.ToString(f.clusters[i].DBClusterIdentifier) == clusterID {
+			f.clusters[i].Status = aws.String(status)
+			return nil
+		}
+	}
+	return &types.DBClusterNotFoundFault{}
+}
+
+// GetJournal returns a copy of the journal, so it's safe to read while
+// snapshots are still being taken.
 func (f *fakeSnapshotTaker) GetJournal() []snapshotCreationRecord {
-	return f.journal
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	journal := make([]snapshotCreationRecord, len(f.journal))
+	copy(journal, f.journal)
+	return journal
 }
 
 func NewFakeSnapshotTaker() *fakeSnapshotTaker {
 	return &fakeSnapshotTaker{
-		journal: make([]snapshotCreationRecord, 0),
+		journal:   make([]snapshotCreationRecord, 0),
+		tags:      make(map[string][]types.Tag),
+		addedTags: make(map[string][]types.Tag),
+	}
+}
+
+func NewFakeSnapshotTakerWithSnapshots(snapshots ...types.DBClusterSnapshot) *fakeSnapshotTaker {
+	f := NewFakeSnapshotTaker()
+	f.snapshots = snapshots
+	return f
+}
+
+func existingSnapshot(clusterID, snapshotID string, created time.Time) types.DBClusterSnapshot {
+	return types.DBClusterSnapshot{
+		DBClusterIdentifier:         aws.String(clusterID),
+		DBClusterSnapshotIdentifier: aws.String(snapshotID),
+		DBClusterSnapshotArn:        aws.String("arn:aws:rds:us-east-1:123456789012:cluster-snapshot:" + snapshotID),
+		SnapshotCreateTime:          aws.Time(created),
+		Status:                      aws.String("available"),
 	}
 }
 
+func existingCluster(clusterID string) types.DBCluster {
+	return types.DBCluster{
+		DBClusterIdentifier: aws.String(clusterID),
+		DBClusterArn:        aws.String("arn:aws:rds:us-east-1:123456789012:cluster:" + clusterID),
+		DeletionProtection:  aws.Bool(false),
+		Status:              aws.String("available"),
+	}
+}
+
+var testNow = time.Date(2022, time.March, 15, 12, 0, 0, 0, time.UTC)
+
 type flakySnapshotTaker struct {
 	*fakeSnapshotTaker
 	offensiveClusterID string
@@ -59,40 +336,238 @@ func (f *flakySnapshotTaker) CreateDBClusterSnapshot(ctx context.Context, in *rd
 	return f.fakeSnapshotTaker.CreateDBClusterSnapshot(ctx, in, optFns...)
 }
 
+// uniqueSnapshotTaker refuses a snapshot identifier it's already created,
+// as RDS does.
+type uniqueSnapshotTaker struct {
+	*fakeSnapshotTaker
+}
+
+func (u *uniqueSnapshotTaker) CreateDBClusterSnapshot(ctx context.Context, in *rds.CreateDBClusterSnapshotInput, optFns ...func(*rds.Options)) (*rds.CreateDBClusterSnapshotOutput, error) {
+	for _, record := range u.GetJournal() {
+		if record.DBClusterSnapshotIdentifier == *in.DBClusterSnapshotIdentifier {
+			return nil, &types.DBClusterSnapshotAlreadyExistsFault{}
+		}
+	}
+	return u.fakeSnapshotTaker.CreateDBClusterSnapshot(ctx, in, optFns...)
+}
+
+// transientSnapshotTaker fails a cluster's first few snapshot attempts, the
+// way a cluster that's mid-modification would.
+type transientSnapshotTaker struct {
+	*fakeSnapshotTaker
+	clusterID string
+	failures  int
+	attempts  int
+}
+
+func NewTransientSnapshotTaker(clusterID string, failures int) *transientSnapshotTaker {
+	return &transientSnapshotTaker{
+		fakeSnapshotTaker: NewFakeSnapshotTaker(),
+		clusterID:         clusterID,
+		failures:          failures,
+	}
+}
+
+func (f *transientSnapshotTaker) CreateDBClusterSnapshot(ctx context.Context, in *rds.CreateDBClusterSnapshotInput, optFns ...func(*rds.Options)) (*rds.CreateDBClusterSnapshotOutput, error) {
+	f.mu.Lock()
+	if *in.DBClusterIdentifier == f.clusterID && f.attempts < f.failures {
+		f.attempts++
+		f.mu.Unlock()
+		return nil, &types.InvalidDBClusterStateFault{}
+	}
+	f.mu.Unlock()
+	return f.fakeSnapshotTaker.CreateDBClusterSnapshot(ctx, in, optFns...)
+}
+
+// stuckSnapshotTaker fails every snapshot attempt of every cluster with a
+// transient error.
+type stuckSnapshotTaker struct {
+	*fakeSnapshotTaker
+	attempts map[string]int
+}
+
+func (f *stuckSnapshotTaker) CreateDBClusterSnapshot(ctx context.Context, in *rds.CreateDBClusterSnapshotInput, optFns ...func(*rds.Options)) (*rds.CreateDBClusterSnapshotOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.attempts[*in.DBClusterIdentifier]++
+	return nil, &types.InvalidDBClusterStateFault{}
+}
+
+func noSleep(context.Context, time.Duration) error {
+	return nil
+}
+
 func TestTriggerSnapshots(t *testing.T) {
 	type testCase struct {
-		clusterIDs      []string
-		st              SnapshotTaker
-		expectedError   error
-		expectedJournal []snapshotCreationRecord
+		clusterIDs         []string
+		st                 SnapshotTaker
+		skipIfRecentWithin time.Duration
+		continueOnError    bool
+		strictIdentifiers  bool
+		expectedError      error
+		expectedJournal    []snapshotCreationRecord
+		expectedResults    []SnapshotResult
+		expectedStats      RunStats
 	}
 
 	unhandledError := &types.DBClusterSnapshotAlreadyExistsFault{}
 	testCases := map[string]testCase{
 		"happy path with no errors": {
-			clusterIDs: []string{"my-cluster-1", "my-cluster-2", "my-cluster-3"},
-			st:         NewFakeSnapshotTaker(),
+			clusterIDs:    []string{"my-cluster-1", "my-cluster-2", "my-cluster-3"},
+			st:            NewFakeSnapshotTaker(),
+			expectedStats: RunStats{Created: 3},
 			expectedJournal: []snapshotCreationRecord{
 				{"my-cluster-1", "testing-my-cluster-1"},
 				{"my-cluster-2", "testing-my-cluster-2"},
 				{"my-cluster-3", "testing-my-cluster-3"},
 			},
+			expectedResults: []SnapshotResult{
+				{ClusterIdentifier: "my-cluster-1", SnapshotIdentifier: "testing-my-cluster-1", Status: StatusCreated},
+				{ClusterIdentifier: "my-cluster-2", SnapshotIdentifier: "testing-my-cluster-2", Status: StatusCreated},
+				{ClusterIdentifier: "my-cluster-3", SnapshotIdentifier: "testing-my-cluster-3", Status: StatusCreated},
+			},
 		},
 		"encounters cluster not found error": {
-			clusterIDs: []string{"my-cluster-1", "my-cluster-2", "my-cluster-3"},
-			st:         NewFlakySnapshotTaker("my-cluster-2", &types.DBClusterNotFoundFault{}),
+			clusterIDs:    []string{"my-cluster-1", "my-cluster-2", "my-cluster-3"},
+			st:            NewFlakySnapshotTaker("my-cluster-2", &types.DBClusterNotFoundFault{}),
+			expectedStats: RunStats{Created: 2, Skipped: 1},
 			expectedJournal: []snapshotCreationRecord{
 				{"my-cluster-1", "testing-my-cluster-1"},
 				{"my-cluster-3", "testing-my-cluster-3"},
 			},
+			expectedResults: []SnapshotResult{
+				{ClusterIdentifier: "my-cluster-1", SnapshotIdentifier: "testing-my-cluster-1", Status: StatusCreated},
+				{ClusterIdentifier: "my-cluster-2", SnapshotIdentifier: "testing-my-cluster-2", Status: StatusSkippedNotFound},
+				{ClusterIdentifier: "my-cluster-3", SnapshotIdentifier: "testing-my-cluster-3", Status: StatusCreated},
+			},
 		},
 		"encounters unexpected error": {
 			clusterIDs:    []string{"my-cluster-1", "my-cluster-2", "my-cluster-3"},
 			st:            NewFlakySnapshotTaker("my-cluster-2", unhandledError),
+			expectedStats: RunStats{Created: 1, Failed: 1},
 			expectedError: unhandledError,
 			expectedJournal: []snapshotCreationRecord{
 				{"my-cluster-1", "testing-my-cluster-1"},
 			},
+			expectedResults: []SnapshotResult{
+				{ClusterIdentifier: "my-cluster-1", SnapshotIdentifier: "testing-my-cluster-1", Status: StatusCreated},
+				{ClusterIdentifier: "my-cluster-2", SnapshotIdentifier: "testing-my-cluster-2", Status: StatusFailed, Err: unhandledError},
+			},
+		},
+		"continues past unexpected error when asked": {
+			clusterIDs:      []string{"my-cluster-1", "my-cluster-2", "my-cluster-3"},
+			st:              NewFlakySnapshotTaker("my-cluster-2", unhandledError),
+			expectedStats:   RunStats{Created: 2, Failed: 1},
+			continueOnError: true,
+			expectedError:   ErrSnapshotsFailed,
+			expectedJournal: []snapshotCreationRecord{
+				{"my-cluster-1", "testing-my-cluster-1"},
+				{"my-cluster-3", "testing-my-cluster-3"},
+			},
+			expectedResults: []SnapshotResult{
+				{ClusterIdentifier: "my-cluster-1", SnapshotIdentifier: "testing-my-cluster-1", Status: StatusCreated},
+				{ClusterIdentifier: "my-cluster-2", SnapshotIdentifier: "testing-my-cluster-2", Status: StatusFailed, Err: unhandledError},
+				{ClusterIdentifier: "my-cluster-3", SnapshotIdentifier: "testing-my-cluster-3", Status: StatusCreated},
+			},
+		},
+		"retries cluster in a transient state": {
+			clusterIDs:    []string{"my-cluster-1", "my-cluster-2"},
+			st:            NewTransientSnapshotTaker("my-cluster-1", 2),
+			expectedStats: RunStats{Created: 2, Retried: 2},
+			expectedJournal: []snapshotCreationRecord{
+				{"my-cluster-1", "testing-my-cluster-1"},
+				{"my-cluster-2", "testing-my-cluster-2"},
+			},
+			expectedResults: []SnapshotResult{
+				{ClusterIdentifier: "my-cluster-1", SnapshotIdentifier: "testing-my-cluster-1", Status: StatusCreated},
+				{ClusterIdentifier: "my-cluster-2", SnapshotIdentifier: "testing-my-cluster-2", Status: StatusCreated},
+			},
+		},
+		"accepts cluster ARNs": {
+			clusterIDs:    []string{"arn:aws:rds:us-east-1:123456789012:cluster:my-cluster-1", "my-cluster-2"},
+			st:            NewFakeSnapshotTaker(),
+			expectedStats: RunStats{Created: 2},
+			expectedJournal: []snapshotCreationRecord{
+				{"my-cluster-1", "testing-my-cluster-1"},
+				{"my-cluster-2", "testing-my-cluster-2"},
+			},
+			expectedResults: []SnapshotResult{
+				{ClusterIdentifier: "my-cluster-1", SnapshotIdentifier: "testing-my-cluster-1", Status: StatusCreated},
+				{ClusterIdentifier: "my-cluster-2", SnapshotIdentifier: "testing-my-cluster-2", Status: StatusCreated},
+			},
+		},
+		"rejects ARNs that aren't clusters": {
+			clusterIDs:      []string{"arn:aws:rds:us-east-1:123456789012:db:my-instance-1", "my-cluster-2"},
+			st:              NewFakeSnapshotTaker(),
+			expectedStats:   RunStats{Failed: 1},
+			expectedError:   ErrNotAClusterARN,
+			expectedJournal: []snapshotCreationRecord{},
+			expectedResults: []SnapshotResult{
+				{
+					ClusterIdentifier: "arn:aws:rds:us-east-1:123456789012:db:my-instance-1",
+					Status:            StatusFailed,
+					Err:               fmt.Errorf("'arn:aws:rds:us-east-1:123456789012:db:my-instance-1': %w", ErrNotAClusterARN),
+				},
+			},
+		},
+		"skips clusters with a recent snapshot": {
+			clusterIDs: []string{"my-cluster-1", "my-cluster-2"},
+			st: NewFakeSnapshotTakerWithSnapshots(
+				existingSnapshot("my-cluster-1", "testing-my-cluster-1-old", testNow.Add(-30*time.Minute)),
+				existingSnapshot("my-cluster-2", "testing-my-cluster-2-old", testNow.Add(-2*time.Hour)),
+			),
+			expectedStats:      RunStats{Created: 1, Skipped: 1},
+			skipIfRecentWithin: time.Hour,
+			expectedJournal: []snapshotCreationRecord{
+				{"my-cluster-2", "testing-my-cluster-2"},
+			},
+			expectedResults: []SnapshotResult{
+				{
+					ClusterIdentifier:  "my-cluster-1",
+					SnapshotIdentifier: "testing-my-cluster-1-old",
+					SnapshotArn:        "arn:aws:rds:us-east-1:123456789012:cluster-snapshot:testing-my-cluster-1-old",
+					Status:             StatusSkippedRecent,
+				},
+				{ClusterIdentifier: "my-cluster-2", SnapshotIdentifier: "testing-my-cluster-2", Status: StatusCreated},
+			},
+		},
+		"ignores recent snapshots from other tools": {
+			clusterIDs: []string{"my-cluster-1"},
+			st: NewFakeSnapshotTakerWithSnapshots(
+				existingSnapshot("my-cluster-1", "someone-else-my-cluster-1", testNow.Add(-time.Minute)),
+			),
+			expectedStats:      RunStats{Created: 1},
+			skipIfRecentWithin: time.Hour,
+			expectedJournal: []snapshotCreationRecord{
+				{"my-cluster-1", "testing-my-cluster-1"},
+			},
+			expectedResults: []SnapshotResult{
+				{ClusterIdentifier: "my-cluster-1", SnapshotIdentifier: "testing-my-cluster-1", Status: StatusCreated},
+			},
+		},
+		"ignores empty identifiers": {
+			clusterIDs: []string{"", "my-cluster"},
+			st:         NewFakeSnapshotTaker(),
+			expectedJournal: []snapshotCreationRecord{
+				{"my-cluster", "testing-my-cluster"},
+			},
+			expectedResults: []SnapshotResult{
+				{ClusterIdentifier: "my-cluster", SnapshotIdentifier: "testing-my-cluster", Status: StatusCreated},
+			},
+			expectedStats: RunStats{Created: 1},
+		},
+		"rejects empty identifiers when strict": {
+			clusterIDs:        []string{"", "my-cluster"},
+			st:                NewFakeSnapshotTaker(),
+			strictIdentifiers: true,
+			expectedError:     ErrEmptyIdentifier,
+			expectedJournal:   []snapshotCreationRecord{},
+		},
+		"only empty identifiers": {
+			clusterIDs:      []string{"", ""},
+			st:              NewFakeSnapshotTaker(),
+			expectedError:   ErrNoIdentifiersSpecified,
+			expectedJournal: []snapshotCreationRecord{},
 		},
 		"no identifiers passed in": {
 			st:              NewFakeSnapshotTaker(),
@@ -104,12 +579,20 @@ func TestTriggerSnapshots(t *testing.T) {
 	for name, tc := range testCases {
 		t.Run(name, func(t *testing.T) {
 			bm := &BackupManager{
-				st:     tc.st,
-				prefix: "testing",
+				st:                 tc.st,
+				prefix:             "testing",
+				now:                func() time.Time { return testNow },
+				sleep:              noSleep,
+				SkipIfRecentWithin: tc.skipIfRecentWithin,
+				ContinueOnError:    tc.continueOnError,
+				StrictIdentifiers:  tc.strictIdentifiers,
 			}
+			bm.sd, _ = tc.st.(SnapshotDescriber)
 
-			err := bm.TriggerSnapshots(tc.clusterIDs...)
-			assert.ErrorIs(t, tc.expectedError, err)
+			results, err := bm.TriggerSnapshots(context.TODO(), tc.clusterIDs...)
+			assert.ErrorIs(t, err, tc.expectedError)
+			assert.Equal(t, tc.expectedResults, results)
+			assert.Equal(t, tc.expectedStats, bm.Stats())
 
 			type journaler interface {
 				GetJournal() []snapshotCreationRecord
@@ -122,20 +605,324 @@ func TestTriggerSnapshots(t *testing.T) {
 	}
 }
 
+func TestTriggerSnapshot(t *testing.T) {
+	type testCase struct {
+		clusterID      string
+		st             SnapshotTaker
+		expectedError  error
+		expectedResult SnapshotResult
+	}
+
+	unhandledError := &types.DBClusterSnapshotAlreadyExistsFault{}
+	testCases := map[string]testCase{
+		"created": {
+			clusterID:      "my-cluster-1",
+			st:             NewFakeSnapshotTaker(),
+			expectedResult: SnapshotResult{ClusterIdentifier: "my-cluster-1", SnapshotIdentifier: "testing-my-cluster-1", Status: StatusCreated},
+		},
+		"from an ARN": {
+			clusterID:      "arn:aws:rds:us-east-1:123456789012:cluster:my-cluster-1",
+			st:             NewFakeSnapshotTaker(),
+			expectedResult: SnapshotResult{ClusterIdentifier: "my-cluster-1", SnapshotIdentifier: "testing-my-cluster-1", Status: StatusCreated},
+		},
+		"skipping isn't an error": {
+			clusterID:      "my-cluster-1",
+			st:             NewFlakySnapshotTaker("my-cluster-1", &types.DBClusterNotFoundFault{}),
+			expectedResult: SnapshotResult{ClusterIdentifier: "my-cluster-1", SnapshotIdentifier: "testing-my-cluster-1", Status: StatusSkippedNotFound},
+		},
+		"failure": {
+			clusterID:      "my-cluster-1",
+			st:             NewFlakySnapshotTaker("my-cluster-1", unhandledError),
+			expectedError:  unhandledError,
+			expectedResult: SnapshotResult{ClusterIdentifier: "my-cluster-1", SnapshotIdentifier: "testing-my-cluster-1", Status: StatusFailed, Err: unhandledError},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			bm := &BackupManager{st: tc.st, prefix: "testing", sleep: noSleep, now: func() time.Time { return testNow }}
+			result, err := bm.TriggerSnapshot(context.TODO(), tc.clusterID)
+			assert.ErrorIs(t, err, tc.expectedError)
+			assert.Equal(t, tc.expectedResult, result)
+		})
+	}
+}
+
+func TestTriggerSnapshotsStrictIdentifierNamesIndex(t *testing.T) {
+	bm := &BackupManager{st: NewFakeSnapshotTaker(), prefix: "testing", StrictIdentifiers: true}
+	_, err := bm.TriggerSnapshots(context.TODO(), "my-cluster-1", "my-cluster-2", "")
+	assert.EqualError(t, err, "identifier 2: cluster identifier is empty")
+}
+
+func TestTriggerSnapshotsPersistentClusterState(t *testing.T) {
+	type testCase struct {
+		continueOnError bool
+		expectedError   error
+		expectedJournal []snapshotCreationRecord
+	}
+
+	testCases := map[string]testCase{
+		"aborts the batch by default": {
+			expectedJournal: []snapshotCreationRecord{
+				{"my-cluster-1", "testing-my-cluster-1"},
+			},
+		},
+		"records a failure under continue on error": {
+			continueOnError: true,
+			expectedError:   ErrSnapshotsFailed,
+			expectedJournal: []snapshotCreationRecord{
+				{"my-cluster-1", "testing-my-cluster-1"},
+				{"my-cluster-3", "testing-my-cluster-3"},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			st := NewTransientSnapshotTaker("my-cluster-2", 100)
+			delays := make([]time.Duration, 0)
+			bm := &BackupManager{
+				st:     st,
+				prefix: "testing",
+				sleep: func(ctx context.Context, d time.Duration) error {
+					delays = append(delays, d)
+					return nil
+				},
+				ContinueOnError: tc.continueOnError,
+			}
+
+			results, err := bm.TriggerSnapshots(context.TODO(), "my-cluster-1", "my-cluster-2", "my-cluster-3")
+			var stateErr *ClusterStateError
+			assert.ErrorAs(t, results[1].Err, &stateErr)
+			assert.Equal(t, 4, stateErr.Attempts)
+			var faultErr *types.InvalidDBClusterStateFault
+			assert.ErrorAs(t, results[1].Err, &faultErr)
+
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+			} else {
+				assert.ErrorAs(t, err, &stateErr)
+			}
+			assert.Equal(t, StatusFailed, results[1].Status)
+			assert.Equal(t, int64(3), bm.Stats().Retried)
+			assert.Equal(t, []time.Duration{2 * time.Second, 4 * time.Second, 8 * time.Second}, delays)
+			assert.Equal(t, tc.expectedJournal, st.GetJournal())
+		})
+	}
+}
+
+func TestTriggerSnapshotsRetryBudget(t *testing.T) {
+	st := &stuckSnapshotTaker{fakeSnapshotTaker: NewFakeSnapshotTaker(), attempts: make(map[string]int)}
+	bm := &BackupManager{
+		st:              st,
+		prefix:          "testing",
+		sleep:           noSleep,
+		ContinueOnError: true,
+		RetryBudget:     4,
+	}
+
+	results, err := bm.TriggerSnapshots(context.TODO(), "my-cluster-1", "my-cluster-2", "my-cluster-3")
+	assert.ErrorIs(t, err, ErrSnapshotsFailed)
+
+	// the first cluster uses its three retries, the second gets the one left
+	// in the budget and the third fails on its first attempt
+	assert.Equal(t, map[string]int{"my-cluster-1": 4, "my-cluster-2": 2, "my-cluster-3": 1}, st.attempts)
+	assert.NotErrorIs(t, results[0].Err, ErrRetryBudgetExhausted)
+	assert.ErrorIs(t, results[1].Err, ErrRetryBudgetExhausted)
+	assert.ErrorIs(t, results[2].Err, ErrRetryBudgetExhausted)
+	var faultErr *types.InvalidDBClusterStateFault
+	assert.ErrorAs(t, results[2].Err, &faultErr)
+	assert.Equal(t, int64(4), bm.Stats().Retried)
+
+	// the budget is per run
+	st.attempts = make(map[string]int)
+	_, err = bm.TriggerSnapshots(context.TODO(), "my-cluster-1")
+	assert.ErrorIs(t, err, ErrSnapshotsFailed)
+	assert.Equal(t, map[string]int{"my-cluster-1": 4}, st.attempts)
+}
+
+func TestTriggerSnapshotsBackoffLimits(t *testing.T) {
+	type testCase struct {
+		maxRetries       int
+		maxAttempts      int
+		maxBackoff       time.Duration
+		expectedAttempts int
+		expectedDelays   []time.Duration
+	}
+
+	testCases := map[string]testCase{
+		"defaults": {
+			expectedAttempts: 4,
+			expectedDelays:   []time.Duration{2 * time.Second, 4 * time.Second, 8 * time.Second},
+		},
+		"attempts cap": {
+			maxAttempts:      2,
+			expectedAttempts: 2,
+			expectedDelays:   []time.Duration{2 * time.Second},
+		},
+		"attempts cap wins over retries": {
+			maxRetries:       5,
+			maxAttempts:      3,
+			expectedAttempts: 3,
+			expectedDelays:   []time.Duration{2 * time.Second, 4 * time.Second},
+		},
+		"backoff cap": {
+			maxAttempts:      6,
+			maxBackoff:       5 * time.Second,
+			expectedAttempts: 6,
+			expectedDelays:   []time.Duration{2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second, 5 * time.Second},
+		},
+		"lots of attempts don't overflow": {
+			maxAttempts:      40,
+			expectedAttempts: 40,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var delays []time.Duration
+			st := &stuckSnapshotTaker{fakeSnapshotTaker: NewFakeSnapshotTaker(), attempts: make(map[string]int)}
+			bm := &BackupManager{
+				st:          st,
+				prefix:      "testing",
+				MaxRetries:  tc.maxRetries,
+				MaxAttempts: tc.maxAttempts,
+				MaxBackoff:  tc.maxBackoff,
+				sleep: func(ctx context.Context, d time.Duration) error {
+					delays = append(delays, d)
+					return nil
+				},
+			}
+
+			_, err := bm.TriggerSnapshots(context.TODO(), "my-cluster-1")
+			var stateErr *ClusterStateError
+			assert.ErrorAs(t, err, &stateErr)
+			assert.Equal(t, tc.expectedAttempts, st.attempts["my-cluster-1"])
+			assert.Equal(t, tc.expectedAttempts, stateErr.Attempts)
+			assert.Len(t, delays, tc.expectedAttempts-1)
+			if tc.expectedDelays != nil {
+				assert.Equal(t, tc.expectedDelays, delays)
+			}
+			for _, delay := range delays {
+				assert.Greater(t, delay, time.Duration(0))
+				assert.LessOrEqual(t, delay, bm.maxBackoff())
+			}
+		})
+	}
+}
+
+// throttlingSnapshotTaker throttles the first snapshot attempt, with the
+// given Retry-After header if it's set.
+type throttlingSnapshotTaker struct {
+	*fakeSnapshotTaker
+	retryAfter string
+	throttled  bool
+}
+
+func (f *throttlingSnapshotTaker) CreateDBClusterSnapshot(ctx context.Context, in *rds.CreateDBClusterSnapshotInput, optFns ...func(*rds.Options)) (*rds.CreateDBClusterSnapshotOutput, error) {
+	if !f.throttled {
+		f.throttled = true
+		header := make(http.Header)
+		if f.retryAfter != "" {
+			header.Set("Retry-After", f.retryAfter)
+		}
+		return nil, &awshttp.ResponseError{
+			ResponseError: &smithyhttp.ResponseError{
+				Response: &smithyhttp.Response{Response: &http.Response{StatusCode: 400, Header: header}},
+				Err:      &smithy.GenericAPIError{Code: "Throttling", Message: "Rate exceeded"},
+			},
+		}
+	}
+	return f.fakeSnapshotTaker.CreateDBClusterSnapshot(ctx, in, optFns...)
+}
+
+func TestTriggerSnapshotsThrottled(t *testing.T) {
+	type testCase struct {
+		retryAfter string
+		minDelay   time.Duration
+		maxDelay   time.Duration
+	}
+
+	testCases := map[string]testCase{
+		"honors the hint in seconds": {
+			retryAfter: "7",
+			minDelay:   7 * time.Second,
+			maxDelay:   7 * time.Second,
+		},
+		"honors the hint as a date": {
+			retryAfter: testNow.Add(time.Minute).Format(http.TimeFormat),
+			minDelay:   time.Minute,
+			maxDelay:   time.Minute,
+		},
+		"caps the hint": {
+			retryAfter: "3600",
+			minDelay:   defaultMaxBackoff,
+			maxDelay:   defaultMaxBackoff,
+		},
+		"jitters without a hint": {
+			minDelay: retryBaseDelay / 2,
+			maxDelay: retryBaseDelay,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var delays []time.Duration
+			st := &throttlingSnapshotTaker{fakeSnapshotTaker: NewFakeSnapshotTaker(), retryAfter: tc.retryAfter}
+			bm := &BackupManager{
+				st:     st,
+				prefix: "testing",
+				now:    func() time.Time { return testNow },
+				sleep: func(ctx context.Context, d time.Duration) error {
+					delays = append(delays, d)
+					return nil
+				},
+			}
+
+			_, err := bm.TriggerSnapshots(context.TODO(), "my-cluster-1")
+			assert.Nil(t, err)
+			assert.Len(t, delays, 1)
+			assert.GreaterOrEqual(t, delays[0], tc.minDelay)
+			assert.LessOrEqual(t, delays[0], tc.maxDelay)
+		})
+	}
+}
+
+func TestTriggerSnapshotsDeadline(t *testing.T) {
+	st := &backuptest.CancellableSnapshotTaker{Delays: map[string]time.Duration{"my-cluster-2": time.Hour}}
+	bm := &BackupManager{st: st, prefix: "testing"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	results, err := bm.TriggerSnapshots(ctx, "my-cluster-1", "my-cluster-2", "my-cluster-3", "my-cluster-4")
+
+	var deadlineErr *DeadlineError
+	assert.ErrorAs(t, err, &deadlineErr)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, []string{"my-cluster-1"}, deadlineErr.Completed)
+	assert.Equal(t, []string{"my-cluster-2", "my-cluster-3", "my-cluster-4"}, deadlineErr.Pending)
+	assert.Len(t, results, 2)
+	assert.Equal(t, []backuptest.Call{
+		{DBClusterIdentifier: "my-cluster-1", DBClusterSnapshotIdentifier: "testing-my-cluster-1"},
+		{DBClusterIdentifier: "my-cluster-2", DBClusterSnapshotIdentifier: "testing-my-cluster-2", Cancelled: true},
+	}, st.Calls())
+}
+
 func TestFormSnapshotIdentifier(t *testing.T) {
 	type testCase struct {
 		input  string
 		result string
 	}
 
+	// RDS allows identifiers of up to 63 characters, not 64
 	testCases := map[string]testCase{
-		"no truncation when less than 64 characters": {
+		"no truncation when less than 63 characters": {
 			input:  "my-cluster-1",
 			result: "testing-my-cluster-1",
 		},
-		"truncates down to 64 characters": {
+		"truncates down to 63 characters": {
 			input:  "my-cluster-1-11111111111111111111111111111111111111111110",
-			result: "testing-my-cluster-1-1111111111111111111111111111111111111111111",
+			result: "testing-my-cluster-1-111111111111111111111111111111111111111111",
 		},
 		"doesn't end with a hyphen": {
 			input:  "my-cluster-1-",
@@ -151,3 +938,164 @@ func TestFormSnapshotIdentifier(t *testing.T) {
 		})
 	}
 }
+
+func TestFormSnapshotIdentifierMaxLen(t *testing.T) {
+	bm := &BackupManager{prefix: "testing", MaxIdentifierLen: 16}
+	assert.Equal(t, "testing-my-clust", bm.formSnapshotIdentifier("my-cluster-1"))
+	assert.Equal(t, "my-cluster-copy", bm.copyIdentifier("my-cluster-1"))
+}
+
+func TestFormSnapshotIdentifierSuffix(t *testing.T) {
+	type testCase struct {
+		input  string
+		suffix string
+		result string
+	}
+
+	testCases := map[string]testCase{
+		"appended with a hyphen": {
+			input:  "my-cluster-1",
+			suffix: "pre-upgrade",
+			result: "testing-my-cluster-1-pre-upgrade",
+		},
+		"surrounding hyphens don't double up": {
+			input:  "my-cluster-1",
+			suffix: "-pre-upgrade-",
+			result: "testing-my-cluster-1-pre-upgrade",
+		},
+		"the cluster is truncated, not the suffix": {
+			input:  "my-cluster-1-11111111111111111111111111111111111111111110",
+			suffix: "pre-upgrade",
+			result: "testing-my-cluster-1-111111111111111111111111111111-pre-upgrade",
+		},
+		"no hyphen left dangling before the suffix": {
+			input:  "my-cluster-1-111111111111111111111111111111-11111111111110",
+			suffix: "pre-upgrade",
+			result: "testing-my-cluster-1-111111111111111111111111111111-pre-upgrade",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			bm := &BackupManager{prefix: "testing", Suffix: tc.suffix}
+			snapshotID := bm.formSnapshotIdentifier(tc.input)
+			assert.Equal(t, tc.result, snapshotID)
+			assert.LessOrEqual(t, len(snapshotID), maxSnapshotIdentifierLen)
+		})
+	}
+}
+
+func TestFormSnapshotIdentifierSeparator(t *testing.T) {
+	type testCase struct {
+		separator string
+		input     string
+		suffix    string
+		result    string
+	}
+
+	testCases := map[string]testCase{
+		"empty separator": {
+			separator: "",
+			input:     "my-cluster-1",
+			result:    "testingmy-cluster-1",
+		},
+		"empty separator with a suffix": {
+			separator: "",
+			input:     "my-cluster-1",
+			suffix:    "pre",
+			result:    "testingmy-cluster-1pre",
+		},
+		"empty separator leaves a trailing hyphen alone": {
+			separator: "",
+			input:     "my-cluster-1-",
+			result:    "testingmy-cluster-1-",
+		},
+		"custom separator": {
+			separator: "x",
+			input:     "my-cluster-1",
+			suffix:    "pre",
+			result:    "testingxmy-cluster-1xpre",
+		},
+		"custom separator is trimmed, not a hyphen": {
+			separator: "x",
+			input:     "my-cluster-1x-",
+			result:    "testingxmy-cluster-1x-",
+		},
+		"custom separators left dangling by truncation": {
+			separator: "x",
+			input:     "my-cluster-1-111111111111111111111111111111111111xx111111111111",
+			suffix:    "pre",
+			result:    "testingxmy-cluster-1-111111111111111111111111111111111111xpre",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			separator := tc.separator
+			bm := &BackupManager{prefix: "testing", Suffix: tc.suffix, Separator: &separator}
+			snapshotID := bm.formSnapshotIdentifier(tc.input)
+			assert.Equal(t, tc.result, snapshotID)
+			assert.LessOrEqual(t, len(snapshotID), maxSnapshotIdentifierLen)
+		})
+	}
+}
+
+func TestTriggerSnapshotsInvalidSeparator(t *testing.T) {
+	st := NewFakeSnapshotTaker()
+	bm := NewBackupManager(st, WithPrefix("testing"), WithSeparator("_"))
+
+	_, err := bm.TriggerSnapshots(context.TODO(), "my-cluster-1")
+	assert.ErrorIs(t, err, ErrInvalidSeparator)
+	assert.Empty(t, st.GetJournal())
+}
+
+func TestTriggerSnapshotsInvalidSuffix(t *testing.T) {
+	st := NewFakeSnapshotTaker()
+	bm := &BackupManager{st: st, prefix: "testing", Suffix: "pre_upgrade"}
+
+	_, err := bm.TriggerSnapshots(context.TODO(), "my-cluster-1")
+	assert.ErrorIs(t, err, ErrInvalidSuffix)
+	assert.Empty(t, st.GetJournal())
+}
+
+func TestFormSnapshotIdentifierMultibytePrefix(t *testing.T) {
+	type testCase struct {
+		prefix string
+		input  string
+		result string
+	}
+
+	testCases := map[string]testCase{
+		"short names are untouched": {
+			prefix: "sauvegarde-été",
+			input:  "my-cluster-1",
+			result: "sauvegarde-été-my-cluster-1",
+		},
+		"doesn't split a rune straddling the limit": {
+			// 62 bytes, then a two byte "é" that would be cut in half
+			prefix: "testing-" + strings.Repeat("1", 54) + "é",
+			input:  "my-cluster-1",
+			result: "testing-" + strings.Repeat("1", 54),
+		},
+		"keeps a rune that ends exactly at the limit": {
+			prefix: "testing-" + strings.Repeat("1", 53) + "é",
+			input:  "my-cluster-1",
+			result: "testing-" + strings.Repeat("1", 53) + "é",
+		},
+		"three byte runes": {
+			prefix: "バックアップ",
+			input:  "my-cluster-with-a-long-name-11111111111111111111111111111",
+			result: "バックアップ-my-cluster-with-a-long-name-1111111111111111",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			bm := &BackupManager{prefix: tc.prefix}
+			result := bm.formSnapshotIdentifier(tc.input)
+			assert.Equal(t, tc.result, result)
+			assert.True(t, utf8.ValidString(result), "identifier isn't valid UTF-8")
+			assert.LessOrEqual(t, len(result), maxSnapshotIdentifierLen)
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const minutesPerWeek = 7 * 24 * 60
+
+var weekdays = map[string]int{"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}
+
+// inMaintenanceWindow reports whether now falls inside an RDS
+// PreferredMaintenanceWindow, which looks like "sun:05:00-sun:05:30" and is
+// always in UTC. Windows can wrap around the end of the week, e.g.
+// "sat:23:30-sun:00:30". The end of the window is exclusive.
+func inMaintenanceWindow(window string, now time.Time) (bool, error) {
+	parts := strings.Split(window, "-")
+	if len(parts) != 2 {
+		return false, fmt.Errorf("maintenance window '%s' should look like ddd:hh24:mi-ddd:hh24:mi", window)
+	}
+	start, err := minuteOfWeek(parts[0])
+	if err != nil {
+		return false, fmt.Errorf("maintenance window '%s': %w", window, err)
+	}
+	end, err := minuteOfWeek(parts[1])
+	if err != nil {
+		return false, fmt.Errorf("maintenance window '%s': %w", window, err)
+	}
+
+	now = now.UTC()
+	current := int(now.Weekday())*24*60 + now.Hour()*60 + now.Minute()
+	if start <= end {
+		return current >= start && current < end, nil
+	}
+	return current >= start || current < end, nil
+}
+
+// minuteOfWeek turns "ddd:hh24:mi" into minutes since the start of Sunday.
+func minuteOfWeek(s string) (int, error) {
+	fields := strings.Split(strings.ToLower(s), ":")
+	if len(fields) != 3 {
+		return 0, fmt.Errorf("'%s' should look like ddd:hh24:mi", s)
+	}
+	day, ok := weekdays[fields[0]]
+	if !ok {
+		return 0, fmt.Errorf("'%s' isn't a day of the week", fields[0])
+	}
+	hour, err := strconv.Atoi(fields[1])
+	if err != nil || hour < 0 || hour > 23 {
+		return 0, fmt.Errorf("'%s' isn't an hour", fields[1])
+	}
+	minute, err := strconv.Atoi(fields[2])
+	if err != nil || minute < 0 || minute > 59 {
+		return 0, fmt.Errorf("'%s' isn't a minute", fields[2])
+	}
+	return (day*24+hour)*60 + minute, nil
+}
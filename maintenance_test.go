@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInMaintenanceWindow(t *testing.T) {
+	type testCase struct {
+		window        string
+		now           time.Time
+		expected      bool
+		expectedError bool
+	}
+
+	// testNow is a Tuesday at noon UTC
+	testCases := map[string]testCase{
+		"inside":                      {window: "tue:11:30-tue:12:30", now: testNow, expected: true},
+		"start is inclusive":          {window: "tue:12:00-tue:12:30", now: testNow, expected: true},
+		"end is exclusive":            {window: "tue:11:30-tue:12:00", now: testNow},
+		"earlier in the day":          {window: "tue:05:00-tue:05:30", now: testNow},
+		"same time, different day":    {window: "wed:11:30-wed:12:30", now: testNow},
+		"spans midnight":              {window: "mon:23:30-tue:00:30", now: testNow.Add(-11*time.Hour - 45*time.Minute), expected: true},
+		"wraps the week, before end":  {window: "sat:23:30-sun:00:30", now: time.Date(2022, time.March, 13, 0, 10, 0, 0, time.UTC), expected: true},
+		"wraps the week, after start": {window: "sat:23:30-sun:00:30", now: time.Date(2022, time.March, 12, 23, 45, 0, 0, time.UTC), expected: true},
+		"wraps the week, outside":     {window: "sat:23:30-sun:00:30", now: testNow},
+		"now is converted to UTC":     {window: "tue:11:30-tue:12:30", now: testNow.In(time.FixedZone("EST", -5*60*60)), expected: true},
+		"upper case days":             {window: "TUE:11:30-TUE:12:30", now: testNow, expected: true},
+		"not a range":                 {window: "tue:11:30", expectedError: true},
+		"not a day":                   {window: "tues:11:30-tue:12:30", expectedError: true},
+		"hour out of range":           {window: "tue:24:00-tue:12:30", expectedError: true},
+		"minute out of range":         {window: "tue:11:60-tue:12:30", expectedError: true},
+		"missing minutes":             {window: "tue:11-tue:12", expectedError: true},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			in, err := inMaintenanceWindow(tc.window, tc.now)
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, in)
+		})
+	}
+}
+
+func maintainedCluster(clusterID, window string) types.DBCluster {
+	cluster := existingCluster(clusterID)
+	cluster.PreferredMaintenanceWindow = aws.String(window)
+	return cluster
+}
+
+func TestDiscoverClustersAvoidMaintenance(t *testing.T) {
+	type testCase struct {
+		avoid       bool
+		expectedIDs []string
+	}
+
+	testCases := map[string]testCase{
+		"includes everything by default": {
+			expectedIDs: []string{"my-cluster-1", "my-cluster-2", "my-cluster-3", "my-cluster-4"},
+		},
+		"skips clusters in maintenance": {
+			avoid:       true,
+			expectedIDs: []string{"my-cluster-1", "my-cluster-3", "my-cluster-4"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			st := NewFakeSnapshotTaker()
+			st.clusters = []types.DBCluster{
+				maintainedCluster("my-cluster-1", "sun:05:00-sun:05:30"),
+				maintainedCluster("my-cluster-2", "tue:11:30-tue:12:30"),
+				maintainedCluster("my-cluster-3", "nonsense"),
+				existingCluster("my-cluster-4"),
+			}
+			bm := NewBackupManager(st, WithAvoidMaintenance(tc.avoid))
+			bm.now = func() time.Time { return testNow }
+
+			clusters, err := bm.DiscoverClusters(context.TODO())
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedIDs, clusterIdentifiers(clusters))
+		})
+	}
+}
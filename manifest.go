@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// ManifestFormatter describes the snapshots a run created as JSON, for
+// restore tooling to pick up. A synthetic manifest lists the snapshots a dry
+// run planned instead.
+type ManifestFormatter struct {
+	RunID     string
+	Region    string
+	Timestamp time.Time
+
+	// Synthetic marks a manifest for snapshots that weren't really taken,
+	// so restore tooling can be exercised without any.
+	Synthetic bool
+}
+
+type manifest struct {
+	RunID     string          `json:"runId"`
+	Region    string          `json:"region"`
+	Timestamp string          `json:"timestamp"`
+	Synthetic bool            `json:"synthetic"`
+	Snapshots []manifestEntry `json:"snapshots"`
+}
+
+type manifestEntry struct {
+	Profile    string `json:"profile,omitempty"`
+	Cluster    string `json:"cluster"`
+	SnapshotID string `json:"snapshotID"`
+	Arn        string `json:"arn,omitempty"`
+}
+
+func (f ManifestFormatter) Format(w io.Writer, results []SnapshotResult) error {
+	m := manifest{
+		RunID:     f.RunID,
+		Region:    f.Region,
+		Timestamp: f.Timestamp.UTC().Format(time.RFC3339),
+		Synthetic: f.Synthetic,
+		Snapshots: make([]manifestEntry, 0, len(results)),
+	}
+	for _, result := range results {
+		if result.Status != StatusCreated && !(f.Synthetic && result.Status == StatusPlanned) {
+			continue
+		}
+		m.Snapshots = append(m.Snapshots, manifestEntry{
+			Profile:    result.Profile,
+			Cluster:    result.ClusterIdentifier,
+			SnapshotID: result.SnapshotIdentifier,
+			Arn:        result.SnapshotArn,
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(m)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManifestFormatter(t *testing.T) {
+	results := []SnapshotResult{
+		{
+			ClusterIdentifier:  "my-cluster-1",
+			SnapshotIdentifier: "testing-my-cluster-1",
+			SnapshotArn:        "arn:aws:rds:us-east-1:123456789012:cluster-snapshot:testing-my-cluster-1",
+			Status:             StatusCreated,
+		},
+		{ClusterIdentifier: "my-cluster-2", SnapshotIdentifier: "testing-my-cluster-2", Status: StatusSkippedNotFound},
+		{ClusterIdentifier: "my-cluster-3", SnapshotIdentifier: "testing-my-cluster-3", Status: StatusCreated},
+		{ClusterIdentifier: "my-cluster-4", SnapshotIdentifier: "testing-my-cluster-4", Status: StatusPlanned},
+	}
+
+	var buf bytes.Buffer
+	f := ManifestFormatter{RunID: "abc123", Region: "us-east-1", Timestamp: testNow, Synthetic: true}
+	assert.Nil(t, f.Format(&buf, results))
+	assert.JSONEq(t, `{
+		"runId": "abc123",
+		"region": "us-east-1",
+		"timestamp": "2022-03-15T12:00:00Z",
+		"synthetic": true,
+		"snapshots": [
+			{"cluster": "my-cluster-1", "snapshotID": "testing-my-cluster-1", "arn": "arn:aws:rds:us-east-1:123456789012:cluster-snapshot:testing-my-cluster-1"},
+			{"cluster": "my-cluster-3", "snapshotID": "testing-my-cluster-3"},
+			{"cluster": "my-cluster-4", "snapshotID": "testing-my-cluster-4"}
+		]
+	}`, buf.String())
+}
+
+func TestManifestFormatterNoSnapshots(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Nil(t, ManifestFormatter{RunID: "abc123", Timestamp: testNow}.Format(&buf, nil))
+	assert.Contains(t, buf.String(), `"snapshots": []`)
+}
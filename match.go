@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+const ErrNoClustersMatch BackupManagerError = "no clusters match"
+
+// matchFragments expands each fragment into every identifier that contains
+// it, ignoring case, in the order the identifiers come in. An identifier
+// matched by several fragments is only given once. A fragment that matches
+// nothing is more likely a typo than a cluster that's gone, so it's an
+// error, naming every such fragment.
+func matchFragments(fragments, identifiers []string) ([]string, error) {
+	seen := make(map[string]bool)
+	matched := make([]string, 0)
+	unmatched := make([]string, 0)
+	for _, fragment := range fragments {
+		lower := strings.ToLower(fragment)
+		found := false
+		for _, identifier := range identifiers {
+			if !strings.Contains(strings.ToLower(identifier), lower) {
+				continue
+			}
+			found = true
+			if !seen[identifier] {
+				seen[identifier] = true
+				matched = append(matched, identifier)
+			}
+		}
+		if !found {
+			unmatched = append(unmatched, fragment)
+		}
+	}
+	if len(unmatched) > 0 {
+		return nil, fmt.Errorf("'%s': %w", strings.Join(unmatched, "', '"), ErrNoClustersMatch)
+	}
+	return matched, nil
+}
+
+// resolveFragments discovers clusters and expands the fragments against
+// them, so discovery's filters apply to the matches too.
+func resolveFragments(ctx context.Context, bm *BackupManager, fragments []string) ([]string, error) {
+	if len(fragments) == 0 {
+		return nil, ErrNoIdentifiersSpecified
+	}
+	clusters, err := bm.DiscoverClusters(ctx)
+	if err != nil {
+		return nil, err
+	}
+	matched, err := matchFragments(fragments, clusterIdentifiers(clusters))
+	if err != nil {
+		return nil, err
+	}
+	bm.logf("Matched %d cluster(s): %s.", len(matched), strings.Join(matched, ", "))
+	return matched, nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchFragments(t *testing.T) {
+	identifiers := []string{"payments-prod", "payments-staging", "Search-Prod", "orders"}
+
+	type testCase struct {
+		identifiers []string
+		fragments   []string
+		expected    []string
+		expectedErr error
+		expectedMsg string
+	}
+
+	testCases := map[string]testCase{
+		"a fragment matching several": {
+			fragments: []string{"payments"},
+			expected:  []string{"payments-prod", "payments-staging"},
+		},
+		"ignoring case": {
+			fragments: []string{"PROD"},
+			expected:  []string{"payments-prod", "Search-Prod"},
+		},
+		"an exact identifier": {
+			fragments: []string{"orders"},
+			expected:  []string{"orders"},
+		},
+		"overlapping fragments": {
+			fragments: []string{"prod", "payments"},
+			expected:  []string{"payments-prod", "Search-Prod", "payments-staging"},
+		},
+		"a typo": {
+			fragments:   []string{"payments", "serach", "ordres"},
+			expectedErr: ErrNoClustersMatch,
+			expectedMsg: "'serach', 'ordres': no clusters match",
+		},
+		"nothing to match against": {
+			identifiers: []string{},
+			fragments:   []string{"payments"},
+			expectedErr: ErrNoClustersMatch,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			against := identifiers
+			if tc.identifiers != nil {
+				against = tc.identifiers
+			}
+			matched, err := matchFragments(tc.fragments, against)
+			assert.ErrorIs(t, err, tc.expectedErr)
+			if tc.expectedMsg != "" {
+				assert.EqualError(t, err, tc.expectedMsg)
+			}
+			assert.Equal(t, tc.expected, matched)
+		})
+	}
+}
+
+func TestResolveFragments(t *testing.T) {
+	st := NewFakeSnapshotTaker()
+	st.clusters = []types.DBCluster{existingCluster("payments-prod"), existingCluster("search-prod"), existingCluster("payments-staging")}
+	bm := NewBackupManager(st)
+
+	matched, err := resolveFragments(context.TODO(), bm, []string{"Payments"})
+	assert.Nil(t, err)
+	// in discovery's order
+	assert.Equal(t, []string{"payments-prod", "payments-staging"}, matched)
+
+	_, err = resolveFragments(context.TODO(), bm, nil)
+	assert.ErrorIs(t, err, ErrNoIdentifiersSpecified)
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+// metricsTimeout bounds publishing metrics after a run. It gets its own
+// context, so a run cut short by its deadline still reports how it went.
+const metricsTimeout = 10 * time.Second
+
+// MetricsPublisher reports the counts from a run somewhere they can be
+// alerted on. RecordSnapshotSize is called with the size of each snapshot a
+// run creates, as each becomes available in the background once the run's
+// done.
+type MetricsPublisher interface {
+	Publish(context.Context, RunStats) error
+	RecordSnapshotSize(clusterID string, gb int32)
+}
+
+// SnapshotSizePublisher is a MetricsPublisher that sends the sizes it's been
+// given on their own, once a run's snapshots are all available, rather than
+// holding on to them until the next Publish.
+type SnapshotSizePublisher interface {
+	PublishSnapshotSizes(context.Context) error
+}
+
+// NopMetrics publishes nothing. The manager doesn't look up snapshot sizes
+// for it, so it costs nothing either.
+type NopMetrics struct{}
+
+func (NopMetrics) Publish(context.Context, RunStats) error { return nil }
+
+func (NopMetrics) RecordSnapshotSize(string, int32) {}
+
+// MetricPutter puts CloudWatch metric data. *cloudwatch.Client implements
+// it.
+type MetricPutter interface {
+	PutMetricData(context.Context, *cloudwatch.PutMetricDataInput, ...func(*cloudwatch.Options)) (*cloudwatch.PutMetricDataOutput, error)
+}
+
+// metricsPerPut is the most datums PutMetricData takes in one call.
+const metricsPerPut = 20
+
+// CloudWatchMetrics publishes SnapshotsCreated, SnapshotsFailed and
+// SnapshotsSkipped as custom metrics in a namespace, along with a SnapshotSize
+// for each cluster snapshotted, with a ClusterIdentifier dimension.
+type CloudWatchMetrics struct {
+	client    MetricPutter
+	namespace string
+	now       func() time.Time
+
+	mu    sync.Mutex
+	sizes []types.MetricDatum
+}
+
+func NewCloudWatchMetrics(client MetricPutter, namespace string) *CloudWatchMetrics {
+	return &CloudWatchMetrics{
+		client:    client,
+		namespace: namespace,
+		now:       time.Now,
+	}
+}
+
+// RecordSnapshotSize holds on to a snapshot's size until the next Publish.
+func (m *CloudWatchMetrics) RecordSnapshotSize(clusterID string, gb int32) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sizes = append(m.sizes, types.MetricDatum{
+		MetricName: aws.String("SnapshotSize"),
+		Dimensions: []types.Dimension{{Name: aws.String("ClusterIdentifier"), Value: aws.String(clusterID)}},
+		Timestamp:  aws.Time(m.now()),
+		Unit:       types.StandardUnitGigabytes,
+		Value:      aws.Float64(float64(gb)),
+	})
+}
+
+// PublishSnapshotSizes sends the sizes recorded since the last Publish.
+func (m *CloudWatchMetrics) PublishSnapshotSizes(ctx context.Context) error {
+	m.mu.Lock()
+	data := m.sizes
+	m.sizes = nil
+	m.mu.Unlock()
+	return m.put(ctx, data)
+}
+
+func (m *CloudWatchMetrics) Publish(ctx context.Context, stats RunStats) error {
+	now := m.now()
+	datum := func(name string, value int64) types.MetricDatum {
+		return types.MetricDatum{
+			MetricName: aws.String(name),
+			Timestamp:  aws.Time(now),
+			Unit:       types.StandardUnitCount,
+			Value:      aws.Float64(float64(value)),
+		}
+	}
+
+	m.mu.Lock()
+	data := append([]types.MetricDatum{
+		datum("SnapshotsCreated", stats.Created),
+		datum("SnapshotsFailed", stats.Failed),
+		datum("SnapshotsSkipped", stats.Skipped),
+	}, m.sizes...)
+	m.sizes = nil
+	m.mu.Unlock()
+	return m.put(ctx, data)
+}
+
+// put sends data in as few PutMetricData calls as it fits in.
+func (m *CloudWatchMetrics) put(ctx context.Context, data []types.MetricDatum) error {
+	for len(data) > 0 {
+		n := len(data)
+		if n > metricsPerPut {
+			n = metricsPerPut
+		}
+		_, err := m.client.PutMetricData(ctx, &cloudwatch.PutMetricDataInput{
+			Namespace:  aws.String(m.namespace),
+			MetricData: data[:n],
+		})
+		if err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	return nil
+}
+
+// publishMetrics sends the run's stats to Metrics, if it's set. Like the
+// catalog, a failure here is only worth a warning.
+func (b *BackupManager) publishMetrics() {
+	// a dry run didn't really do anything to report
+	if b.Metrics == nil || b.DryRun {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), metricsTimeout)
+	defer cancel()
+	if err := b.Metrics.Publish(ctx, b.Stats()); err != nil {
+		b.logf("Couldn't publish metrics for run '%s': %v", b.RunID, err)
+	}
+}
+
+// recordSnapshotSizesLater looks up the sizes of the snapshots a run created
+// in the background, so the run's results, events and error don't wait on
+// them, then publishes them if Metrics is a SnapshotSizePublisher. The
+// lookup gets a context of its own, bounded by WaitTimeout, since the run's
+// may be about to run out. It's not worth the describe calls without
+// somewhere to send the sizes.
+func (b *BackupManager) recordSnapshotSizesLater(results []SnapshotResult) {
+	if _, nop := b.Metrics.(NopMetrics); b.Metrics == nil || nop || b.sd == nil || b.DryRun {
+		return
+	}
+	sizes := b.sizeLookups()
+	sizes.Add(1)
+	go func() {
+		defer sizes.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), b.waitTimeout())
+		defer cancel()
+		b.recordSnapshotSizes(ctx, results)
+
+		publisher, ok := b.Metrics.(SnapshotSizePublisher)
+		if !ok {
+			return
+		}
+		ctx, cancel = context.WithTimeout(context.Background(), metricsTimeout)
+		defer cancel()
+		if err := publisher.PublishSnapshotSizes(ctx); err != nil {
+			b.logf("Couldn't publish snapshot sizes for run '%s': %v", b.RunID, err)
+		}
+	}()
+}
+
+// WaitForSnapshotSizes blocks until the sizes of every run's snapshots have
+// been looked up and published, or given up on. Anything that exits after a
+// run with Metrics should call it first.
+func (b *BackupManager) WaitForSnapshotSizes() {
+	b.sizeLookups().Wait()
+}
+
+func (b *BackupManager) sizeLookups() *sync.WaitGroup {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.sizes == nil {
+		b.sizes = &sync.WaitGroup{}
+	}
+	return b.sizes
+}
+
+// recordSnapshotSizes waits for the snapshots a run created to become
+// available, which is when their AllocatedStorage is known, and passes each
+// one's size on to Metrics. They're waited on together, and a failure is
+// only worth a debug line.
+func (b *BackupManager) recordSnapshotSizes(ctx context.Context, results []SnapshotResult) {
+	clusters := make(map[string]string)
+	pending := make([]string, 0)
+	for _, result := range results {
+		if result.Status == StatusCreated && result.InstanceIdentifier == "" {
+			clusters[result.SnapshotIdentifier] = result.ClusterIdentifier
+			pending = append(pending, result.SnapshotIdentifier)
+		}
+	}
+	if len(pending) == 0 {
+		return
+	}
+
+	err := b.pollUntil(ctx, func(ctx context.Context) (bool, error) {
+		stillPending := pending[:0]
+		for _, snapshotID := range pending {
+			out, err := b.describeSnapshot(ctx, snapshotID)
+			if err != nil {
+				return false, err
+			}
+			if len(out.DBClusterSnapshots) == 0 {
+				stillPending = append(stillPending, snapshotID)
+				continue
+			}
+			snapshot := out.DBClusterSnapshots[0]
+			switch status := aws.ToString(snapshot.Status); {
+			case status == "available" && snapshot.AllocatedStorage > 0:
+				b.Metrics.RecordSnapshotSize(clusters[snapshotID], snapshot.AllocatedStorage)
+			case status == "available" || status == "failed":
+				b.debugf("The size of snapshot '%s' isn't known.", snapshotID)
+			default:
+				stillPending = append(stillPending, snapshotID)
+			}
+		}
+		pending = stillPending
+		return len(pending) == 0, nil
+	})
+	if err != nil {
+		b.debugf("Couldn't look up the size of %d snapshot(s): %v", len(pending), err)
+	}
+}
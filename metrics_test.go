@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeMetricPutter struct {
+	inputs []*cloudwatch.PutMetricDataInput
+}
+
+func (f *fakeMetricPutter) PutMetricData(ctx context.Context, in *cloudwatch.PutMetricDataInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.PutMetricDataOutput, error) {
+	f.inputs = append(f.inputs, in)
+	return &cloudwatch.PutMetricDataOutput{}, nil
+}
+
+type fakeMetricsPublisher struct {
+	published []RunStats
+	err       error
+
+	mu             sync.Mutex
+	sizes          map[string]int32
+	sizesPublished int
+}
+
+func (f *fakeMetricsPublisher) RecordSnapshotSize(clusterID string, gb int32) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.sizes == nil {
+		f.sizes = make(map[string]int32)
+	}
+	f.sizes[clusterID] = gb
+}
+
+func (f *fakeMetricsPublisher) PublishSnapshotSizes(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sizesPublished++
+	return nil
+}
+
+func (f *fakeMetricsPublisher) Publish(ctx context.Context, stats RunStats) error {
+	f.published = append(f.published, stats)
+	return f.err
+}
+
+func TestCloudWatchMetricsPublish(t *testing.T) {
+	putter := &fakeMetricPutter{}
+	metrics := NewCloudWatchMetrics(putter, "Backups/RDS")
+	metrics.now = func() time.Time { return testNow }
+
+	err := metrics.Publish(context.TODO(), RunStats{Created: 3, Skipped: 2, Failed: 1, Retried: 5})
+	assert.Nil(t, err)
+
+	datum := func(name string, value float64) cwtypes.MetricDatum {
+		return cwtypes.MetricDatum{
+			MetricName: aws.String(name),
+			Timestamp:  aws.Time(testNow),
+			Unit:       cwtypes.StandardUnitCount,
+			Value:      aws.Float64(value),
+		}
+	}
+	assert.Equal(t, []*cloudwatch.PutMetricDataInput{
+		{
+			Namespace: aws.String("Backups/RDS"),
+			MetricData: []cwtypes.MetricDatum{
+				datum("SnapshotsCreated", 3),
+				datum("SnapshotsFailed", 1),
+				datum("SnapshotsSkipped", 2),
+			},
+		},
+	}, putter.inputs)
+}
+
+func TestTriggerSnapshotsPublishesMetrics(t *testing.T) {
+	publisher := &fakeMetricsPublisher{}
+	bm := NewBackupManager(NewFlakySnapshotTaker("my-cluster-2", &types.DBClusterNotFoundFault{}), WithPrefix("testing"))
+	bm.Metrics = publisher
+
+	_, err := bm.TriggerSnapshots(context.TODO(), "my-cluster-1", "my-cluster-2", "my-cluster-3")
+	assert.Nil(t, err)
+	assert.Equal(t, []RunStats{{Created: 2, Skipped: 1}}, publisher.published)
+}
+
+func TestTriggerSnapshotsMetricsFailureIsOnlyLogged(t *testing.T) {
+	var buf bytes.Buffer
+	publisher := &fakeMetricsPublisher{err: errors.New("throttled")}
+	bm := NewBackupManager(NewFakeSnapshotTaker(), WithPrefix("testing"), WithLogger(log.New(&buf, "", 0)), WithRunID("run-1"))
+	bm.Metrics = publisher
+
+	_, err := bm.TriggerSnapshots(context.TODO(), "my-cluster-1")
+	assert.Nil(t, err)
+	assert.Contains(t, buf.String(), "Couldn't publish metrics for run 'run-1': throttled")
+}
+
+func TestTriggerSnapshotsRecordsSnapshotSizes(t *testing.T) {
+	sized := func(snapshot types.DBClusterSnapshot, gb int32) types.DBClusterSnapshot {
+		snapshot.AllocatedStorage = gb
+		return snapshot
+	}
+	// what describing the new snapshots will find; my-cluster-3's size isn't
+	// known yet
+	st := NewFakeSnapshotTakerWithSnapshots(
+		sized(existingSnapshot("my-cluster-1", "testing-my-cluster-1", testNow), 120),
+		sized(existingSnapshot("my-cluster-2", "testing-my-cluster-2", testNow), 4096),
+		existingSnapshot("my-cluster-3", "testing-my-cluster-3", testNow),
+	)
+	publisher := &fakeMetricsPublisher{}
+	bm := NewBackupManager(st, WithPrefix("testing"))
+	bm.Metrics = publisher
+
+	_, err := bm.TriggerSnapshots(context.TODO(), "my-cluster-1", "my-cluster-2", "my-cluster-3")
+	assert.Nil(t, err)
+	bm.WaitForSnapshotSizes()
+	assert.Equal(t, map[string]int32{"my-cluster-1": 120, "my-cluster-2": 4096}, publisher.sizes)
+	assert.Equal(t, 1, publisher.sizesPublished)
+}
+
+// growingSnapshotDescriber reports each snapshot as still being created,
+// with no size yet, for its first few describes.
+type growingSnapshotDescriber struct {
+	mu        sync.Mutex
+	describes map[string]int
+	sizes     map[string]int32
+}
+
+func (g *growingSnapshotDescriber) DescribeDBClusterSnapshots(ctx context.Context, in *rds.DescribeDBClusterSnapshotsInput, optFns ...func(*rds.Options)) (*rds.DescribeDBClusterSnapshotsOutput, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	snapshotID := aws.ToString(in.DBClusterSnapshotIdentifier)
+	g.describes[snapshotID]++
+	snapshot := types.DBClusterSnapshot{DBClusterSnapshotIdentifier: in.DBClusterSnapshotIdentifier, Status: aws.String("creating")}
+	if g.describes[snapshotID] > 2 {
+		snapshot.Status, snapshot.AllocatedStorage = aws.String("available"), g.sizes[snapshotID]
+	}
+	return &rds.DescribeDBClusterSnapshotsOutput{DBClusterSnapshots: []types.DBClusterSnapshot{snapshot}}, nil
+}
+
+func TestTriggerSnapshotsRecordsSizesOnceAvailable(t *testing.T) {
+	publisher := &fakeMetricsPublisher{}
+	bm := NewBackupManager(NewFakeSnapshotTaker(), WithPrefix("testing"))
+	bm.sleep = noSleep
+	bm.Metrics = publisher
+	bm.sd = &growingSnapshotDescriber{
+		describes: make(map[string]int),
+		sizes:     map[string]int32{"testing-my-cluster-1": 120, "testing-my-cluster-2": 64},
+	}
+
+	_, err := bm.TriggerSnapshots(context.TODO(), "my-cluster-1", "my-cluster-2")
+	assert.Nil(t, err)
+	bm.WaitForSnapshotSizes()
+	assert.Equal(t, map[string]int32{"my-cluster-1": 120, "my-cluster-2": 64}, publisher.sizes)
+}
+
+func TestTriggerSnapshotsWithMetricsMeetsDeadline(t *testing.T) {
+	// the new snapshots never show up, so their sizes are never known
+	publisher := &fakeMetricsPublisher{}
+	bm := NewBackupManager(NewFakeSnapshotTaker(), WithPrefix("testing"))
+	bm.Metrics = publisher
+	bm.WaitTimeout, bm.PollInterval = 200*time.Millisecond, time.Millisecond
+	ctx, cancel := context.WithTimeout(context.TODO(), 50*time.Millisecond)
+	defer cancel()
+
+	results, err := bm.TriggerSnapshots(ctx, "my-cluster-1", "my-cluster-2")
+	assert.Nil(t, err)
+	assert.Len(t, results, 2)
+	assert.Nil(t, ctx.Err(), "the run waited on the sizes")
+
+	bm.WaitForSnapshotSizes()
+	assert.Empty(t, publisher.sizes)
+}
+
+func TestCloudWatchMetricsPublishSnapshotSizes(t *testing.T) {
+	putter := &fakeMetricPutter{}
+	metrics := NewCloudWatchMetrics(putter, "Backups/RDS")
+	metrics.now = func() time.Time { return testNow }
+	metrics.RecordSnapshotSize("my-cluster-1", 120)
+
+	assert.Nil(t, metrics.PublishSnapshotSizes(context.TODO()))
+	if assert.Len(t, putter.inputs, 1) && assert.Len(t, putter.inputs[0].MetricData, 1) {
+		assert.Equal(t, "SnapshotSize", aws.ToString(putter.inputs[0].MetricData[0].MetricName))
+	}
+
+	// they're not sent again with the next run's counts
+	putter.inputs = nil
+	assert.Nil(t, metrics.Publish(context.TODO(), RunStats{}))
+	assert.Len(t, putter.inputs[0].MetricData, 3)
+}
+
+func TestCloudWatchMetricsSnapshotSizes(t *testing.T) {
+	putter := &fakeMetricPutter{}
+	metrics := NewCloudWatchMetrics(putter, "Backups/RDS")
+	metrics.now = func() time.Time { return testNow }
+	for i := 0; i < 25; i++ {
+		metrics.RecordSnapshotSize(fmt.Sprintf("my-cluster-%d", i), int32(i))
+	}
+
+	assert.Nil(t, metrics.Publish(context.TODO(), RunStats{Created: 25}))
+	// three counts and twenty-five sizes don't fit in one put
+	if assert.Len(t, putter.inputs, 2) {
+		assert.Len(t, putter.inputs[0].MetricData, 20)
+		last := putter.inputs[1].MetricData[7]
+		assert.Equal(t, cwtypes.MetricDatum{
+			MetricName: aws.String("SnapshotSize"),
+			Dimensions: []cwtypes.Dimension{{Name: aws.String("ClusterIdentifier"), Value: aws.String("my-cluster-24")}},
+			Timestamp:  aws.Time(testNow),
+			Unit:       cwtypes.StandardUnitGigabytes,
+			Value:      aws.Float64(24),
+		}, last)
+	}
+
+	// the sizes went with that run
+	putter.inputs = nil
+	assert.Nil(t, metrics.Publish(context.TODO(), RunStats{}))
+	assert.Len(t, putter.inputs[0].MetricData, 3)
+}
+
+func TestNopMetricsSkipsTheLookup(t *testing.T) {
+	st := NewFakeSnapshotTaker()
+	bm := NewBackupManager(st, WithPrefix("testing"))
+	bm.Metrics = NopMetrics{}
+	// a describer that's there would be called, and this one fails the test
+	bm.sd = failingSnapshotDescriber{t}
+
+	_, err := bm.TriggerSnapshots(context.TODO(), "my-cluster-1")
+	assert.Nil(t, err)
+}
+
+type failingSnapshotDescriber struct {
+	t *testing.T
+}
+
+func (f failingSnapshotDescriber) DescribeDBClusterSnapshots(ctx context.Context, in *rds.DescribeDBClusterSnapshotsInput, optFns ...func(*rds.Options)) (*rds.DescribeDBClusterSnapshotsOutput, error) {
+	f.t.Errorf("unexpected describe of '%s'", aws.ToString(in.DBClusterSnapshotIdentifier))
+	return &rds.DescribeDBClusterSnapshotsOutput{}, nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// notifyTimeout bounds telling anyone about a run. Like publishing metrics,
+// it gets its own context, so a run that ran out of time still gets reported.
+const notifyTimeout = 10 * time.Second
+
+// maxNotifiedFailures is how many failures a notification lists one by one
+// before it just says how many more there were.
+const maxNotifiedFailures = 20
+
+// Notifier tells someone how a run went once it's over.
+type Notifier interface {
+	Notify(ctx context.Context, runID string, results []SnapshotResult) error
+}
+
+// HTTPDoer sends HTTP requests. *http.Client implements it.
+type HTTPDoer interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+// SlackNotifier posts a run's counts, and any failures, to a Slack incoming
+// webhook.
+type SlackNotifier struct {
+	client HTTPDoer
+	url    string
+}
+
+func NewSlackNotifier(client HTTPDoer, url string) *SlackNotifier {
+	return &SlackNotifier{
+		client: client,
+		url:    url,
+	}
+}
+
+type slackMessage struct {
+	Text string `json:"text"`
+}
+
+func (n *SlackNotifier) Notify(ctx context.Context, runID string, results []SnapshotResult) error {
+	body, err := json.Marshal(slackMessage{Text: slackText(runID, results)})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		// the webhook URL is as good as a password, so it's kept out of logs
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("posting to the slack webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		reply, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("slack webhook answered %s: %s", resp.Status, strings.TrimSpace(string(reply)))
+	}
+	return nil
+}
+
+// slackText is the message for a run: a line of counts, then a line for each
+// failure.
+func slackText(runID string, results []SnapshotResult) string {
+	stats := resultStats(results)
+	var b strings.Builder
+	fmt.Fprintf(&b, "Backup run `%s`: %d created, %d skipped, %d failed", runID, stats.Created, stats.Skipped, stats.Failed)
+
+	listed := 0
+	for _, result := range results {
+		if result.Status != StatusFailed {
+			continue
+		}
+		if listed == maxNotifiedFailures {
+			fmt.Fprintf(&b, "\n…and %d more", stats.Failed-int64(listed))
+			break
+		}
+		fmt.Fprintf(&b, "\n• `%s`: %v", qualifiedCluster(result), result.Err)
+		listed++
+	}
+	return b.String()
+}
+
+// notify sends the results to notifier. A backup that worked is still a
+// backup that worked if nobody heard about it, so a failure is only logged.
+func notify(notifier Notifier, runID string, results []SnapshotResult) {
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+	if err := notifier.Notify(ctx, runID, results); err != nil {
+		log.Printf("Couldn't send a notification about run '%s': %v", runID, err)
+	}
+}
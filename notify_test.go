@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// webhookClient answers every request with status, or fails it with err,
+// keeping hold of what was posted.
+type webhookClient struct {
+	status int
+	err    error
+
+	request *http.Request
+	body    string
+}
+
+func (c *webhookClient) Do(req *http.Request) (*http.Response, error) {
+	c.request = req
+	body, _ := io.ReadAll(req.Body)
+	c.body = string(body)
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &http.Response{
+		StatusCode: c.status,
+		Status:     fmt.Sprintf("%d %s", c.status, http.StatusText(c.status)),
+		Body:       io.NopCloser(strings.NewReader("no_text")),
+	}, nil
+}
+
+func TestSlackNotifier(t *testing.T) {
+	results := []SnapshotResult{
+		{ClusterIdentifier: "my-cluster-1", Status: StatusCreated},
+		{ClusterIdentifier: "my-cluster-2", Status: StatusFailed, Err: errors.New("quota exceeded")},
+		{ClusterIdentifier: "my-cluster-3", Status: StatusSkippedNotFound},
+	}
+
+	type testCase struct {
+		status      int
+		err         error
+		expectedErr string
+	}
+
+	testCases := map[string]testCase{
+		"posted": {
+			status: http.StatusOK,
+		},
+		"webhook refuses it": {
+			status:      http.StatusBadRequest,
+			expectedErr: "slack webhook answered 400 Bad Request: no_text",
+		},
+		"can't reach slack": {
+			err:         errors.New("connection refused"),
+			expectedErr: "posting to the slack webhook: connection refused",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			client := &webhookClient{status: tc.status, err: tc.err}
+			n := NewSlackNotifier(client, "https://hooks.slack.com/services/T000/B000/XXXX")
+
+			err := n.Notify(context.TODO(), "run-1", results)
+			if tc.expectedErr == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expectedErr)
+			}
+
+			assert.Equal(t, http.MethodPost, client.request.Method)
+			assert.Equal(t, "https://hooks.slack.com/services/T000/B000/XXXX", client.request.URL.String())
+			assert.Equal(t, "application/json", client.request.Header.Get("Content-Type"))
+			var msg slackMessage
+			assert.Nil(t, json.Unmarshal([]byte(client.body), &msg))
+			assert.Equal(t, "Backup run `run-1`: 1 created, 1 skipped, 1 failed\n• `my-cluster-2`: quota exceeded", msg.Text)
+		})
+	}
+}
+
+func TestSlackTextListsSomeFailures(t *testing.T) {
+	results := make([]SnapshotResult, 0, maxNotifiedFailures+3)
+	for i := 0; i < maxNotifiedFailures+3; i++ {
+		results = append(results, SnapshotResult{ClusterIdentifier: fmt.Sprintf("cluster-%d", i), Status: StatusFailed, Err: errors.New("boom")})
+	}
+
+	lines := strings.Split(slackText("run-1", results), "\n")
+	assert.Len(t, lines, maxNotifiedFailures+2)
+	assert.Equal(t, "…and 3 more", lines[len(lines)-1])
+}
+
+// failingNotifier can't get through to anyone.
+type failingNotifier struct {
+	calls int
+}
+
+func (f *failingNotifier) Notify(context.Context, string, []SnapshotResult) error {
+	f.calls++
+	return errors.New("nobody's listening")
+}
+
+func TestNotifyOnlyLogsFailures(t *testing.T) {
+	n := &failingNotifier{}
+	notify(n, "run-1", []SnapshotResult{{ClusterIdentifier: "my-cluster-1", Status: StatusCreated}})
+	assert.Equal(t, 1, n.calls)
+}
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// Option configures a BackupManager built with NewBackupManager.
+type Option func(*BackupManager)
+
+// NewBackupManager returns a BackupManager that makes its RDS calls, from
+// taking snapshots to describing clusters and copying and deleting
+// snapshots, with api. Each feature is wired up to the part of api it needs;
+// tests can leave one out by setting its field to nil. With no options, the
+// manager behaves just like a bare &BackupManager{st: api}.
+func NewBackupManager(api RDSAPI, opts ...Option) *BackupManager {
+	b := &BackupManager{
+		st:  api,
+		ist: api,
+		isd: api,
+		sd:  api,
+		del: api,
+		cp:  api,
+		cd:  api,
+		gcd: api,
+		tl:  api,
+		ta:  api,
+		rs:  api,
+		ic:  api,
+		cs:  api,
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	b.meterAPICalls()
+	return b
+}
+
+// WithPrefix sets the prefix for new snapshot identifiers.
+func WithPrefix(prefix string) Option {
+	return func(b *BackupManager) {
+		b.prefix = prefix
+		b.prefixSet = true
+	}
+}
+
+// WithPrefixTag starts the snapshot identifiers of discovered clusters with
+// the value of their tag named tag, if they have it.
+func WithPrefixTag(tag string) Option {
+	return func(b *BackupManager) {
+		b.PrefixTag = tag
+	}
+}
+
+// WithReadPrefixes sets the prefixes recognized when listing and pruning.
+func WithReadPrefixes(prefixes ...string) Option {
+	return func(b *BackupManager) {
+		b.ReadPrefixes = prefixes
+	}
+}
+
+// WithTagSelector restricts listing and pruning to snapshots carrying all of
+// the given tags. If only is set, read prefixes are ignored.
+func WithTagSelector(selector map[string]string, only bool) Option {
+	return func(b *BackupManager) {
+		b.TagSelector = selector
+		b.SelectByTagsOnly = only
+	}
+}
+
+// WithIncludeReaders snapshots reader instances as well as writers.
+func WithIncludeReaders(include bool) Option {
+	return func(b *BackupManager) {
+		b.IncludeReaders = include
+	}
+}
+
+// WithConcurrency sets how many clusters are snapshotted at once.
+func WithConcurrency(n int) Option {
+	return func(b *BackupManager) {
+		b.Concurrency = n
+	}
+}
+
+// WithAutoConcurrency adapts how many clusters are snapshotted at once to
+// throttling, up to max. Zero leaves it to Concurrency.
+func WithAutoConcurrency(max int) Option {
+	return func(b *BackupManager) {
+		b.AutoConcurrency = max
+	}
+}
+
+// WithSinceLastRun only discovers clusters that look to have changed since
+// the last complete run recorded in the state.
+func WithSinceLastRun(sinceLastRun bool) Option {
+	return func(b *BackupManager) {
+		b.SinceLastRun = sinceLastRun
+	}
+}
+
+// WithWaitForStable waits for snapshots that are still being created before
+// deleting or copying them, rather than failing.
+func WithWaitForStable(wait bool) Option {
+	return func(b *BackupManager) {
+		b.WaitForStable = wait
+	}
+}
+
+// WithWeightBudget limits the clusters snapshotted at once by their total
+// allocated storage, in GiB.
+func WithWeightBudget(budget int) Option {
+	return func(b *BackupManager) {
+		b.WeightBudget = budget
+	}
+}
+
+// WithChunks splits each batch into chunks of size clusters, pausing for
+// pause between them.
+func WithChunks(size int, pause time.Duration) Option {
+	return func(b *BackupManager) {
+		b.ChunkSize = size
+		b.ChunkPause = pause
+	}
+}
+
+// WithSuffix ends new snapshot identifiers with suffix.
+func WithSuffix(suffix string) Option {
+	return func(b *BackupManager) {
+		b.Suffix = suffix
+	}
+}
+
+// WithOnlyIfChanged skips clusters that don't look to have changed since
+// their last snapshot.
+func WithOnlyIfChanged(onlyIfChanged bool) Option {
+	return func(b *BackupManager) {
+		b.OnlyIfChanged = onlyIfChanged
+	}
+}
+
+// WithPrecheckClusters skips requested clusters that don't exist, after
+// looking them all up at the start of each run.
+func WithPrecheckClusters(precheck bool) Option {
+	return func(b *BackupManager) {
+		b.PrecheckClusters = precheck
+	}
+}
+
+// WithSeparator joins the parts of new snapshot identifiers with sep instead
+// of a hyphen.
+func WithSeparator(sep string) Option {
+	return func(b *BackupManager) {
+		b.Separator = &sep
+	}
+}
+
+// WithSanitizeName replaces illegal characters in new snapshot identifiers.
+func WithSanitizeName(sanitize bool) Option {
+	return func(b *BackupManager) {
+		b.SanitizeName = sanitize
+	}
+}
+
+// WithPreserveCase keeps new snapshot identifiers in the case they were
+// given in, rather than lowercasing them.
+func WithPreserveCase(preserve bool) Option {
+	return func(b *BackupManager) {
+		b.PreserveCase = preserve
+	}
+}
+
+// WithDisambiguateNames gives clusters whose snapshot names would collide
+// with another's in the same run a name with a short hash in it instead.
+func WithDisambiguateNames(disambiguate bool) Option {
+	return func(b *BackupManager) {
+		b.DisambiguateNames = disambiguate
+	}
+}
+
+// WithSequenceNames numbers each cluster's new snapshots, one past the
+// snapshots of it the tool already has.
+func WithSequenceNames(sequence bool) Option {
+	return func(b *BackupManager) {
+		b.SequenceNames = sequence
+	}
+}
+
+// WithTags sets tags applied to every snapshot created.
+func WithTags(tags map[string]string) Option {
+	return func(b *BackupManager) {
+		b.Tags = tags
+	}
+}
+
+// WithTagAfterCreate tags snapshots once they exist, rather than as they're
+// created.
+func WithTagAfterCreate(after bool) Option {
+	return func(b *BackupManager) {
+		b.TagAfterCreate = after
+	}
+}
+
+// WithDryRun plans runs without creating anything. With compare, each
+// planned cluster's newest snapshot is looked up too.
+func WithDryRun(dryRun, compare bool) Option {
+	return func(b *BackupManager) {
+		b.DryRun = dryRun
+		b.CompareExisting = compare
+	}
+}
+
+// WithSourceRegion copies snapshots from region, in accountID, rather than
+// from the manager's own region.
+func WithSourceRegion(region, accountID string) Option {
+	return func(b *BackupManager) {
+		b.SourceRegion = region
+		b.AccountID = accountID
+	}
+}
+
+// WithRunID sets the run ID instead of generating one.
+func WithRunID(runID string) Option {
+	return func(b *BackupManager) {
+		b.RunID = runID
+	}
+}
+
+// WithCreatedBy sets the created-by tag on new snapshots and, if inName is
+// set, puts it in their identifiers too.
+func WithCreatedBy(createdBy string, inName bool) Option {
+	return func(b *BackupManager) {
+		b.CreatedBy = createdBy
+		b.CreatedByInName = inName
+	}
+}
+
+// WithOnlyCreatedBy restricts listing and pruning to snapshots with this
+// manager's created-by tag.
+func WithOnlyCreatedBy(only bool) Option {
+	return func(b *BackupManager) {
+		b.OnlyCreatedBy = only
+	}
+}
+
+// WithLogger sends the manager's log output to l instead of the standard logger.
+func WithLogger(l *log.Logger) Option {
+	return func(b *BackupManager) {
+		b.logger = l
+	}
+}
+
+// WithRetries sets how many times transient errors are retried. Pass a
+// negative number to disable retries.
+func WithRetries(n int) Option {
+	return func(b *BackupManager) {
+		b.MaxRetries = n
+	}
+}
+
+// WithMaxAttempts caps how many times each snapshot is tried in all.
+func WithMaxAttempts(n int) Option {
+	return func(b *BackupManager) {
+		b.MaxAttempts = n
+	}
+}
+
+// WithMaxBackoff caps the wait between retries.
+func WithMaxBackoff(d time.Duration) Option {
+	return func(b *BackupManager) {
+		b.MaxBackoff = d
+	}
+}
+
+// WithRetryBudget caps the retries across a whole run.
+func WithRetryBudget(budget int) Option {
+	return func(b *BackupManager) {
+		b.RetryBudget = budget
+	}
+}
+
+// WithAPICallBudget caps the RDS calls the manager makes.
+func WithAPICallBudget(budget int) Option {
+	return func(b *BackupManager) {
+		b.APICallBudget = budget
+	}
+}
+
+// WithContinueOnError keeps the batch going past unexpected errors.
+func WithContinueOnError(continueOnError bool) Option {
+	return func(b *BackupManager) {
+		b.ContinueOnError = continueOnError
+	}
+}
+
+// WithStrictIdentifiers makes empty cluster identifiers an error rather
+// than ignoring them.
+func WithStrictIdentifiers(strict bool) Option {
+	return func(b *BackupManager) {
+		b.StrictIdentifiers = strict
+	}
+}
+
+// WithSkipIfRecentWithin skips clusters with a snapshot younger than d.
+func WithSkipIfRecentWithin(d time.Duration) Option {
+	return func(b *BackupManager) {
+		b.SkipIfRecentWithin = d
+	}
+}
+
+// WithSkipExisting skips clusters whose snapshot already exists under the
+// new snapshot's name, reporting that snapshot's ARN.
+func WithSkipExisting(skip bool) Option {
+	return func(b *BackupManager) {
+		b.SkipExisting = skip
+	}
+}
+
+// WithRenameOnConflict retries snapshots whose name is already taken under
+// a fresh name.
+func WithRenameOnConflict(rename bool) Option {
+	return func(b *BackupManager) {
+		b.RenameOnConflict = rename
+	}
+}
+
+// WithTreatNotFoundAsFailure fails clusters that don't exist instead of
+// skipping them.
+func WithTreatNotFoundAsFailure(fail bool) Option {
+	return func(b *BackupManager) {
+		b.TreatNotFoundAsFailure = fail
+	}
+}
+
+// WithCatalog records every created snapshot in c.
+func WithCatalog(c Catalog) Option {
+	return func(b *BackupManager) {
+		b.Catalog = c
+	}
+}
+
+// WithVerbose turns on extra logging.
+func WithVerbose(verbose bool) Option {
+	return func(b *BackupManager) {
+		b.Verbose = verbose
+	}
+}
+
+// WithRedactKeys replaces the default key substrings whose tag values are
+// hidden in verbose logs.
+func WithRedactKeys(keys ...string) Option {
+	return func(b *BackupManager) {
+		b.RedactKeys = keys
+	}
+}
+
+// WithForce allows deleting snapshots of deletion-protected clusters.
+func WithForce(force bool) Option {
+	return func(b *BackupManager) {
+		b.Force = force
+	}
+}
+
+// WithAvoidMaintenance leaves clusters in their maintenance window out of
+// discovery.
+func WithAvoidMaintenance(avoid bool) Option {
+	return func(b *BackupManager) {
+		b.AvoidMaintenance = avoid
+	}
+}
+
+// WithOptOutTag makes key=value a tag that opts clusters out of discovery
+// and, with respect, out of runs that name them too.
+func WithOptOutTag(key, value string, respect bool) Option {
+	return func(b *BackupManager) {
+		b.OptOutTagKey = key
+		b.OptOutTagValue = value
+		b.RespectOptOut = respect
+	}
+}
+
+// WithClusterTags leaves clusters whose tags don't match expr out of
+// discovery.
+func WithClusterTags(expr TagExpr) Option {
+	return func(b *BackupManager) {
+		b.ClusterTags = expr
+	}
+}
+
+// WithMinEngineVersions sets the oldest engine versions discovery returns.
+func WithMinEngineVersions(minimums map[string]string) Option {
+	return func(b *BackupManager) {
+		b.MinEngineVersions = minimums
+	}
+}
+
+// WithOutputOrder sets the order TriggerSnapshots returns results in.
+func WithOutputOrder(order OutputOrder) Option {
+	return func(b *BackupManager) {
+		b.OutputOrder = order
+	}
+}
+
+// WithStartStopped starts stopped clusters to back them up, rather than
+// skipping them.
+func WithStartStopped(start bool) Option {
+	return func(b *BackupManager) {
+		b.StartStopped = start
+	}
+}
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/dishbreak/example-rds-backup/backuptest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewBackupManagerWithoutOptions(t *testing.T) {
+	type testCase struct {
+		newSnapshotTaker func() *flakySnapshotTaker
+	}
+
+	testCases := map[string]testCase{
+		"happy path with no errors": {
+			newSnapshotTaker: func() *flakySnapshotTaker { return NewFlakySnapshotTaker("", nil) },
+		},
+		"encounters cluster not found error": {
+			newSnapshotTaker: func() *flakySnapshotTaker {
+				return NewFlakySnapshotTaker("my-cluster-2", &types.DBClusterNotFoundFault{})
+			},
+		},
+		"encounters unexpected error": {
+			newSnapshotTaker: func() *flakySnapshotTaker {
+				return NewFlakySnapshotTaker("my-cluster-2", &types.DBClusterSnapshotAlreadyExistsFault{})
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			clusterIDs := []string{"my-cluster-1", "my-cluster-2", "my-cluster-3"}
+
+			structST := tc.newSnapshotTaker()
+			structBM := &BackupManager{st: structST, now: func() time.Time { return testNow }}
+			structResults, structErr := structBM.TriggerSnapshots(context.TODO(), clusterIDs...)
+
+			optsST := tc.newSnapshotTaker()
+			optsBM := NewBackupManager(optsST)
+			optsBM.now = func() time.Time { return testNow }
+			optsResults, optsErr := optsBM.TriggerSnapshots(context.TODO(), clusterIDs...)
+
+			assert.Equal(t, structErr, optsErr)
+			assert.Equal(t, structResults, optsResults)
+			assert.Equal(t, structST.GetJournal(), optsST.GetJournal())
+		})
+	}
+}
+
+func TestNewBackupManagerOptions(t *testing.T) {
+	logger := log.New(&bytes.Buffer{}, "", 0)
+	st := NewFakeSnapshotTaker()
+	bm := NewBackupManager(st,
+		WithPrefix("testing"),
+		WithReadPrefixes("testing", "legacy"),
+		WithConcurrency(4),
+		WithTags(map[string]string{"env": "prod"}),
+		WithLogger(logger),
+		WithRetries(5),
+		WithContinueOnError(true),
+		WithSkipIfRecentWithin(time.Hour),
+		WithVerbose(true),
+		WithForce(true),
+		WithMinEngineVersions(map[string]string{"aurora-postgresql": "13.7"}),
+	)
+
+	assert.Equal(t, &BackupManager{
+		st:                 st,
+		ist:                st,
+		isd:                st,
+		sd:                 st,
+		del:                st,
+		cp:                 st,
+		cd:                 st,
+		gcd:                st,
+		tl:                 st,
+		ta:                 st,
+		rs:                 st,
+		ic:                 st,
+		cs:                 st,
+		prefix:             "testing",
+		prefixSet:          true,
+		logger:             logger,
+		ReadPrefixes:       []string{"testing", "legacy"},
+		SkipIfRecentWithin: time.Hour,
+		MaxRetries:         5,
+		ContinueOnError:    true,
+		Concurrency:        4,
+		Tags:               map[string]string{"env": "prod"},
+		Verbose:            true,
+		Force:              true,
+		MinEngineVersions:  map[string]string{"aurora-postgresql": "13.7"},
+	}, bm)
+}
+
+func TestTriggerSnapshotsConcurrently(t *testing.T) {
+	clusterIDs := make([]string, 0, 20)
+	expectedJournal := make([]snapshotCreationRecord, 0, 20)
+	expectedResults := make([]SnapshotResult, 0, 20)
+	for i := 0; i < 20; i++ {
+		clusterID := fmt.Sprintf("my-cluster-%d", i)
+		clusterIDs = append(clusterIDs, clusterID)
+		expectedJournal = append(expectedJournal, snapshotCreationRecord{clusterID, "testing-" + clusterID})
+		expectedResults = append(expectedResults, SnapshotResult{
+			ClusterIdentifier:  clusterID,
+			SnapshotIdentifier: "testing-" + clusterID,
+			Status:             StatusCreated,
+		})
+	}
+
+	st := NewFakeSnapshotTaker()
+	bm := NewBackupManager(st, WithPrefix("testing"), WithConcurrency(4))
+	bm.now = func() time.Time { return testNow }
+
+	results, err := bm.TriggerSnapshots(context.TODO(), clusterIDs...)
+	assert.Nil(t, err)
+	assert.Equal(t, expectedResults, results)
+	assert.ElementsMatch(t, expectedJournal, st.GetJournal())
+}
+
+func TestWorkerCount(t *testing.T) {
+	type testCase struct {
+		bm       *BackupManager
+		clusters int
+		expected int
+	}
+
+	testCases := map[string]testCase{
+		"concurrency": {
+			bm:       &BackupManager{Concurrency: 4},
+			clusters: 10,
+			expected: 4,
+		},
+		"more concurrency than clusters": {
+			bm:       &BackupManager{Concurrency: 50},
+			clusters: 3,
+			expected: 3,
+		},
+		"no concurrency": {
+			bm:       &BackupManager{},
+			clusters: 3,
+			expected: 1,
+		},
+		"weight budget": {
+			bm:       &BackupManager{WeightBudget: 100},
+			clusters: 3,
+			expected: 3,
+		},
+		"auto concurrency": {
+			bm:       &BackupManager{Concurrency: 2, AutoConcurrency: 8},
+			clusters: 10,
+			expected: 8,
+		},
+		"more auto concurrency than clusters": {
+			bm:       &BackupManager{AutoConcurrency: 8},
+			clusters: 2,
+			expected: 2,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.bm.workerCount(tc.clusters))
+		})
+	}
+}
+
+func TestTriggerSnapshotsClampsConcurrency(t *testing.T) {
+	var buf bytes.Buffer
+	st := NewFakeSnapshotTaker()
+	bm := NewBackupManager(st, WithPrefix("testing"), WithConcurrency(50), WithVerbose(true), WithLogger(log.New(&buf, "", 0)))
+
+	results, err := bm.TriggerSnapshots(context.TODO(), "my-cluster-1", "my-cluster-2", "my-cluster-3")
+	assert.Nil(t, err)
+	assert.Len(t, results, 3)
+	assert.Contains(t, buf.String(), "Only starting 3 worker(s) for 3 cluster(s), not 50.\n")
+}
+
+func TestTriggerSnapshotsInChunks(t *testing.T) {
+	type pause struct {
+		after int
+		d     time.Duration
+	}
+
+	st := NewFakeSnapshotTaker()
+	bm := NewBackupManager(st, WithPrefix("testing"), WithConcurrency(2), WithChunks(2, 30*time.Second))
+	var pauses []pause
+	bm.sleep = func(ctx context.Context, d time.Duration) error {
+		// every cluster in the chunk is done by the time it pauses
+		pauses = append(pauses, pause{len(st.GetJournal()), d})
+		return nil
+	}
+
+	results, err := bm.TriggerSnapshots(context.TODO(), "my-cluster-1", "my-cluster-2", "my-cluster-3", "my-cluster-4", "my-cluster-5")
+	assert.Nil(t, err)
+	assert.Len(t, results, 5)
+	assert.Equal(t, []pause{{2, 30 * time.Second}, {4, 30 * time.Second}}, pauses)
+	assert.ElementsMatch(t, []snapshotCreationRecord{
+		{"my-cluster-1", "testing-my-cluster-1"},
+		{"my-cluster-2", "testing-my-cluster-2"},
+	}, st.GetJournal()[:2])
+
+	// a cancelled pause ends the batch there
+	st = NewFakeSnapshotTaker()
+	bm = NewBackupManager(st, WithPrefix("testing"), WithChunks(2, time.Hour))
+	ctx, cancel := context.WithCancel(context.TODO())
+	bm.sleep = func(ctx context.Context, d time.Duration) error {
+		cancel()
+		return ctx.Err()
+	}
+	results, err = bm.TriggerSnapshots(ctx, "my-cluster-1", "my-cluster-2", "my-cluster-3")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Len(t, results, 2)
+	assert.Len(t, st.GetJournal(), 2)
+}
+
+func TestTriggerSnapshotsLargeConcurrentBatch(t *testing.T) {
+	const n = 500
+	// clusters take a random few milliseconds each, so they finish in a
+	// different order to the one they were given in
+	rng := rand.New(rand.NewSource(1))
+	st := &backuptest.CancellableSnapshotTaker{Delays: make(map[string]time.Duration, n)}
+	clusterIDs := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		clusterID := fmt.Sprintf("my-cluster-%d", i)
+		clusterIDs = append(clusterIDs, clusterID)
+		st.Delays[clusterID] = time.Duration(rng.Intn(3000)) * time.Microsecond
+	}
+	bm := &BackupManager{st: st, prefix: "testing", Concurrency: 32}
+
+	results, err := bm.TriggerSnapshots(context.TODO(), clusterIDs...)
+	assert.Nil(t, err)
+	assert.Equal(t, RunStats{Created: n}, bm.Stats())
+
+	if assert.Len(t, results, n) {
+		for i, result := range results {
+			assert.Equal(t, clusterIDs[i], result.ClusterIdentifier)
+			assert.Equal(t, StatusCreated, result.Status)
+		}
+	}
+
+	calls := st.Calls()
+	assert.Len(t, calls, n)
+	seen := make(map[string]bool, n)
+	for _, call := range calls {
+		assert.False(t, seen[call.DBClusterIdentifier], "'%s' was snapshotted twice", call.DBClusterIdentifier)
+		seen[call.DBClusterIdentifier] = true
+	}
+}
+
+func TestTriggerSnapshotsWithTags(t *testing.T) {
+	st := NewFakeSnapshotTaker()
+	bm := NewBackupManager(st,
+		WithPrefix("testing"),
+		WithTags(map[string]string{"team": "payments", "env": "prod"}),
+		WithRunID("run-1"),
+	)
+	bm.now = func() time.Time { return testNow }
+
+	_, err := bm.TriggerSnapshots(context.TODO(), "my-cluster-1")
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Tag{
+		{Key: aws.String("created-at"), Value: aws.String("2022-03-15T12:00:00Z")},
+		{Key: aws.String("created-by"), Value: aws.String("example-rds-backup")},
+		{Key: aws.String("env"), Value: aws.String("prod")},
+		{Key: aws.String("run-id"), Value: aws.String("run-1")},
+		{Key: aws.String("team"), Value: aws.String("payments")},
+	}, st.tags["testing-my-cluster-1"])
+}
+
+func TestTriggerSnapshotsGeneratesRunID(t *testing.T) {
+	st := NewFakeSnapshotTaker()
+	bm := NewBackupManager(st, WithPrefix("testing"))
+	bm.now = func() time.Time { return testNow }
+
+	_, err := bm.TriggerSnapshots(context.TODO(), "my-cluster-1", "my-cluster-2")
+	assert.Nil(t, err)
+	assert.NotEmpty(t, bm.RunID)
+	for _, snapshotID := range []string{"testing-my-cluster-1", "testing-my-cluster-2"} {
+		assert.Equal(t, []types.Tag{
+			{Key: aws.String("created-at"), Value: aws.String("2022-03-15T12:00:00Z")},
+			{Key: aws.String("created-by"), Value: aws.String("example-rds-backup")},
+			{Key: aws.String("run-id"), Value: aws.String(bm.RunID)},
+		}, st.tags[snapshotID])
+	}
+
+	// the run's snapshots can be found again by selecting on the tag
+	st.snapshots = []types.DBClusterSnapshot{
+		existingSnapshot("my-cluster-1", "testing-my-cluster-1", testNow),
+		existingSnapshot("my-cluster-2", "testing-my-cluster-2", testNow),
+		existingSnapshot("my-cluster-1", "testing-my-cluster-1-earlier", testNow),
+	}
+	st.snapshots[0].TagList = st.tags["testing-my-cluster-1"]
+	st.snapshots[1].TagList = st.tags["testing-my-cluster-2"]
+	bm.TagSelector = map[string]string{"run-id": bm.RunID}
+	snapshots, err := bm.ListSnapshots(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"testing-my-cluster-1", "testing-my-cluster-2"}, snapshotIDs(snapshots))
+}
+
+func TestUserTagsWinOverAutomaticTags(t *testing.T) {
+	bm := NewBackupManager(NewFakeSnapshotTaker(),
+		WithTags(map[string]string{"run-id": "mine", "created-at": "yesterday", "created-by": "someone"}),
+		WithRunID("generated"),
+		WithCreatedBy("nightly-backup", false),
+	)
+	assert.Equal(t, []types.Tag{
+		{Key: aws.String("created-at"), Value: aws.String("yesterday")},
+		{Key: aws.String("created-by"), Value: aws.String("someone")},
+		{Key: aws.String("run-id"), Value: aws.String("mine")},
+	}, bm.snapshotTags())
+}
+
+func TestCreatedBy(t *testing.T) {
+	st := NewFakeSnapshotTaker()
+	bm := NewBackupManager(st,
+		WithPrefix("testing"),
+		WithRunID("run-1"),
+		WithCreatedBy("nightly", true),
+	)
+	bm.now = func() time.Time { return testNow }
+
+	results, err := bm.TriggerSnapshots(context.TODO(), "my-cluster-1")
+	assert.Nil(t, err)
+	assert.Equal(t, "testing-nightly-my-cluster-1", results[0].SnapshotIdentifier)
+	assert.Equal(t, []types.Tag{
+		{Key: aws.String("created-at"), Value: aws.String("2022-03-15T12:00:00Z")},
+		{Key: aws.String("created-by"), Value: aws.String("nightly")},
+		{Key: aws.String("run-id"), Value: aws.String("run-1")},
+	}, st.tags["testing-nightly-my-cluster-1"])
+
+	// with OnlyCreatedBy, snapshots under the prefix from anything else are
+	// left alone
+	st.snapshots = []types.DBClusterSnapshot{
+		tagged(existingSnapshot("my-cluster-1", "testing-nightly-my-cluster-1", testNow), map[string]string{"created-by": "nightly"}),
+		tagged(existingSnapshot("my-cluster-1", "testing-my-cluster-1", testNow), map[string]string{"created-by": "someone-else"}),
+		existingSnapshot("my-cluster-1", "testing-my-cluster-1-untagged", testNow),
+	}
+	snapshots, err := bm.ListSnapshots(context.TODO())
+	assert.Nil(t, err)
+	assert.Len(t, snapshots, 3)
+
+	bm.OnlyCreatedBy = true
+	snapshots, err = bm.ListSnapshots(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"testing-nightly-my-cluster-1"}, snapshotIDs(snapshots))
+
+	// it also gives SelectByTagsOnly something to select on
+	bm.SelectByTagsOnly = true
+	snapshots, err = bm.ListSnapshots(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"testing-nightly-my-cluster-1"}, snapshotIDs(snapshots))
+}
+
+func TestTriggerSnapshotsNotFoundAsFailure(t *testing.T) {
+	st := NewFlakySnapshotTaker("my-cluster-2", &types.DBClusterNotFoundFault{})
+	bm := NewBackupManager(st, WithPrefix("testing"), WithTreatNotFoundAsFailure(true), WithContinueOnError(true))
+	bm.now = func() time.Time { return testNow }
+
+	results, err := bm.TriggerSnapshots(context.TODO(), "my-cluster-1", "my-cluster-2", "my-cluster-3")
+	assert.ErrorIs(t, err, ErrSnapshotsFailed)
+	assert.EqualError(t, err, "1 of 3 clusters: failed to snapshot some clusters")
+	assert.Len(t, results, 3)
+	assert.Equal(t, StatusFailed, results[1].Status)
+	assert.ErrorIs(t, results[1].Err, ErrClusterNotFound)
+	assert.Equal(t, RunStats{Created: 2, Failed: 1}, bm.Stats())
+	// the rest of the batch still ran
+	assert.Equal(t, []snapshotCreationRecord{
+		{"my-cluster-1", "testing-my-cluster-1"},
+		{"my-cluster-3", "testing-my-cluster-3"},
+	}, st.GetJournal())
+}
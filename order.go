@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+const ErrInvalidOutputOrder BackupManagerError = "output order must be input or sorted"
+
+// OutputOrder is the order a batch's results are returned and printed in.
+type OutputOrder string
+
+const (
+	// OrderInput keeps results in the order their clusters were given.
+	OrderInput OutputOrder = "input"
+	// OrderSorted sorts results by cluster identifier, so the same clusters
+	// always come out the same way, however they were given or finished.
+	OrderSorted OutputOrder = "sorted"
+)
+
+// validateOutputOrder checks order is one we know. Empty means OrderInput.
+func validateOutputOrder(order OutputOrder) error {
+	switch order {
+	case "", OrderInput, OrderSorted:
+		return nil
+	}
+	return fmt.Errorf("'%s': %w", order, ErrInvalidOutputOrder)
+}
+
+func (o *OutputOrder) String() string {
+	return string(*o)
+}
+
+func (o *OutputOrder) Set(s string) error {
+	if err := validateOutputOrder(OutputOrder(s)); err != nil {
+		return err
+	}
+	*o = OutputOrder(s)
+	return nil
+}
+
+// orderResults puts results in order. Sorting is stable, so results for the
+// same cluster, like its instances' snapshots, keep the order they were in.
+func orderResults(results []SnapshotResult, order OutputOrder) []SnapshotResult {
+	if order == OrderSorted {
+		sort.SliceStable(results, func(i, j int) bool {
+			return results[i].ClusterIdentifier < results[j].ClusterIdentifier
+		})
+	}
+	return results
+}
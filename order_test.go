@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/stretchr/testify/assert"
+)
+
+// slowSnapshotTaker takes longer over some clusters than others, so a
+// concurrent batch finishes them out of order.
+type slowSnapshotTaker struct {
+	*fakeSnapshotTaker
+	delays map[string]time.Duration
+}
+
+func (s *slowSnapshotTaker) CreateDBClusterSnapshot(ctx context.Context, in *rds.CreateDBClusterSnapshotInput, optFns ...func(*rds.Options)) (*rds.CreateDBClusterSnapshotOutput, error) {
+	time.Sleep(s.delays[*in.DBClusterIdentifier])
+	return s.fakeSnapshotTaker.CreateDBClusterSnapshot(ctx, in, optFns...)
+}
+
+func TestOutputOrder(t *testing.T) {
+	clusters := make([]string, 0, 20)
+	for i := 0; i < 20; i++ {
+		clusters = append(clusters, fmt.Sprintf("my-cluster-%02d", i))
+	}
+	random := rand.New(rand.NewSource(1))
+	random.Shuffle(len(clusters), func(i, j int) { clusters[i], clusters[j] = clusters[j], clusters[i] })
+	sorted := append([]string(nil), clusters...)
+	sort.Strings(sorted)
+
+	type testCase struct {
+		order    OutputOrder
+		expected []string
+	}
+
+	testCases := map[string]testCase{
+		"default is input order": {
+			expected: clusters,
+		},
+		"input": {
+			order:    OrderInput,
+			expected: clusters,
+		},
+		"sorted": {
+			order:    OrderSorted,
+			expected: sorted,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			// the first clusters given are the slowest, so they finish last
+			delays := make(map[string]time.Duration, len(clusters))
+			for i, cluster := range clusters {
+				delays[cluster] = time.Duration(len(clusters)-i) * time.Millisecond
+			}
+			st := &slowSnapshotTaker{fakeSnapshotTaker: NewFakeSnapshotTaker(), delays: delays}
+			bm := NewBackupManager(st, WithPrefix("testing"), WithConcurrency(8), WithOutputOrder(tc.order))
+
+			results, err := bm.TriggerSnapshots(context.TODO(), clusters...)
+			assert.Nil(t, err)
+			identifiers := make([]string, 0, len(results))
+			for _, result := range results {
+				identifiers = append(identifiers, result.ClusterIdentifier)
+			}
+			assert.Equal(t, tc.expected, identifiers)
+		})
+	}
+}
+
+func TestOrderResultsIsStable(t *testing.T) {
+	results := []SnapshotResult{
+		{ClusterIdentifier: "my-cluster-2", InstanceIdentifier: "instance-b"},
+		{ClusterIdentifier: "my-cluster-1"},
+		{ClusterIdentifier: "my-cluster-2", InstanceIdentifier: "instance-a"},
+	}
+	assert.Equal(t, []SnapshotResult{
+		{ClusterIdentifier: "my-cluster-1"},
+		{ClusterIdentifier: "my-cluster-2", InstanceIdentifier: "instance-b"},
+		{ClusterIdentifier: "my-cluster-2", InstanceIdentifier: "instance-a"},
+	}, orderResults(results, OrderSorted))
+}
+
+func TestOutputOrderFlag(t *testing.T) {
+	var order OutputOrder
+	assert.Nil(t, order.Set("sorted"))
+	assert.Equal(t, OrderSorted, order)
+	assert.ErrorIs(t, order.Set("by-size"), ErrInvalidOutputOrder)
+	assert.Equal(t, OrderSorted, order)
+}
+
+func TestInvalidOutputOrder(t *testing.T) {
+	bm := NewBackupManager(NewFakeSnapshotTaker(), WithOutputOrder("reversed"))
+	_, err := bm.TriggerSnapshots(context.TODO(), "my-cluster-1")
+	assert.ErrorIs(t, err, ErrInvalidOutputOrder)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+)
+
+// FindOrphanedSnapshots returns the snapshots from ListSnapshots whose
+// cluster no longer exists, going by every cluster the ClusterDescriber can
+// see. A copy's cluster is usually in another region, so copies are never
+// counted as orphaned. Nothing is deleted.
+func (b *BackupManager) FindOrphanedSnapshots(ctx context.Context) ([]types.DBClusterSnapshot, error) {
+	if b.cd == nil {
+		return nil, ErrNoClusterDescriber
+	}
+	snapshots, err := b.ListSnapshots(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// a cluster we couldn't see would leave its snapshots looking orphaned,
+	// so one failed page fails the lot
+	clusters := make(map[string]bool)
+	paginator := rds.NewDescribeDBClustersPaginator(b.cd, &rds.DescribeDBClustersInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, cluster := range page.DBClusters {
+			clusters[aws.ToString(cluster.DBClusterIdentifier)] = true
+		}
+	}
+
+	orphans := make([]types.DBClusterSnapshot, 0)
+	for _, snapshot := range snapshots {
+		if snapshot.SourceDBClusterSnapshotArn != nil || clusters[aws.ToString(snapshot.DBClusterIdentifier)] {
+			continue
+		}
+		orphans = append(orphans, snapshot)
+	}
+	return orphans, nil
+}
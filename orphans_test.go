@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// pagedClusterDescriber hands out the fake's clusters one page at a time,
+// and can fail the page after the first.
+type pagedClusterDescriber struct {
+	*fakeSnapshotTaker
+	failSecondPage bool
+}
+
+func (p *pagedClusterDescriber) DescribeDBClusters(ctx context.Context, in *rds.DescribeDBClustersInput, optFns ...func(*rds.Options)) (*rds.DescribeDBClustersOutput, error) {
+	if in.Marker == nil {
+		return &rds.DescribeDBClustersOutput{DBClusters: p.clusters[:1], Marker: aws.String("page-2")}, nil
+	}
+	if p.failSecondPage {
+		return nil, errors.New("connection reset")
+	}
+	return &rds.DescribeDBClustersOutput{DBClusters: p.clusters[1:]}, nil
+}
+
+func TestFindOrphanedSnapshots(t *testing.T) {
+	type testCase struct {
+		failSecondPage bool
+		expected       []string
+		expectErr      bool
+	}
+
+	testCases := map[string]testCase{
+		"snapshots of deleted clusters": {
+			expected: []string{"testing-deleted-cluster-old", "testing-deleted-cluster-new"},
+		},
+		"a failed page": {
+			failSecondPage: true,
+			expectErr:      true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			copied := existingSnapshot("cluster-in-another-region", "testing-cluster-in-another-region-copy", testNow.Add(-time.Hour))
+			copied.SourceDBClusterSnapshotArn = aws.String("arn:aws:rds:eu-west-1:123456789012:cluster-snapshot:testing-cluster-in-another-region")
+			st := NewFakeSnapshotTakerWithSnapshots(
+				existingSnapshot("my-cluster-1", "testing-my-cluster-1", testNow.Add(-time.Hour)),
+				existingSnapshot("deleted-cluster", "testing-deleted-cluster-old", testNow.Add(-48*time.Hour)),
+				existingSnapshot("my-cluster-2", "testing-my-cluster-2", testNow.Add(-time.Hour)),
+				existingSnapshot("deleted-cluster", "testing-deleted-cluster-new", testNow.Add(-24*time.Hour)),
+				existingSnapshot("deleted-cluster", "someone-elses-deleted-cluster", testNow.Add(-24*time.Hour)),
+				copied,
+			)
+			st.clusters = []types.DBCluster{existingCluster("my-cluster-1"), existingCluster("my-cluster-2")}
+			cd := &pagedClusterDescriber{fakeSnapshotTaker: st, failSecondPage: tc.failSecondPage}
+			bm := NewBackupManager(cd, WithPrefix("testing"))
+
+			orphans, err := bm.FindOrphanedSnapshots(context.TODO())
+			if tc.expectErr {
+				assert.Error(t, err)
+				assert.Nil(t, orphans)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, snapshotIDs(orphans))
+			assert.Empty(t, st.deleted)
+		})
+	}
+}
+
+func TestFindOrphanedSnapshotsWithoutDescriber(t *testing.T) {
+	st := NewFakeSnapshotTaker()
+	bm := NewBackupManager(st)
+	bm.cd = nil
+	_, err := bm.FindOrphanedSnapshots(context.TODO())
+	assert.ErrorIs(t, err, ErrNoClusterDescriber)
+}
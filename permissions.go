@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/smithy-go"
+)
+
+const ErrMissingPermission BackupManagerError = "missing permission"
+
+// permissionCheckIdentifier names the cluster and snapshot the preflight asks
+// to create. A double hyphen isn't allowed in RDS identifiers, so no cluster
+// can have it and the call can never create anything.
+const permissionCheckIdentifier = programName + "--permission-check"
+
+// accessDeniedCodes are the error codes AWS answers with when IAM turns a
+// call down.
+var accessDeniedCodes = map[string]bool{
+	"AccessDenied":          true,
+	"AccessDeniedException": true,
+	"UnauthorizedOperation": true,
+}
+
+// isAccessDenied reports whether a call failed because the caller isn't
+// allowed to make it.
+func isAccessDenied(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && accessDeniedCodes[apiErr.ErrorCode()]
+}
+
+// DeniedSnapshotError explains a snapshot of a cluster being turned down by
+// IAM. The role may well be allowed to snapshot other clusters, so it names
+// the actions and the resource a policy has to let through. It's an
+// ErrMissingPermission, and unwraps to the error AWS answered with.
+type DeniedSnapshotError struct {
+	ClusterIdentifier string
+	Actions           string
+	Err               error
+}
+
+func (e *DeniedSnapshotError) Error() string {
+	return fmt.Sprintf("%s: %s on cluster '%s' and its snapshot: %v", ErrMissingPermission, e.Actions, e.ClusterIdentifier, e.Err)
+}
+
+func (e *DeniedSnapshotError) Is(target error) bool {
+	return target == ErrMissingPermission
+}
+
+func (e *DeniedSnapshotError) Unwrap() error {
+	return e.Err
+}
+
+// deniedSnapshot explains a denied snapshot, including tagging in the
+// actions if the snapshot was created with tags.
+func deniedSnapshot(clusterIdentifier string, tagged bool, err error) error {
+	actions := "rds:CreateDBClusterSnapshot"
+	if tagged {
+		actions += " and rds:AddTagsToResource"
+	}
+	return &DeniedSnapshotError{ClusterIdentifier: clusterIdentifier, Actions: actions, Err: err}
+}
+
+// permissionCheck is a call that's only allowed with IAM permission for
+// action.
+type permissionCheck struct {
+	action string
+	call   func() error
+}
+
+// VerifyPermissions makes a harmless call for each RDS action a backup needs,
+// so a role that's missing one fails before a big batch starts, not partway
+// through it. Describe calls ask for a single page; creating a snapshot is
+// tried against a cluster that can't exist, which IAM still has to allow
+// before RDS says it isn't there. Only access being denied fails the check;
+// any other error is left for the run itself to hit.
+func (b *BackupManager) VerifyPermissions(ctx context.Context) error {
+	checks := []permissionCheck{
+		{"rds:CreateDBClusterSnapshot", func() error {
+			_, err := b.st.CreateDBClusterSnapshot(ctx, &rds.CreateDBClusterSnapshotInput{
+				DBClusterIdentifier:         aws.String(permissionCheckIdentifier),
+				DBClusterSnapshotIdentifier: aws.String(permissionCheckIdentifier),
+			})
+			return err
+		}},
+	}
+	if b.sd != nil {
+		checks = append(checks, permissionCheck{"rds:DescribeDBClusterSnapshots", func() error {
+			_, err := b.sd.DescribeDBClusterSnapshots(ctx, &rds.DescribeDBClusterSnapshotsInput{MaxRecords: aws.Int32(20)})
+			return err
+		}})
+	}
+	if b.cd != nil {
+		checks = append(checks, permissionCheck{"rds:DescribeDBClusters", func() error {
+			_, err := b.cd.DescribeDBClusters(ctx, &rds.DescribeDBClustersInput{MaxRecords: aws.Int32(20)})
+			return err
+		}})
+	}
+
+	for _, check := range checks {
+		err := check.call()
+		if isAccessDenied(err) {
+			return fmt.Errorf("%w: %s: %v", ErrMissingPermission, check.action, err)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		b.debugf("Permission check for %s passed.", check.action)
+	}
+	b.logf("Verified permissions for %d RDS action(s).", len(checks))
+	return nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// denyingSnapshotTaker turns down snapshot creation the way IAM does, or
+// answers that the cluster doesn't exist the way RDS does once IAM has let
+// the call through.
+type denyingSnapshotTaker struct {
+	*fakeSnapshotTaker
+	denyCreate   bool
+	denyDescribe bool
+}
+
+func (d *denyingSnapshotTaker) CreateDBClusterSnapshot(ctx context.Context, in *rds.CreateDBClusterSnapshotInput, optFns ...func(*rds.Options)) (*rds.CreateDBClusterSnapshotOutput, error) {
+	if d.denyCreate {
+		return nil, &smithy.GenericAPIError{Code: "AccessDenied", Message: "not authorized to perform: rds:CreateDBClusterSnapshot"}
+	}
+	return nil, &types.DBClusterNotFoundFault{}
+}
+
+func (d *denyingSnapshotTaker) DescribeDBClusters(ctx context.Context, in *rds.DescribeDBClustersInput, optFns ...func(*rds.Options)) (*rds.DescribeDBClustersOutput, error) {
+	if d.denyDescribe {
+		return nil, &smithy.GenericAPIError{Code: "AccessDenied", Message: "not authorized to perform: rds:DescribeDBClusters"}
+	}
+	return d.fakeSnapshotTaker.DescribeDBClusters(ctx, in, optFns...)
+}
+
+func TestVerifyPermissions(t *testing.T) {
+	type testCase struct {
+		denyCreate   bool
+		denyDescribe bool
+		expected     string
+	}
+
+	testCases := map[string]testCase{
+		"everything allowed": {},
+		"can't create snapshots": {
+			denyCreate: true,
+			expected:   "missing permission: rds:CreateDBClusterSnapshot",
+		},
+		"can't describe clusters": {
+			denyDescribe: true,
+			expected:     "missing permission: rds:DescribeDBClusters",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			fake := NewFakeSnapshotTaker()
+			bm := NewBackupManager(&denyingSnapshotTaker{fake, tc.denyCreate, tc.denyDescribe})
+
+			err := bm.VerifyPermissions(context.TODO())
+			if tc.expected == "" {
+				assert.Nil(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, ErrMissingPermission)
+			assert.Contains(t, err.Error(), tc.expected)
+		})
+	}
+}
+
+func TestVerifyPermissionsOnlyChecksWhatsWired(t *testing.T) {
+	// without a describer, only creating snapshots is checked
+	fake := NewFakeSnapshotTaker()
+	bm := NewBackupManager(&denyingSnapshotTaker{fake, false, true})
+	bm.sd, bm.cd = nil, nil
+	assert.Nil(t, bm.VerifyPermissions(context.TODO()))
+}
+
+func TestDeniedSnapshot(t *testing.T) {
+	// a resource-level policy lets every cluster but one through
+	denied := &smithy.GenericAPIError{Code: "AccessDeniedException", Message: "not authorized to perform: rds:CreateDBClusterSnapshot"}
+	bm := NewBackupManager(NewFlakySnapshotTaker("my-cluster-2", denied), WithPrefix("testing"), WithContinueOnError(true))
+
+	results, err := bm.TriggerSnapshots(context.TODO(), "my-cluster-1", "my-cluster-2", "my-cluster-3")
+	assert.Error(t, err)
+	assert.Len(t, results, 3)
+	assert.Equal(t, StatusCreated, results[0].Status)
+	assert.Equal(t, StatusCreated, results[2].Status)
+
+	assert.Equal(t, StatusFailed, results[1].Status)
+	assert.ErrorIs(t, results[1].Err, ErrMissingPermission)
+	assert.EqualError(t, results[1].Err, "missing permission: rds:CreateDBClusterSnapshot and rds:AddTagsToResource on cluster 'my-cluster-2' and its snapshot: "+denied.Error())
+	// AWS's answer is still there to be checked
+	var apiErr smithy.APIError
+	if assert.ErrorAs(t, results[1].Err, &apiErr) {
+		assert.Equal(t, "AccessDeniedException", apiErr.ErrorCode())
+	}
+	assert.ErrorIs(t, results[1].Err, denied)
+}
+
+func TestDeniedSnapshotUntagged(t *testing.T) {
+	err := deniedSnapshot("my-cluster-1", false, errors.New("denied"))
+	assert.ErrorIs(t, err, ErrMissingPermission)
+	assert.EqualError(t, err, "missing permission: rds:CreateDBClusterSnapshot on cluster 'my-cluster-1' and its snapshot: denied")
+}
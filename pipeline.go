@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+const ErrPipelinesFailed BackupManagerError = "failed to snapshot and copy some clusters"
+
+// PipelineStage is how far a cluster got through SnapshotAndCopy.
+type PipelineStage string
+
+const (
+	StageCreate PipelineStage = "create"
+	StageWait   PipelineStage = "wait"
+	StageCopy   PipelineStage = "copy"
+	StageDone   PipelineStage = "done"
+)
+
+// PipelineResult records one cluster's way through SnapshotAndCopy. Stage is
+// the last stage it reached: where it failed if Err is set, otherwise done,
+// or create if the snapshot was skipped and there was nothing to copy.
+type PipelineResult struct {
+	Snapshot SnapshotResult
+	Copy     *CopyResult
+	Stage    PipelineStage
+	Err      error
+}
+
+// PipelineError pins a SnapshotAndCopy failure on the cluster and the stage
+// it happened at.
+type PipelineError struct {
+	ClusterIdentifier string
+	Stage             PipelineStage
+	Err               error
+}
+
+func (e *PipelineError) Error() string {
+	return fmt.Sprintf("cluster '%s' failed at %s: %v", e.ClusterIdentifier, e.Stage, e.Err)
+}
+
+func (e *PipelineError) Unwrap() error {
+	return e.Err
+}
+
+// SnapshotAndCopy snapshots each of the given clusters, waits for each
+// snapshot to be available and copies it with dest, which is usually a
+// manager in another region with this one as its SourceRegion. Clusters go
+// through as TriggerSnapshots would create them, Concurrency and all, and
+// each one's snapshot is waited for and copied as soon as it's created,
+// without holding up the rest. Skipped clusters aren't copied. Without
+// ContinueOnError, the first failure at any stage stops new clusters from
+// starting.
+func (b *BackupManager) SnapshotAndCopy(ctx context.Context, dest *BackupManager, kmsKeyID string, clusterIdentifers ...string) ([]PipelineResult, error) {
+	if b.sd == nil {
+		return nil, ErrNoSnapshotDescriber
+	}
+	if dest.cp == nil {
+		return nil, ErrNoSnapshotCopier
+	}
+	if dest.SourceRegion != "" {
+		if err := validateRegion(dest.SourceRegion); err != nil {
+			return nil, err
+		}
+	}
+
+	// a failed copy cancels the batch just as a failed snapshot does
+	pipelineCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// each result is picked up as it's streamed, and passed on to anyone
+	// else who was listening
+	created := make(chan SnapshotResult)
+	forward := b.Results
+	b.Results = created
+	defer func() { b.Results = forward }()
+
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		copied = make(map[string]PipelineResult)
+		done   = make(chan struct{})
+	)
+	go func() {
+		defer close(done)
+		for result := range created {
+			if forward != nil {
+				forward <- result
+			}
+			if result.Status != StatusCreated {
+				continue
+			}
+			wg.Add(1)
+			go func(result SnapshotResult) {
+				defer wg.Done()
+				p := b.waitAndCopy(pipelineCtx, dest, kmsKeyID, result)
+				if p.Err != nil && !b.ContinueOnError {
+					cancel()
+				}
+				mu.Lock()
+				copied[result.ClusterIdentifier] = p
+				mu.Unlock()
+			}(result)
+		}
+	}()
+
+	snapshots, err := b.TriggerSnapshots(pipelineCtx, clusterIdentifers...)
+	close(created)
+	<-done
+	wg.Wait()
+
+	results := make([]PipelineResult, 0, len(snapshots))
+	var firstErr error
+	failed := 0
+	for _, snapshot := range snapshots {
+		p, ok := copied[snapshot.ClusterIdentifier]
+		if !ok {
+			p = PipelineResult{Snapshot: snapshot, Stage: StageCreate}
+			if snapshot.Err != nil {
+				p.Err = &PipelineError{ClusterIdentifier: snapshot.ClusterIdentifier, Stage: StageCreate, Err: snapshot.Err}
+			}
+		}
+		results = append(results, p)
+		if p.Err != nil {
+			failed++
+			if firstErr == nil {
+				firstErr = p.Err
+			}
+		}
+	}
+
+	switch {
+	case b.ContinueOnError && failed > 0:
+		return results, fmt.Errorf("%d of %d clusters: %w", failed, len(clusterIdentifers), ErrPipelinesFailed)
+	case firstErr != nil:
+		return results, firstErr
+	}
+	return results, err
+}
+
+// waitAndCopy takes a newly created snapshot through the rest of the
+// pipeline.
+func (b *BackupManager) waitAndCopy(ctx context.Context, dest *BackupManager, kmsKeyID string, snapshot SnapshotResult) PipelineResult {
+	p := PipelineResult{Snapshot: snapshot, Stage: StageWait}
+	fail := func(err error) PipelineResult {
+		p.Err = &PipelineError{ClusterIdentifier: snapshot.ClusterIdentifier, Stage: p.Stage, Err: err}
+		return p
+	}
+
+	if err := b.WaitForSnapshots(ctx, snapshot.SnapshotIdentifier); err != nil {
+		return fail(err)
+	}
+
+	p.Stage = StageCopy
+	// the ARN means a copy from another region doesn't need AccountID
+	source := snapshot.SnapshotArn
+	if source == "" {
+		source = snapshot.SnapshotIdentifier
+	}
+	copyResult := dest.copySnapshot(ctx, kmsKeyID, source)
+	p.Copy = &copyResult
+	if copyResult.Err != nil {
+		return fail(copyResult.Err)
+	}
+
+	p.Stage = StageDone
+	return p
+}
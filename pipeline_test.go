@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// availableSnapshotTaker creates snapshots that are straight away available,
+// except for the cluster whose snapshot fails once it's created and the one
+// whose snapshot can't be created at all. It's safe to use from several
+// goroutines at once.
+type availableSnapshotTaker struct {
+	*fakeSnapshotTaker
+	failingClusterID string
+	refusedClusterID string
+	describeMu       sync.Mutex
+}
+
+func (a *availableSnapshotTaker) CreateDBClusterSnapshot(ctx context.Context, in *rds.CreateDBClusterSnapshotInput, optFns ...func(*rds.Options)) (*rds.CreateDBClusterSnapshotOutput, error) {
+	if *in.DBClusterIdentifier == a.refusedClusterID {
+		return nil, &types.SnapshotQuotaExceededFault{}
+	}
+	out, err := a.fakeSnapshotTaker.CreateDBClusterSnapshot(ctx, in, optFns...)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := existingSnapshot(*in.DBClusterIdentifier, *in.DBClusterSnapshotIdentifier, testNow)
+	if *in.DBClusterIdentifier == a.failingClusterID {
+		snapshot.Status = aws.String("failed")
+	}
+	a.describeMu.Lock()
+	a.snapshots = append(a.snapshots, snapshot)
+	a.describeMu.Unlock()
+	out.DBClusterSnapshot = &snapshot
+	return out, nil
+}
+
+func (a *availableSnapshotTaker) DescribeDBClusterSnapshots(ctx context.Context, in *rds.DescribeDBClusterSnapshotsInput, optFns ...func(*rds.Options)) (*rds.DescribeDBClusterSnapshotsOutput, error) {
+	a.describeMu.Lock()
+	defer a.describeMu.Unlock()
+	return a.fakeSnapshotTaker.DescribeDBClusterSnapshots(ctx, in, optFns...)
+}
+
+// recordingCopier copies any snapshot it's asked to, except the ones in
+// refuse.
+type recordingCopier struct {
+	*fakeSnapshotTaker
+	refuse map[string]bool
+
+	mu      sync.Mutex
+	sources []string
+}
+
+func (r *recordingCopier) CopyDBClusterSnapshot(ctx context.Context, in *rds.CopyDBClusterSnapshotInput, optFns ...func(*rds.Options)) (*rds.CopyDBClusterSnapshotOutput, error) {
+	source := aws.ToString(in.SourceDBClusterSnapshotIdentifier)
+	if r.refuse[source] {
+		return nil, &types.SnapshotQuotaExceededFault{}
+	}
+	r.mu.Lock()
+	r.sources = append(r.sources, source)
+	r.mu.Unlock()
+	return &rds.CopyDBClusterSnapshotOutput{DBClusterSnapshot: &types.DBClusterSnapshot{
+		DBClusterSnapshotIdentifier: in.TargetDBClusterSnapshotIdentifier,
+		DBClusterSnapshotArn:        aws.String("arn:aws:rds:us-west-2:123456789012:cluster-snapshot:" + aws.ToString(in.TargetDBClusterSnapshotIdentifier)),
+	}}, nil
+}
+
+func TestSnapshotAndCopy(t *testing.T) {
+	sourceArn := "arn:aws:rds:us-east-1:123456789012:cluster-snapshot:"
+
+	st := &availableSnapshotTaker{fakeSnapshotTaker: NewFakeSnapshotTaker(), failingClusterID: "my-cluster-3"}
+	bm := NewBackupManager(st, WithPrefix("testing"), WithConcurrency(4), WithContinueOnError(true))
+	bm.sleep = noSleep
+
+	copier := &recordingCopier{fakeSnapshotTaker: NewFakeSnapshotTaker(), refuse: map[string]bool{sourceArn + "testing-my-cluster-4": true}}
+	dest := NewBackupManager(copier, WithSourceRegion("us-east-1", ""))
+
+	results, err := bm.SnapshotAndCopy(context.TODO(), dest, "", "my-cluster-1", "my-cluster-2", "my-cluster-3", "my-cluster-4")
+	assert.ErrorIs(t, err, ErrPipelinesFailed)
+	assert.Len(t, results, 4)
+
+	stages := make([]PipelineStage, 0)
+	for _, result := range results {
+		stages = append(stages, result.Stage)
+	}
+	assert.Equal(t, []PipelineStage{StageDone, StageDone, StageWait, StageCopy}, stages)
+
+	assert.Nil(t, results[0].Err)
+	assert.Equal(t, "testing-my-cluster-1-copy", results[0].Copy.TargetSnapshotIdentifier)
+	assert.Equal(t, "arn:aws:rds:us-west-2:123456789012:cluster-snapshot:testing-my-cluster-1-copy", results[0].Copy.TargetSnapshotArn)
+
+	// each failure says which cluster and stage it was
+	var pipelineErr *PipelineError
+	assert.ErrorAs(t, results[2].Err, &pipelineErr)
+	assert.Equal(t, PipelineError{ClusterIdentifier: "my-cluster-3", Stage: StageWait, Err: pipelineErr.Err}, *pipelineErr)
+	assert.ErrorIs(t, results[2].Err, ErrSnapshotFailed)
+	assert.Nil(t, results[2].Copy)
+
+	var quotaErr *types.SnapshotQuotaExceededFault
+	assert.ErrorAs(t, results[3].Err, &pipelineErr)
+	assert.Equal(t, StageCopy, pipelineErr.Stage)
+	assert.ErrorAs(t, results[3].Err, &quotaErr)
+
+	assert.ElementsMatch(t, []string{sourceArn + "testing-my-cluster-1", sourceArn + "testing-my-cluster-2"}, copier.sources)
+}
+
+func TestSnapshotAndCopyCreateFailure(t *testing.T) {
+	st := &availableSnapshotTaker{fakeSnapshotTaker: NewFakeSnapshotTaker(), refusedClusterID: "my-cluster-1"}
+	bm := NewBackupManager(st, WithPrefix("testing"))
+	bm.sleep = noSleep
+	dest := NewBackupManager(&recordingCopier{fakeSnapshotTaker: NewFakeSnapshotTaker()})
+
+	results, err := bm.SnapshotAndCopy(context.TODO(), dest, "", "my-cluster-1", "my-cluster-2")
+	var pipelineErr *PipelineError
+	assert.ErrorAs(t, err, &pipelineErr)
+	assert.Equal(t, StageCreate, pipelineErr.Stage)
+	assert.Equal(t, "my-cluster-1", pipelineErr.ClusterIdentifier)
+	// the failure stopped the batch before the second cluster started
+	assert.Len(t, results, 1)
+	assert.Equal(t, StageCreate, results[0].Stage)
+}
+
+func TestSnapshotAndCopySkipsSkipped(t *testing.T) {
+	bm := NewBackupManager(NewFlakySnapshotTaker("gone", &types.DBClusterNotFoundFault{}), WithPrefix("testing"))
+	copier := &recordingCopier{fakeSnapshotTaker: NewFakeSnapshotTaker()}
+	dest := NewBackupManager(copier)
+
+	results, err := bm.SnapshotAndCopy(context.TODO(), dest, "", "gone")
+	assert.Nil(t, err)
+	assert.Equal(t, []PipelineResult{{
+		Snapshot: SnapshotResult{ClusterIdentifier: "gone", SnapshotIdentifier: "testing-gone", Status: StatusSkippedNotFound, Duration: results[0].Snapshot.Duration},
+		Stage:    StageCreate,
+	}}, results)
+	assert.Empty(t, copier.sources)
+}
+
+func TestSnapshotAndCopyNeedsCopier(t *testing.T) {
+	bm := NewBackupManager(NewFakeSnapshotTaker())
+	dest := NewBackupManager(NewFakeSnapshotTaker())
+	dest.cp = nil
+	_, err := bm.SnapshotAndCopy(context.TODO(), dest, "", "my-cluster-1")
+	assert.ErrorIs(t, err, ErrNoSnapshotCopier)
+}
@@ -0,0 +1,34 @@
+package main
+
+// defaultPrefixFormat is the layout of the prefix a manager falls back to
+// when it isn't given one, with the day the run started.
+const defaultPrefixFormat = "snapshot-20060102"
+
+// defaultReadPrefix is read back without a prefix, so snapshots from every
+// day's default prefix are recognized.
+const defaultReadPrefix = "snapshot"
+
+// applyDefaultPrefix picks the prefix for a run of a manager with no prefix,
+// so new snapshot names still start with a letter. It's picked again every
+// run, so each day's snapshots get that day's prefix.
+func (b *BackupManager) applyDefaultPrefix() {
+	b.defaultPrefix = ""
+	if b.prefix != "" {
+		return
+	}
+	b.defaultPrefix = b.clock().UTC().Format(defaultPrefixFormat)
+	if b.prefixSet {
+		b.logf("The prefix is empty, using '%s' instead.", b.defaultPrefix)
+	} else {
+		b.logf("No prefix set, using '%s'.", b.defaultPrefix)
+	}
+}
+
+// writePrefix is the prefix new snapshot names start with: the prefix, or
+// without one, the run's default.
+func (b *BackupManager) writePrefix() string {
+	if b.prefix != "" {
+		return b.prefix
+	}
+	return b.defaultPrefix
+}
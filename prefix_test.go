@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultPrefix(t *testing.T) {
+	type testCase struct {
+		opts           []Option
+		expectedID     string
+		expectedLog    string
+		expectedNextID string
+	}
+
+	testCases := map[string]testCase{
+		"no prefix": {
+			expectedID:     "snapshot-20220315-my-cluster-1",
+			expectedLog:    "No prefix set, using 'snapshot-20220315'.\n",
+			expectedNextID: "snapshot-20220316-my-cluster-1",
+		},
+		"an empty prefix": {
+			opts:           []Option{WithPrefix("")},
+			expectedID:     "snapshot-20220315-my-cluster-1",
+			expectedLog:    "The prefix is empty, using 'snapshot-20220315' instead.\n",
+			expectedNextID: "snapshot-20220316-my-cluster-1",
+		},
+		"a prefix": {
+			opts:           []Option{WithPrefix("testing")},
+			expectedID:     "testing-my-cluster-1",
+			expectedNextID: "testing-my-cluster-1",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			st := NewFakeSnapshotTaker()
+			bm := NewBackupManager(st, append(tc.opts, WithLogger(log.New(&buf, "", 0)), WithRunID("run-1"))...)
+			bm.now = func() time.Time { return testNow }
+
+			results, err := bm.TriggerSnapshots(context.TODO(), "my-cluster-1")
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedID, results[0].SnapshotIdentifier)
+			assert.Nil(t, validatePrefix(bm.writePrefix()))
+			assert.Equal(t, tc.expectedLog+"Starting run 'run-1' for 1 cluster(s).\n", buf.String())
+
+			// the next day's default prefix is that day's, and either day's
+			// snapshots are read back
+			bm.now = func() time.Time { return testNow.Add(24 * time.Hour) }
+			results, err = bm.TriggerSnapshots(context.TODO(), "my-cluster-1")
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedNextID, results[0].SnapshotIdentifier)
+			assert.True(t, bm.hasReadPrefix(tc.expectedID))
+			assert.True(t, bm.hasReadPrefix(tc.expectedNextID))
+		})
+	}
+}
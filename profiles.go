@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+)
+
+const (
+	ErrNoProfiles            BackupManagerError = "no profiles given"
+	ErrProfilesNamedClusters BackupManagerError = "clusters are discovered in each profile, they can't be named as well"
+	ErrProfilesSharedState   BackupManagerError = "a state file can't be shared by the runs of several profiles"
+)
+
+// parseProfiles splits a comma-separated list of shared config profiles,
+// dropping blanks and repeats.
+func parseProfiles(s string) ([]string, error) {
+	seen := make(map[string]bool)
+	profiles := make([]string, 0)
+	for _, profile := range strings.Split(s, ",") {
+		profile = strings.TrimSpace(profile)
+		if profile == "" || seen[profile] {
+			continue
+		}
+		seen[profile] = true
+		profiles = append(profiles, profile)
+	}
+	if len(profiles) == 0 {
+		return nil, fmt.Errorf("'%s': %w", s, ErrNoProfiles)
+	}
+	return profiles, nil
+}
+
+// newProfileClient loads the shared config profile named profile, which
+// brings its own credentials and region, and returns an RDS client for it
+// along with the config it came from. loadOpts go to the config loader after
+// the profile, and optFns to the client.
+func newProfileClient(ctx context.Context, profile string, loadOpts []func(*config.LoadOptions) error, optFns ...func(*rds.Options)) (*rds.Client, aws.Config, error) {
+	cfg, err := config.LoadDefaultConfig(ctx, append([]func(*config.LoadOptions) error{config.WithSharedConfigProfile(profile)}, loadOpts...)...)
+	if err != nil {
+		return nil, aws.Config{}, fmt.Errorf("profile '%s': %w", profile, err)
+	}
+	return rds.NewFromConfig(cfg, optFns...), cfg, nil
+}
+
+// runProfiles backs up every cluster in each of a comma-separated list of
+// profiles.
+func runProfiles(ctx context.Context, list string, clusterIDs []string, managerFor func(profile string) (*BackupManager, error)) ([]SnapshotResult, error) {
+	if len(clusterIDs) > 0 {
+		return nil, ErrProfilesNamedClusters
+	}
+	profiles, err := parseProfiles(list)
+	if err != nil {
+		return nil, err
+	}
+	return runBackupByProfile(ctx, profiles, managerFor)
+}
+
+// runBackupByProfile discovers and backs up the clusters of each profile with
+// its own manager, all profiles at once, and returns their results in the
+// order the profiles were given, each marked with its profile. Like
+// runBackupByRegion, a failure in one profile doesn't stop the others, and the
+// first error, in profile order, is returned once they've all had a go. A
+// profile that can't be loaded is one of those failures.
+func runBackupByProfile(ctx context.Context, profiles []string, managerFor func(profile string) (*BackupManager, error)) ([]SnapshotResult, error) {
+	results := make([][]SnapshotResult, len(profiles))
+	errs := make([]error, len(profiles))
+	managers := make([]*BackupManager, len(profiles))
+	for i, profile := range profiles {
+		managers[i], errs[i] = managerFor(profile)
+		// the same cluster identifier in two accounts would share a line, and
+		// one profile finishing would clear the other's
+		if managers[i] != nil && managers[i].State != nil && len(profiles) > 1 {
+			return nil, ErrProfilesSharedState
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i, profile := range profiles {
+		bm := managers[i]
+		if bm == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, profile string, bm *BackupManager) {
+			defer wg.Done()
+			bm.logf("Backing up the clusters of profile '%s'.", profile)
+			results[i], errs[i] = runBackup(ctx, bm, nil, true, nil)
+			for j := range results[i] {
+				results[i][j].Profile = profile
+			}
+			// other profiles had clusters, so the run as a whole isn't empty
+			if errors.Is(errs[i], ErrNoClustersDiscovered) && len(profiles) > 1 {
+				bm.logf("Discovery found no clusters in profile '%s'.", profile)
+				errs[i] = nil
+			}
+			if errs[i] != nil {
+				bm.logf("Backing up the clusters of profile '%s' failed: %v", profile, errs[i])
+			}
+		}(i, profile, bm)
+	}
+	wg.Wait()
+
+	var (
+		firstErr error
+		all      []SnapshotResult
+	)
+	for i := range profiles {
+		all = append(all, results[i]...)
+		if errs[i] != nil && firstErr == nil {
+			firstErr = errs[i]
+		}
+	}
+	return all, firstErr
+}
+
+// qualifiedCluster names a result's cluster for a report, with its profile
+// in front when it has one, since two profiles can have clusters of the
+// same name.
+func qualifiedCluster(result SnapshotResult) string {
+	if result.Profile == "" {
+		return result.ClusterIdentifier
+	}
+	return result.Profile + "/" + result.ClusterIdentifier
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseProfiles(t *testing.T) {
+	type testCase struct {
+		input       string
+		expected    []string
+		expectedErr error
+	}
+
+	testCases := map[string]testCase{
+		"one": {
+			input:    "prod",
+			expected: []string{"prod"},
+		},
+		"several": {
+			input:    "prod, staging ,dev",
+			expected: []string{"prod", "staging", "dev"},
+		},
+		"blanks and repeats": {
+			input:    "prod,,staging,prod,",
+			expected: []string{"prod", "staging"},
+		},
+		"nothing": {
+			input:       " , ",
+			expectedErr: ErrNoProfiles,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			profiles, err := parseProfiles(tc.input)
+			assert.ErrorIs(t, err, tc.expectedErr)
+			assert.Equal(t, tc.expected, profiles)
+		})
+	}
+}
+
+func TestNewProfileClient(t *testing.T) {
+	// nothing from the environment gets in ahead of the profiles
+	for _, key := range []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN", "AWS_PROFILE", "AWS_REGION", "AWS_DEFAULT_REGION"} {
+		t.Setenv(key, "")
+	}
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config")
+	credentialsFile := filepath.Join(dir, "credentials")
+	assert.Nil(t, os.WriteFile(configFile, []byte(""+
+		"[profile prod]\nregion = us-west-2\n"+
+		"[profile staging]\nregion = eu-west-1\n"), 0o600))
+	assert.Nil(t, os.WriteFile(credentialsFile, []byte(""+
+		"[prod]\naws_access_key_id = AKIDPROD\naws_secret_access_key = secret\n"+
+		"[staging]\naws_access_key_id = AKIDSTAGING\naws_secret_access_key = secret\n"), 0o600))
+	loadOpts := []func(*config.LoadOptions) error{
+		config.WithSharedConfigFiles([]string{configFile}),
+		config.WithSharedCredentialsFiles([]string{credentialsFile}),
+	}
+
+	type testCase struct {
+		profile        string
+		expectedRegion string
+		expectedKey    string
+	}
+
+	testCases := map[string]testCase{
+		"prod": {
+			profile:        "prod",
+			expectedRegion: "us-west-2",
+			expectedKey:    "AKIDPROD",
+		},
+		"staging": {
+			profile:        "staging",
+			expectedRegion: "eu-west-1",
+			expectedKey:    "AKIDSTAGING",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			httpClient := &capturingHTTPClient{}
+			client, cfg, err := newProfileClient(context.TODO(), tc.profile, loadOpts, func(o *rds.Options) {
+				o.HTTPClient = httpClient
+				o.Retryer = aws.NopRetryer{}
+			})
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedRegion, cfg.Region)
+
+			// the client signs with the profile's keys, for the profile's
+			// region
+			_, err = client.DescribeDBClusters(context.TODO(), &rds.DescribeDBClustersInput{})
+			assert.Error(t, err)
+			assert.Equal(t, "rds."+tc.expectedRegion+".amazonaws.com", httpClient.request.URL.Host)
+			authorization := httpClient.request.Header.Get("Authorization")
+			assert.True(t, strings.Contains(authorization, "Credential="+tc.expectedKey+"/"), authorization)
+		})
+	}
+}
+
+func TestRunBackupByProfile(t *testing.T) {
+	prodTaker := NewFakeSnapshotTaker()
+	prodTaker.clusters = []types.DBCluster{existingCluster("payments"), existingCluster("search")}
+	stagingTaker := NewFakeSnapshotTaker()
+	stagingTaker.clusters = []types.DBCluster{existingCluster("payments")}
+	takers := map[string]RDSAPI{"prod": prodTaker, "staging": stagingTaker}
+
+	results, err := runProfiles(context.TODO(), "staging,broken,prod", nil, func(profile string) (*BackupManager, error) {
+		if profile == "broken" {
+			return nil, ErrNoProfiles
+		}
+		bm := NewBackupManager(takers[profile], WithPrefix("testing"))
+		bm.now = func() time.Time { return testNow }
+		return bm, nil
+	})
+
+	// a profile that can't be loaded doesn't stop the rest
+	assert.ErrorIs(t, err, ErrNoProfiles)
+	assert.Equal(t, []SnapshotResult{
+		{ClusterIdentifier: "payments", SnapshotIdentifier: "testing-payments", Status: StatusCreated, Profile: "staging"},
+		{ClusterIdentifier: "payments", SnapshotIdentifier: "testing-payments", Status: StatusCreated, Profile: "prod"},
+		{ClusterIdentifier: "search", SnapshotIdentifier: "testing-search", Status: StatusCreated, Profile: "prod"},
+	}, results)
+	assert.Equal(t, []snapshotCreationRecord{{"payments", "testing-payments"}}, stagingTaker.GetJournal())
+	assert.Len(t, prodTaker.GetJournal(), 2)
+}
+
+func TestRunProfilesNamedClusters(t *testing.T) {
+	_, err := runProfiles(context.TODO(), "prod", []string{"my-cluster-1"}, func(profile string) (*BackupManager, error) {
+		t.Fatal("no manager should be made")
+		return nil, nil
+	})
+	assert.ErrorIs(t, err, ErrProfilesNamedClusters)
+}
+
+func TestRunProfilesSharedState(t *testing.T) {
+	st := NewFakeSnapshotTaker()
+	st.clusters = []types.DBCluster{existingCluster("payments")}
+	path := filepath.Join(t.TempDir(), "state")
+	managerFor := func(profile string) (*BackupManager, error) {
+		bm := NewBackupManager(st, WithPrefix("testing"))
+		bm.State = NewFileStateStore(path)
+		return bm, nil
+	}
+
+	_, err := runProfiles(context.TODO(), "prod,staging", nil, managerFor)
+	assert.ErrorIs(t, err, ErrProfilesSharedState)
+	assert.Empty(t, st.GetJournal())
+
+	// one profile has the file to itself
+	_, err = runProfiles(context.TODO(), "prod", nil, managerFor)
+	assert.Nil(t, err)
+	assert.Len(t, st.GetJournal(), 1)
+}
+
+func TestProfilesInReports(t *testing.T) {
+	results := []SnapshotResult{
+		{ClusterIdentifier: "payments", SnapshotIdentifier: "testing-payments", Status: StatusCreated, Profile: "prod"},
+		{ClusterIdentifier: "payments", SnapshotIdentifier: "testing-payments", Status: StatusFailed, Err: errors.New("boom"), Profile: "staging"},
+	}
+
+	var buf bytes.Buffer
+	assert.Nil(t, JUnitFormatter{}.Format(&buf, results))
+	assert.Contains(t, buf.String(), `name="prod/payments"`)
+	assert.Contains(t, buf.String(), `name="staging/payments"`)
+
+	buf.Reset()
+	assert.Nil(t, IDsFormatter{}.Format(&buf, results))
+	assert.Equal(t, "prod\ttesting-payments\n", buf.String())
+
+	buf.Reset()
+	assert.Nil(t, ManifestFormatter{}.Format(&buf, results))
+	assert.Contains(t, buf.String(), `"profile": "prod"`)
+
+	buf.Reset()
+	assert.Nil(t, SummaryFormatter{}.Format(&buf, results))
+	assert.Contains(t, buf.String(), `"profile":"staging"`)
+
+	assert.Contains(t, slackText("run-1", results), "• `staging/payments`: boom")
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+)
+
+// progressWidth is how many cells wide the bar itself is.
+const progressWidth = 30
+
+// clearLine takes the cursor back to the start of the line and blanks it.
+const clearLine = "\r\033[K"
+
+// renderProgress draws a bar like
+//
+//	[#########---------------------] 12/40, 1 failed
+func renderProgress(done, failed, total, width int) string {
+	filled := width
+	if total > 0 {
+		filled = width * done / total
+	}
+	if filled > width {
+		filled = width
+	}
+	bar := fmt.Sprintf("[%s%s] %d/%d", strings.Repeat("#", filled), strings.Repeat("-", width-filled), done, total)
+	if failed > 0 {
+		bar += fmt.Sprintf(", %d failed", failed)
+	}
+	return bar
+}
+
+// progress follows a run as its results come in. With somewhere to draw it,
+// that's a bar redrawn in place, with log lines printed above it; otherwise
+// it's a log line per cluster.
+type progress struct {
+	mu     sync.Mutex
+	bar    io.Writer
+	logs   io.Writer
+	logf   func(format string, v ...interface{})
+	total  int
+	done   int
+	failed int
+}
+
+// Write prints a log line, moving the bar out of its way and drawing it again
+// underneath.
+func (p *progress) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	io.WriteString(p.bar, clearLine)
+	n, err := p.logs.Write(b)
+	p.draw()
+	return n, err
+}
+
+func (p *progress) draw() {
+	io.WriteString(p.bar, clearLine+renderProgress(p.done, p.failed, p.total, progressWidth))
+}
+
+func (p *progress) record(result SnapshotResult) {
+	p.mu.Lock()
+	p.done++
+	if result.Status == StatusFailed {
+		p.failed++
+	}
+	done, failed := p.done, p.failed
+	if p.bar != nil {
+		p.draw()
+	}
+	p.mu.Unlock()
+
+	if p.bar == nil {
+		if failed > 0 {
+			p.logf("Finished %d of %d cluster(s), %d failed.", done, p.total, failed)
+		} else {
+			p.logf("Finished %d of %d cluster(s).", done, p.total)
+		}
+	}
+}
+
+// followProgress shows how far bm is through a run of total clusters, using
+// its Results channel, until the returned function is called. Given a bar to
+// draw on, the standard logger is routed through it so log lines don't land
+// in the middle of the bar; without one, progress is logged.
+func followProgress(bm *BackupManager, total int, bar io.Writer) (stop func()) {
+	p := &progress{bar: bar, total: total, logf: log.Printf}
+	logs := log.Writer()
+	if bar != nil {
+		p.logs = logs
+		p.draw()
+		log.SetOutput(p)
+	}
+
+	results := make(chan SnapshotResult)
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		for result := range results {
+			p.record(result)
+		}
+	}()
+	bm.Results = results
+
+	return func() {
+		bm.Results = nil
+		close(results)
+		<-finished
+		if bar != nil {
+			log.SetOutput(logs)
+			fmt.Fprintln(bar)
+		}
+	}
+}
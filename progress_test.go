@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderProgress(t *testing.T) {
+	type testCase struct {
+		done, failed, total int
+		expected            string
+	}
+
+	testCases := map[string]testCase{
+		"nothing yet":  {0, 0, 4, "[----------] 0/4"},
+		"part way":     {1, 0, 4, "[##--------] 1/4"},
+		"with failure": {3, 1, 4, "[#######---] 3/4, 1 failed"},
+		"all done":     {4, 0, 4, "[##########] 4/4"},
+		"no clusters":  {0, 0, 0, "[##########] 0/0"},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, renderProgress(tc.done, tc.failed, tc.total, 10))
+		})
+	}
+}
+
+func TestProgressBar(t *testing.T) {
+	var out bytes.Buffer
+	p := &progress{bar: &out, logs: &out, total: 2}
+
+	p.record(SnapshotResult{ClusterIdentifier: "my-cluster-1", Status: StatusCreated})
+	fmt.Fprintln(p, "a log line")
+	p.record(SnapshotResult{ClusterIdentifier: "my-cluster-2", Status: StatusFailed})
+
+	assert.Equal(t, ""+
+		clearLine+"[###############---------------] 1/2"+
+		clearLine+"a log line\n"+
+		clearLine+"[###############---------------] 1/2"+
+		clearLine+"[##############################] 2/2, 1 failed",
+		out.String())
+}
+
+func TestProgressWithoutBar(t *testing.T) {
+	var lines []string
+	p := &progress{total: 2, logf: func(format string, v ...interface{}) {
+		lines = append(lines, fmt.Sprintf(format, v...))
+	}}
+
+	p.record(SnapshotResult{ClusterIdentifier: "my-cluster-1", Status: StatusFailed})
+	p.record(SnapshotResult{ClusterIdentifier: "my-cluster-2", Status: StatusCreated})
+	assert.Equal(t, []string{
+		"Finished 1 of 2 cluster(s), 1 failed.",
+		"Finished 2 of 2 cluster(s), 1 failed.",
+	}, lines)
+}
+
+func TestTriggerSnapshotsStreamsResults(t *testing.T) {
+	bm := NewBackupManager(NewFakeSnapshotTaker(), WithPrefix("testing"), WithConcurrency(2))
+	var out bytes.Buffer
+	stop := followProgress(bm, 3, &out)
+	results, err := bm.TriggerSnapshots(context.TODO(), "my-cluster-1", "my-cluster-2", "my-cluster-3")
+	stop()
+
+	assert.Nil(t, err)
+	assert.Len(t, results, 3)
+	assert.Nil(t, bm.Results)
+	assert.Contains(t, out.String(), "3/3")
+	assert.Equal(t, "\n", out.String()[out.Len()-1:])
+}
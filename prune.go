@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+)
+
+// SnapshotDeleter removes cluster snapshots. *rds.Client implements it.
+type SnapshotDeleter interface {
+	DeleteDBClusterSnapshot(context.Context, *rds.DeleteDBClusterSnapshotInput, ...func(*rds.Options)) (*rds.DeleteDBClusterSnapshotOutput, error)
+}
+
+const (
+	ErrNoSnapshotDeleter BackupManagerError = "pruning snapshots requires a SnapshotDeleter"
+	ErrDeletionProtected BackupManagerError = "refusing to delete snapshot of a deletion-protected cluster without -force"
+)
+
+// ListSnapshots returns every manual snapshot created by this tool, across all
+// clusters, matching any of the read prefixes.
+func (b *BackupManager) ListSnapshots(ctx context.Context) ([]types.DBClusterSnapshot, error) {
+	return b.describeOwnSnapshots(ctx, "")
+}
+
+// ListFailedSnapshots returns the snapshots from ListSnapshots that failed.
+// They never become usable but still count toward the snapshot quota, so
+// they're worth deleting whatever their age.
+func (b *BackupManager) ListFailedSnapshots(ctx context.Context) ([]types.DBClusterSnapshot, error) {
+	snapshots, err := b.ListSnapshots(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	failed := make([]types.DBClusterSnapshot, 0)
+	for _, snapshot := range snapshots {
+		if aws.ToString(snapshot.Status) == "failed" {
+			failed = append(failed, snapshot)
+		}
+	}
+	return failed, nil
+}
+
+// PruneCandidates returns the snapshots that PruneSnapshots would delete:
+// those created by this tool more than olderThan ago. It doesn't delete
+// anything, so callers can confirm before going ahead.
+func (b *BackupManager) PruneCandidates(ctx context.Context, olderThan time.Duration) ([]types.DBClusterSnapshot, error) {
+	snapshots, err := b.ListSnapshots(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := b.clock().Add(-olderThan)
+	candidates := make([]types.DBClusterSnapshot, 0)
+	for _, snapshot := range snapshots {
+		// snapshots still being created may have no creation time yet, leave
+		// them be
+		createdAt := snapshotCreatedAt(snapshot)
+		if createdAt == nil || !createdAt.Before(cutoff) {
+			continue
+		}
+		candidates = append(candidates, snapshot)
+	}
+	return candidates, nil
+}
+
+// EstimatePruneSavings reports how much storage pruning snapshots older than
+// maxAge would free up, in GB, and how many snapshots it would delete. It
+// goes by PruneCandidates, so nothing is deleted.
+func (b *BackupManager) EstimatePruneSavings(ctx context.Context, maxAge time.Duration) (int64, int, error) {
+	candidates, err := b.PruneCandidates(ctx, maxAge)
+	if err != nil {
+		return 0, 0, err
+	}
+	return snapshotStorage(candidates), len(candidates), nil
+}
+
+// snapshotStorage adds up the snapshots' allocated storage in GB.
+func snapshotStorage(snapshots []types.DBClusterSnapshot) int64 {
+	var total int64
+	for _, snapshot := range snapshots {
+		total += int64(snapshot.AllocatedStorage)
+	}
+	return total
+}
+
+// DeleteSnapshots deletes the given snapshots, stopping at the first error
+// unless ContinueOnError is set. It returns the snapshots that were actually
+// deleted. Unless Force is set, nothing is deleted if any snapshot belongs to
+// a cluster with deletion protection; that's only checked when the manager can
+// describe clusters.
+func (b *BackupManager) DeleteSnapshots(ctx context.Context, snapshots ...types.DBClusterSnapshot) ([]types.DBClusterSnapshot, error) {
+	if b.del == nil {
+		return nil, ErrNoSnapshotDeleter
+	}
+	if err := b.checkDeletionProtection(ctx, snapshots); err != nil {
+		return nil, err
+	}
+
+	deleted := make([]types.DBClusterSnapshot, 0, len(snapshots))
+	failed := 0
+	for _, snapshot := range snapshots {
+		snapshotID := aws.ToString(snapshot.DBClusterSnapshotIdentifier)
+		err := b.whenStable(ctx, snapshotID, "delete", true, func() error {
+			_, err := b.del.DeleteDBClusterSnapshot(ctx, &rds.DeleteDBClusterSnapshotInput{
+				DBClusterSnapshotIdentifier: snapshot.DBClusterSnapshotIdentifier,
+			})
+			return err
+		})
+		if err != nil && !b.ContinueOnError {
+			return deleted, err
+		}
+		if err != nil {
+			b.logf("Failed to delete '%s', continuing: %v", snapshotID, err)
+			failed++
+			continue
+		}
+		b.logf("Deleted snapshot '%s'.", snapshotID)
+		deleted = append(deleted, snapshot)
+	}
+
+	if failed > 0 {
+		return deleted, fmt.Errorf("%d of %d snapshots: %w", failed, len(snapshots), ErrDeletesFailed)
+	}
+	return deleted, nil
+}
+
+// PruneSnapshots deletes every snapshot created by this tool more than
+// olderThan ago.
+func (b *BackupManager) PruneSnapshots(ctx context.Context, olderThan time.Duration) ([]types.DBClusterSnapshot, error) {
+	candidates, err := b.PruneCandidates(ctx, olderThan)
+	if err != nil {
+		return nil, err
+	}
+	return b.DeleteSnapshots(ctx, candidates...)
+}
+
+func (b *BackupManager) checkDeletionProtection(ctx context.Context, snapshots []types.DBClusterSnapshot) error {
+	if b.Force || b.cd == nil {
+		return nil
+	}
+	for _, snapshot := range snapshots {
+		info, err := b.clusterInfo(ctx, aws.ToString(snapshot.DBClusterIdentifier))
+		if err != nil {
+			return err
+		}
+		if info.DeletionProtection {
+			return fmt.Errorf("'%s': %w", aws.ToString(snapshot.DBClusterSnapshotIdentifier), ErrDeletionProtected)
+		}
+	}
+	return nil
+}
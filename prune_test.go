@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func snapshotIDs(snapshots []types.DBClusterSnapshot) []string {
+	ids := make([]string, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		ids = append(ids, aws.ToString(snapshot.DBClusterSnapshotIdentifier))
+	}
+	return ids
+}
+
+func TestListSnapshots(t *testing.T) {
+	type testCase struct {
+		readPrefixes []string
+		expectedIDs  []string
+	}
+
+	testCases := map[string]testCase{
+		"defaults to the write prefix": {
+			expectedIDs: []string{"testing-my-cluster-1", "testing-my-cluster-2"},
+		},
+		"matches every read prefix": {
+			readPrefixes: []string{"testing", "legacy"},
+			expectedIDs:  []string{"testing-my-cluster-1", "legacy-my-cluster-1", "testing-my-cluster-2"},
+		},
+		"read prefixes replace the write prefix": {
+			readPrefixes: []string{"legacy"},
+			expectedIDs:  []string{"legacy-my-cluster-1"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			st := NewFakeSnapshotTakerWithSnapshots(
+				existingSnapshot("my-cluster-1", "testing-my-cluster-1", testNow),
+				existingSnapshot("my-cluster-1", "legacy-my-cluster-1", testNow),
+				existingSnapshot("my-cluster-1", "testingfoo-my-cluster-1", testNow),
+				existingSnapshot("my-cluster-2", "testing-my-cluster-2", testNow),
+				existingSnapshot("my-cluster-2", "someone-else-my-cluster-2", testNow),
+			)
+			bm := &BackupManager{
+				st:           st,
+				sd:           st,
+				prefix:       "testing",
+				ReadPrefixes: tc.readPrefixes,
+			}
+
+			snapshots, err := bm.ListSnapshots(context.TODO())
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedIDs, snapshotIDs(snapshots))
+		})
+	}
+}
+
+func TestListFailedSnapshots(t *testing.T) {
+	failed := func(clusterID, snapshotID string) types.DBClusterSnapshot {
+		snapshot := existingSnapshot(clusterID, snapshotID, testNow)
+		snapshot.Status = aws.String("failed")
+		return snapshot
+	}
+	st := NewFakeSnapshotTakerWithSnapshots(
+		existingSnapshot("my-cluster-1", "testing-my-cluster-1", testNow),
+		failed("my-cluster-1", "testing-my-cluster-1-old"),
+		failed("my-cluster-2", "someone-else-my-cluster-2"),
+	)
+	bm := &BackupManager{st: st, sd: st, prefix: "testing"}
+
+	snapshots, err := bm.ListFailedSnapshots(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"testing-my-cluster-1-old"}, snapshotIDs(snapshots))
+}
+
+func TestPruneSnapshots(t *testing.T) {
+	type testCase struct {
+		readPrefixes      []string
+		olderThan         time.Duration
+		expectedDeleted   []string
+		expectedRemaining []string
+	}
+
+	testCases := map[string]testCase{
+		"prunes snapshots past the cutoff": {
+			olderThan:         7 * 24 * time.Hour,
+			expectedDeleted:   []string{"testing-my-cluster-1-old"},
+			expectedRemaining: []string{"testing-my-cluster-1-new", "legacy-my-cluster-1-old", "testing-my-cluster-1-creating"},
+		},
+		"prunes snapshots under legacy prefixes": {
+			readPrefixes:      []string{"testing", "legacy"},
+			olderThan:         7 * 24 * time.Hour,
+			expectedDeleted:   []string{"testing-my-cluster-1-old", "legacy-my-cluster-1-old"},
+			expectedRemaining: []string{"testing-my-cluster-1-new", "testing-my-cluster-1-creating"},
+		},
+		"nothing old enough": {
+			olderThan:         60 * 24 * time.Hour,
+			expectedDeleted:   []string{},
+			expectedRemaining: []string{"testing-my-cluster-1-old", "testing-my-cluster-1-new", "legacy-my-cluster-1-old", "testing-my-cluster-1-creating"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			st := NewFakeSnapshotTakerWithSnapshots(
+				existingSnapshot("my-cluster-1", "testing-my-cluster-1-old", testNow.Add(-10*24*time.Hour)),
+				existingSnapshot("my-cluster-1", "testing-my-cluster-1-new", testNow.Add(-time.Hour)),
+				existingSnapshot("my-cluster-1", "legacy-my-cluster-1-old", testNow.Add(-10*24*time.Hour)),
+				types.DBClusterSnapshot{
+					DBClusterIdentifier:         aws.String("my-cluster-1"),
+					DBClusterSnapshotIdentifier: aws.String("testing-my-cluster-1-creating"),
+					Status:                      aws.String("creating"),
+				},
+			)
+			bm := &BackupManager{
+				st:           st,
+				sd:           st,
+				del:          st,
+				prefix:       "testing",
+				now:          func() time.Time { return testNow },
+				ReadPrefixes: tc.readPrefixes,
+			}
+
+			deleted, err := bm.PruneSnapshots(context.TODO(), tc.olderThan)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedDeleted, snapshotIDs(deleted))
+			assert.Equal(t, tc.expectedRemaining, snapshotIDs(st.snapshots))
+		})
+	}
+}
+
+func TestPruneSnapshotsWithoutDeleter(t *testing.T) {
+	st := NewFakeSnapshotTakerWithSnapshots(
+		existingSnapshot("my-cluster-1", "testing-my-cluster-1", testNow.Add(-10*24*time.Hour)),
+	)
+	bm := &BackupManager{st: st, sd: st, prefix: "testing", now: func() time.Time { return testNow }}
+
+	_, err := bm.PruneSnapshots(context.TODO(), time.Hour)
+	assert.ErrorIs(t, err, ErrNoSnapshotDeleter)
+}
+
+func TestEstimatePruneSavings(t *testing.T) {
+	sized := func(snapshot types.DBClusterSnapshot, gb int32) types.DBClusterSnapshot {
+		snapshot.AllocatedStorage = gb
+		return snapshot
+	}
+	st := NewFakeSnapshotTakerWithSnapshots(
+		sized(existingSnapshot("my-cluster-1", "testing-my-cluster-1-old", testNow.Add(-10*24*time.Hour)), 100),
+		sized(existingSnapshot("my-cluster-2", "testing-my-cluster-2-old", testNow.Add(-9*24*time.Hour)), 250),
+		sized(existingSnapshot("my-cluster-1", "testing-my-cluster-1-new", testNow.Add(-time.Hour)), 100),
+		sized(existingSnapshot("my-cluster-1", "someone-elses-old", testNow.Add(-10*24*time.Hour)), 1000),
+	)
+	bm := NewBackupManager(st, WithPrefix("testing"))
+	bm.now = func() time.Time { return testNow }
+
+	gb, count, err := bm.EstimatePruneSavings(context.TODO(), 7*24*time.Hour)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(350), gb)
+	assert.Equal(t, 2, count)
+	// nothing was deleted to find out
+	assert.Len(t, st.snapshots, 4)
+
+	gb, count, err = bm.EstimatePruneSavings(context.TODO(), 30*24*time.Hour)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), gb)
+	assert.Equal(t, 0, count)
+}
+
+func TestRunPruneFirst(t *testing.T) {
+	type testCase struct {
+		withoutDeleter  bool
+		continueOnError bool
+		expectedErr     error
+		expectedSteps   []string
+	}
+
+	testCases := map[string]testCase{
+		"prunes then backs up": {
+			expectedSteps: []string{"prune", "backup"},
+		},
+		"a failed prune stops the backup": {
+			withoutDeleter: true,
+			expectedErr:    ErrNoSnapshotDeleter,
+			expectedSteps:  []string{"prune"},
+		},
+		"a failed prune is tolerated with ContinueOnError": {
+			withoutDeleter:  true,
+			continueOnError: true,
+			expectedSteps:   []string{"prune", "backup"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			st := NewFakeSnapshotTakerWithSnapshots(
+				existingSnapshot("my-cluster-1", "testing-my-cluster-1-old", testNow.Add(-10*24*time.Hour)),
+			)
+			bm := NewBackupManager(st, WithPrefix("testing"), WithContinueOnError(tc.continueOnError))
+			bm.now = func() time.Time { return testNow }
+			if tc.withoutDeleter {
+				bm.del = nil
+			}
+
+			steps := make([]string, 0)
+			prune := func() error {
+				steps = append(steps, "prune")
+				_, err := bm.PruneSnapshots(context.TODO(), 7*24*time.Hour)
+				return err
+			}
+			backup := func() ([]SnapshotResult, error) {
+				steps = append(steps, "backup")
+				// the old snapshot's already gone by the time the new one's
+				// created, unless the prune failed
+				if !tc.withoutDeleter {
+					assert.Equal(t, []string{"testing-my-cluster-1-old"}, st.deleted)
+				}
+				return bm.TriggerSnapshots(context.TODO(), "my-cluster-1")
+			}
+
+			results, err := runPruneFirst(bm.ContinueOnError, prune, backup)
+			assert.ErrorIs(t, err, tc.expectedErr)
+			assert.Equal(t, tc.expectedSteps, steps)
+			if len(tc.expectedSteps) == 2 {
+				assert.Len(t, results, 1)
+				assert.Equal(t, StatusCreated, results[0].Status)
+			} else {
+				assert.Nil(t, results)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import "github.com/aws/aws-sdk-go-v2/service/rds"
+
+// RDSAPI is every RDS call this tool makes, and what NewBackupManager takes.
+// Each feature only depends on the narrow interface it needs, which is
+// wired up to the manager's RDSAPI; RDSAPI gathers them up so there's one
+// seam for the whole of RDS.
+type RDSAPI interface {
+	SnapshotTaker
+	InstanceSnapshotTaker
+	InstanceSnapshotDescriber
+	SnapshotDescriber
+	SnapshotDeleter
+	SnapshotCopier
+	ClusterDescriber
+	GlobalClusterDescriber
+	TagLister
+	TagAdder
+	ClusterRestorer
+	InstanceCreator
+	ClusterStarter
+}
+
+var _ RDSAPI = (*rds.Client)(nil)
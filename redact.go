@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+)
+
+// redacted replaces values that shouldn't end up in logs.
+const redacted = "***"
+
+// defaultRedactKeys are redacted when RedactKeys isn't set.
+var defaultRedactKeys = []string{"password", "secret", "token"}
+
+func (b *BackupManager) redactKeys() []string {
+	if len(b.RedactKeys) == 0 {
+		return defaultRedactKeys
+	}
+	return b.RedactKeys
+}
+
+// redactTags returns a copy of tags with the value of any tag whose key
+// contains one of denied, ignoring case, replaced by "***".
+func redactTags(tags []types.Tag, denied []string) []types.Tag {
+	out := make([]types.Tag, 0, len(tags))
+	for _, tag := range tags {
+		key := strings.ToLower(aws.ToString(tag.Key))
+		for _, d := range denied {
+			if d != "" && strings.Contains(key, strings.ToLower(d)) {
+				tag.Value = aws.String(redacted)
+				break
+			}
+		}
+		out = append(out, tag)
+	}
+	return out
+}
+
+// formatCreateInput describes a snapshot request for debug logs, with
+// sensitive tag values redacted.
+func formatCreateInput(in *rds.CreateDBClusterSnapshotInput, denied []string) string {
+	tags := make([]string, 0, len(in.Tags))
+	for _, tag := range redactTags(in.Tags, denied) {
+		tags = append(tags, aws.ToString(tag.Key)+"="+aws.ToString(tag.Value))
+	}
+	return fmt.Sprintf("cluster=%s snapshot=%s tags=[%s]",
+		aws.ToString(in.DBClusterIdentifier), aws.ToString(in.DBClusterSnapshotIdentifier), strings.Join(tags, " "))
+}
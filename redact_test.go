@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactTags(t *testing.T) {
+	type testCase struct {
+		denied   []string
+		expected map[string]string
+	}
+
+	tags := []types.Tag{
+		{Key: aws.String("team"), Value: aws.String("data")},
+		{Key: aws.String("DB_PASSWORD"), Value: aws.String("hunter2")},
+		{Key: aws.String("api-token"), Value: aws.String("abc123")},
+	}
+	testCases := map[string]testCase{
+		"defaults": {
+			denied:   defaultRedactKeys,
+			expected: map[string]string{"team": "data", "DB_PASSWORD": "***", "api-token": "***"},
+		},
+		"custom deny-list": {
+			denied:   []string{"TEAM"},
+			expected: map[string]string{"team": "***", "DB_PASSWORD": "hunter2", "api-token": "abc123"},
+		},
+		"empty entries match nothing": {
+			denied:   []string{""},
+			expected: map[string]string{"team": "data", "DB_PASSWORD": "hunter2", "api-token": "abc123"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			got := make(map[string]string)
+			for _, tag := range redactTags(tags, tc.denied) {
+				got[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+			}
+			assert.Equal(t, tc.expected, got)
+		})
+	}
+
+	// the tags themselves are left alone
+	assert.Equal(t, "hunter2", aws.ToString(tags[1].Value))
+}
+
+func TestFormatCreateInput(t *testing.T) {
+	in := &rds.CreateDBClusterSnapshotInput{
+		DBClusterIdentifier:         aws.String("my-cluster-1"),
+		DBClusterSnapshotIdentifier: aws.String("testing-my-cluster-1"),
+		Tags: []types.Tag{
+			{Key: aws.String("team"), Value: aws.String("data")},
+			{Key: aws.String("secret"), Value: aws.String("shh")},
+		},
+	}
+	assert.Equal(t, "cluster=my-cluster-1 snapshot=testing-my-cluster-1 tags=[team=data secret=***]", formatCreateInput(in, defaultRedactKeys))
+}
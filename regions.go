@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+)
+
+const ErrNoRegions BackupManagerError = "no regions listed"
+
+// groupByRegion splits clusters by the region in their ARN, keeping their
+// order within each region. Bare identifiers carry no region, so they're
+// grouped under defaultRegion, as are ARNs that leave the region out.
+func groupByRegion(clusterIDs []string, defaultRegion string) (map[string][]string, error) {
+	groups := make(map[string][]string)
+	for _, clusterID := range clusterIDs {
+		region := defaultRegion
+		if strings.HasPrefix(clusterID, "arn:") {
+			parsed, err := arn.Parse(clusterID)
+			if err != nil {
+				return nil, err
+			}
+			if parsed.Region != "" {
+				region = parsed.Region
+			}
+		}
+		groups[region] = append(groups[region], clusterID)
+	}
+	return groups, nil
+}
+
+// withRegion points an RDS client at a region other than the config's.
+func withRegion(region string) func(*rds.Options) {
+	return func(o *rds.Options) {
+		o.Region = region
+	}
+}
+
+// runBackupByRegion backs up each region's clusters with its own manager,
+// all regions at once, and returns their results in region order. Discovery
+// only happens in defaultRegion. When maxPerRegion is set, it sizes each
+// region's worker pool in place of the manager's Concurrency, so a region
+// with lots of clusters can't hog the API while the others wait. A failure
+// in one region doesn't stop the others; the first error, in region order,
+// is returned once they've all had a go.
+func runBackupByRegion(ctx context.Context, clusterIDs []string, defaultRegion string, discover bool, maxPerRegion int, managerFor func(region string) *BackupManager) ([]SnapshotResult, error) {
+	groups, err := groupByRegion(clusterIDs, defaultRegion)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := groups[defaultRegion]; discover && !ok {
+		groups[defaultRegion] = nil
+	}
+	return backUpRegions(ctx, groups, map[string]bool{defaultRegion: discover}, maxPerRegion, managerFor)
+}
+
+// runBackupInRegions discovers clusters in each of regions and backs them
+// up, along with any clusters given, which go to the region their ARN names
+// or to defaultRegion, just as runBackupByRegion does.
+func runBackupInRegions(ctx context.Context, clusterIDs []string, regions []string, defaultRegion string, maxPerRegion int, managerFor func(region string) *BackupManager) ([]SnapshotResult, error) {
+	groups, err := groupByRegion(clusterIDs, defaultRegion)
+	if err != nil {
+		return nil, err
+	}
+	discoverIn := make(map[string]bool, len(regions))
+	for _, region := range regions {
+		if _, ok := groups[region]; !ok {
+			groups[region] = nil
+		}
+		discoverIn[region] = true
+	}
+	return backUpRegions(ctx, groups, discoverIn, maxPerRegion, managerFor)
+}
+
+// backUpRegions runs each region's group of clusters through its own
+// manager, discovering more in the regions in discoverIn.
+func backUpRegions(ctx context.Context, groups map[string][]string, discoverIn map[string]bool, maxPerRegion int, managerFor func(region string) *BackupManager) ([]SnapshotResult, error) {
+	regions := make([]string, 0, len(groups))
+	for region := range groups {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+
+	results := make([][]SnapshotResult, len(regions))
+	errs := make([]error, len(regions))
+	var wg sync.WaitGroup
+	for i, region := range regions {
+		bm := managerFor(region)
+		if maxPerRegion > 0 {
+			bm.Concurrency = maxPerRegion
+		}
+		// the regions run at once, and can have clusters of the same name
+		if state, ok := bm.State.(RegionStateStore); ok {
+			bm.State = state.ForRegion(region)
+		}
+		wg.Add(1)
+		go func(i int, region string, bm *BackupManager) {
+			defer wg.Done()
+			bm.logf("Backing up %d cluster(s) in %s.", len(groups[region]), region)
+			results[i], errs[i] = runBackup(ctx, bm, groups[region], discoverIn[region], nil)
+			// other regions had clusters, so the run as a whole isn't empty
+			if errors.Is(errs[i], ErrNoClustersDiscovered) && len(regions) > 1 {
+				bm.logf("Discovery found no clusters in %s.", region)
+				errs[i] = nil
+			}
+			if errs[i] != nil {
+				bm.logf("Backing up clusters in %s failed: %v", region, errs[i])
+			}
+		}(i, region, bm)
+	}
+	wg.Wait()
+
+	var (
+		firstErr error
+		all      []SnapshotResult
+	)
+	for i := range regions {
+		all = append(all, results[i]...)
+		if errs[i] != nil && firstErr == nil {
+			firstErr = errs[i]
+		}
+	}
+	return all, firstErr
+}
+
+// loadRegionsFile reads the regions listed in the file at path, one to a
+// line, leaving out repeats. Every one has to look like a region, and there
+// has to be at least one.
+func loadRegionsFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	lines, err := readLines(f)
+	if err != nil {
+		return nil, fmt.Errorf("'%s': %w", path, err)
+	}
+	seen := make(map[string]bool)
+	regions := make([]string, 0, len(lines))
+	for _, region := range lines {
+		if err := validateRegion(region); err != nil {
+			return nil, fmt.Errorf("'%s': %w", path, err)
+		}
+		if !seen[region] {
+			seen[region] = true
+			regions = append(regions, region)
+		}
+	}
+	if len(regions) == 0 {
+		return nil, fmt.Errorf("'%s': %w", path, ErrNoRegions)
+	}
+	return regions, nil
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupByRegion(t *testing.T) {
+	type testCase struct {
+		clusterIDs    []string
+		expected      map[string][]string
+		expectedError bool
+	}
+
+	testCases := map[string]testCase{
+		"bare identifiers use the default region": {
+			clusterIDs: []string{"my-cluster-1", "my-cluster-2"},
+			expected:   map[string][]string{"us-east-1": {"my-cluster-1", "my-cluster-2"}},
+		},
+		"ARNs are grouped by their region, in order": {
+			clusterIDs: []string{
+				"arn:aws:rds:eu-west-1:123456789012:cluster:my-cluster-1",
+				"my-cluster-2",
+				"arn:aws:rds:us-west-2:123456789012:cluster:my-cluster-3",
+				"arn:aws:rds:eu-west-1:123456789012:cluster:my-cluster-4",
+			},
+			expected: map[string][]string{
+				"eu-west-1": {
+					"arn:aws:rds:eu-west-1:123456789012:cluster:my-cluster-1",
+					"arn:aws:rds:eu-west-1:123456789012:cluster:my-cluster-4",
+				},
+				"us-east-1": {"my-cluster-2"},
+				"us-west-2": {"arn:aws:rds:us-west-2:123456789012:cluster:my-cluster-3"},
+			},
+		},
+		"ARN without a region uses the default": {
+			clusterIDs: []string{"arn:aws:rds::123456789012:cluster:my-cluster-1"},
+			expected:   map[string][]string{"us-east-1": {"arn:aws:rds::123456789012:cluster:my-cluster-1"}},
+		},
+		"malformed ARN": {
+			clusterIDs:    []string{"arn:aws:rds"},
+			expectedError: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			groups, err := groupByRegion(tc.clusterIDs, "us-east-1")
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, groups)
+		})
+	}
+}
+
+func TestRunBackupByRegion(t *testing.T) {
+	euTaker := NewFlakySnapshotTaker("my-cluster-1", &ClusterStateError{})
+	usTaker := NewFakeSnapshotTaker()
+	takers := map[string]RDSAPI{"eu-west-1": euTaker, "us-east-1": usTaker}
+	results, err := runBackupByRegion(context.TODO(), []string{
+		"arn:aws:rds:eu-west-1:123456789012:cluster:my-cluster-1",
+		"my-cluster-2",
+		"arn:aws:rds:us-east-1:123456789012:cluster:my-cluster-3",
+	}, "us-east-1", false, 0, func(region string) *BackupManager {
+		return NewBackupManager(takers[region], WithPrefix("testing"))
+	})
+
+	// eu-west-1 failing doesn't stop us-east-1
+	var stateErr *ClusterStateError
+	assert.ErrorAs(t, err, &stateErr)
+	assert.Len(t, results, 3)
+	assert.Equal(t, []snapshotCreationRecord{}, euTaker.GetJournal())
+	assert.Equal(t, []snapshotCreationRecord{
+		{"my-cluster-2", "testing-my-cluster-2"},
+		{"my-cluster-3", "testing-my-cluster-3"},
+	}, usTaker.GetJournal())
+}
+
+func TestRunBackupByRegionSharesStateFile(t *testing.T) {
+	state := NewFileStateStore(filepath.Join(t.TempDir(), "state"))
+	clusterIDs := []string{
+		"arn:aws:rds:eu-west-1:123456789012:cluster:payments",
+		"payments",
+		"search",
+	}
+	run := func(takers map[string]RDSAPI) {
+		runBackupByRegion(context.TODO(), clusterIDs, "us-east-1", false, 0, func(region string) *BackupManager {
+			bm := NewBackupManager(takers[region], WithPrefix("testing"))
+			bm.State = state
+			return bm
+		})
+	}
+
+	// eu-west-1's payments fails, and us-east-1's search does after its
+	// payments is done
+	run(map[string]RDSAPI{
+		"eu-west-1": NewFlakySnapshotTaker("payments", &ClusterStateError{}),
+		"us-east-1": NewFlakySnapshotTaker("search", &ClusterStateError{}),
+	})
+	done, err := state.ForRegion("us-east-1").Done()
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]bool{"payments": true}, done)
+	done, err = state.ForRegion("eu-west-1").Done()
+	assert.Nil(t, err)
+	assert.Empty(t, done)
+
+	// so the re-run still does eu-west-1's payments
+	euTaker, usTaker := NewFakeSnapshotTaker(), NewFakeSnapshotTaker()
+	run(map[string]RDSAPI{"eu-west-1": euTaker, "us-east-1": usTaker})
+	assert.Equal(t, []snapshotCreationRecord{{"payments", "testing-payments"}}, euTaker.GetJournal())
+	assert.Equal(t, []snapshotCreationRecord{{"search", "testing-search"}}, usTaker.GetJournal())
+}
+
+// countingSnapshotTaker holds each snapshot for a moment and remembers the
+// most it saw being taken at once.
+type countingSnapshotTaker struct {
+	*fakeSnapshotTaker
+	mu       sync.Mutex
+	inFlight int
+	peak     int
+}
+
+func (c *countingSnapshotTaker) CreateDBClusterSnapshot(ctx context.Context, in *rds.CreateDBClusterSnapshotInput, optFns ...func(*rds.Options)) (*rds.CreateDBClusterSnapshotOutput, error) {
+	c.mu.Lock()
+	c.inFlight++
+	if c.inFlight > c.peak {
+		c.peak = c.inFlight
+	}
+	c.mu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	c.mu.Lock()
+	c.inFlight--
+	c.mu.Unlock()
+	return c.fakeSnapshotTaker.CreateDBClusterSnapshot(ctx, in, optFns...)
+}
+
+func TestRunBackupByRegionMaxPerRegion(t *testing.T) {
+	takers := map[string]*countingSnapshotTaker{
+		"eu-west-1": {fakeSnapshotTaker: NewFakeSnapshotTaker()},
+		"us-east-1": {fakeSnapshotTaker: NewFakeSnapshotTaker()},
+	}
+	clusterIDs := make([]string, 0)
+	for _, region := range []string{"eu-west-1", "us-east-1"} {
+		for i := 1; i <= 4; i++ {
+			clusterIDs = append(clusterIDs, fmt.Sprintf("arn:aws:rds:%s:123456789012:cluster:my-cluster-%d", region, i))
+		}
+	}
+
+	results, err := runBackupByRegion(context.TODO(), clusterIDs, "us-east-1", false, 2, func(region string) *BackupManager {
+		return NewBackupManager(takers[region], WithPrefix("testing"), WithConcurrency(8))
+	})
+
+	assert.Nil(t, err)
+	assert.Len(t, results, 8)
+	for region, taker := range takers {
+		assert.Equal(t, 2, taker.peak, region)
+		assert.Len(t, taker.GetJournal(), 4, region)
+	}
+}
+
+func TestRunBackupByRegionNothingDiscovered(t *testing.T) {
+	euTaker := NewFakeSnapshotTaker()
+	usTaker := NewFakeSnapshotTaker()
+	takers := map[string]RDSAPI{"eu-west-1": euTaker, "us-east-1": usTaker}
+	managerFor := func(region string) *BackupManager {
+		return NewBackupManager(takers[region], WithPrefix("testing"))
+	}
+
+	// us-east-1 discovering nothing is fine while eu-west-1 has clusters
+	results, err := runBackupByRegion(context.TODO(), []string{
+		"arn:aws:rds:eu-west-1:123456789012:cluster:my-cluster-1",
+	}, "us-east-1", true, 0, managerFor)
+	assert.Nil(t, err)
+	assert.Len(t, results, 1)
+
+	// but with it the only region, the run is empty
+	_, err = runBackupByRegion(context.TODO(), nil, "us-east-1", true, 0, managerFor)
+	assert.ErrorIs(t, err, ErrNoClustersDiscovered)
+}
+
+func TestRunBackupInRegions(t *testing.T) {
+	takers := map[string]*countingSnapshotTaker{
+		"eu-west-1": {fakeSnapshotTaker: NewFakeSnapshotTaker()},
+		"us-east-1": {fakeSnapshotTaker: NewFakeSnapshotTaker()},
+		"us-west-2": {fakeSnapshotTaker: NewFakeSnapshotTaker()},
+	}
+	for i := 1; i <= 4; i++ {
+		takers["eu-west-1"].clusters = append(takers["eu-west-1"].clusters, existingCluster(fmt.Sprintf("eu-cluster-%d", i)))
+	}
+	takers["us-east-1"].clusters = []types.DBCluster{existingCluster("us-cluster-1")}
+
+	// us-east-1 isn't listed, so only the cluster named there is backed up
+	results, err := runBackupInRegions(context.TODO(), []string{
+		"arn:aws:rds:us-east-1:123456789012:cluster:named-cluster",
+	}, []string{"eu-west-1", "us-west-2"}, "us-east-1", 2, func(region string) *BackupManager {
+		return NewBackupManager(takers[region], WithPrefix("testing"), WithConcurrency(8))
+	})
+
+	assert.Nil(t, err)
+	assert.Len(t, results, 5)
+	assert.Equal(t, 2, takers["eu-west-1"].peak)
+	assert.Len(t, takers["eu-west-1"].GetJournal(), 4)
+	assert.Equal(t, []snapshotCreationRecord{{"named-cluster", "testing-named-cluster"}}, takers["us-east-1"].GetJournal())
+	assert.Empty(t, takers["us-west-2"].GetJournal())
+}
+
+func TestLoadRegionsFile(t *testing.T) {
+	type testCase struct {
+		contents    string
+		expected    []string
+		expectedErr error
+	}
+
+	testCases := map[string]testCase{
+		"one to a line, with comments": {
+			contents: "# the regions we run in\nus-east-1\n\n  eu-west-1  # Ireland\nus-east-1\n",
+			expected: []string{"us-east-1", "eu-west-1"},
+		},
+		"malformed region": {
+			contents:    "us-east-1\nEast US\n",
+			expectedErr: ErrInvalidRegion,
+		},
+		"only comments": {
+			contents:    "# nothing yet\n\n",
+			expectedErr: ErrNoRegions,
+		},
+		"empty": {
+			expectedErr: ErrNoRegions,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "regions")
+			assert.Nil(t, os.WriteFile(path, []byte(tc.contents), 0644))
+
+			regions, err := loadRegionsFile(path)
+			assert.ErrorIs(t, err, tc.expectedErr)
+			assert.Equal(t, tc.expected, regions)
+		})
+	}
+}
+
+func TestLoadRegionsFileMissing(t *testing.T) {
+	_, err := loadRegionsFile(filepath.Join(t.TempDir(), "regions"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+)
+
+// maxRenameAttempts is how many fresh names RenameOnConflict tries before
+// giving up on a cluster.
+const maxRenameAttempts = 3
+
+func isAlreadyExists(err error) bool {
+	var existsErr *types.DBClusterSnapshotAlreadyExistsFault
+	return errors.As(err, &existsErr)
+}
+
+// conflictTag is a few random hex digits to tell a snapshot apart from the
+// one that already has its name.
+func conflictTag() string {
+	buf := make([]byte, 3)
+	if _, err := rand.Read(buf); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano()%0xffffff, 16)
+	}
+	return hex.EncodeToString(buf)
+}
+
+// withConflictTag is name with tag added at the end, but before the suffix,
+// which is kept whole as it is everywhere else. The rest is cut short to fit.
+func (b *BackupManager) withConflictTag(name, tag string) string {
+	sep := b.separator()
+	end := tag
+	if suffix := strings.Trim(b.Suffix, "-"); suffix != "" && strings.HasSuffix(name, sep+suffix) {
+		name = strings.TrimSuffix(name, sep+suffix)
+		end = tag + sep + suffix
+	}
+	return trimSeparator(cutTo(name, b.maxIdentifierLen()-len(end)-len(sep)), sep) + sep + end
+}
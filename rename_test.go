@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// conflictingSnapshotTaker refuses to create the first few snapshots it's
+// asked for, as though they already existed, then creates the rest.
+type conflictingSnapshotTaker struct {
+	*fakeSnapshotTaker
+	conflicts int
+	tried     []string
+}
+
+func (c *conflictingSnapshotTaker) CreateDBClusterSnapshot(ctx context.Context, in *rds.CreateDBClusterSnapshotInput, optFns ...func(*rds.Options)) (*rds.CreateDBClusterSnapshotOutput, error) {
+	c.tried = append(c.tried, *in.DBClusterSnapshotIdentifier)
+	if len(c.tried) <= c.conflicts {
+		return nil, &types.DBClusterSnapshotAlreadyExistsFault{}
+	}
+	return c.fakeSnapshotTaker.CreateDBClusterSnapshot(ctx, in, optFns...)
+}
+
+func TestRenameOnConflict(t *testing.T) {
+	type testCase struct {
+		conflicts        int
+		rename           bool
+		suffix           string
+		expectedStatus   SnapshotStatus
+		expectedTries    int
+		expectedName     *regexp.Regexp
+		expectedConflict bool
+	}
+
+	testCases := map[string]testCase{
+		"the second name is taken": {
+			conflicts:      1,
+			rename:         true,
+			expectedStatus: StatusCreated,
+			expectedTries:  2,
+			expectedName:   regexp.MustCompile(`^testing-my-cluster-1-[0-9a-f]{6}$`),
+		},
+		"the suffix stays at the end": {
+			conflicts:      1,
+			rename:         true,
+			suffix:         "daily",
+			expectedStatus: StatusCreated,
+			expectedTries:  2,
+			expectedName:   regexp.MustCompile(`^testing-my-cluster-1-[0-9a-f]{6}-daily$`),
+		},
+		"gives up after a few names": {
+			conflicts:        maxRenameAttempts + 1,
+			rename:           true,
+			expectedStatus:   StatusFailed,
+			expectedTries:    maxRenameAttempts + 1,
+			expectedConflict: true,
+		},
+		"without RenameOnConflict it fails": {
+			conflicts:        1,
+			expectedStatus:   StatusFailed,
+			expectedTries:    1,
+			expectedConflict: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			st := &conflictingSnapshotTaker{fakeSnapshotTaker: NewFakeSnapshotTaker(), conflicts: tc.conflicts}
+			bm := NewBackupManager(st, WithPrefix("testing"), WithSuffix(tc.suffix), WithRenameOnConflict(tc.rename))
+
+			result, err := bm.TriggerSnapshot(context.TODO(), "my-cluster-1")
+			assert.Equal(t, tc.expectedStatus, result.Status)
+			assert.Len(t, st.tried, tc.expectedTries)
+			if tc.expectedConflict {
+				var existsErr *types.DBClusterSnapshotAlreadyExistsFault
+				assert.ErrorAs(t, err, &existsErr)
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Regexp(t, tc.expectedName, result.SnapshotIdentifier)
+			assert.NotEqual(t, st.tried[0], st.tried[1])
+			assert.Equal(t, []snapshotCreationRecord{{"my-cluster-1", result.SnapshotIdentifier}}, st.GetJournal())
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Formatter writes a report of a run's results for something else to read.
+type Formatter interface {
+	Format(w io.Writer, results []SnapshotResult) error
+}
+
+// JUnitFormatter reports a run as a JUnit test suite, with a test case per
+// cluster, so CI systems can show backups the way they show tests. Created
+// snapshots pass, skipped clusters are skipped and failures carry the error.
+type JUnitFormatter struct {
+	// SuiteName names the test suite. It defaults to "rds-backup".
+	SuiteName string
+}
+
+type junitTestSuite struct {
+	XMLName   xml.Name        `xml:"testsuite"`
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+}
+
+func (f JUnitFormatter) Format(w io.Writer, results []SnapshotResult) error {
+	suite := junitTestSuite{Name: f.SuiteName, Tests: len(results)}
+	if suite.Name == "" {
+		suite.Name = "rds-backup"
+	}
+
+	for _, result := range results {
+		tc := junitTestCase{ClassName: suite.Name, Name: qualifiedCluster(result), SystemOut: result.SnapshotIdentifier}
+		if result.InstanceIdentifier != "" {
+			tc.Name += "/" + result.InstanceIdentifier
+		}
+		switch result.Status {
+		case StatusSkippedNotFound:
+			tc.Skipped = &junitMessage{Message: "cluster not found"}
+			suite.Skipped++
+		case StatusSkippedRecent:
+			tc.Skipped = &junitMessage{Message: fmt.Sprintf("recent snapshot '%s' already exists", result.SnapshotIdentifier)}
+			suite.Skipped++
+		case StatusSkippedUnchanged:
+			tc.Skipped = &junitMessage{Message: fmt.Sprintf("unchanged since snapshot '%s'", result.SnapshotIdentifier)}
+			suite.Skipped++
+		case StatusSkippedOptOut:
+			tc.Skipped = &junitMessage{Message: "opted out of backups by its tags"}
+			suite.Skipped++
+		case StatusSkippedExisting:
+			tc.Skipped = &junitMessage{Message: fmt.Sprintf("snapshot '%s' already exists", result.SnapshotIdentifier)}
+			suite.Skipped++
+		case StatusSkippedStopped:
+			tc.Skipped = &junitMessage{Message: "cluster is stopped"}
+			suite.Skipped++
+		case StatusSkippedDone:
+			tc.Skipped = &junitMessage{Message: "already snapshotted earlier in the run"}
+			suite.Skipped++
+		case StatusFailed:
+			tc.Failure = &junitMessage{Message: "snapshot failed"}
+			if result.Err != nil {
+				tc.Failure.Message = result.Err.Error()
+			}
+			suite.Failures++
+		}
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suite); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// IDsFormatter writes the identifier of each snapshot created, one per line
+// and nothing else, for piping into other tools. For a dry run, it writes the
+// identifiers of the snapshots planned instead. A snapshot taken with a
+// profile has the profile and a tab in front, as it's only found with it.
+type IDsFormatter struct {
+	// DryRun writes planned snapshots' identifiers, as none were created.
+	DryRun bool
+}
+
+func (f IDsFormatter) Format(w io.Writer, results []SnapshotResult) error {
+	for _, result := range results {
+		if result.Status != StatusCreated && !(f.DryRun && result.Status == StatusPlanned) {
+			continue
+		}
+		line := result.SnapshotIdentifier
+		if result.Profile != "" {
+			line = result.Profile + "\t" + line
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeReport formats results into the file at path, replacing it.
+func writeReport(path string, f Formatter, results []SnapshotResult) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := f.Format(file, results); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden files in testdata")
+
+func TestJUnitFormatter(t *testing.T) {
+	results := []SnapshotResult{
+		{ClusterIdentifier: "my-cluster-1", SnapshotIdentifier: "testing-my-cluster-1", Status: StatusCreated},
+		{ClusterIdentifier: "my-cluster-2", SnapshotIdentifier: "testing-my-cluster-2", Status: StatusSkippedNotFound},
+		{ClusterIdentifier: "my-cluster-3", SnapshotIdentifier: "testing-my-cluster-3-old", Status: StatusSkippedRecent},
+		{
+			ClusterIdentifier:  "my-cluster-4",
+			SnapshotIdentifier: "testing-my-cluster-4",
+			Status:             StatusFailed,
+			Err:                &ClusterStateError{ClusterIdentifier: "my-cluster-4", Attempts: 4, Err: errors.New("cluster is <modifying>")},
+		},
+	}
+
+	var buf bytes.Buffer
+	assert.Nil(t, JUnitFormatter{}.Format(&buf, results))
+
+	golden := filepath.Join("testdata", "junit.golden.xml")
+	if *updateGolden {
+		assert.Nil(t, ioutil.WriteFile(golden, buf.Bytes(), 0644))
+	}
+	expected, err := ioutil.ReadFile(golden)
+	assert.Nil(t, err)
+	assert.Equal(t, string(expected), buf.String())
+}
+
+func TestJUnitFormatterSuiteName(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Nil(t, JUnitFormatter{SuiteName: "nightly"}.Format(&buf, nil))
+	assert.Contains(t, buf.String(), `<testsuite name="nightly" tests="0" failures="0" skipped="0"></testsuite>`)
+}
+
+func TestIDsFormatter(t *testing.T) {
+	results := []SnapshotResult{
+		{ClusterIdentifier: "my-cluster-1", SnapshotIdentifier: "testing-my-cluster-1", Status: StatusCreated},
+		{ClusterIdentifier: "my-cluster-2", SnapshotIdentifier: "testing-my-cluster-2", Status: StatusSkippedNotFound},
+		{ClusterIdentifier: "my-cluster-3", SnapshotIdentifier: "testing-my-cluster-3", Status: StatusFailed, Err: errors.New("boom")},
+		{ClusterIdentifier: "my-cluster-4", SnapshotIdentifier: "testing-my-cluster-4", Status: StatusCreated},
+	}
+
+	var buf bytes.Buffer
+	assert.Nil(t, IDsFormatter{}.Format(&buf, results))
+	assert.Equal(t, "testing-my-cluster-1\ntesting-my-cluster-4\n", buf.String())
+}
+
+func TestIDsFormatterDryRun(t *testing.T) {
+	results := []SnapshotResult{
+		{ClusterIdentifier: "my-cluster-1", SnapshotIdentifier: "testing-my-cluster-1", Status: StatusPlanned},
+		{ClusterIdentifier: "my-cluster-2", SnapshotIdentifier: "testing-my-cluster-2", Status: StatusSkippedRecent},
+	}
+
+	var buf bytes.Buffer
+	assert.Nil(t, IDsFormatter{}.Format(&buf, results))
+	assert.Equal(t, "", buf.String())
+	assert.Nil(t, IDsFormatter{DryRun: true}.Format(&buf, results))
+	assert.Equal(t, "testing-my-cluster-1\n", buf.String())
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+)
+
+// ClusterRestorer creates clusters from snapshots. *rds.Client implements it.
+type ClusterRestorer interface {
+	RestoreDBClusterFromSnapshot(context.Context, *rds.RestoreDBClusterFromSnapshotInput, ...func(*rds.Options)) (*rds.RestoreDBClusterFromSnapshotOutput, error)
+}
+
+// InstanceCreator adds instances to clusters and looks them up while they
+// come up. *rds.Client implements it.
+type InstanceCreator interface {
+	CreateDBInstance(context.Context, *rds.CreateDBInstanceInput, ...func(*rds.Options)) (*rds.CreateDBInstanceOutput, error)
+	DescribeDBInstances(context.Context, *rds.DescribeDBInstancesInput, ...func(*rds.Options)) (*rds.DescribeDBInstancesOutput, error)
+}
+
+const (
+	ErrNoClusterRestorer BackupManagerError = "restoring snapshots requires a ClusterRestorer"
+	ErrNoInstanceCreator BackupManagerError = "adding instances to a restored cluster requires an InstanceCreator"
+	ErrNoInstanceClass   BackupManagerError = "adding instances to a restored cluster requires an instance class"
+)
+
+// RestoreOptions describes the cluster to restore a snapshot into.
+type RestoreOptions struct {
+	// ClusterIdentifier names the new cluster.
+	ClusterIdentifier string
+
+	// Engine is the new cluster's engine. It defaults to the snapshot's.
+	Engine string
+
+	// Instances is how many instances to add to the new cluster, all of
+	// DBInstanceClass. A restored cluster has none otherwise, so it can't
+	// serve queries.
+	Instances       int
+	DBInstanceClass string
+}
+
+// RestoreResult is what a restore created.
+type RestoreResult struct {
+	ClusterIdentifier   string
+	InstanceIdentifiers []string
+}
+
+// RestoreSnapshot restores a cluster snapshot into a new cluster, waits for
+// it to be available, then adds opts.Instances instances and waits for those
+// too. The instances are named after the cluster, e.g. my-restore-1. When a
+// step fails, the result still lists what was created before it, so it can
+// be cleaned up.
+func (b *BackupManager) RestoreSnapshot(ctx context.Context, snapshotID string, opts RestoreOptions) (RestoreResult, error) {
+	result := RestoreResult{ClusterIdentifier: opts.ClusterIdentifier}
+	if b.rs == nil {
+		return result, ErrNoClusterRestorer
+	}
+	if b.cd == nil {
+		return result, ErrNoClusterDescriber
+	}
+	if opts.Instances > 0 && b.ic == nil {
+		return result, ErrNoInstanceCreator
+	}
+	if opts.Instances > 0 && opts.DBInstanceClass == "" {
+		return result, ErrNoInstanceClass
+	}
+
+	engine, err := b.restoreEngine(ctx, snapshotID, opts)
+	if err != nil {
+		return result, err
+	}
+
+	b.logf("Restoring snapshot '%s' to cluster '%s'.", snapshotID, opts.ClusterIdentifier)
+	_, err = b.rs.RestoreDBClusterFromSnapshot(ctx, &rds.RestoreDBClusterFromSnapshotInput{
+		DBClusterIdentifier: aws.String(opts.ClusterIdentifier),
+		SnapshotIdentifier:  aws.String(snapshotID),
+		Engine:              aws.String(engine),
+	})
+	if err != nil {
+		return result, fmt.Errorf("restoring snapshot '%s': %w", snapshotID, err)
+	}
+	if err := b.waitForCluster(ctx, opts.ClusterIdentifier); err != nil {
+		return result, err
+	}
+
+	for i := 1; i <= opts.Instances; i++ {
+		instanceID := fmt.Sprintf("%s-%d", opts.ClusterIdentifier, i)
+		b.logf("Adding %s instance '%s' to cluster '%s'.", opts.DBInstanceClass, instanceID, opts.ClusterIdentifier)
+		_, err := b.ic.CreateDBInstance(ctx, &rds.CreateDBInstanceInput{
+			DBClusterIdentifier:  aws.String(opts.ClusterIdentifier),
+			DBInstanceIdentifier: aws.String(instanceID),
+			DBInstanceClass:      aws.String(opts.DBInstanceClass),
+			Engine:               aws.String(engine),
+		})
+		if err != nil {
+			return result, fmt.Errorf("adding instance '%s': %w", instanceID, err)
+		}
+		result.InstanceIdentifiers = append(result.InstanceIdentifiers, instanceID)
+	}
+	if err := b.waitForInstances(ctx, result.InstanceIdentifiers); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// restoreEngine is the engine from opts, or failing that, the snapshot's.
+func (b *BackupManager) restoreEngine(ctx context.Context, snapshotID string, opts RestoreOptions) (string, error) {
+	if opts.Engine != "" {
+		return opts.Engine, nil
+	}
+	if b.sd == nil {
+		return "", ErrNoSnapshotDescriber
+	}
+	out, err := b.sd.DescribeDBClusterSnapshots(ctx, &rds.DescribeDBClusterSnapshotsInput{
+		DBClusterSnapshotIdentifier: aws.String(snapshotID),
+	})
+	if err != nil {
+		return "", fmt.Errorf("looking up snapshot '%s': %w", snapshotID, err)
+	}
+	if len(out.DBClusterSnapshots) == 0 {
+		return "", fmt.Errorf("'%s': %w", snapshotID, &types.DBClusterSnapshotNotFoundFault{})
+	}
+	return aws.ToString(out.DBClusterSnapshots[0].Engine), nil
+}
+
+// waitForCluster blocks until a cluster is available. It may take a moment
+// for a new cluster to show up at all.
+func (b *BackupManager) waitForCluster(ctx context.Context, clusterIdentifier string) error {
+	err := b.pollUntil(ctx, func(ctx context.Context) (bool, error) {
+		out, err := b.cd.DescribeDBClusters(ctx, &rds.DescribeDBClustersInput{
+			DBClusterIdentifier: aws.String(clusterIdentifier),
+		})
+		var nfErr *types.DBClusterNotFoundFault
+		if errors.As(err, &nfErr) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		return len(out.DBClusters) > 0 && aws.ToString(out.DBClusters[0].Status) == "available", nil
+	})
+	if err != nil {
+		return fmt.Errorf("waiting for cluster '%s': %w", clusterIdentifier, err)
+	}
+	return nil
+}
+
+// waitForInstances blocks until every given instance is available.
+func (b *BackupManager) waitForInstances(ctx context.Context, instanceIDs []string) error {
+	pending := append([]string(nil), instanceIDs...)
+	err := b.pollUntil(ctx, func(ctx context.Context) (bool, error) {
+		stillPending := pending[:0]
+		for _, instanceID := range pending {
+			out, err := b.ic.DescribeDBInstances(ctx, &rds.DescribeDBInstancesInput{
+				DBInstanceIdentifier: aws.String(instanceID),
+			})
+			var nfErr *types.DBInstanceNotFoundFault
+			if err != nil && !errors.As(err, &nfErr) {
+				return false, err
+			}
+			if err != nil || len(out.DBInstances) == 0 || aws.ToString(out.DBInstances[0].DBInstanceStatus) != "available" {
+				stillPending = append(stillPending, instanceID)
+			}
+		}
+		pending = stillPending
+		return len(pending) == 0, nil
+	})
+	if err != nil {
+		return fmt.Errorf("waiting for instances: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRestorer restores snapshots into clusters that take a describe call to
+// become available, and the same for their instances. createErr fails every
+// instance after the first. steps records every call in order.
+type fakeRestorer struct {
+	*fakeSnapshotTaker
+	createErr error
+	steps     []string
+	restores  []*rds.RestoreDBClusterFromSnapshotInput
+	creates   []*rds.CreateDBInstanceInput
+	statuses  map[string][]string
+}
+
+func newFakeRestorer(snapshots ...types.DBClusterSnapshot) *fakeRestorer {
+	return &fakeRestorer{
+		fakeSnapshotTaker: NewFakeSnapshotTakerWithSnapshots(snapshots...),
+		statuses:          make(map[string][]string),
+	}
+}
+
+// nextStatus walks a resource through its statuses, sticking on the last.
+func (f *fakeRestorer) nextStatus(id string) (string, bool) {
+	statuses, ok := f.statuses[id]
+	if !ok {
+		return "", false
+	}
+	if len(statuses) > 1 {
+		f.statuses[id] = statuses[1:]
+	}
+	return statuses[0], true
+}
+
+func (f *fakeRestorer) RestoreDBClusterFromSnapshot(ctx context.Context, in *rds.RestoreDBClusterFromSnapshotInput, optFns ...func(*rds.Options)) (*rds.RestoreDBClusterFromSnapshotOutput, error) {
+	f.steps = append(f.steps, "restore "+*in.DBClusterIdentifier)
+	f.restores = append(f.restores, in)
+	f.statuses[*in.DBClusterIdentifier] = []string{"creating", "available"}
+	return &rds.RestoreDBClusterFromSnapshotOutput{}, nil
+}
+
+func (f *fakeRestorer) DescribeDBClusters(ctx context.Context, in *rds.DescribeDBClustersInput, optFns ...func(*rds.Options)) (*rds.DescribeDBClustersOutput, error) {
+	status, ok := f.nextStatus(*in.DBClusterIdentifier)
+	f.steps = append(f.steps, "describe "+*in.DBClusterIdentifier+" "+status)
+	if !ok {
+		return nil, &types.DBClusterNotFoundFault{}
+	}
+	return &rds.DescribeDBClustersOutput{
+		DBClusters: []types.DBCluster{{DBClusterIdentifier: in.DBClusterIdentifier, Status: aws.String(status)}},
+	}, nil
+}
+
+func (f *fakeRestorer) CreateDBInstance(ctx context.Context, in *rds.CreateDBInstanceInput, optFns ...func(*rds.Options)) (*rds.CreateDBInstanceOutput, error) {
+	f.steps = append(f.steps, "create "+*in.DBInstanceIdentifier)
+	if f.createErr != nil && len(f.creates) > 0 {
+		return nil, f.createErr
+	}
+	f.creates = append(f.creates, in)
+	f.statuses[*in.DBInstanceIdentifier] = []string{"creating", "available"}
+	return &rds.CreateDBInstanceOutput{}, nil
+}
+
+func (f *fakeRestorer) DescribeDBInstances(ctx context.Context, in *rds.DescribeDBInstancesInput, optFns ...func(*rds.Options)) (*rds.DescribeDBInstancesOutput, error) {
+	status, ok := f.nextStatus(*in.DBInstanceIdentifier)
+	f.steps = append(f.steps, "describe "+*in.DBInstanceIdentifier+" "+status)
+	if !ok {
+		return nil, &types.DBInstanceNotFoundFault{}
+	}
+	return &rds.DescribeDBInstancesOutput{
+		DBInstances: []types.DBInstance{{DBInstanceIdentifier: in.DBInstanceIdentifier, DBInstanceStatus: aws.String(status)}},
+	}, nil
+}
+
+func snapshotWithEngine(snapshot types.DBClusterSnapshot, engine string) types.DBClusterSnapshot {
+	snapshot.Engine = aws.String(engine)
+	return snapshot
+}
+
+func TestRestoreSnapshot(t *testing.T) {
+	rs := newFakeRestorer(snapshotWithEngine(existingSnapshot("my-cluster-1", "testing-my-cluster-1", testNow), "aurora-postgresql"))
+	bm := NewBackupManager(rs, WithPrefix("testing"))
+	bm.sleep = noSleep
+
+	result, err := bm.RestoreSnapshot(context.TODO(), "testing-my-cluster-1", RestoreOptions{
+		ClusterIdentifier: "my-restore",
+		Instances:         2,
+		DBInstanceClass:   "db.r6g.large",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, RestoreResult{
+		ClusterIdentifier:   "my-restore",
+		InstanceIdentifiers: []string{"my-restore-1", "my-restore-2"},
+	}, result)
+
+	// each step waits for the one before it
+	assert.Equal(t, []string{
+		"restore my-restore",
+		"describe my-restore creating",
+		"describe my-restore available",
+		"create my-restore-1",
+		"create my-restore-2",
+		"describe my-restore-1 creating",
+		"describe my-restore-2 creating",
+		"describe my-restore-1 available",
+		"describe my-restore-2 available",
+	}, rs.steps)
+
+	assert.Equal(t, "aurora-postgresql", aws.ToString(rs.restores[0].Engine))
+	assert.Equal(t, "testing-my-cluster-1", aws.ToString(rs.restores[0].SnapshotIdentifier))
+	for _, create := range rs.creates {
+		assert.Equal(t, "my-restore", aws.ToString(create.DBClusterIdentifier))
+		assert.Equal(t, "db.r6g.large", aws.ToString(create.DBInstanceClass))
+		assert.Equal(t, "aurora-postgresql", aws.ToString(create.Engine))
+	}
+}
+
+func TestRestoreSnapshotWithoutInstances(t *testing.T) {
+	rs := newFakeRestorer()
+	bm := NewBackupManager(rs)
+	bm.sleep = noSleep
+
+	result, err := bm.RestoreSnapshot(context.TODO(), "testing-my-cluster-1", RestoreOptions{ClusterIdentifier: "my-restore", Engine: "aurora-mysql"})
+	assert.Nil(t, err)
+	assert.Empty(t, result.InstanceIdentifiers)
+	assert.Empty(t, rs.creates)
+	assert.Equal(t, "aurora-mysql", aws.ToString(rs.restores[0].Engine))
+}
+
+func TestRestoreSnapshotErrors(t *testing.T) {
+	type testCase struct {
+		bm            func() *BackupManager
+		opts          RestoreOptions
+		expectedError error
+		expectedIDs   []string
+	}
+
+	createErr := errors.New("quota exceeded")
+	opts := RestoreOptions{ClusterIdentifier: "my-restore", Engine: "aurora-mysql", Instances: 2, DBInstanceClass: "db.t4g.medium"}
+	testCases := map[string]testCase{
+		"no restorer": {
+			bm:            func() *BackupManager { return &BackupManager{st: NewFakeSnapshotTaker()} },
+			opts:          opts,
+			expectedError: ErrNoClusterRestorer,
+		},
+		"instances without a class": {
+			bm:            func() *BackupManager { return NewBackupManager(newFakeRestorer()) },
+			opts:          RestoreOptions{ClusterIdentifier: "my-restore", Engine: "aurora-mysql", Instances: 1},
+			expectedError: ErrNoInstanceClass,
+		},
+		"adding an instance fails": {
+			bm: func() *BackupManager {
+				rs := newFakeRestorer()
+				rs.createErr = createErr
+				return NewBackupManager(rs)
+			},
+			opts:          opts,
+			expectedError: createErr,
+			expectedIDs:   []string{"my-restore-1"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			bm := tc.bm()
+			bm.sleep = noSleep
+			result, err := bm.RestoreSnapshot(context.TODO(), "testing-my-cluster-1", tc.opts)
+			assert.ErrorIs(t, err, tc.expectedError)
+			assert.Equal(t, tc.expectedIDs, result.InstanceIdentifiers)
+		})
+	}
+}
+
+func TestRestoreSnapshotEngineFromMissingSnapshot(t *testing.T) {
+	rs := newFakeRestorer()
+	bm := NewBackupManager(rs)
+
+	_, err := bm.RestoreSnapshot(context.TODO(), "testing-my-cluster-1", RestoreOptions{ClusterIdentifier: "my-restore"})
+	var nfErr *types.DBClusterSnapshotNotFoundFault
+	assert.ErrorAs(t, err, &nfErr)
+	assert.Empty(t, rs.restores)
+}
+
+func TestRestoreSnapshotWithFakeRDS(t *testing.T) {
+	st := NewFakeSnapshotTaker()
+	bm := NewBackupManager(st)
+
+	result, err := bm.RestoreSnapshot(context.TODO(), "testing-my-cluster-1", RestoreOptions{
+		ClusterIdentifier: "my-restore",
+		Engine:            "aurora-mysql",
+		Instances:         1,
+		DBInstanceClass:   "db.t4g.medium",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"my-restore-1"}, result.InstanceIdentifiers)
+	assert.Equal(t, []string{"my-restore"}, clusterIdentifiers(st.clusters))
+	assert.Equal(t, "db.t4g.medium", aws.ToString(st.instances[0].DBInstanceClass))
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+)
+
+const ErrNoRetagTags BackupManagerError = "retagging snapshots needs at least one tag"
+
+// RetagSnapshots applies tags to every snapshot ListSnapshots returns, so the
+// prefixes and tag selector decide what's touched just as they do for
+// pruning. Snapshots that already have the tags are left alone. In a dry run,
+// it only logs what it would tag.
+func (b *BackupManager) RetagSnapshots(ctx context.Context, tags map[string]string) error {
+	if len(tags) == 0 {
+		return ErrNoRetagTags
+	}
+	if b.ta == nil && !b.DryRun {
+		return ErrNoTagAdder
+	}
+
+	snapshots, err := b.ListSnapshots(ctx)
+	if err != nil {
+		return err
+	}
+
+	tagList := sortedTags(tags)
+	updated := 0
+	for _, snapshot := range snapshots {
+		snapshotID := aws.ToString(snapshot.DBClusterSnapshotIdentifier)
+		if matchesTags(snapshot.TagList, tags) {
+			b.debugf("Snapshot '%s' already has the tags.", snapshotID)
+			continue
+		}
+		if b.DryRun {
+			b.logf("Would tag snapshot '%s'.", snapshotID)
+			updated++
+			continue
+		}
+		_, err := b.ta.AddTagsToResource(ctx, &rds.AddTagsToResourceInput{
+			ResourceName: snapshot.DBClusterSnapshotArn,
+			Tags:         tagList,
+		})
+		if err != nil {
+			b.logf("Retagged %d of %d snapshot(s) before failing.", updated, len(snapshots))
+			return fmt.Errorf("tagging snapshot '%s': %w", snapshotID, err)
+		}
+		updated++
+	}
+
+	if b.DryRun {
+		b.logf("Would retag %d of %d snapshot(s).", updated, len(snapshots))
+		return nil
+	}
+	b.logf("Retagged %d of %d snapshot(s).", updated, len(snapshots))
+	return nil
+}
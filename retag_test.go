@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetagSnapshots(t *testing.T) {
+	arn := "arn:aws:rds:us-east-1:123456789012:cluster-snapshot:"
+	newSnapshots := func() *fakeSnapshotTaker {
+		st := NewFakeSnapshotTaker()
+		st.snapshots = []types.DBClusterSnapshot{
+			tagged(existingSnapshot("my-cluster-1", "testing-my-cluster-1", testNow), map[string]string{"env": "prod"}),
+			tagged(existingSnapshot("my-cluster-2", "testing-my-cluster-2", testNow), map[string]string{"env": "prod", "cost-center": "data"}),
+			tagged(existingSnapshot("my-cluster-3", "testing-my-cluster-3", testNow), map[string]string{"env": "staging"}),
+			existingSnapshot("my-cluster-1", "someone-elses", testNow),
+		}
+		return st
+	}
+	tags := map[string]string{"cost-center": "data", "owner": "platform"}
+	expected := []types.Tag{
+		{Key: aws.String("cost-center"), Value: aws.String("data")},
+		{Key: aws.String("owner"), Value: aws.String("platform")},
+	}
+
+	t.Run("everything with the prefix", func(t *testing.T) {
+		st := newSnapshots()
+		bm := NewBackupManager(st, WithPrefix("testing"))
+		assert.Nil(t, bm.RetagSnapshots(context.TODO(), tags))
+		assert.Equal(t, map[string][]types.Tag{
+			arn + "testing-my-cluster-1": expected,
+			arn + "testing-my-cluster-2": expected,
+			arn + "testing-my-cluster-3": expected,
+		}, st.addedTags)
+
+		// again, there's nothing left to do
+		st.addedTags = map[string][]types.Tag{}
+		for i := range st.snapshots[:3] {
+			st.snapshots[i].TagList = append(st.snapshots[i].TagList, expected...)
+		}
+		assert.Nil(t, bm.RetagSnapshots(context.TODO(), tags))
+		assert.Empty(t, st.addedTags)
+	})
+
+	t.Run("only selected snapshots", func(t *testing.T) {
+		st := newSnapshots()
+		bm := NewBackupManager(st, WithPrefix("testing"), WithTagSelector(map[string]string{"env": "prod"}, false))
+		assert.Nil(t, bm.RetagSnapshots(context.TODO(), map[string]string{"owner": "platform"}))
+		assert.Equal(t, []string{arn + "testing-my-cluster-1", arn + "testing-my-cluster-2"}, sortedKeys(st.addedTags))
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		st := newSnapshots()
+		bm := NewBackupManager(st, WithPrefix("testing"), WithDryRun(true, false))
+		assert.Nil(t, bm.RetagSnapshots(context.TODO(), tags))
+		assert.Empty(t, st.addedTags)
+	})
+
+	t.Run("no tags", func(t *testing.T) {
+		bm := NewBackupManager(newSnapshots(), WithPrefix("testing"))
+		assert.ErrorIs(t, bm.RetagSnapshots(context.TODO(), nil), ErrNoRetagTags)
+	})
+}
+
+func sortedKeys(m map[string][]types.Tag) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
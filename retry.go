@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+const (
+	defaultMaxRetries = 3
+	retryBaseDelay    = 2 * time.Second
+	defaultMaxBackoff = 5 * time.Minute
+
+	// describeRetries is how many times a describe call made while waiting
+	// is retried, so a blip doesn't end an otherwise healthy wait.
+	describeRetries = 2
+)
+
+const ErrRetryBudgetExhausted BackupManagerError = "the run's retry budget is used up"
+
+// ClusterStateError is returned when a cluster stayed in a state that doesn't
+// allow snapshots (e.g. mid-modification) through every retry, or when the
+// run's retry budget ran out before it recovered.
+type ClusterStateError struct {
+	ClusterIdentifier string
+	Attempts          int
+	BudgetExhausted   bool
+	Err               error
+}
+
+func (e *ClusterStateError) Error() string {
+	if e.BudgetExhausted {
+		return fmt.Sprintf("cluster '%s' can't be snapshotted after %d attempts and %s: %v", e.ClusterIdentifier, e.Attempts, ErrRetryBudgetExhausted, e.Err)
+	}
+	return fmt.Sprintf("cluster '%s' still can't be snapshotted after %d attempts: %v", e.ClusterIdentifier, e.Attempts, e.Err)
+}
+
+func (e *ClusterStateError) Unwrap() error {
+	return e.Err
+}
+
+// Is lets errors.Is find ErrRetryBudgetExhausted, while Unwrap still leads to
+// the underlying fault.
+func (e *ClusterStateError) Is(target error) bool {
+	return e.BudgetExhausted && target == ErrRetryBudgetExhausted
+}
+
+// isTransient reports whether an error is likely to clear up by itself, so
+// the call is worth retrying.
+func isTransient(err error) bool {
+	var isErr *types.InvalidDBClusterStateFault
+	var instanceErr *types.InvalidDBInstanceStateFault
+	return errors.As(err, &isErr) || errors.As(err, &instanceErr) || isThrottle(err)
+}
+
+// isThrottle reports whether RDS turned a call down for being made too
+// often, going by the same error codes the SDK's own retryer uses.
+func isThrottle(err error) bool {
+	throttles := retry.ThrottleErrorCode{Codes: retry.DefaultThrottleErrorCodes}
+	return throttles.IsErrorThrottle(err) == aws.TrueTernary
+}
+
+// isRetryable reports whether a read-only call is worth making again: it was
+// throttled, or failed in a way the SDK's own retryer would retry, like a
+// dropped connection or a 5xx.
+func isRetryable(err error) bool {
+	retryables := retry.IsErrorRetryables(retry.DefaultRetryables)
+	return isThrottle(err) || retryables.IsErrorRetryable(err) == aws.TrueTernary
+}
+
+// retryHint reads how long a failed call asked us to wait from the response's
+// Retry-After header, which may be either seconds or an HTTP date.
+func retryHint(err error, now time.Time) (time.Duration, bool) {
+	var respErr interface{ HTTPResponse() *smithyhttp.Response }
+	if !errors.As(err, &respErr) {
+		return 0, false
+	}
+	resp := respErr.HTTPResponse()
+	if resp == nil || resp.Response == nil {
+		return 0, false
+	}
+
+	header := resp.Header.Get("Retry-After")
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(header); err == nil && at.After(now) {
+		return at.Sub(now), true
+	}
+	return 0, false
+}
+
+// retryDelay works out how long to wait before the next attempt. Throttling
+// honors the response's hint if there is one, otherwise it gets jitter so
+// throttled workers don't all come back at once. Either way, it's capped.
+func (b *BackupManager) retryDelay(err error, attempt int) time.Duration {
+	delay := b.maxBackoff()
+	// past a few dozen attempts, the shift would overflow
+	if attempt < 30 && retryBaseDelay<<attempt < delay {
+		delay = retryBaseDelay << attempt
+	}
+	if isThrottle(err) {
+		if hint, ok := retryHint(err, b.clock()); ok {
+			delay = hint
+		} else {
+			delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+		}
+	}
+	if delay > b.maxBackoff() {
+		delay = b.maxBackoff()
+	}
+	return delay
+}
+
+func (b *BackupManager) maxBackoff() time.Duration {
+	if b.MaxBackoff <= 0 {
+		return defaultMaxBackoff
+	}
+	return b.MaxBackoff
+}
+
+// maxAttempts is how many times a snapshot is tried in all. MaxAttempts wins
+// over MaxRetries when both are set.
+func (b *BackupManager) maxAttempts() int {
+	if b.MaxAttempts > 0 {
+		return b.MaxAttempts
+	}
+	return b.maxRetries() + 1
+}
+
+func (b *BackupManager) maxRetries() int {
+	if b.MaxRetries == 0 {
+		return defaultMaxRetries
+	}
+	if b.MaxRetries < 0 {
+		return 0
+	}
+	return b.MaxRetries
+}
+
+// resetRetryBudget refills the shared retry budget at the start of a run.
+func (b *BackupManager) resetRetryBudget() {
+	atomic.StoreInt64(&b.retriesLeft, int64(b.RetryBudget))
+}
+
+// takeRetry spends one retry from the shared budget, reporting false if
+// there's none left. Without a budget, there's always one to spend.
+func (b *BackupManager) takeRetry() bool {
+	if b.RetryBudget <= 0 {
+		return true
+	}
+	return atomic.AddInt64(&b.retriesLeft, -1) >= 0
+}
+
+// createSnapshot calls CreateDBClusterSnapshot, retrying transient errors
+// with exponential backoff until the cluster's retries or the run's retry
+// budget run out.
+func (b *BackupManager) createSnapshot(ctx context.Context, in *rds.CreateDBClusterSnapshotInput) (*rds.CreateDBClusterSnapshotOutput, error) {
+	var out *rds.CreateDBClusterSnapshotOutput
+	err := b.retryTransient(ctx, *in.DBClusterIdentifier, "cluster", *in.DBClusterIdentifier, func() (err error) {
+		out, err = b.st.CreateDBClusterSnapshot(ctx, in)
+		return err
+	})
+	return out, err
+}
+
+// createInstanceSnapshot is createSnapshot for one of a cluster's instances.
+func (b *BackupManager) createInstanceSnapshot(ctx context.Context, clusterIdentifier string, in *rds.CreateDBSnapshotInput) (*rds.CreateDBSnapshotOutput, error) {
+	var out *rds.CreateDBSnapshotOutput
+	err := b.retryTransient(ctx, clusterIdentifier, "instance", *in.DBInstanceIdentifier, func() (err error) {
+		out, err = b.ist.CreateDBSnapshot(ctx, in)
+		return err
+	})
+	return out, err
+}
+
+// retryTransient makes a snapshot call, retrying it while it fails with
+// transient errors until the retries or the budget run out. kind and name,
+// like "instance" and its identifier, say what's being snapshotted in the
+// logs.
+func (b *BackupManager) retryTransient(ctx context.Context, clusterIdentifier, kind, name string, call func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := call()
+		if err == nil || !isTransient(err) {
+			return err
+		}
+		if isThrottle(err) {
+			atomic.AddInt64(&b.stats.throttled, 1)
+		}
+		if attempt+1 >= b.maxAttempts() {
+			return &ClusterStateError{
+				ClusterIdentifier: clusterIdentifier,
+				Attempts:          attempt + 1,
+				Err:               err,
+			}
+		}
+		if !b.takeRetry() {
+			return &ClusterStateError{
+				ClusterIdentifier: clusterIdentifier,
+				Attempts:          attempt + 1,
+				BudgetExhausted:   true,
+				Err:               err,
+			}
+		}
+
+		atomic.AddInt64(&b.stats.retried, 1)
+		delay := b.retryDelay(err, attempt)
+		if isThrottle(err) {
+			b.logf("Snapshotting %s '%s' was throttled, retrying in %s.", kind, name, delay)
+		} else {
+			b.logf("%s '%s' isn't ready for a snapshot, retrying in %s.", strings.ToUpper(kind[:1])+kind[1:], name, delay)
+		}
+		if err := b.wait(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+func (b *BackupManager) wait(ctx context.Context, d time.Duration) error {
+	if b.sleep != nil {
+		return b.sleep(ctx, d)
+	}
+	return sleepContext(ctx, d)
+}
+
+// sleepContext sleeps for d, returning early with the context's error if it's
+// cancelled first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
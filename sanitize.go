@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxSuffixLen leaves a snapshot identifier room for more than its suffix,
+// and maxPrefixLen and maxSeparatorLen do the same for a prefix taken from a
+// tag and for the separator.
+const (
+	maxSuffixLen    = 32
+	maxPrefixLen    = 32
+	maxSeparatorLen = 32
+)
+
+// sanitizeIdentifier makes s acceptable as an RDS snapshot identifier, which
+// may only contain ASCII letters, digits and single hyphens, and can't start
+// or end with a hyphen. Anything else is replaced with a hyphen, and runs of
+// hyphens are collapsed into one.
+func sanitizeIdentifier(s string) string {
+	var sb strings.Builder
+	sb.Grow(len(s))
+	lastHyphen := true // so a leading hyphen is dropped
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			sb.WriteRune(r)
+			lastHyphen = false
+		case !lastHyphen:
+			sb.WriteByte('-')
+			lastHyphen = true
+		}
+	}
+	return strings.TrimSuffix(sb.String(), "-")
+}
+
+// normalizeCase lowercases part of a snapshot identifier, as RDS would once
+// it's created, unless PreserveCase is set.
+func (b *BackupManager) normalizeCase(s string) string {
+	if b.PreserveCase {
+		return s
+	}
+	return strings.ToLower(s)
+}
+
+// validateSuffix checks that a suffix can go in a snapshot identifier as
+// is. Leading and trailing hyphens are fine, since they're trimmed off when
+// it's joined on.
+func validateSuffix(suffix string) error {
+	trimmed := strings.Trim(suffix, "-")
+	if len(trimmed) > maxSuffixLen || sanitizeIdentifier(trimmed) != trimmed {
+		return fmt.Errorf("'%s': %w", suffix, ErrInvalidSuffix)
+	}
+	return nil
+}
+
+// validateSeparator checks that a separator can join the parts of a snapshot
+// identifier as is. It's held to the same characters as a suffix, but since
+// it goes between the parts, it can't start or end with a hyphen, which would
+// double up with a hyphen in a cluster's name, unless it's just the one.
+// Empty is fine too, for parts run together.
+func validateSeparator(sep string) error {
+	if sep != "-" && (len(sep) > maxSeparatorLen || sanitizeIdentifier(sep) != sep) {
+		return fmt.Errorf("'%s': %w", sep, ErrInvalidSeparator)
+	}
+	return nil
+}
+
+// validatePrefix checks that a prefix taken from a cluster tag can start a
+// snapshot identifier as is. Unlike a suffix, it has to start with a letter,
+// like the identifier.
+func validatePrefix(prefix string) error {
+	if len(prefix) > maxPrefixLen || prefix == "" || !isLetter(prefix[0]) || sanitizeIdentifier(prefix) != prefix {
+		return fmt.Errorf("'%s': %w", prefix, ErrInvalidPrefix)
+	}
+	return nil
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitizeIdentifier(t *testing.T) {
+	type testCase struct {
+		input    string
+		expected string
+	}
+
+	testCases := map[string]testCase{
+		"already valid":                 {"testing-my-cluster-1", "testing-my-cluster-1"},
+		"underscores become hyphens":    {"testing-my_cluster_1", "testing-my-cluster-1"},
+		"dots become hyphens":           {"testing-my.cluster.1", "testing-my-cluster-1"},
+		"runs of hyphens collapse":      {"testing--my__.cluster", "testing-my-cluster"},
+		"no leading or trailing hyphen": {"_testing-my-cluster.", "testing-my-cluster"},
+		"non-ascii is illegal too":      {"testing-café-1", "testing-caf-1"},
+		"nothing legal left":            {"_._", ""},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, sanitizeIdentifier(tc.input))
+		})
+	}
+}
+
+func TestFormSnapshotIdentifierSanitized(t *testing.T) {
+	bm := &BackupManager{prefix: "testing", SanitizeName: true}
+	assert.Equal(t, "testing-my-cluster-1", bm.formSnapshotIdentifier("my_cluster.1"))
+
+	bm.SanitizeName = false
+	assert.Equal(t, "testing-my_cluster.1", bm.formSnapshotIdentifier("my_cluster.1"))
+}
+
+func TestFormSnapshotIdentifierCase(t *testing.T) {
+	type testCase struct {
+		prefix       string
+		suffix       string
+		clusterID    string
+		preserveCase bool
+		expected     string
+	}
+
+	testCases := map[string]testCase{
+		"lowercased by default": {
+			prefix:    "Nightly",
+			clusterID: "Payments-DB",
+			expected:  "nightly-payments-db",
+		},
+		"suffix too": {
+			prefix:    "nightly",
+			suffix:    "EU",
+			clusterID: "payments",
+			expected:  "nightly-payments-eu",
+		},
+		"already lowercase": {
+			prefix:    "nightly",
+			clusterID: "payments",
+			expected:  "nightly-payments",
+		},
+		"preserved": {
+			prefix:       "Nightly",
+			suffix:       "EU",
+			clusterID:    "Payments-DB",
+			preserveCase: true,
+			expected:     "Nightly-Payments-DB-EU",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			bm := &BackupManager{prefix: tc.prefix, Suffix: tc.suffix, PreserveCase: tc.preserveCase}
+			assert.Equal(t, tc.expected, bm.formSnapshotIdentifier(tc.clusterID))
+		})
+	}
+}
+
+func TestHasReadPrefixCase(t *testing.T) {
+	bm := &BackupManager{prefix: "Nightly"}
+	assert.True(t, bm.hasReadPrefix("nightly-payments"))
+	assert.True(t, bm.hasReadPrefix("NIGHTLY-payments"))
+
+	bm.PreserveCase = true
+	assert.False(t, bm.hasReadPrefix("nightly-payments"))
+	assert.True(t, bm.hasReadPrefix("Nightly-payments"))
+}
+
+func TestValidateSuffix(t *testing.T) {
+	type testCase struct {
+		suffix string
+		valid  bool
+	}
+
+	testCases := map[string]testCase{
+		"empty":                       {"", true},
+		"letters, digits and hyphens": {"pre-upgrade-2", true},
+		"surrounding hyphens":         {"-pre-upgrade-", true},
+		"underscore":                  {"pre_upgrade", false},
+		"double hyphen":               {"pre--upgrade", false},
+		"space":                       {"pre upgrade", false},
+		"32 characters":               {strings.Repeat("a", 32), true},
+		"too long to leave any room":  {strings.Repeat("a", 33), false},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := validateSuffix(tc.suffix)
+			if tc.valid {
+				assert.Nil(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrInvalidSuffix)
+			}
+		})
+	}
+}
+
+func TestValidateSeparator(t *testing.T) {
+	type testCase struct {
+		sep   string
+		valid bool
+	}
+
+	testCases := map[string]testCase{
+		"empty":                  {"", true},
+		"hyphen":                 {"-", true},
+		"letters":                {"x", true},
+		"hyphen inside":          {"x-y", true},
+		"double hyphen":          {"--", false},
+		"leading hyphen":         {"-x", false},
+		"trailing hyphen":        {"x-", false},
+		"underscore":             {"_", false},
+		"32 characters":          {strings.Repeat("x", 32), true},
+		"too long to leave room": {strings.Repeat("x", 33), false},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := validateSeparator(tc.sep)
+			if tc.valid {
+				assert.Nil(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrInvalidSeparator)
+			}
+		})
+	}
+}
+
+func TestValidatePrefix(t *testing.T) {
+	type testCase struct {
+		prefix string
+		valid  bool
+	}
+
+	testCases := map[string]testCase{
+		"environment name":    {"prod", true},
+		"with a digit":        {"staging-2", true},
+		"empty":               {"", false},
+		"starts with a digit": {"2-staging", false},
+		"leading hyphen":      {"-prod", false},
+		"trailing hyphen":     {"prod-", false},
+		"space":               {"prod env", false},
+		"32 characters":       {strings.Repeat("a", 32), true},
+		"too long":            {strings.Repeat("a", 33), false},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := validatePrefix(tc.prefix)
+			if tc.valid {
+				assert.Nil(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrInvalidPrefix)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const ErrInvalidSchedule BackupManagerError = "schedule should be a cron expression of five fields: minute, hour, day of month, month and day of week"
+
+// cronSchedule is a parsed cron expression. Each field is the set of values
+// it allows.
+type cronSchedule struct {
+	minutes, hours, days, months, weekdays []bool
+
+	// with both restricted, a day matches if either the day of the month or
+	// the day of the week does, as cron has it
+	anyDay, anyWeekday bool
+}
+
+// cronField is the range one field of a cron expression can cover.
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	// 7 is Sunday too
+	{"day of week", 0, 7},
+}
+
+// parseCron parses a standard five-field cron expression, like
+// "30 2 * * 1-5". Each field is *, a number, a range like 1-5, or a list of
+// those separated by commas, and any but a number can be stepped, like */15
+// or 0-12/2. Names of months and days aren't understood.
+func parseCron(expr string) (*cronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("'%s': %w", expr, ErrInvalidSchedule)
+	}
+
+	sets := make([][]bool, len(fields))
+	for i, field := range fields {
+		set, err := parseCronField(field, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("'%s': %s: %v: %w", expr, cronFields[i].name, err, ErrInvalidSchedule)
+		}
+		sets[i] = set
+	}
+	// Sunday's 0 either way
+	sets[4][0] = sets[4][0] || sets[4][7]
+
+	return &cronSchedule{
+		minutes:    sets[0],
+		hours:      sets[1],
+		days:       sets[2],
+		months:     sets[3],
+		weekdays:   sets[4],
+		anyDay:     fields[2] == "*",
+		anyWeekday: fields[4] == "*",
+	}, nil
+}
+
+func parseCronField(field string, f cronField) ([]bool, error) {
+	set := make([]bool, f.max+1)
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("'%s' isn't a step", part[i+1:])
+			}
+			step = n
+			part = part[:i]
+		}
+
+		lo, hi := f.min, f.max
+		switch i := strings.Index(part, "-"); {
+		case part == "*":
+		case i >= 0:
+			var err error
+			if lo, err = cronValue(part[:i], f); err != nil {
+				return nil, err
+			}
+			if hi, err = cronValue(part[i+1:], f); err != nil {
+				return nil, err
+			}
+			if lo > hi {
+				return nil, fmt.Errorf("'%s' runs backwards", part)
+			}
+		default:
+			n, err := cronValue(part, f)
+			if err != nil {
+				return nil, err
+			}
+			lo, hi = n, n
+			if step > 1 {
+				hi = f.max
+			}
+		}
+		for n := lo; n <= hi; n += step {
+			set[n] = true
+		}
+	}
+	return set, nil
+}
+
+func cronValue(s string, f cronField) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < f.min || n > f.max {
+		return 0, fmt.Errorf("'%s' isn't from %d to %d", s, f.min, f.max)
+	}
+	return n, nil
+}
+
+func (s *cronSchedule) matchesDay(t time.Time) bool {
+	day, weekday := s.days[t.Day()], s.weekdays[int(t.Weekday())]
+	switch {
+	case s.anyDay && s.anyWeekday:
+		return true
+	case s.anyDay:
+		return weekday
+	case s.anyWeekday:
+		return day
+	}
+	return day || weekday
+}
+
+// next is the first minute the schedule allows after t, in t's location, or
+// the zero time if there isn't one within five years, as with February 30th.
+func (s *cronSchedule) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case !s.months[int(t.Month())]:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !s.hours[t.Hour()]:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !s.minutes[t.Minute()]:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// runSchedule calls run at each of the schedule's ticks until ctx is done,
+// which is a clean shutdown rather than an error, and logs each run's
+// summary. Runs never overlap: ticks that pass while one's going are
+// skipped, and the next run waits for the next tick after it finishes.
+func runSchedule(ctx context.Context, schedule *cronSchedule, now func() time.Time, wait func(context.Context, time.Duration) error, run func(context.Context) ([]SnapshotResult, error)) error {
+	for {
+		tick := schedule.next(now())
+		if tick.IsZero() {
+			return fmt.Errorf("the schedule never comes round: %w", ErrInvalidSchedule)
+		}
+		log.Printf("Next run at %s.", tick.Format(time.RFC3339))
+		if err := wait(ctx, tick.Sub(now())); err != nil {
+			log.Printf("Stopping the schedule: %v", err)
+			return nil
+		}
+
+		started := now()
+		results, err := run(ctx)
+		finished := now()
+		log.Printf("Run at %s: %s", tick.Format(time.RFC3339), summaryLine(resultStats(results), finished.Sub(started)))
+		if err != nil {
+			log.Printf("Run at %s failed: %v", tick.Format(time.RFC3339), err)
+		}
+		if ctx.Err() != nil {
+			log.Printf("Stopping the schedule: %v", ctx.Err())
+			return nil
+		}
+		if skipped := schedule.missed(tick, finished); skipped > 0 {
+			log.Printf("Skipped %d tick(s) while the run at %s was going.", skipped, tick.Format(time.RFC3339))
+		}
+	}
+}
+
+// missed counts the schedule's ticks after from, up to and including until.
+func (s *cronSchedule) missed(from, until time.Time) int {
+	n := 0
+	for t := s.next(from); !t.IsZero() && !t.After(until); t = s.next(t) {
+		n++
+	}
+	return n
+}
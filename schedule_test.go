@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCron(t *testing.T) {
+	type testCase struct {
+		expr        string
+		expectedErr error
+	}
+
+	testCases := map[string]testCase{
+		"every minute":       {expr: "* * * * *"},
+		"lists and ranges":   {expr: "0,30 9-17 * * 1-5"},
+		"steps":              {expr: "*/15 0-12/2 1/10 * *"},
+		"sunday as 7":        {expr: "0 0 * * 7"},
+		"too few fields":     {expr: "0 0 * *", expectedErr: ErrInvalidSchedule},
+		"too many fields":    {expr: "0 0 * * * 2024", expectedErr: ErrInvalidSchedule},
+		"out of range":       {expr: "60 * * * *", expectedErr: ErrInvalidSchedule},
+		"day zero":           {expr: "0 0 0 * *", expectedErr: ErrInvalidSchedule},
+		"backwards range":    {expr: "0 17-9 * * *", expectedErr: ErrInvalidSchedule},
+		"zero step":          {expr: "*/0 * * * *", expectedErr: ErrInvalidSchedule},
+		"names":              {expr: "0 0 * jan mon", expectedErr: ErrInvalidSchedule},
+		"nothing at all":     {expr: "", expectedErr: ErrInvalidSchedule},
+		"an empty list item": {expr: "0, * * * *", expectedErr: ErrInvalidSchedule},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseCron(tc.expr)
+			assert.ErrorIs(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	type testCase struct {
+		expr     string
+		after    time.Time
+		expected time.Time
+	}
+
+	// testNow is Tuesday the 15th of March 2022, at noon
+	testCases := map[string]testCase{
+		"every minute": {
+			expr:     "* * * * *",
+			after:    testNow.Add(30 * time.Second),
+			expected: testNow.Add(time.Minute),
+		},
+		"never the same minute": {
+			expr:     "0 12 * * *",
+			after:    testNow,
+			expected: testNow.AddDate(0, 0, 1),
+		},
+		"every six hours": {
+			expr:     "0 */6 * * *",
+			after:    testNow,
+			expected: testNow.Add(6 * time.Hour),
+		},
+		"weekdays only": {
+			expr:     "30 2 * * 1-5",
+			after:    time.Date(2022, time.March, 18, 3, 0, 0, 0, time.UTC),
+			expected: time.Date(2022, time.March, 21, 2, 30, 0, 0, time.UTC),
+		},
+		"sunday as 7": {
+			expr:     "0 0 * * 7",
+			after:    testNow,
+			expected: time.Date(2022, time.March, 20, 0, 0, 0, 0, time.UTC),
+		},
+		"the first of the month": {
+			expr:     "0 1 1 * *",
+			after:    testNow,
+			expected: time.Date(2022, time.April, 1, 1, 0, 0, 0, time.UTC),
+		},
+		"across the year": {
+			expr:     "0 0 1 1 *",
+			after:    testNow,
+			expected: time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC),
+		},
+		"day of month or day of week": {
+			expr:     "0 0 1 * 5",
+			after:    testNow,
+			expected: time.Date(2022, time.March, 18, 0, 0, 0, 0, time.UTC),
+		},
+		"leap day": {
+			expr:     "0 0 29 2 *",
+			after:    testNow,
+			expected: time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC),
+		},
+		"never": {
+			expr:  "0 0 30 2 *",
+			after: testNow,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			schedule, err := parseCron(tc.expr)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, schedule.next(tc.after))
+		})
+	}
+}
+
+func TestRunSchedule(t *testing.T) {
+	schedule, err := parseCron("0 * * * *")
+	assert.Nil(t, err)
+
+	clock := testNow.Add(10 * time.Minute)
+	now := func() time.Time { return clock }
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wait := func(ctx context.Context, d time.Duration) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		clock = clock.Add(d)
+		return nil
+	}
+
+	// the second run takes 150 minutes, so the ticks at 15:00 and 16:00 are
+	// skipped rather than piling up behind it
+	durations := []time.Duration{time.Minute, 150 * time.Minute, time.Minute, time.Minute}
+	var ticks []time.Time
+	run := func(ctx context.Context) ([]SnapshotResult, error) {
+		ticks = append(ticks, clock)
+		clock = clock.Add(durations[len(ticks)-1])
+		if len(ticks) == len(durations) {
+			cancel()
+		}
+		if len(ticks) == 3 {
+			// a failed run doesn't stop the schedule
+			return []SnapshotResult{{Status: StatusFailed}}, errors.New("something went wrong")
+		}
+		return []SnapshotResult{{Status: StatusCreated}}, nil
+	}
+
+	assert.Nil(t, runSchedule(ctx, schedule, now, wait, run))
+	assert.Equal(t, []time.Time{
+		testNow.Add(time.Hour),
+		testNow.Add(2 * time.Hour),
+		testNow.Add(5 * time.Hour),
+		testNow.Add(6 * time.Hour),
+	}, ticks)
+}
+
+func TestRunScheduleStopsWhileWaiting(t *testing.T) {
+	schedule, err := parseCron("0 0 * * *")
+	assert.Nil(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	ran := false
+	err = runSchedule(ctx, schedule, func() time.Time { return testNow }, sleepContext, func(ctx context.Context) ([]SnapshotResult, error) {
+		ran = true
+		return nil, nil
+	})
+	assert.Nil(t, err)
+	assert.False(t, ran)
+}
+
+func TestCronMissed(t *testing.T) {
+	schedule, err := parseCron("0 * * * *")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, schedule.missed(testNow, testNow.Add(59*time.Minute)))
+	assert.Equal(t, 2, schedule.missed(testNow, testNow.Add(2*time.Hour)))
+}
+
+func TestScheduledBackupEachTickIsItsOwnRun(t *testing.T) {
+	st := &uniqueSnapshotTaker{NewFakeSnapshotTaker()}
+	now := testNow
+	// like main, each manager's prefix comes from when it was made, and
+	// its budget only has room for the one snapshot
+	tick := scheduledBackup([]string{"my-cluster-1"}, false, func() *BackupManager {
+		return NewBackupManager(st, WithPrefix(fmt.Sprintf("run-%d", now.Unix())), WithAPICallBudget(1))
+	})
+
+	first, err := tick(context.TODO())
+	assert.Nil(t, err)
+	now = now.Add(time.Hour)
+	second, err := tick(context.TODO())
+	assert.Nil(t, err)
+
+	assert.Equal(t, StatusCreated, first[0].Status)
+	assert.Equal(t, StatusCreated, second[0].Status)
+	assert.NotEqual(t, first[0].SnapshotIdentifier, second[0].SnapshotIdentifier)
+	assert.Len(t, st.GetJournal(), 2)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+)
+
+const ErrSelfTestFailed BackupManagerError = "self-test failed"
+
+// selfTestSnapshotTaker is an in-process RDSAPI for -self-test. It records
+// the snapshots it's asked for, and pretends missingClusterID doesn't exist.
+// The self-test only takes snapshots, so the rest of RDSAPI is left nil.
+type selfTestSnapshotTaker struct {
+	RDSAPI
+	mu               sync.Mutex
+	missingClusterID string
+	journal          []string
+}
+
+func (s *selfTestSnapshotTaker) CreateDBClusterSnapshot(ctx context.Context, in *rds.CreateDBClusterSnapshotInput, optFns ...func(*rds.Options)) (*rds.CreateDBClusterSnapshotOutput, error) {
+	if *in.DBClusterIdentifier == s.missingClusterID {
+		return nil, &types.DBClusterNotFoundFault{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.journal = append(s.journal, *in.DBClusterIdentifier+" -> "+*in.DBClusterSnapshotIdentifier)
+	return &rds.CreateDBClusterSnapshotOutput{
+		DBClusterSnapshot: &types.DBClusterSnapshot{
+			DBClusterIdentifier:         in.DBClusterIdentifier,
+			DBClusterSnapshotIdentifier: in.DBClusterSnapshotIdentifier,
+		},
+	}, nil
+}
+
+// runSelfTest runs a batch against a fake SnapshotTaker and checks that the
+// expected snapshots were requested, without talking to AWS. It's meant for
+// smoke-testing a freshly built binary.
+func runSelfTest(out io.Writer) error {
+	st := &selfTestSnapshotTaker{missingClusterID: "self-test-missing"}
+	bm := NewBackupManager(st, WithPrefix("self-test"))
+
+	_, err := bm.TriggerSnapshots(context.Background(),
+		"self-test-cluster-1",
+		"self-test-missing",
+		"arn:aws:rds:us-east-1:123456789012:cluster:self-test-cluster-2",
+		"self-test-cluster-with-a-very-long-name-that-needs-truncating-0123456789",
+	)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSelfTestFailed, err)
+	}
+
+	expected := []string{
+		"self-test-cluster-1 -> self-test-self-test-cluster-1",
+		"self-test-cluster-2 -> self-test-self-test-cluster-2",
+		"self-test-cluster-with-a-very-long-name-that-needs-truncating-0123456789 -> self-test-self-test-cluster-with-a-very-long-name-that-needs-tr",
+	}
+	if err := verifyJournal(st.journal, expected); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "self-test passed: %d snapshots requested as expected\n", len(expected))
+	return nil
+}
+
+func verifyJournal(got, expected []string) error {
+	if len(got) != len(expected) {
+		return fmt.Errorf("%w: expected %d snapshots, got %d: %q", ErrSelfTestFailed, len(expected), len(got), got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			return fmt.Errorf("%w: snapshot %d: expected %q, got %q", ErrSelfTestFailed, i, expected[i], got[i])
+		}
+	}
+	return nil
+}
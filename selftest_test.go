@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunSelfTest(t *testing.T) {
+	out := &bytes.Buffer{}
+	assert.Nil(t, runSelfTest(out))
+	assert.Contains(t, out.String(), "self-test passed")
+}
+
+func TestVerifyJournal(t *testing.T) {
+	type testCase struct {
+		got      []string
+		expected []string
+		pass     bool
+	}
+
+	testCases := map[string]testCase{
+		"matches": {
+			got:      []string{"a -> p-a", "b -> p-b"},
+			expected: []string{"a -> p-a", "b -> p-b"},
+			pass:     true,
+		},
+		"missing snapshot": {
+			got:      []string{"a -> p-a"},
+			expected: []string{"a -> p-a", "b -> p-b"},
+		},
+		"wrong name": {
+			got:      []string{"a -> p-a", "b -> q-b"},
+			expected: []string{"a -> p-a", "b -> p-b"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := verifyJournal(tc.got, tc.expected)
+			if tc.pass {
+				assert.Nil(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, ErrSelfTestFailed)
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+)
+
+// sequenceTimeFormat stands in for a sequence number when the cluster's
+// snapshots can't be counted.
+const sequenceTimeFormat = "20060102150405"
+
+// sequencedIdentifier names a cluster's next snapshot with a sequence number,
+// zero-padded to three digits, after tag if there is one. The number is one
+// more than the cluster has snapshots from this tool, or than the highest
+// number already used, whichever is bigger, so a pruned snapshot's number
+// isn't handed out again.
+func (b *BackupManager) sequencedIdentifier(ctx context.Context, prefix, clusterIdentifier, tag string) string {
+	snapshots, err := b.describeOwnSnapshots(ctx, clusterIdentifier)
+	if err != nil {
+		stamp := b.clock().UTC().Format(sequenceTimeFormat)
+		b.logf("Couldn't count the snapshots of '%s', naming its new one by time instead: %v", clusterIdentifier, err)
+		return b.snapshotIdentifierWith(prefix, clusterIdentifier, joinNameTags(b.separator(), tag, stamp))
+	}
+
+	next := len(snapshots) + 1
+	if highest := b.highestSequence(snapshots, prefix, clusterIdentifier, tag); highest >= next {
+		next = highest + 1
+	}
+	return b.snapshotIdentifierWith(prefix, clusterIdentifier, joinNameTags(b.separator(), tag, formatSequence(next)))
+}
+
+// highestSequence is the highest sequence number in the snapshots' names, or
+// 0 if none of them have one. A number only counts if naming the cluster's
+// snapshot with it gives back the same name, so a cluster whose identifier
+// ends in digits isn't mistaken for a numbered snapshot.
+func (b *BackupManager) highestSequence(snapshots []types.DBClusterSnapshot, prefix, clusterIdentifier, tag string) int {
+	sep := b.separator()
+	if sep == "" {
+		return 0
+	}
+
+	highest := 0
+	for _, snapshot := range snapshots {
+		name := aws.ToString(snapshot.DBClusterSnapshotIdentifier)
+		for _, part := range strings.Split(name, sep) {
+			n, err := strconv.Atoi(part)
+			if err != nil || n <= highest {
+				continue
+			}
+			if b.snapshotIdentifierWith(prefix, clusterIdentifier, joinNameTags(sep, tag, formatSequence(n))) == name {
+				highest = n
+			}
+		}
+	}
+	return highest
+}
+
+func formatSequence(n int) string {
+	return fmt.Sprintf("%03d", n)
+}
+
+// joinNameTags joins the parts of a name's tag that are set.
+func joinNameTags(sep string, tags ...string) string {
+	set := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if tag != "" {
+			set = append(set, tag)
+		}
+	}
+	return strings.Join(set, sep)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSequenceNames(t *testing.T) {
+	type testCase struct {
+		cluster   string
+		suffix    string
+		snapshots []types.DBClusterSnapshot
+		expected  string
+	}
+
+	testCases := map[string]testCase{
+		"first snapshot": {
+			cluster:  "my-cluster-1",
+			expected: "testing-my-cluster-1-001",
+		},
+		"after the last": {
+			cluster: "my-cluster-1",
+			snapshots: []types.DBClusterSnapshot{
+				existingSnapshot("my-cluster-1", "testing-my-cluster-1-001", testNow.Add(-2*time.Hour)),
+				existingSnapshot("my-cluster-1", "testing-my-cluster-1-002", testNow.Add(-time.Hour)),
+			},
+			expected: "testing-my-cluster-1-003",
+		},
+		"a pruned number isn't reused": {
+			cluster: "my-cluster-1",
+			snapshots: []types.DBClusterSnapshot{
+				existingSnapshot("my-cluster-1", "testing-my-cluster-1-007", testNow.Add(-time.Hour)),
+			},
+			expected: "testing-my-cluster-1-008",
+		},
+		"counts snapshots from before numbering": {
+			cluster: "my-cluster-1",
+			snapshots: []types.DBClusterSnapshot{
+				existingSnapshot("my-cluster-1", "testing-my-cluster-1", testNow.Add(-2*time.Hour)),
+				existingSnapshot("my-cluster-1", "testing-my-cluster-1-old", testNow.Add(-time.Hour)),
+			},
+			expected: "testing-my-cluster-1-003",
+		},
+		"digits in the cluster aren't a number": {
+			cluster: "db-2024",
+			snapshots: []types.DBClusterSnapshot{
+				existingSnapshot("db-2024", "testing-db-2024", testNow.Add(-time.Hour)),
+			},
+			expected: "testing-db-2024-002",
+		},
+		"past 999": {
+			cluster: "my-cluster-1",
+			snapshots: []types.DBClusterSnapshot{
+				existingSnapshot("my-cluster-1", "testing-my-cluster-1-999", testNow.Add(-time.Hour)),
+			},
+			expected: "testing-my-cluster-1-1000",
+		},
+		"before the suffix": {
+			cluster: "my-cluster-1",
+			suffix:  "pre-upgrade",
+			snapshots: []types.DBClusterSnapshot{
+				existingSnapshot("my-cluster-1", "testing-my-cluster-1-001-pre-upgrade", testNow.Add(-time.Hour)),
+			},
+			expected: "testing-my-cluster-1-002-pre-upgrade",
+		},
+		"kept within the limit": {
+			cluster: "a-cluster-with-a-very-long-identifier-that-runs-past-the-limit",
+			snapshots: []types.DBClusterSnapshot{
+				existingSnapshot("a-cluster-with-a-very-long-identifier-that-runs-past-the-limit",
+					"testing-a-cluster-with-a-very-long-identifier-that-runs-pas-001", testNow.Add(-time.Hour)),
+			},
+			expected: "testing-a-cluster-with-a-very-long-identifier-that-runs-pas-002",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			st := NewFakeSnapshotTakerWithSnapshots(tc.snapshots...)
+			bm := NewBackupManager(st, WithPrefix("testing"), WithSuffix(tc.suffix), WithSequenceNames(true))
+			bm.now = func() time.Time { return testNow }
+
+			results, err := bm.TriggerSnapshots(context.TODO(), tc.cluster)
+			assert.Nil(t, err)
+			assert.Equal(t, []snapshotCreationRecord{{tc.cluster, tc.expected}}, st.GetJournal())
+			assert.Equal(t, tc.expected, results[0].SnapshotIdentifier)
+			assert.LessOrEqual(t, len(results[0].SnapshotIdentifier), maxSnapshotIdentifierLen)
+		})
+	}
+}
+
+type brokenSnapshotDescriber struct{}
+
+func (brokenSnapshotDescriber) DescribeDBClusterSnapshots(ctx context.Context, in *rds.DescribeDBClusterSnapshotsInput, optFns ...func(*rds.Options)) (*rds.DescribeDBClusterSnapshotsOutput, error) {
+	return nil, errors.New("describe is down")
+}
+
+func TestSequenceNamesFallBackToTime(t *testing.T) {
+	st := NewFakeSnapshotTaker()
+	bm := NewBackupManager(st, WithPrefix("testing"), WithSequenceNames(true))
+	bm.now = func() time.Time { return testNow }
+	bm.sd = brokenSnapshotDescriber{}
+
+	_, err := bm.TriggerSnapshots(context.TODO(), "my-cluster-1")
+	assert.Nil(t, err)
+	assert.Equal(t, []snapshotCreationRecord{
+		{"my-cluster-1", "testing-my-cluster-1-" + testNow.UTC().Format(sequenceTimeFormat)},
+	}, st.GetJournal())
+}
+
+func TestSequenceNamesNeedsDescriber(t *testing.T) {
+	bm := NewBackupManager(NewFakeSnapshotTaker(), WithSequenceNames(true))
+	bm.sd = nil
+	_, err := bm.TriggerSnapshots(context.TODO(), "my-cluster-1")
+	assert.ErrorIs(t, err, ErrNoSnapshotDescriber)
+}
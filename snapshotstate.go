@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+)
+
+const ErrDeletesFailed BackupManagerError = "failed to delete some snapshots"
+
+// SnapshotStateError is returned when RDS won't delete or copy a snapshot
+// because of the state it's in, which almost always means it's still being
+// created, and WaitForStable is off or the snapshot can't be waited for.
+type SnapshotStateError struct {
+	SnapshotIdentifier string
+	Operation          string
+	Err                error
+}
+
+func (e *SnapshotStateError) Error() string {
+	return fmt.Sprintf("can't %s snapshot '%s' in its current state, most likely because it's still being created; wait for it to become available and try again: %v",
+		e.Operation, e.SnapshotIdentifier, e.Err)
+}
+
+func (e *SnapshotStateError) Unwrap() error {
+	return e.Err
+}
+
+// whenStable runs op against a snapshot and, if RDS turns it down for the
+// snapshot's state, either waits for the snapshot to become available and
+// runs op once more, with WaitForStable and a describer that can see it, or
+// returns a SnapshotStateError.
+func (b *BackupManager) whenStable(ctx context.Context, snapshotID, operation string, canWait bool, op func() error) error {
+	err := op()
+	var stateErr *types.InvalidDBClusterSnapshotStateFault
+	if !errors.As(err, &stateErr) {
+		return err
+	}
+	if !b.WaitForStable || !canWait || b.sd == nil {
+		return &SnapshotStateError{SnapshotIdentifier: snapshotID, Operation: operation, Err: err}
+	}
+
+	b.logf("Snapshot '%s' isn't ready to %s, waiting for it to become available.", snapshotID, operation)
+	if err := b.WaitForSnapshots(ctx, snapshotID); err != nil {
+		return fmt.Errorf("waiting to %s snapshot '%s': %w", operation, snapshotID, err)
+	}
+	return op()
+}
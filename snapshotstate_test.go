@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// creatingSnapshotTaker won't delete or copy snapshots that are still being
+// created, the way RDS won't. Each describe brings them a step closer to
+// being available.
+type creatingSnapshotTaker struct {
+	*fakeSnapshotTaker
+	describesLeft int
+}
+
+func (c *creatingSnapshotTaker) creating(snapshotID string) bool {
+	for _, snapshot := range c.snapshots {
+		if aws.ToString(snapshot.DBClusterSnapshotIdentifier) == snapshotID {
+			return aws.ToString(snapshot.Status) == "creating"
+		}
+	}
+	return false
+}
+
+func (c *creatingSnapshotTaker) DescribeDBClusterSnapshots(ctx context.Context, in *rds.DescribeDBClusterSnapshotsInput, optFns ...func(*rds.Options)) (*rds.DescribeDBClusterSnapshotsOutput, error) {
+	if c.describesLeft--; c.describesLeft <= 0 {
+		for i := range c.snapshots {
+			c.snapshots[i].Status = aws.String("available")
+		}
+	}
+	return c.fakeSnapshotTaker.DescribeDBClusterSnapshots(ctx, in, optFns...)
+}
+
+func (c *creatingSnapshotTaker) DeleteDBClusterSnapshot(ctx context.Context, in *rds.DeleteDBClusterSnapshotInput, optFns ...func(*rds.Options)) (*rds.DeleteDBClusterSnapshotOutput, error) {
+	if c.creating(*in.DBClusterSnapshotIdentifier) {
+		return nil, &types.InvalidDBClusterSnapshotStateFault{Message: aws.String("Cannot delete the snapshot because it is not currently in the available state.")}
+	}
+	return c.fakeSnapshotTaker.DeleteDBClusterSnapshot(ctx, in, optFns...)
+}
+
+func (c *creatingSnapshotTaker) CopyDBClusterSnapshot(ctx context.Context, in *rds.CopyDBClusterSnapshotInput, optFns ...func(*rds.Options)) (*rds.CopyDBClusterSnapshotOutput, error) {
+	if c.creating(*in.SourceDBClusterSnapshotIdentifier) {
+		return nil, &types.InvalidDBClusterSnapshotStateFault{Message: aws.String("The source snapshot is not in the available state.")}
+	}
+	return c.fakeSnapshotTaker.CopyDBClusterSnapshot(ctx, in, optFns...)
+}
+
+func creating(snapshot types.DBClusterSnapshot) types.DBClusterSnapshot {
+	snapshot.Status = aws.String("creating")
+	return snapshot
+}
+
+func newCreatingSnapshotTaker() *creatingSnapshotTaker {
+	return &creatingSnapshotTaker{
+		fakeSnapshotTaker: NewFakeSnapshotTakerWithSnapshots(
+			existingSnapshot("my-cluster-1", "testing-my-cluster-1", testNow),
+			creating(existingSnapshot("my-cluster-2", "testing-my-cluster-2", testNow)),
+			existingSnapshot("my-cluster-3", "testing-my-cluster-3", testNow),
+		),
+		describesLeft: 3,
+	}
+}
+
+func TestDeleteSnapshotsStillCreating(t *testing.T) {
+	type testCase struct {
+		waitForStable   bool
+		continueOnError bool
+		expectedDeleted []string
+		expectedError   error
+	}
+
+	testCases := map[string]testCase{
+		"stops at the snapshot": {
+			expectedDeleted: []string{"testing-my-cluster-1"},
+		},
+		"fails just the snapshot with continue on error": {
+			continueOnError: true,
+			expectedDeleted: []string{"testing-my-cluster-1", "testing-my-cluster-3"},
+			expectedError:   ErrDeletesFailed,
+		},
+		"waits for the snapshot": {
+			waitForStable:   true,
+			expectedDeleted: []string{"testing-my-cluster-1", "testing-my-cluster-2", "testing-my-cluster-3"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			st := newCreatingSnapshotTaker()
+			bm := NewBackupManager(st, WithWaitForStable(tc.waitForStable), WithContinueOnError(tc.continueOnError))
+			bm.sleep = noSleep
+
+			_, err := bm.DeleteSnapshots(context.TODO(), append([]types.DBClusterSnapshot(nil), st.snapshots...)...)
+			assert.Equal(t, tc.expectedDeleted, st.deleted)
+			switch {
+			case tc.waitForStable:
+				assert.Nil(t, err)
+			case tc.expectedError != nil:
+				assert.ErrorIs(t, err, tc.expectedError)
+			default:
+				var stateErr *SnapshotStateError
+				if assert.ErrorAs(t, err, &stateErr) {
+					assert.Equal(t, "testing-my-cluster-2", stateErr.SnapshotIdentifier)
+					assert.Contains(t, stateErr.Error(), "wait for it")
+				}
+			}
+		})
+	}
+}
+
+func TestCopySnapshotsStillCreating(t *testing.T) {
+	st := newCreatingSnapshotTaker()
+	bm := NewBackupManager(st, WithContinueOnError(true))
+	bm.sleep = noSleep
+
+	results, err := bm.CopySnapshots(context.TODO(), "", "testing-my-cluster-1", "testing-my-cluster-2")
+	assert.ErrorIs(t, err, ErrCopiesFailed)
+	var stateErr *SnapshotStateError
+	if assert.ErrorAs(t, results[1].Err, &stateErr) {
+		assert.Equal(t, "copy", stateErr.Operation)
+	}
+
+	st = newCreatingSnapshotTaker()
+	bm = NewBackupManager(st, WithWaitForStable(true))
+	bm.sleep = noSleep
+	results, err = bm.CopySnapshots(context.TODO(), "", "testing-my-cluster-2")
+	assert.Nil(t, err)
+	assert.Equal(t, "testing-my-cluster-2-copy", results[0].TargetSnapshotIdentifier)
+	assert.Len(t, st.copies, 1)
+}
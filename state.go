@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StateStore remembers which clusters have already been snapshotted, so a
+// run that's restarted after a crash can pick up where it left off.
+type StateStore interface {
+	// Done returns the clusters that have already been snapshotted.
+	Done() (map[string]bool, error)
+	// MarkDone records that a cluster has been snapshotted.
+	MarkDone(clusterIdentifier string) error
+}
+
+// LastRunStore remembers when the last complete run started, for
+// SinceLastRun. A store that has no time yet returns false.
+type LastRunStore interface {
+	LastRun() (time.Time, bool, error)
+	// RecordLastRun records a complete run that started at t. The run
+	// finished, so the clusters marked done along the way are forgotten.
+	RecordLastRun(t time.Time) error
+}
+
+// DoneClearer forgets the clusters marked done once a run has finished
+// without failures, so the next run snapshots them all again.
+type DoneClearer interface {
+	ClearDone() error
+}
+
+const ErrNoLastRunStore BackupManagerError = "snapshotting only clusters modified since the last run requires a State that records it"
+
+// lastRunLinePrefix starts the line a FileStateStore records the last run
+// on. A cluster identifier can't have a space in it, so it can't be
+// mistaken for one.
+const lastRunLinePrefix = "last-run "
+
+// RegionStateStore hands out a view of its state for one region, so managers
+// for different regions can share it, at once, without their clusters
+// getting mixed up.
+type RegionStateStore interface {
+	ForRegion(region string) StateStore
+}
+
+// FileStateStore keeps state in a local file, one cluster identifier per
+// line, plus a last-run line with a timestamp once a run has finished. A file
+// that doesn't exist yet just means nothing is done. The views ForRegion
+// hands out keep their lines in the same file, each starting with the region
+// and a space.
+type FileStateStore struct {
+	path   string
+	region string
+	// mu is shared with the views of other regions, as they write the same
+	// file
+	mu *sync.Mutex
+}
+
+func NewFileStateStore(path string) *FileStateStore {
+	return &FileStateStore{path: path, mu: &sync.Mutex{}}
+}
+
+// ForRegion returns a view of the file that only sees region's lines.
+func (s *FileStateStore) ForRegion(region string) StateStore {
+	return &FileStateStore{path: s.path, region: region, mu: s.mu}
+}
+
+// ownLine returns what's left of a line of the file once its region is taken
+// off, if it's one of this store's. A region's lines are otherwise just like
+// the lines of a file without regions.
+func (s *FileStateStore) ownLine(line string) (string, bool) {
+	if s.region == "" {
+		return line, strings.HasPrefix(line, lastRunLinePrefix) || !strings.Contains(line, " ")
+	}
+	rest := strings.TrimPrefix(line, s.region+" ")
+	return rest, rest != line
+}
+
+// ownLines returns the store's lines in the file, without their region, and
+// every other line as it is.
+func (s *FileStateStore) ownLines() (own, others []string, err error) {
+	f, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// a crash mid-write can leave a partial line, which won't match a
+		// real cluster and so is harmless
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if rest, ok := s.ownLine(line); ok {
+			own = append(own, rest)
+		} else {
+			others = append(others, line)
+		}
+	}
+	return own, others, scanner.Err()
+}
+
+func (s *FileStateStore) Done() (map[string]bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	own, _, err := s.ownLines()
+	if err != nil {
+		return nil, err
+	}
+	done := make(map[string]bool)
+	for _, line := range own {
+		if !strings.HasPrefix(line, lastRunLinePrefix) {
+			done[line] = true
+		}
+	}
+	return done, nil
+}
+
+func (s *FileStateStore) MarkDone(clusterIdentifier string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(f, s.line(clusterIdentifier)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// line puts the store's region, if it has one, in front of a line.
+func (s *FileStateStore) line(line string) string {
+	if s.region == "" {
+		return line
+	}
+	return s.region + " " + line
+}
+
+func (s *FileStateStore) LastRun() (time.Time, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	own, _, err := s.ownLines()
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	var lastRun time.Time
+	found := false
+	for _, line := range own {
+		if !strings.HasPrefix(line, lastRunLinePrefix) {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, strings.TrimPrefix(line, lastRunLinePrefix))
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("bad last-run line %q: %w", line, err)
+		}
+		lastRun, found = t, true
+	}
+	return lastRun, found, nil
+}
+
+// RecordLastRun replaces the store's lines with just the last-run line.
+func (s *FileStateStore) RecordLastRun(t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, others, err := s.ownLines()
+	if err != nil {
+		return err
+	}
+	return s.rewrite(append(others, s.line(lastRunLinePrefix+t.UTC().Format(time.RFC3339))))
+}
+
+// ClearDone replaces the store's lines with just its last-run line, if it
+// has one.
+func (s *FileStateStore) ClearDone() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	own, others, err := s.ownLines()
+	if err != nil {
+		return err
+	}
+	if own == nil && others == nil {
+		return nil
+	}
+	for _, line := range own {
+		if strings.HasPrefix(line, lastRunLinePrefix) {
+			others = append(others, s.line(line))
+		}
+	}
+	return s.rewrite(others)
+}
+
+// rewrite replaces the file with lines, by way of a temporary file so a crash
+// can't leave it half written.
+func (s *FileStateStore) rewrite(lines []string) error {
+	var contents strings.Builder
+	for _, line := range lines {
+		contents.WriteString(line + "\n")
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(contents.String()), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// loadState reads which clusters are already done at the start of a run.
+func (b *BackupManager) loadState() error {
+	b.done = nil
+	if b.State == nil {
+		return nil
+	}
+	done, err := b.State.Done()
+	if err != nil {
+		return fmt.Errorf("reading state: %w", err)
+	}
+	b.done = done
+	return nil
+}
+
+// markDone records a snapshotted cluster in the state. The snapshot exists
+// either way, so a failure only means it might be taken again on a re-run.
+func (b *BackupManager) markDone(clusterIdentifier string) {
+	if b.State == nil {
+		return
+	}
+	if err := b.State.MarkDone(clusterIdentifier); err != nil {
+		b.logf("Snapshotted '%s' but couldn't record it in the state: %v", clusterIdentifier, err)
+	}
+}
+
+// loadLastRun reads when the last complete run started, for SinceLastRun.
+func (b *BackupManager) loadLastRun() (time.Time, bool, error) {
+	store, ok := b.State.(LastRunStore)
+	if !ok {
+		return time.Time{}, false, ErrNoLastRunStore
+	}
+	lastRun, found, err := store.LastRun()
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("reading state: %w", err)
+	}
+	return lastRun, found, nil
+}
+
+// recordLastRun records a run that discovered its clusters and snapshotted
+// every one of them, so the next run with SinceLastRun can start from there.
+// The snapshots exist either way, so a failure is only a warning; the next run
+// just does more than it needed to.
+func (b *BackupManager) recordLastRun() {
+	if !b.SinceLastRun || b.discoveredAt.IsZero() || b.DryRun {
+		return
+	}
+	store, ok := b.State.(LastRunStore)
+	if !ok {
+		return
+	}
+	if err := store.RecordLastRun(b.discoveredAt); err != nil {
+		b.logf("Couldn't record the last run in the state: %v", err)
+	}
+}
+
+// clearDone forgets the clusters marked done once a run has snapshotted all
+// of them, so they aren't skipped as done by every run after it. A failure
+// is only a warning, but the next run will skip what this one did.
+func (b *BackupManager) clearDone() {
+	if b.DryRun {
+		return
+	}
+	store, ok := b.State.(DoneClearer)
+	if !ok {
+		return
+	}
+	if err := store.ClearDone(); err != nil {
+		b.logf("Couldn't clear the clusters done from the state: %v", err)
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeStateStore struct {
+	done    map[string]bool
+	marked  []string
+	loadErr error
+}
+
+func (f *fakeStateStore) Done() (map[string]bool, error) {
+	return f.done, f.loadErr
+}
+
+func (f *fakeStateStore) MarkDone(clusterIdentifier string) error {
+	f.marked = append(f.marked, clusterIdentifier)
+	return nil
+}
+
+func TestFileStateStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state")
+	store := NewFileStateStore(path)
+
+	done, err := store.Done()
+	assert.Nil(t, err)
+	assert.Empty(t, done)
+
+	assert.Nil(t, store.MarkDone("my-cluster-1"))
+	assert.Nil(t, store.MarkDone("my-cluster-2"))
+	contents, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "my-cluster-1\nmy-cluster-2\n", string(contents))
+
+	done, err = NewFileStateStore(path).Done()
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]bool{"my-cluster-1": true, "my-cluster-2": true}, done)
+}
+
+func TestFileStateStoreIgnoresBlankLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state")
+	assert.Nil(t, ioutil.WriteFile(path, []byte("my-cluster-1\n\n  \nmy-cluster-2"), 0644))
+
+	done, err := NewFileStateStore(path).Done()
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]bool{"my-cluster-1": true, "my-cluster-2": true}, done)
+}
+
+func TestTriggerSnapshotsWithState(t *testing.T) {
+	state := &fakeStateStore{done: map[string]bool{"my-cluster-2": true}}
+	st := NewFakeSnapshotTaker()
+	bm := NewBackupManager(st, WithPrefix("testing"))
+	bm.State = state
+
+	results, err := bm.TriggerSnapshots(context.TODO(), "my-cluster-1", "my-cluster-2", "my-cluster-3")
+	assert.Nil(t, err)
+	assert.Equal(t, StatusSkippedDone, results[1].Status)
+	assert.Equal(t, []snapshotCreationRecord{
+		{"my-cluster-1", "testing-my-cluster-1"},
+		{"my-cluster-3", "testing-my-cluster-3"},
+	}, st.GetJournal())
+	assert.ElementsMatch(t, []string{"my-cluster-1", "my-cluster-3"}, state.marked)
+	assert.Equal(t, RunStats{Created: 2, Skipped: 1}, bm.Stats())
+}
+
+func TestTriggerSnapshotsStateLoadError(t *testing.T) {
+	loadErr := errors.New("permission denied")
+	st := NewFakeSnapshotTaker()
+	bm := NewBackupManager(st, WithPrefix("testing"))
+	bm.State = &fakeStateStore{loadErr: loadErr}
+
+	_, err := bm.TriggerSnapshots(context.TODO(), "my-cluster-1")
+	assert.ErrorIs(t, err, loadErr)
+	assert.Empty(t, st.GetJournal())
+}
+
+func TestTriggerSnapshotsResumesFromStateFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state")
+
+	// the first run dies on my-cluster-2...
+	first := NewFlakySnapshotTaker("my-cluster-2", &types.DBClusterSnapshotAlreadyExistsFault{})
+	bm := NewBackupManager(first, WithPrefix("testing"))
+	bm.State = NewFileStateStore(path)
+	_, err := bm.TriggerSnapshots(context.TODO(), "my-cluster-1", "my-cluster-2", "my-cluster-3")
+	assert.NotNil(t, err)
+
+	// ...and the re-run only does what's left
+	second := NewFakeSnapshotTaker()
+	bm = NewBackupManager(second, WithPrefix("testing"))
+	bm.State = NewFileStateStore(path)
+	_, err = bm.TriggerSnapshots(context.TODO(), "my-cluster-1", "my-cluster-2", "my-cluster-3")
+	assert.Nil(t, err)
+	assert.Equal(t, []snapshotCreationRecord{
+		{"my-cluster-2", "testing-my-cluster-2"},
+		{"my-cluster-3", "testing-my-cluster-3"},
+	}, second.GetJournal())
+}
+
+func TestTriggerSnapshotsClearsStateFileWhenComplete(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state")
+	st := NewFakeSnapshotTaker()
+	run := func() {
+		bm := NewBackupManager(st, WithPrefix("testing"))
+		bm.State = NewFileStateStore(path)
+		_, err := bm.TriggerSnapshots(context.TODO(), "my-cluster-1", "my-cluster-2")
+		assert.Nil(t, err)
+	}
+
+	// a run that gets through everything leaves nothing done for the next
+	run()
+	done, err := NewFileStateStore(path).Done()
+	assert.Nil(t, err)
+	assert.Empty(t, done)
+	run()
+	assert.Len(t, st.GetJournal(), 4)
+}
+
+func TestFileStateStoreClearDone(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state")
+	store := NewFileStateStore(path)
+	assert.Nil(t, store.ClearDone())
+
+	assert.Nil(t, store.RecordLastRun(testNow))
+	assert.Nil(t, store.MarkDone("my-cluster-1"))
+	assert.Nil(t, store.ClearDone())
+	contents, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "last-run 2022-03-15T12:00:00Z\n", string(contents))
+}
+
+func TestFileStateStoreForRegion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state")
+	store := NewFileStateStore(path)
+	east, west := store.ForRegion("us-east-1"), store.ForRegion("us-west-2")
+
+	assert.Nil(t, store.MarkDone("my-cluster-1"))
+	assert.Nil(t, east.MarkDone("my-cluster-1"))
+	assert.Nil(t, west.MarkDone("my-cluster-2"))
+	assert.Nil(t, west.(LastRunStore).RecordLastRun(testNow))
+	contents, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "my-cluster-1\nus-east-1 my-cluster-1\nus-west-2 last-run 2022-03-15T12:00:00Z\n", string(contents))
+
+	done, err := east.Done()
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]bool{"my-cluster-1": true}, done)
+	done, err = store.Done()
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]bool{"my-cluster-1": true}, done)
+	_, found, err := store.LastRun()
+	assert.Nil(t, err)
+	assert.False(t, found)
+
+	// clearing one region leaves the others be
+	assert.Nil(t, east.(DoneClearer).ClearDone())
+	contents, err = ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "my-cluster-1\nus-west-2 last-run 2022-03-15T12:00:00Z\n", string(contents))
+}
+
+func TestFileStateStoreLastRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state")
+	store := NewFileStateStore(path)
+
+	_, found, err := store.LastRun()
+	assert.Nil(t, err)
+	assert.False(t, found)
+
+	assert.Nil(t, store.MarkDone("my-cluster-1"))
+	assert.Nil(t, store.RecordLastRun(testNow))
+	contents, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "last-run 2022-03-15T12:00:00Z\n", string(contents))
+
+	// clusters done since then are still skipped if the run's restarted
+	assert.Nil(t, store.MarkDone("my-cluster-2"))
+	done, err := store.Done()
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]bool{"my-cluster-2": true}, done)
+	lastRun, found, err := NewFileStateStore(path).LastRun()
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.True(t, testNow.Equal(lastRun))
+}
+
+func TestSinceLastRun(t *testing.T) {
+	restorable := func(cluster types.DBCluster, at time.Time) types.DBCluster {
+		cluster.LatestRestorableTime = aws.Time(at)
+		return cluster
+	}
+	st := NewFakeSnapshotTaker()
+	st.clusters = []types.DBCluster{
+		restorable(existingCluster("busy"), testNow.Add(-time.Minute)),
+		restorable(existingCluster("quiet"), testNow.Add(-48*time.Hour)),
+		existingCluster("new"),
+	}
+	path := filepath.Join(t.TempDir(), "state")
+	newManager := func(now time.Time) *BackupManager {
+		bm := NewBackupManager(st, WithPrefix("testing"), WithSinceLastRun(true))
+		bm.State = NewFileStateStore(path)
+		bm.now = func() time.Time { return now }
+		return bm
+	}
+	run := func(bm *BackupManager) []string {
+		clusters, err := bm.DiscoverClusters(context.TODO())
+		assert.Nil(t, err)
+		_, err = bm.TriggerSnapshots(context.TODO(), clusterIdentifiers(clusters)...)
+		assert.Nil(t, err)
+		return clusterIdentifiers(clusters)
+	}
+
+	// with no state yet, it's everything, and then there's a last run
+	assert.Equal(t, []string{"busy", "new", "quiet"}, run(newManager(testNow.Add(-24*time.Hour))))
+	lastRun, found, err := NewFileStateStore(path).LastRun()
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.True(t, testNow.Add(-24*time.Hour).Equal(lastRun))
+
+	assert.Equal(t, []string{"busy", "new"}, run(newManager(testNow)))
+
+	// not without somewhere to keep the last run
+	bm := NewBackupManager(st, WithSinceLastRun(true))
+	_, err = bm.DiscoverClusters(context.TODO())
+	assert.ErrorIs(t, err, ErrNoLastRunStore)
+}
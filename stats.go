@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// RunStats counts what happened during the most recent TriggerSnapshots run.
+type RunStats struct {
+	Created int64
+	Skipped int64
+	Failed  int64
+	Retried int64
+}
+
+// runCounters holds the live counts behind RunStats. Workers update them
+// concurrently, so they're only ever touched through sync/atomic.
+type runCounters struct {
+	created int64
+	skipped int64
+	failed  int64
+	retried int64
+
+	// throttled counts snapshot attempts turned down for being made too
+	// often, for AutoConcurrency to back off on
+	throttled int64
+}
+
+func (c *runCounters) reset() {
+	atomic.StoreInt64(&c.created, 0)
+	atomic.StoreInt64(&c.skipped, 0)
+	atomic.StoreInt64(&c.failed, 0)
+	atomic.StoreInt64(&c.retried, 0)
+	atomic.StoreInt64(&c.throttled, 0)
+}
+
+func (c *runCounters) record(status SnapshotStatus) {
+	switch status {
+	case StatusCreated:
+		atomic.AddInt64(&c.created, 1)
+	case StatusSkippedNotFound, StatusSkippedRecent, StatusSkippedDone, StatusSkippedUnchanged, StatusSkippedOptOut, StatusSkippedExisting, StatusSkippedStopped:
+		atomic.AddInt64(&c.skipped, 1)
+	case StatusFailed:
+		atomic.AddInt64(&c.failed, 1)
+	}
+}
+
+// Stats returns the counts from the most recent run. It's safe to call while
+// a run is in progress, though the counts will still be moving.
+func (b *BackupManager) Stats() RunStats {
+	return RunStats{
+		Created: atomic.LoadInt64(&b.stats.created),
+		Skipped: atomic.LoadInt64(&b.stats.skipped),
+		Failed:  atomic.LoadInt64(&b.stats.failed),
+		Retried: atomic.LoadInt64(&b.stats.retried),
+	}
+}
+
+// resultStats counts results the way a run's stats do, so runs across several
+// managers can be added up.
+func resultStats(results []SnapshotResult) RunStats {
+	var c runCounters
+	for _, result := range results {
+		c.record(result.Status)
+	}
+	return RunStats{Created: c.created, Skipped: c.skipped, Failed: c.failed}
+}
+
+// summaryLine is the last thing a backup run prints, whatever else is, for
+// monitoring to pick up. Its format is fixed: four space-separated key=value
+// pairs in this order, the duration rounded to the second and written as Go
+// writes durations.
+//
+//	created=12 skipped=2 failed=0 duration=1m3s
+func summaryLine(stats RunStats, d time.Duration) string {
+	return fmt.Sprintf("created=%d skipped=%d failed=%d duration=%s", stats.Created, stats.Skipped, stats.Failed, d.Round(time.Second))
+}
+
+// failureRate counts the failed results and works out what percentage of
+// all of them that is. No results means no failures.
+func failureRate(results []SnapshotResult) (failed int, percent float64) {
+	for _, result := range results {
+		if result.Status == StatusFailed {
+			failed++
+		}
+	}
+	if len(results) == 0 {
+		return 0, 0
+	}
+	return failed, float64(failed) * 100 / float64(len(results))
+}
+
+// overFailThreshold reports whether more than threshold percent of the results
+// failed. A threshold of zero means any failure is too many.
+func overFailThreshold(results []SnapshotResult, threshold float64) bool {
+	failed, percent := failureRate(results)
+	return failed > 0 && percent > threshold
+}
+
+// byDuration returns the results that got as far as creating a snapshot,
+// slowest first.
+func byDuration(results []SnapshotResult) []SnapshotResult {
+	timed := make([]SnapshotResult, 0, len(results))
+	for _, result := range results {
+		if result.Duration > 0 {
+			timed = append(timed, result)
+		}
+	}
+	sort.SliceStable(timed, func(i, j int) bool {
+		return timed[i].Duration > timed[j].Duration
+	})
+	return timed
+}
+
+// logTimings logs how long each snapshot took to start, slowest first, in
+// verbose mode.
+func (b *BackupManager) logTimings(results []SnapshotResult) {
+	if !b.Verbose {
+		return
+	}
+	timed := byDuration(results)
+	if len(timed) == 0 {
+		return
+	}
+	timings := make([]string, 0, len(timed))
+	for _, result := range timed {
+		timings = append(timings, result.ClusterIdentifier+" "+result.Duration.String())
+	}
+	b.debugf("Snapshot timings, slowest first: %s.", strings.Join(timings, ", "))
+}
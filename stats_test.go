@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatsResetBetweenRuns(t *testing.T) {
+	bm := &BackupManager{st: NewFakeSnapshotTaker(), prefix: "testing"}
+
+	_, err := bm.TriggerSnapshots(context.TODO(), "my-cluster-1", "my-cluster-2")
+	assert.NoError(t, err)
+	assert.Equal(t, RunStats{Created: 2}, bm.Stats())
+
+	_, err = bm.TriggerSnapshots(context.TODO(), "my-cluster-3")
+	assert.NoError(t, err)
+	assert.Equal(t, RunStats{Created: 1}, bm.Stats())
+}
+
+func TestStatsConcurrent(t *testing.T) {
+	bm := &BackupManager{st: NewFakeSnapshotTaker(), prefix: "testing", Concurrency: 8}
+
+	clusterIDs := make([]string, 0, 100)
+	for i := 0; i < 100; i++ {
+		clusterIDs = append(clusterIDs, fmt.Sprintf("my-cluster-%d", i))
+	}
+	_, err := bm.TriggerSnapshots(context.TODO(), clusterIDs...)
+	assert.NoError(t, err)
+	assert.Equal(t, RunStats{Created: 100}, bm.Stats())
+}
+
+func TestOverFailThreshold(t *testing.T) {
+	// one in four failed
+	results := []SnapshotResult{
+		{ClusterIdentifier: "my-cluster-1", Status: StatusCreated},
+		{ClusterIdentifier: "my-cluster-2", Status: StatusFailed},
+		{ClusterIdentifier: "my-cluster-3", Status: StatusSkippedNotFound},
+		{ClusterIdentifier: "my-cluster-4", Status: StatusCreated},
+	}
+
+	type testCase struct {
+		results   []SnapshotResult
+		threshold float64
+		expected  bool
+	}
+
+	testCases := map[string]testCase{
+		"any failure is too many by default": {
+			results:  results,
+			expected: true,
+		},
+		"below the threshold": {
+			results:   results,
+			threshold: 30,
+			expected:  false,
+		},
+		"exactly at the threshold": {
+			results:   results,
+			threshold: 25,
+			expected:  false,
+		},
+		"just over the threshold": {
+			results:   results,
+			threshold: 24.9,
+			expected:  true,
+		},
+		"everything failed": {
+			results:   []SnapshotResult{{Status: StatusFailed}, {Status: StatusFailed}},
+			threshold: 99,
+			expected:  true,
+		},
+		"nothing failed": {
+			results:  []SnapshotResult{{Status: StatusCreated}},
+			expected: false,
+		},
+		"no results": {
+			expected: false,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, overFailThreshold(tc.results, tc.threshold))
+		})
+	}
+}
+
+func TestFailureRate(t *testing.T) {
+	failed, percent := failureRate([]SnapshotResult{
+		{Status: StatusFailed},
+		{Status: StatusCreated},
+		{Status: StatusCreated},
+	})
+	assert.Equal(t, 1, failed)
+	assert.InDelta(t, 33.3, percent, 0.1)
+}
+
+func TestSummaryLine(t *testing.T) {
+	results := []SnapshotResult{
+		{ClusterIdentifier: "my-cluster-1", Status: StatusCreated},
+		{ClusterIdentifier: "my-cluster-2", Status: StatusSkippedRecent},
+		{ClusterIdentifier: "my-cluster-3", Status: StatusCreated},
+		{ClusterIdentifier: "my-cluster-4", Status: StatusFailed},
+	}
+	assert.Equal(t, "created=2 skipped=1 failed=1 duration=1m3s", summaryLine(resultStats(results), 63*time.Second+400*time.Millisecond))
+	assert.Equal(t, "created=0 skipped=0 failed=0 duration=0s", summaryLine(resultStats(nil), 0))
+
+	// they agree with the run's own counts
+	bm := NewBackupManager(NewFakeSnapshotTaker(), WithPrefix("testing"))
+	results, err := bm.TriggerSnapshots(context.TODO(), "my-cluster-1", "my-cluster-2")
+	assert.Nil(t, err)
+	assert.Equal(t, bm.Stats(), resultStats(results))
+}
+
+func TestByDuration(t *testing.T) {
+	results := []SnapshotResult{
+		{ClusterIdentifier: "my-cluster-1", Duration: time.Second},
+		{ClusterIdentifier: "my-cluster-2", Status: StatusSkippedRecent},
+		{ClusterIdentifier: "my-cluster-3", Duration: 3 * time.Second},
+		{ClusterIdentifier: "my-cluster-4", Duration: time.Second},
+	}
+
+	clusters := make([]string, 0)
+	for _, result := range byDuration(results) {
+		clusters = append(clusters, result.ClusterIdentifier)
+	}
+	assert.Equal(t, []string{"my-cluster-3", "my-cluster-1", "my-cluster-4"}, clusters)
+}
+
+func TestTriggerSnapshotsDurationIncludesRetries(t *testing.T) {
+	now := testNow
+	st := NewTransientSnapshotTaker("my-cluster-1", 2)
+	bm := &BackupManager{
+		st:     st,
+		prefix: "testing",
+		now:    func() time.Time { return now },
+		sleep: func(ctx context.Context, d time.Duration) error {
+			now = now.Add(d)
+			return nil
+		},
+	}
+
+	results, err := bm.TriggerSnapshots(context.TODO(), "my-cluster-1", "my-cluster-2")
+	assert.Nil(t, err)
+	assert.Equal(t, 6*time.Second, results[0].Duration)
+	assert.Equal(t, time.Duration(0), results[1].Duration)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+)
+
+// ClusterStarter starts and stops clusters. *rds.Client implements it.
+type ClusterStarter interface {
+	StartDBCluster(context.Context, *rds.StartDBClusterInput, ...func(*rds.Options)) (*rds.StartDBClusterOutput, error)
+	StopDBCluster(context.Context, *rds.StopDBClusterInput, ...func(*rds.Options)) (*rds.StopDBClusterOutput, error)
+}
+
+const ErrNoClusterStarter BackupManagerError = "starting stopped clusters requires a ClusterStarter"
+
+// clusterStatusStopped is the status of a cluster that's been stopped, which
+// can't be snapshotted until it's started again.
+const clusterStatusStopped = "stopped"
+
+// isStopped reports whether discovery or the precheck found a cluster
+// stopped. Clusters that were neither discovered nor prechecked aren't known
+// to be stopped, and fail when they're snapshotted if they are.
+func (b *BackupManager) isStopped(clusterIdentifier string) bool {
+	if b.stopped[clusterIdentifier] {
+		return true
+	}
+	info, ok := b.annotation(clusterIdentifier)
+	return ok && info.Stopped
+}
+
+// startCluster starts a stopped cluster and waits for it to be available.
+func (b *BackupManager) startCluster(ctx context.Context, clusterIdentifier string) error {
+	b.logf("Starting stopped cluster '%s' to back it up.", clusterIdentifier)
+	_, err := b.cs.StartDBCluster(ctx, &rds.StartDBClusterInput{
+		DBClusterIdentifier: aws.String(clusterIdentifier),
+	})
+	if err != nil {
+		return fmt.Errorf("starting cluster '%s': %w", clusterIdentifier, err)
+	}
+	return b.waitForCluster(ctx, clusterIdentifier)
+}
+
+// stopClusterAgain stops a cluster that was started to back it up, once
+// it's available again, which it isn't while the snapshot is being taken. A
+// cluster left running costs money but loses nothing, so a failure is only
+// worth a warning. It doesn't use the run's context: a run cut short still
+// has to put back what it started.
+func (b *BackupManager) stopClusterAgain(clusterIdentifier string) {
+	ctx := context.Background()
+	err := b.waitForCluster(ctx, clusterIdentifier)
+	if err == nil {
+		_, err = b.cs.StopDBCluster(ctx, &rds.StopDBClusterInput{
+			DBClusterIdentifier: aws.String(clusterIdentifier),
+		})
+	}
+	if err != nil {
+		b.logf("Couldn't stop cluster '%s' again, it's been left running: %v", clusterIdentifier, err)
+		return
+	}
+	b.logf("Stopped cluster '%s' again.", clusterIdentifier)
+}
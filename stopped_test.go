@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// startingSnapshotTaker writes down each start, snapshot and stop, in the
+// order they happen, and can refuse to start clusters.
+type startingSnapshotTaker struct {
+	*fakeSnapshotTaker
+	startErr error
+	steps    []string
+}
+
+func (s *startingSnapshotTaker) StartDBCluster(ctx context.Context, in *rds.StartDBClusterInput, optFns ...func(*rds.Options)) (*rds.StartDBClusterOutput, error) {
+	if s.startErr != nil {
+		return nil, s.startErr
+	}
+	s.steps = append(s.steps, "start "+*in.DBClusterIdentifier)
+	return s.fakeSnapshotTaker.StartDBCluster(ctx, in, optFns...)
+}
+
+func (s *startingSnapshotTaker) StopDBCluster(ctx context.Context, in *rds.StopDBClusterInput, optFns ...func(*rds.Options)) (*rds.StopDBClusterOutput, error) {
+	s.steps = append(s.steps, "stop "+*in.DBClusterIdentifier)
+	return s.fakeSnapshotTaker.StopDBCluster(ctx, in, optFns...)
+}
+
+func (s *startingSnapshotTaker) CreateDBClusterSnapshot(ctx context.Context, in *rds.CreateDBClusterSnapshotInput, optFns ...func(*rds.Options)) (*rds.CreateDBClusterSnapshotOutput, error) {
+	s.steps = append(s.steps, "snapshot "+*in.DBClusterIdentifier)
+	return s.fakeSnapshotTaker.CreateDBClusterSnapshot(ctx, in, optFns...)
+}
+
+func stoppedCluster(clusterID string) types.DBCluster {
+	cluster := existingCluster(clusterID)
+	cluster.Status = aws.String("stopped")
+	return cluster
+}
+
+func newStartingSnapshotTaker() *startingSnapshotTaker {
+	fake := NewFakeSnapshotTaker()
+	fake.clusters = []types.DBCluster{existingCluster("my-cluster-1"), stoppedCluster("my-cluster-2")}
+	return &startingSnapshotTaker{fakeSnapshotTaker: fake}
+}
+
+func TestStoppedClustersAreSkipped(t *testing.T) {
+	type testCase struct {
+		discover bool
+		precheck bool
+	}
+
+	testCases := map[string]testCase{
+		"found by discovery":    {discover: true},
+		"found by the precheck": {precheck: true},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			st := newStartingSnapshotTaker()
+			bm := NewBackupManager(st, WithPrefix("testing"), WithPrecheckClusters(tc.precheck))
+			if tc.discover {
+				_, err := bm.DiscoverClusters(context.TODO())
+				assert.Nil(t, err)
+			}
+
+			results, err := bm.TriggerSnapshots(context.TODO(), "my-cluster-1", "my-cluster-2")
+			assert.Nil(t, err)
+			assert.Equal(t, StatusCreated, results[0].Status)
+			assert.Equal(t, StatusSkippedStopped, results[1].Status)
+			assert.Equal(t, RunStats{Created: 1, Skipped: 1}, bm.Stats())
+			assert.Equal(t, []string{"snapshot my-cluster-1"}, st.steps)
+		})
+	}
+}
+
+func TestStartStopped(t *testing.T) {
+	st := newStartingSnapshotTaker()
+	bm := NewBackupManager(st, WithPrefix("testing"), WithPrecheckClusters(true), WithStartStopped(true))
+	bm.sleep = noSleep
+
+	results, err := bm.TriggerSnapshots(context.TODO(), "my-cluster-1", "my-cluster-2")
+	assert.Nil(t, err)
+	assert.Equal(t, StatusCreated, results[0].Status)
+	assert.Equal(t, StatusCreated, results[1].Status)
+	assert.Equal(t, []string{"snapshot my-cluster-1", "start my-cluster-2", "snapshot my-cluster-2", "stop my-cluster-2"}, st.steps)
+	assert.Equal(t, "stopped", aws.ToString(st.clusters[1].Status))
+}
+
+func TestStartStoppedFailsToStart(t *testing.T) {
+	st := newStartingSnapshotTaker()
+	st.startErr = errors.New("cluster can't be started")
+	bm := NewBackupManager(st, WithPrefix("testing"), WithPrecheckClusters(true), WithStartStopped(true), WithContinueOnError(true))
+	bm.sleep = noSleep
+
+	results, err := bm.TriggerSnapshots(context.TODO(), "my-cluster-1", "my-cluster-2")
+	assert.ErrorIs(t, err, ErrSnapshotsFailed)
+	assert.Equal(t, StatusFailed, results[1].Status)
+	assert.ErrorIs(t, results[1].Err, st.startErr)
+	// nothing was started, so there's nothing to stop
+	assert.Equal(t, []string{"snapshot my-cluster-1"}, st.steps)
+}
+
+func TestStartStoppedDryRun(t *testing.T) {
+	st := newStartingSnapshotTaker()
+	bm := NewBackupManager(st, WithPrefix("testing"), WithPrecheckClusters(true), WithStartStopped(true), WithDryRun(true, false))
+
+	results, err := bm.TriggerSnapshots(context.TODO(), "my-cluster-2")
+	assert.Nil(t, err)
+	assert.Equal(t, StatusPlanned, results[0].Status)
+	assert.Empty(t, st.steps)
+}
+
+func TestStartStoppedNeedsStarter(t *testing.T) {
+	bm := NewBackupManager(NewFakeSnapshotTaker(), WithStartStopped(true))
+	bm.cs = nil
+	_, err := bm.TriggerSnapshots(context.TODO(), "my-cluster-1")
+	assert.ErrorIs(t, err, ErrNoClusterStarter)
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+)
+
+const ErrInvalidTagExpr BackupManagerError = "invalid tag expression"
+
+// TagExpr is a query over a resource's tags, parsed by ParseTagExpr.
+type TagExpr interface {
+	Match(tags map[string]string) bool
+	String() string
+}
+
+// ParseTagExpr parses a tag query like
+//
+//	env=prod AND team=payments AND NOT temporary=true
+//
+// Terms are key=value, key!=value (which a missing tag satisfies) or a bare
+// key, which matches if the tag is there at all. Terms combine with NOT, AND
+// and OR, binding in that order, and parentheses group them. Keys and values
+// with spaces or any of ()=!" in them can be double-quoted.
+func ParseTagExpr(s string) (TagExpr, error) {
+	tokens, err := lexTagExpr(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &tagExprParser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if next := p.peek(); next.kind != tokenEnd {
+		return nil, tagExprError(next, "unexpected '%s'", next.text)
+	}
+	return expr, nil
+}
+
+// tagsToMap turns an SDK tag list into a map, for matching against.
+func tagsToMap(tags []types.Tag) map[string]string {
+	m := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		m[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	return m
+}
+
+type tagEquals struct {
+	key, value string
+	negate     bool
+}
+
+func (e tagEquals) Match(tags map[string]string) bool {
+	value, ok := tags[e.key]
+	return (ok && value == e.value) != e.negate
+}
+
+func (e tagEquals) String() string {
+	op := "="
+	if e.negate {
+		op = "!="
+	}
+	return quoteTagWord(e.key) + op + quoteTagWord(e.value)
+}
+
+type tagExists struct {
+	key string
+}
+
+func (e tagExists) Match(tags map[string]string) bool {
+	_, ok := tags[e.key]
+	return ok
+}
+
+func (e tagExists) String() string {
+	return quoteTagWord(e.key)
+}
+
+type tagNot struct {
+	expr TagExpr
+}
+
+func (e tagNot) Match(tags map[string]string) bool {
+	return !e.expr.Match(tags)
+}
+
+func (e tagNot) String() string {
+	return "NOT " + e.expr.String()
+}
+
+type tagAnd struct {
+	left, right TagExpr
+}
+
+func (e tagAnd) Match(tags map[string]string) bool {
+	return e.left.Match(tags) && e.right.Match(tags)
+}
+
+func (e tagAnd) String() string {
+	return "(" + e.left.String() + " AND " + e.right.String() + ")"
+}
+
+type tagOr struct {
+	left, right TagExpr
+}
+
+func (e tagOr) Match(tags map[string]string) bool {
+	return e.left.Match(tags) || e.right.Match(tags)
+}
+
+func (e tagOr) String() string {
+	return "(" + e.left.String() + " OR " + e.right.String() + ")"
+}
+
+// quoteTagWord quotes a key or value if it wouldn't parse back as itself.
+func quoteTagWord(s string) string {
+	if s == "" || isTagKeyword(s) || strings.ContainsAny(s, " \t\n()=!\"") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+func isTagKeyword(s string) bool {
+	return strings.EqualFold(s, "AND") || strings.EqualFold(s, "OR") || strings.EqualFold(s, "NOT")
+}
+
+type tokenKind int
+
+const (
+	tokenEnd tokenKind = iota
+	tokenWord
+	tokenQuoted
+	tokenOpen
+	tokenClose
+	tokenEquals
+	tokenNotEquals
+)
+
+type tagToken struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// isKeyword reports whether the token is an unquoted AND, OR or NOT, in any
+// case.
+func (t tagToken) isKeyword(keyword string) bool {
+	return t.kind == tokenWord && strings.EqualFold(t.text, keyword)
+}
+
+func tagExprError(t tagToken, format string, v ...interface{}) error {
+	if t.kind == tokenEnd {
+		return fmt.Errorf("%w: %s at the end", ErrInvalidTagExpr, fmt.Sprintf(format, v...))
+	}
+	return fmt.Errorf("%w: %s at position %d", ErrInvalidTagExpr, fmt.Sprintf(format, v...), t.pos+1)
+}
+
+func lexTagExpr(s string) ([]tagToken, error) {
+	tokens := make([]tagToken, 0)
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '(':
+			tokens = append(tokens, tagToken{kind: tokenOpen, text: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, tagToken{kind: tokenClose, text: ")", pos: i})
+			i++
+		case c == '=':
+			tokens = append(tokens, tagToken{kind: tokenEquals, text: "=", pos: i})
+			i++
+		case c == '!':
+			if i+1 == len(s) || s[i+1] != '=' {
+				return nil, tagExprError(tagToken{kind: tokenWord, pos: i}, "'!' must be followed by '='")
+			}
+			tokens = append(tokens, tagToken{kind: tokenNotEquals, text: "!=", pos: i})
+			i += 2
+		case c == '"':
+			end := i + 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(s) {
+				return nil, tagExprError(tagToken{kind: tokenQuoted, pos: i}, "unterminated quote")
+			}
+			text, err := strconv.Unquote(s[i : end+1])
+			if err != nil {
+				return nil, tagExprError(tagToken{kind: tokenQuoted, pos: i}, "bad quoted string")
+			}
+			tokens = append(tokens, tagToken{kind: tokenQuoted, text: text, pos: i})
+			i = end + 1
+		default:
+			end := i
+			for end < len(s) && !strings.ContainsRune(" \t\n()=!\"", rune(s[end])) {
+				end++
+			}
+			tokens = append(tokens, tagToken{kind: tokenWord, text: s[i:end], pos: i})
+			i = end
+		}
+	}
+	return append(tokens, tagToken{kind: tokenEnd, pos: len(s)}), nil
+}
+
+// tagExprParser is a recursive descent parser over the tokens, always ending
+// with tokenEnd.
+type tagExprParser struct {
+	tokens []tagToken
+	next   int
+}
+
+func (p *tagExprParser) peek() tagToken {
+	return p.tokens[p.next]
+}
+
+func (p *tagExprParser) take() tagToken {
+	t := p.tokens[p.next]
+	if t.kind != tokenEnd {
+		p.next++
+	}
+	return t
+}
+
+func (p *tagExprParser) parseOr() (TagExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().isKeyword("OR") {
+		p.take()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = tagOr{left, right}
+	}
+	return left, nil
+}
+
+func (p *tagExprParser) parseAnd() (TagExpr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().isKeyword("AND") {
+		p.take()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = tagAnd{left, right}
+	}
+	return left, nil
+}
+
+func (p *tagExprParser) parseNot() (TagExpr, error) {
+	if !p.peek().isKeyword("NOT") {
+		return p.parseTerm()
+	}
+	p.take()
+	expr, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	return tagNot{expr}, nil
+}
+
+func (p *tagExprParser) parseTerm() (TagExpr, error) {
+	t := p.take()
+	switch {
+	case t.kind == tokenOpen:
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.take(); closing.kind != tokenClose {
+			return nil, tagExprError(closing, "expected ')'")
+		}
+		return expr, nil
+	case t.kind == tokenQuoted, t.kind == tokenWord && !isTagKeyword(t.text):
+		// carry on to the key's operator below
+	default:
+		return nil, tagExprError(t, "expected a tag")
+	}
+
+	op := p.peek()
+	if op.kind != tokenEquals && op.kind != tokenNotEquals {
+		return tagExists{key: t.text}, nil
+	}
+	p.take()
+	// any word will do as a value, keywords included
+	value := p.take()
+	if value.kind != tokenWord && value.kind != tokenQuoted {
+		return nil, tagExprError(value, "expected a value for '%s'", t.text)
+	}
+	return tagEquals{key: t.text, value: value.text, negate: op.kind == tokenNotEquals}, nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTagExpr(t *testing.T) {
+	prod := map[string]string{"env": "prod", "team": "payments"}
+	temporary := map[string]string{"env": "prod", "team": "payments", "temporary": "true"}
+	staging := map[string]string{"env": "staging", "team": "payments"}
+
+	type testCase struct {
+		expr      string
+		canonical string
+		matches   []map[string]string
+		misses    []map[string]string
+	}
+
+	testCases := map[string]testCase{
+		"single term": {
+			expr:      "env=prod",
+			canonical: "env=prod",
+			matches:   []map[string]string{prod, temporary},
+			misses:    []map[string]string{staging, {}},
+		},
+		"the example": {
+			expr:      "env=prod AND team=payments AND NOT temporary=true",
+			canonical: "((env=prod AND team=payments) AND NOT temporary=true)",
+			matches:   []map[string]string{prod},
+			misses:    []map[string]string{temporary, staging},
+		},
+		"not equals matches a missing tag": {
+			expr:      "temporary!=true",
+			canonical: "temporary!=true",
+			matches:   []map[string]string{prod, {}},
+			misses:    []map[string]string{temporary},
+		},
+		"bare key checks the tag is there": {
+			expr:      "temporary",
+			canonical: "temporary",
+			matches:   []map[string]string{temporary, {"temporary": ""}},
+			misses:    []map[string]string{prod},
+		},
+		"AND binds tighter than OR": {
+			expr:      "env=staging OR env=prod AND temporary",
+			canonical: "(env=staging OR (env=prod AND temporary))",
+			matches:   []map[string]string{staging, temporary},
+			misses:    []map[string]string{prod},
+		},
+		"parentheses group": {
+			expr:      "(env=staging OR env=prod) AND NOT temporary",
+			canonical: "((env=staging OR env=prod) AND NOT temporary)",
+			matches:   []map[string]string{staging, prod},
+			misses:    []map[string]string{temporary},
+		},
+		"keywords in any case": {
+			expr:      "not env=staging and team=payments",
+			canonical: "(NOT env=staging AND team=payments)",
+			matches:   []map[string]string{prod},
+			misses:    []map[string]string{staging},
+		},
+		"quoted keys and values": {
+			expr:      `"cost center"="data (shared)" OR owner=""`,
+			canonical: `("cost center"="data (shared)" OR owner="")`,
+			matches:   []map[string]string{{"cost center": "data (shared)"}, {"owner": ""}},
+			misses:    []map[string]string{{"cost center": "data"}, prod},
+		},
+		"a keyword as a value": {
+			expr:      `gate=OR`,
+			canonical: `gate="OR"`,
+			matches:   []map[string]string{{"gate": "OR"}},
+			misses:    []map[string]string{{"gate": "or"}},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			expr, err := ParseTagExpr(tc.expr)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.canonical, expr.String())
+			for _, tags := range tc.matches {
+				assert.True(t, expr.Match(tags), "%v", tags)
+			}
+			for _, tags := range tc.misses {
+				assert.False(t, expr.Match(tags), "%v", tags)
+			}
+
+			// the canonical form means the same thing
+			again, err := ParseTagExpr(expr.String())
+			assert.Nil(t, err)
+			assert.Equal(t, expr, again)
+		})
+	}
+}
+
+func TestParseTagExprErrors(t *testing.T) {
+	testCases := map[string]string{
+		"empty":                "",
+		"dangling AND":         "env=prod AND",
+		"missing value":        "team=payments AND env=",
+		"lone bang":            "env!prod",
+		"unclosed group":       "(env=prod OR env=staging",
+		"stray close":          "env=prod)",
+		"unterminated quote":   `env="prod`,
+		"two terms, no AND":    "env=prod team=payments",
+		"keyword as a tag":     "AND=prod",
+		"operator with no key": "=prod",
+	}
+
+	for name, expr := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseTagExpr(expr)
+			assert.ErrorIs(t, err, ErrInvalidTagExpr)
+		})
+	}
+}
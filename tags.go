@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+)
+
+// TagLister looks up the tags on an RDS resource. *rds.Client implements it.
+type TagLister interface {
+	ListTagsForResource(context.Context, *rds.ListTagsForResourceInput, ...func(*rds.Options)) (*rds.ListTagsForResourceOutput, error)
+}
+
+// TagAdder tags an RDS resource after it's created. *rds.Client implements
+// it.
+type TagAdder interface {
+	AddTagsToResource(context.Context, *rds.AddTagsToResourceInput, ...func(*rds.Options)) (*rds.AddTagsToResourceOutput, error)
+}
+
+const (
+	ErrNoTagAdder       BackupManagerError = "tagging snapshots requires a TagAdder"
+	ErrNoTagLister      BackupManagerError = "selecting snapshots by tag requires a TagLister"
+	ErrEmptyTagSelector BackupManagerError = "selecting by tags only needs at least one tag in the selector"
+)
+
+// snapshotTagsFor fetches the tags on a snapshot.
+func (b *BackupManager) snapshotTagsFor(ctx context.Context, snapshot types.DBClusterSnapshot) ([]types.Tag, error) {
+	if b.tl == nil {
+		return nil, ErrNoTagLister
+	}
+	out, err := b.tl.ListTagsForResource(ctx, &rds.ListTagsForResourceInput{
+		ResourceName: snapshot.DBClusterSnapshotArn,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.TagList, nil
+}
+
+// tagAfterCreate waits for a new snapshot to show up in a state that can be
+// tagged, then tags it. Snapshots can be tagged while they're still being
+// created, so there's no need to wait for them to be available.
+func (b *BackupManager) tagAfterCreate(ctx context.Context, snapshotID, snapshotArn string, tags []types.Tag) error {
+	err := b.pollSnapshots(ctx, []string{snapshotID}, func(snapshotID, status string, found bool) (bool, error) {
+		if status == "failed" {
+			return false, fmt.Errorf("'%s': %w", snapshotID, ErrSnapshotFailed)
+		}
+		// a brand new snapshot may not be visible to describe calls yet
+		return found, nil
+	})
+	if err != nil {
+		return fmt.Errorf("waiting to tag snapshot '%s': %w", snapshotID, err)
+	}
+
+	_, err = b.ta.AddTagsToResource(ctx, &rds.AddTagsToResourceInput{
+		ResourceName: aws.String(snapshotArn),
+		Tags:         tags,
+	})
+	if err != nil {
+		return fmt.Errorf("tagging snapshot '%s': %w", snapshotID, err)
+	}
+	return nil
+}
+
+// sortedTags converts tags to the SDK's form, sorted by key so requests are
+// deterministic.
+func sortedTags(tags map[string]string) []types.Tag {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	sorted := make([]types.Tag, 0, len(keys))
+	for _, key := range keys {
+		sorted = append(sorted, types.Tag{Key: aws.String(key), Value: aws.String(tags[key])})
+	}
+	return sorted
+}
+
+// matchesTags reports whether tags has every key=value pair in selector.
+func matchesTags(tags []types.Tag, selector map[string]string) bool {
+	have := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		have[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	for key, value := range selector {
+		if v, ok := have[key]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+// snapshotCreatedAt is when a snapshot was created, as far as age goes. The
+// created-at tag we stamp is preferred, since it's when we asked for the
+// snapshot; otherwise it's SnapshotCreateTime, which is nil until AWS is done.
+func snapshotCreatedAt(snapshot types.DBClusterSnapshot) *time.Time {
+	for _, tag := range snapshot.TagList {
+		if aws.ToString(tag.Key) != createdAtTagKey {
+			continue
+		}
+		if createdAt, err := time.Parse(time.RFC3339, aws.ToString(tag.Value)); err == nil {
+			return &createdAt
+		}
+	}
+	return snapshot.SnapshotCreateTime
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// tagged sets exactly the given tags on a snapshot, without the automatic
+// ones snapshotTags adds.
+func tagged(snapshot types.DBClusterSnapshot, tags map[string]string) types.DBClusterSnapshot {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	snapshot.TagList = make([]types.Tag, 0, len(keys))
+	for _, key := range keys {
+		snapshot.TagList = append(snapshot.TagList, types.Tag{Key: aws.String(key), Value: aws.String(tags[key])})
+	}
+	return snapshot
+}
+
+func TestMatchesTags(t *testing.T) {
+	type testCase struct {
+		tags     map[string]string
+		selector map[string]string
+		matches  bool
+	}
+
+	testCases := map[string]testCase{
+		"empty selector matches anything": {
+			tags:    map[string]string{"env": "prod"},
+			matches: true,
+		},
+		"single pair matches": {
+			tags:     map[string]string{"created-by": "go-unit-testing", "env": "prod"},
+			selector: map[string]string{"created-by": "go-unit-testing"},
+			matches:  true,
+		},
+		"all pairs must match": {
+			tags:     map[string]string{"created-by": "go-unit-testing", "env": "prod"},
+			selector: map[string]string{"created-by": "go-unit-testing", "env": "staging"},
+		},
+		"missing key doesn't match": {
+			tags:     map[string]string{"env": "prod"},
+			selector: map[string]string{"created-by": "go-unit-testing"},
+		},
+		"empty value must match exactly": {
+			tags:     map[string]string{"temporary": ""},
+			selector: map[string]string{"temporary": ""},
+			matches:  true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			tags := (&BackupManager{Tags: tc.tags}).snapshotTags()
+			assert.Equal(t, tc.matches, matchesTags(tags, tc.selector))
+		})
+	}
+}
+
+func TestListSnapshotsByTag(t *testing.T) {
+	type testCase struct {
+		selector      map[string]string
+		tagsOnly      bool
+		expectedIDs   []string
+		expectedError error
+	}
+
+	ours := map[string]string{"created-by": "go-unit-testing"}
+	testCases := map[string]testCase{
+		"prefix and tags": {
+			selector:    ours,
+			expectedIDs: []string{"testing-my-cluster-1"},
+		},
+		"tags instead of prefix": {
+			selector:    ours,
+			tagsOnly:    true,
+			expectedIDs: []string{"testing-my-cluster-1", "renamed-my-cluster-1"},
+		},
+		"tags only with no selector": {
+			tagsOnly:      true,
+			expectedError: ErrEmptyTagSelector,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			st := NewFakeSnapshotTakerWithSnapshots(
+				tagged(existingSnapshot("my-cluster-1", "testing-my-cluster-1", testNow), ours),
+				tagged(existingSnapshot("my-cluster-1", "renamed-my-cluster-1", testNow), ours),
+				existingSnapshot("my-cluster-1", "testing-untagged", testNow),
+				tagged(existingSnapshot("my-cluster-1", "testing-other-tool", testNow), map[string]string{"created-by": "other"}),
+			)
+			bm := NewBackupManager(st, WithPrefix("testing"), WithTagSelector(tc.selector, tc.tagsOnly))
+
+			snapshots, err := bm.ListSnapshots(context.TODO())
+			assert.ErrorIs(t, err, tc.expectedError)
+			if tc.expectedError == nil {
+				assert.Equal(t, tc.expectedIDs, snapshotIDs(snapshots))
+			}
+		})
+	}
+}
+
+func TestListSnapshotsByTagWithoutLister(t *testing.T) {
+	st := NewFakeSnapshotTakerWithSnapshots(existingSnapshot("my-cluster-1", "testing-my-cluster-1", testNow))
+	bm := &BackupManager{st: st, sd: st, prefix: "testing", TagSelector: map[string]string{"env": "prod"}}
+
+	_, err := bm.ListSnapshots(context.TODO())
+	assert.ErrorIs(t, err, ErrNoTagLister)
+}
+
+func TestSnapshotCreatedAt(t *testing.T) {
+	type testCase struct {
+		snapshot types.DBClusterSnapshot
+		expected *time.Time
+	}
+
+	tagTime := testNow.Add(-time.Hour)
+	awsTime := testNow.Add(-30 * time.Minute)
+	testCases := map[string]testCase{
+		"prefers the created-at tag": {
+			snapshot: tagged(existingSnapshot("my-cluster-1", "testing-my-cluster-1", awsTime), map[string]string{"created-at": tagTime.Format(time.RFC3339)}),
+			expected: &tagTime,
+		},
+		"falls back to SnapshotCreateTime": {
+			snapshot: existingSnapshot("my-cluster-1", "testing-my-cluster-1", awsTime),
+			expected: &awsTime,
+		},
+		"ignores a created-at tag it can't parse": {
+			snapshot: tagged(existingSnapshot("my-cluster-1", "testing-my-cluster-1", awsTime), map[string]string{"created-at": "last tuesday"}),
+			expected: &awsTime,
+		},
+		"tag is enough while AWS is still creating": {
+			snapshot: tagged(types.DBClusterSnapshot{}, map[string]string{"created-at": tagTime.Format(time.RFC3339)}),
+			expected: &tagTime,
+		},
+		"neither": {},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			createdAt := snapshotCreatedAt(tc.snapshot)
+			if tc.expected == nil {
+				assert.Nil(t, createdAt)
+				return
+			}
+			if assert.NotNil(t, createdAt) {
+				assert.True(t, tc.expected.Equal(*createdAt), "expected %s, got %s", tc.expected, createdAt)
+			}
+		})
+	}
+}
+
+func TestPruneCandidatesPreferCreatedAtTag(t *testing.T) {
+	// AWS finished the snapshot just inside the window, but we asked for it
+	// well before that
+	snapshot := tagged(
+		existingSnapshot("my-cluster-1", "testing-my-cluster-1", testNow.Add(-23*time.Hour)),
+		map[string]string{"created-at": testNow.Add(-25 * time.Hour).Format(time.RFC3339)},
+	)
+	st := NewFakeSnapshotTakerWithSnapshots(snapshot)
+	bm := NewBackupManager(st, WithPrefix("testing"))
+	bm.now = func() time.Time { return testNow }
+
+	candidates, err := bm.PruneCandidates(context.TODO(), 24*time.Hour)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"testing-my-cluster-1"}, snapshotIDs(candidates))
+}
+
+type fakeTagAdder struct {
+	err   error
+	added [][]types.Tag
+}
+
+func (f *fakeTagAdder) AddTagsToResource(ctx context.Context, in *rds.AddTagsToResourceInput, optFns ...func(*rds.Options)) (*rds.AddTagsToResourceOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.added = append(f.added, in.Tags)
+	return &rds.AddTagsToResourceOutput{}, nil
+}
+
+func TestTagAfterCreate(t *testing.T) {
+	type testCase struct {
+		statuses       []string
+		tagErr         error
+		expectedStatus SnapshotStatus
+		expectedError  error
+		expectedTagged int
+	}
+
+	tagErr := errors.New("not allowed")
+	testCases := map[string]testCase{
+		"tags once the snapshot shows up": {
+			statuses:       []string{"", "creating"},
+			expectedStatus: StatusCreated,
+			expectedTagged: 1,
+		},
+		"snapshot failed before tagging": {
+			statuses:       []string{"failed"},
+			expectedStatus: StatusFailed,
+			expectedError:  ErrSnapshotFailed,
+		},
+		"tagging failed": {
+			statuses:       []string{"available"},
+			tagErr:         tagErr,
+			expectedStatus: StatusFailed,
+			expectedError:  tagErr,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			st := NewFakeSnapshotTaker()
+			ta := &fakeTagAdder{err: tc.tagErr}
+			bm := &BackupManager{
+				st:             st,
+				sd:             &progressingSnapshotDescriber{statuses: map[string][]string{"testing-my-cluster-1": tc.statuses}},
+				ta:             ta,
+				prefix:         "testing",
+				sleep:          noSleep,
+				TagAfterCreate: true,
+				Tags:           map[string]string{"team": "data"},
+			}
+
+			result, err := bm.TriggerSnapshot(context.TODO(), "my-cluster-1")
+			assert.ErrorIs(t, err, tc.expectedError)
+			assert.Equal(t, tc.expectedStatus, result.Status)
+			assert.NotContains(t, st.tags, "testing-my-cluster-1")
+			assert.Len(t, ta.added, tc.expectedTagged)
+			for _, tags := range ta.added {
+				assert.Contains(t, tags, types.Tag{Key: aws.String("team"), Value: aws.String("data")})
+			}
+		})
+	}
+}
+
+func TestTagAfterCreateWithoutAdder(t *testing.T) {
+	bm := &BackupManager{st: NewFakeSnapshotTaker(), sd: &progressingSnapshotDescriber{}, prefix: "testing", TagAfterCreate: true}
+	_, err := bm.TriggerSnapshots(context.TODO(), "my-cluster-1")
+	assert.ErrorIs(t, err, ErrNoTagAdder)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+const (
+	ErrInvalidTag      BackupManagerError = "tag isn't valid for RDS"
+	ErrInvalidTagsFile BackupManagerError = "tags file must be a JSON object of string keys to string values"
+)
+
+// RDS's limits on tags: keys are 1 to 128 characters and values up to 256,
+// both drawn from letters, digits, spaces and _.:/=+-@, and keys can't start
+// with the prefixes AWS reserves.
+const (
+	maxTagKeyLen   = 128
+	maxTagValueLen = 256
+	maxTags        = 50
+)
+
+var (
+	tagChars            = regexp.MustCompile(`^[\p{L}\p{Z}\p{N}_.:/=+\-@]*$`)
+	reservedTagPrefixes = []string{"aws:", "rds:"}
+)
+
+// validateTag checks a tag against what RDS will accept, so a bad one is
+// caught before any snapshot is created.
+func validateTag(key, value string) error {
+	switch n := utf8.RuneCountInString(key); {
+	case n == 0:
+		return fmt.Errorf("%w: a key can't be empty", ErrInvalidTag)
+	case n > maxTagKeyLen:
+		return fmt.Errorf("'%s': %w: keys may be up to %d characters", key, ErrInvalidTag, maxTagKeyLen)
+	}
+	if utf8.RuneCountInString(value) > maxTagValueLen {
+		return fmt.Errorf("'%s': %w: values may be up to %d characters", key, ErrInvalidTag, maxTagValueLen)
+	}
+	for _, prefix := range reservedTagPrefixes {
+		if strings.HasPrefix(strings.ToLower(key), prefix) {
+			return fmt.Errorf("'%s': %w: keys can't start with '%s'", key, ErrInvalidTag, prefix)
+		}
+	}
+	if !tagChars.MatchString(key) || !tagChars.MatchString(value) {
+		return fmt.Errorf("'%s': %w: only letters, digits, spaces and _.:/=+-@ are allowed", key, ErrInvalidTag)
+	}
+	return nil
+}
+
+// loadTagsFile reads tags from a JSON object like {"env": "prod"}, checking
+// each one is a tag RDS will take. How many there are is left to
+// validateTags, once they're merged with the inline ones.
+func loadTagsFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var tags map[string]string
+	if err := json.Unmarshal(data, &tags); err != nil {
+		return nil, fmt.Errorf("'%s': %w: %v", path, ErrInvalidTagsFile, err)
+	}
+	if tags == nil {
+		// a file holding just null
+		return nil, fmt.Errorf("'%s': %w", path, ErrInvalidTagsFile)
+	}
+	for _, tag := range sortedTags(tags) {
+		if err := validateTag(aws.ToString(tag.Key), aws.ToString(tag.Value)); err != nil {
+			return nil, fmt.Errorf("'%s': %w", path, err)
+		}
+	}
+	return tags, nil
+}
+
+// mergeTags lays inline tags over ones from a file, so a -tag flag wins over
+// the file.
+func mergeTags(file, inline map[string]string) map[string]string {
+	merged := make(map[string]string, len(file)+len(inline))
+	for key, value := range file {
+		merged[key] = value
+	}
+	for key, value := range inline {
+		merged[key] = value
+	}
+	return merged
+}
+
+// automaticTagKeys are the tags snapshotTags adds to every snapshot, unless
+// they're given explicitly.
+var automaticTagKeys = []string{createdAtTagKey, createdByTagKey, runIDTagKey}
+
+// validateTags checks every tag a snapshot will be given, once they're all
+// merged, leaving room for the automatic ones, so a bad tag or one too many
+// is caught before any snapshot is created rather than failing every one.
+func validateTags(tags map[string]string) error {
+	for _, tag := range sortedTags(tags) {
+		if err := validateTag(aws.ToString(tag.Key), aws.ToString(tag.Value)); err != nil {
+			return err
+		}
+	}
+	count := len(tags)
+	for _, key := range automaticTagKeys {
+		if _, ok := tags[key]; !ok {
+			count++
+		}
+	}
+	if count > maxTags {
+		return fmt.Errorf("%w: RDS allows up to %d tags, and these come to %d with the %d added to every snapshot", ErrInvalidTag, maxTags, count, count-len(tags))
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadTagsFile(t *testing.T) {
+	type testCase struct {
+		contents    string
+		expected    map[string]string
+		expectedErr error
+	}
+
+	testCases := map[string]testCase{
+		"tags": {
+			contents: `{"env": "prod", "cost center": "data/shared", "temporary": ""}`,
+			expected: map[string]string{"env": "prod", "cost center": "data/shared", "temporary": ""},
+		},
+		"empty object": {
+			contents: `{}`,
+			expected: map[string]string{},
+		},
+		"not JSON": {
+			contents:    `env=prod`,
+			expectedErr: ErrInvalidTagsFile,
+		},
+		"a list": {
+			contents:    `[{"Key": "env", "Value": "prod"}]`,
+			expectedErr: ErrInvalidTagsFile,
+		},
+		"a number value": {
+			contents:    `{"retention": 30}`,
+			expectedErr: ErrInvalidTagsFile,
+		},
+		"null": {
+			contents:    `null`,
+			expectedErr: ErrInvalidTagsFile,
+		},
+		"empty key": {
+			contents:    `{"": "prod"}`,
+			expectedErr: ErrInvalidTag,
+		},
+		"reserved prefix": {
+			contents:    `{"AWS:cloudformation": "stack"}`,
+			expectedErr: ErrInvalidTag,
+		},
+		"bad character": {
+			contents:    `{"env": "prod;drop"}`,
+			expectedErr: ErrInvalidTag,
+		},
+		"key too long": {
+			contents:    `{"` + strings.Repeat("k", 129) + `": "v"}`,
+			expectedErr: ErrInvalidTag,
+		},
+		"value too long": {
+			contents:    `{"k": "` + strings.Repeat("v", 257) + `"}`,
+			expectedErr: ErrInvalidTag,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tags.json")
+			assert.Nil(t, os.WriteFile(path, []byte(tc.contents), 0o644))
+
+			tags, err := loadTagsFile(path)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				assert.Contains(t, err.Error(), path)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, tags)
+		})
+	}
+}
+
+func TestLoadTagsFileMissing(t *testing.T) {
+	_, err := loadTagsFile(filepath.Join(t.TempDir(), "missing.json"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestMergeTags(t *testing.T) {
+	file := map[string]string{"env": "prod", "team": "payments"}
+	inline := tagFlag{"env": "staging", "owner": "dba"}
+	assert.Equal(t, map[string]string{"env": "staging", "team": "payments", "owner": "dba"}, mergeTags(file, inline))
+	assert.Equal(t, map[string]string{"env": "prod", "team": "payments"}, file)
+}
+
+func TestValidateTags(t *testing.T) {
+	nTags := func(n int) map[string]string {
+		tags := make(map[string]string, n)
+		for i := 0; i < n; i++ {
+			tags[fmt.Sprintf("tag-%d", i)] = "v"
+		}
+		return tags
+	}
+	withTag := func(tags map[string]string, key, value string) map[string]string {
+		tags[key] = value
+		return tags
+	}
+
+	type testCase struct {
+		tags  map[string]string
+		valid bool
+	}
+
+	testCases := map[string]testCase{
+		"none":                           {map[string]string{}, true},
+		"room for the automatic tags":    {nTags(47), true},
+		"no room for the automatic tags": {nTags(48), false},
+		"overriding an automatic tag":    {withTag(nTags(47), "run-id", "mine"), true},
+		"a bad inline tag":               {map[string]string{"env": "prod;drop"}, false},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := validateTags(tc.tags)
+			if tc.valid {
+				assert.Nil(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrInvalidTag)
+			}
+		})
+	}
+}
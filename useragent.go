@@ -0,0 +1,29 @@
+package main
+
+import (
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+)
+
+// version is this build's version, set with
+//
+//	go build -ldflags "-X main.version=1.2.3"
+var version = "dev"
+
+// defaultUserAgent identifies this tool's API calls to AWS, as
+// example-rds-backup/<version>.
+func defaultUserAgent() string {
+	return programName + "/" + version
+}
+
+// withUserAgent adds suffix to the User-Agent of every request an RDS client
+// makes, after the SDK's own, so AWS can tell this tool's traffic apart. An
+// empty suffix leaves the User-Agent as it is.
+func withUserAgent(suffix string) func(*rds.Options) {
+	return func(o *rds.Options) {
+		if suffix == "" {
+			return
+		}
+		o.APIOptions = append(o.APIOptions, awsmiddleware.AddUserAgentKey(suffix))
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/stretchr/testify/assert"
+)
+
+// capturingHTTPClient keeps the request it's given and fails it, so nothing
+// is sent anywhere.
+type capturingHTTPClient struct {
+	request *http.Request
+}
+
+func (c *capturingHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	c.request = req
+	return nil, errors.New("not sending requests in a test")
+}
+
+func TestWithUserAgent(t *testing.T) {
+	type testCase struct {
+		suffix       string
+		expectedEnd  string
+		expectedOpts int
+	}
+
+	testCases := map[string]testCase{
+		"adds the suffix": {
+			suffix:       "go-unit-testing/1.2.3",
+			expectedEnd:  " go-unit-testing/1.2.3",
+			expectedOpts: 1,
+		},
+		"empty leaves it alone": {
+			expectedOpts: 0,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var opts rds.Options
+			withUserAgent(tc.suffix)(&opts)
+			assert.Len(t, opts.APIOptions, tc.expectedOpts)
+
+			httpClient := &capturingHTTPClient{}
+			client := rds.New(rds.Options{
+				Region:      "us-east-1",
+				Credentials: aws.AnonymousCredentials{},
+				HTTPClient:  httpClient,
+				Retryer:     aws.NopRetryer{},
+			}, withUserAgent(tc.suffix))
+			_, err := client.DescribeDBClusters(context.TODO(), &rds.DescribeDBClustersInput{})
+			assert.Error(t, err)
+
+			userAgent := httpClient.request.Header.Get("User-Agent")
+			assert.True(t, strings.HasPrefix(userAgent, "aws-sdk-go-v2/"), userAgent)
+			if tc.expectedEnd != "" {
+				assert.True(t, strings.HasSuffix(userAgent, tc.expectedEnd), userAgent)
+			}
+		})
+	}
+}
+
+func TestDefaultUserAgent(t *testing.T) {
+	assert.Equal(t, "example-rds-backup/dev", defaultUserAgent())
+}
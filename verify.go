@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+)
+
+// maxFilterValues is how many values RDS takes in one describe filter.
+const maxFilterValues = 100
+
+// statusNotFound stands in for the status of a snapshot that doesn't exist.
+const statusNotFound = "not-found"
+
+const ErrSnapshotsUnavailable BackupManagerError = "some snapshots aren't available"
+
+// VerifySnapshots looks up the status of every given snapshot as it is right
+// now, without waiting for any of them. Snapshots that don't exist are
+// reported as "not-found". If any snapshot isn't available, the statuses come
+// back along with an error listing those snapshots.
+func (b *BackupManager) VerifySnapshots(ctx context.Context, snapshotIDs ...string) (map[string]string, error) {
+	if b.sd == nil {
+		return nil, ErrNoSnapshotDescriber
+	}
+
+	statuses := make(map[string]string, len(snapshotIDs))
+	for _, snapshotID := range snapshotIDs {
+		statuses[snapshotID] = statusNotFound
+	}
+	for start := 0; start < len(snapshotIDs); start += maxFilterValues {
+		end := start + maxFilterValues
+		if end > len(snapshotIDs) {
+			end = len(snapshotIDs)
+		}
+		if err := b.describeStatuses(ctx, snapshotIDs[start:end], statuses); err != nil {
+			return nil, err
+		}
+	}
+
+	unavailable := make([]string, 0)
+	for snapshotID, status := range statuses {
+		if status != "available" {
+			unavailable = append(unavailable, fmt.Sprintf("%s (%s)", snapshotID, status))
+		}
+	}
+	if len(unavailable) > 0 {
+		sort.Strings(unavailable)
+		return statuses, fmt.Errorf("%w: %s", ErrSnapshotsUnavailable, strings.Join(unavailable, ", "))
+	}
+	return statuses, nil
+}
+
+// describeStatuses fills in statuses for one batch of snapshots.
+func (b *BackupManager) describeStatuses(ctx context.Context, snapshotIDs []string, statuses map[string]string) error {
+	paginator := rds.NewDescribeDBClusterSnapshotsPaginator(b.sd, &rds.DescribeDBClusterSnapshotsInput{
+		Filters: []types.Filter{{Name: aws.String("db-cluster-snapshot-id"), Values: snapshotIDs}},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, snapshot := range page.DBClusterSnapshots {
+			snapshotID := aws.ToString(snapshot.DBClusterSnapshotIdentifier)
+			if _, ok := statuses[snapshotID]; ok {
+				statuses[snapshotID] = aws.ToString(snapshot.Status)
+			}
+		}
+	}
+	return nil
+}
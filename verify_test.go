@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/stretchr/testify/assert"
+)
+
+// batchCountingDescriber remembers how many snapshots each describe call
+// filtered on.
+type batchCountingDescriber struct {
+	*fakeSnapshotTaker
+	batches []int
+}
+
+func (b *batchCountingDescriber) DescribeDBClusterSnapshots(ctx context.Context, in *rds.DescribeDBClusterSnapshotsInput, optFns ...func(*rds.Options)) (*rds.DescribeDBClusterSnapshotsOutput, error) {
+	for _, filter := range in.Filters {
+		b.batches = append(b.batches, len(filter.Values))
+	}
+	return b.fakeSnapshotTaker.DescribeDBClusterSnapshots(ctx, in, optFns...)
+}
+
+func TestVerifySnapshots(t *testing.T) {
+	creating := existingSnapshot("my-cluster-2", "testing-my-cluster-2", testNow)
+	creating.Status = aws.String("creating")
+	sd := &batchCountingDescriber{fakeSnapshotTaker: NewFakeSnapshotTakerWithSnapshots(
+		existingSnapshot("my-cluster-1", "testing-my-cluster-1", testNow),
+		creating,
+		existingSnapshot("my-cluster-4", "testing-my-cluster-4", testNow),
+	)}
+	bm := &BackupManager{sd: sd}
+
+	statuses, err := bm.VerifySnapshots(context.TODO(), "testing-my-cluster-1", "testing-my-cluster-2", "testing-my-cluster-3")
+	assert.ErrorIs(t, err, ErrSnapshotsUnavailable)
+	assert.EqualError(t, err, "some snapshots aren't available: testing-my-cluster-2 (creating), testing-my-cluster-3 (not-found)")
+	assert.Equal(t, map[string]string{
+		"testing-my-cluster-1": "available",
+		"testing-my-cluster-2": "creating",
+		"testing-my-cluster-3": "not-found",
+	}, statuses)
+
+	statuses, err = bm.VerifySnapshots(context.TODO(), "testing-my-cluster-1", "testing-my-cluster-4")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"testing-my-cluster-1": "available", "testing-my-cluster-4": "available"}, statuses)
+}
+
+func TestVerifySnapshotsBatches(t *testing.T) {
+	st := NewFakeSnapshotTaker()
+	snapshotIDs := make([]string, 0, 250)
+	for i := 0; i < 250; i++ {
+		snapshotID := fmt.Sprintf("testing-my-cluster-%d", i)
+		st.snapshots = append(st.snapshots, existingSnapshot(fmt.Sprintf("my-cluster-%d", i), snapshotID, testNow))
+		snapshotIDs = append(snapshotIDs, snapshotID)
+	}
+	sd := &batchCountingDescriber{fakeSnapshotTaker: st}
+	bm := &BackupManager{sd: sd}
+
+	statuses, err := bm.VerifySnapshots(context.TODO(), snapshotIDs...)
+	assert.Nil(t, err)
+	assert.Len(t, statuses, 250)
+	assert.Equal(t, []int{100, 100, 50}, sd.batches)
+}
+
+func TestVerifySnapshotsWithoutDescriber(t *testing.T) {
+	bm := &BackupManager{}
+	_, err := bm.VerifySnapshots(context.TODO(), "testing-my-cluster-1")
+	assert.ErrorIs(t, err, ErrNoSnapshotDescriber)
+}
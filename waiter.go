@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+)
+
+const (
+	defaultWaitTimeout  = 30 * time.Minute
+	defaultPollInterval = 30 * time.Second
+)
+
+const (
+	ErrWaitTimeout    BackupManagerError = "timed out waiting for snapshots"
+	ErrSnapshotFailed BackupManagerError = "snapshot failed"
+)
+
+// WaitForSnapshots blocks until every given snapshot is available, one of
+// them fails, or WaitTimeout passes.
+func (b *BackupManager) WaitForSnapshots(ctx context.Context, snapshotIDs ...string) error {
+	return b.pollSnapshots(ctx, snapshotIDs, func(snapshotID, status string, found bool) (bool, error) {
+		switch {
+		case !found:
+			return false, fmt.Errorf("'%s': %w", snapshotID, &types.DBClusterSnapshotNotFoundFault{})
+		case status == "available":
+			return true, nil
+		case status == "failed":
+			return false, fmt.Errorf("'%s': %w", snapshotID, ErrSnapshotFailed)
+		}
+		return false, nil
+	})
+}
+
+// WaitForDeletion blocks until every given snapshot is gone, or WaitTimeout
+// passes. It's useful after pruning, when quota needs to be freed up before
+// creating new snapshots.
+func (b *BackupManager) WaitForDeletion(ctx context.Context, snapshotIDs ...string) error {
+	return b.pollSnapshots(ctx, snapshotIDs, func(snapshotID, status string, found bool) (bool, error) {
+		return !found, nil
+	})
+}
+
+// snapshotCheck reports whether a snapshot has reached the state being
+// waited for.
+type snapshotCheck func(snapshotID, status string, found bool) (bool, error)
+
+// pollSnapshots describes each pending snapshot every PollInterval until
+// check is satisfied for all of them.
+func (b *BackupManager) pollSnapshots(ctx context.Context, snapshotIDs []string, check snapshotCheck) error {
+	if b.sd == nil {
+		return ErrNoSnapshotDescriber
+	}
+
+	pending := append([]string(nil), snapshotIDs...)
+	return b.pollUntil(ctx, func(ctx context.Context) (bool, error) {
+		stillPending := pending[:0]
+		for _, snapshotID := range pending {
+			status, found, err := b.snapshotStatus(ctx, snapshotID)
+			if err != nil {
+				return false, err
+			}
+			done, err := check(snapshotID, status, found)
+			if err != nil {
+				return false, err
+			}
+			if !done {
+				stillPending = append(stillPending, snapshotID)
+			}
+		}
+		pending = stillPending
+		if len(pending) > 0 {
+			b.debugf("Waiting on %d snapshot(s).", len(pending))
+		}
+		return len(pending) == 0, nil
+	})
+}
+
+// pollUntil calls done every PollInterval until it returns true or an error,
+// giving up with ErrWaitTimeout after WaitTimeout.
+func (b *BackupManager) pollUntil(ctx context.Context, done func(context.Context) (bool, error)) error {
+	waitCtx, cancel := context.WithTimeout(ctx, b.waitTimeout())
+	defer cancel()
+
+	for {
+		ok, err := done(waitCtx)
+		if err != nil {
+			// a describe call cut off by the wait running out is a timeout too
+			if ctx.Err() == nil && errors.Is(waitCtx.Err(), context.DeadlineExceeded) {
+				return ErrWaitTimeout
+			}
+			return err
+		}
+		if ok {
+			return nil
+		}
+		if err := b.wait(waitCtx, b.pollInterval()); err != nil {
+			if ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+				return ErrWaitTimeout
+			}
+			return err
+		}
+	}
+}
+
+// snapshotStatus looks up a single snapshot's status. found is false if the
+// snapshot doesn't exist.
+func (b *BackupManager) snapshotStatus(ctx context.Context, snapshotID string) (status string, found bool, err error) {
+	out, err := b.describeSnapshot(ctx, snapshotID)
+	var nfErr *types.DBClusterSnapshotNotFoundFault
+	if errors.As(err, &nfErr) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	if len(out.DBClusterSnapshots) == 0 {
+		return "", false, nil
+	}
+	return aws.ToString(out.DBClusterSnapshots[0].Status), true, nil
+}
+
+// describeSnapshot describes a single snapshot, retrying a few times if the
+// call fails in a way that's likely to clear up.
+func (b *BackupManager) describeSnapshot(ctx context.Context, snapshotID string) (*rds.DescribeDBClusterSnapshotsOutput, error) {
+	in := &rds.DescribeDBClusterSnapshotsInput{
+		DBClusterSnapshotIdentifier: aws.String(snapshotID),
+	}
+	for attempt := 0; ; attempt++ {
+		out, err := b.sd.DescribeDBClusterSnapshots(ctx, in)
+		if err == nil || !isRetryable(err) {
+			return out, err
+		}
+		if attempt == describeRetries {
+			return nil, fmt.Errorf("describing snapshot '%s' after %d attempts: %w", snapshotID, attempt+1, err)
+		}
+
+		delay := b.retryDelay(err, attempt)
+		b.logf("Describing snapshot '%s' failed, retrying in %s: %v", snapshotID, delay, err)
+		if err := b.wait(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (b *BackupManager) waitTimeout() time.Duration {
+	if b.WaitTimeout <= 0 {
+		return defaultWaitTimeout
+	}
+	return b.WaitTimeout
+}
+
+func (b *BackupManager) pollInterval() time.Duration {
+	if b.PollInterval <= 0 {
+		return defaultPollInterval
+	}
+	return b.PollInterval
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// progressingSnapshotDescriber walks each snapshot through a list of
+// statuses, one per describe call. An empty status means the snapshot is
+// gone. The last status sticks.
+type progressingSnapshotDescriber struct {
+	statuses  map[string][]string
+	describes int
+}
+
+func (p *progressingSnapshotDescriber) DescribeDBClusterSnapshots(ctx context.Context, in *rds.DescribeDBClusterSnapshotsInput, optFns ...func(*rds.Options)) (*rds.DescribeDBClusterSnapshotsOutput, error) {
+	p.describes++
+	snapshotID := *in.DBClusterSnapshotIdentifier
+	statuses := p.statuses[snapshotID]
+	if len(statuses) == 0 {
+		return nil, &types.DBClusterSnapshotNotFoundFault{}
+	}
+
+	status := statuses[0]
+	if len(statuses) > 1 {
+		p.statuses[snapshotID] = statuses[1:]
+	}
+	if status == "" {
+		return nil, &types.DBClusterSnapshotNotFoundFault{}
+	}
+	return &rds.DescribeDBClusterSnapshotsOutput{
+		DBClusterSnapshots: []types.DBClusterSnapshot{
+			{DBClusterSnapshotIdentifier: aws.String(snapshotID), Status: aws.String(status)},
+		},
+	}, nil
+}
+
+func TestWaitForSnapshots(t *testing.T) {
+	type testCase struct {
+		snapshotIDs   []string
+		statuses      map[string][]string
+		expectedError error
+		expectedPolls int
+	}
+
+	testCases := map[string]testCase{
+		"already available": {
+			snapshotIDs: []string{"testing-my-cluster-1"},
+			statuses: map[string][]string{
+				"testing-my-cluster-1": {"available"},
+			},
+			expectedPolls: 0,
+		},
+		"becomes available": {
+			snapshotIDs: []string{"testing-my-cluster-1", "testing-my-cluster-2"},
+			statuses: map[string][]string{
+				"testing-my-cluster-1": {"creating", "creating", "available"},
+				"testing-my-cluster-2": {"creating", "available"},
+			},
+			expectedPolls: 2,
+		},
+		"snapshot fails": {
+			snapshotIDs: []string{"testing-my-cluster-1", "testing-my-cluster-2"},
+			statuses: map[string][]string{
+				"testing-my-cluster-1": {"creating", "failed"},
+				"testing-my-cluster-2": {"available"},
+			},
+			expectedError: ErrSnapshotFailed,
+			expectedPolls: 1,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			polls := 0
+			bm := &BackupManager{
+				sd: &progressingSnapshotDescriber{statuses: tc.statuses},
+				sleep: func(ctx context.Context, d time.Duration) error {
+					polls++
+					return nil
+				},
+			}
+
+			err := bm.WaitForSnapshots(context.TODO(), tc.snapshotIDs...)
+			assert.ErrorIs(t, err, tc.expectedError)
+			assert.Equal(t, tc.expectedPolls, polls)
+		})
+	}
+}
+
+func TestWaitForDeletion(t *testing.T) {
+	type testCase struct {
+		statuses      map[string][]string
+		expectedError error
+		expectedPolls int
+	}
+
+	testCases := map[string]testCase{
+		"already gone": {
+			statuses:      map[string][]string{},
+			expectedPolls: 0,
+		},
+		"disappears after deleting": {
+			statuses: map[string][]string{
+				"testing-my-cluster-1": {"deleting", "deleting", ""},
+				"testing-my-cluster-2": {"deleting", ""},
+			},
+			expectedPolls: 2,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			polls := 0
+			bm := &BackupManager{
+				sd: &progressingSnapshotDescriber{statuses: tc.statuses},
+				sleep: func(ctx context.Context, d time.Duration) error {
+					polls++
+					return nil
+				},
+			}
+
+			err := bm.WaitForDeletion(context.TODO(), "testing-my-cluster-1", "testing-my-cluster-2")
+			assert.ErrorIs(t, err, tc.expectedError)
+			assert.Equal(t, tc.expectedPolls, polls)
+		})
+	}
+}
+
+func TestWaitForDeletionTimesOut(t *testing.T) {
+	bm := &BackupManager{
+		sd: &progressingSnapshotDescriber{statuses: map[string][]string{
+			"testing-my-cluster-1": {"deleting"},
+		}},
+		WaitTimeout:  20 * time.Millisecond,
+		PollInterval: time.Millisecond,
+	}
+
+	err := bm.WaitForDeletion(context.TODO(), "testing-my-cluster-1")
+	assert.ErrorIs(t, err, ErrWaitTimeout)
+}
+
+// hangingSnapshotDescriber doesn't answer until its context is done.
+type hangingSnapshotDescriber struct{}
+
+func (hangingSnapshotDescriber) DescribeDBClusterSnapshots(ctx context.Context, in *rds.DescribeDBClusterSnapshotsInput, optFns ...func(*rds.Options)) (*rds.DescribeDBClusterSnapshotsOutput, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestWaitForDeletionTimesOutMidCall(t *testing.T) {
+	bm := &BackupManager{
+		sd:           hangingSnapshotDescriber{},
+		WaitTimeout:  20 * time.Millisecond,
+		PollInterval: time.Millisecond,
+	}
+
+	err := bm.WaitForDeletion(context.TODO(), "testing-my-cluster-1")
+	assert.ErrorIs(t, err, ErrWaitTimeout)
+}
+
+func TestWaitForDeletionRespectsCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+	bm := &BackupManager{
+		sd: &progressingSnapshotDescriber{statuses: map[string][]string{
+			"testing-my-cluster-1": {"deleting"},
+		}},
+	}
+
+	err := bm.WaitForDeletion(ctx, "testing-my-cluster-1")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+// flakySnapshotDescriber fails the first failures describe calls with err,
+// then hands over to the wrapped describer.
+type flakySnapshotDescriber struct {
+	SnapshotDescriber
+	failures int
+	err      error
+	calls    int
+}
+
+func (f *flakySnapshotDescriber) DescribeDBClusterSnapshots(ctx context.Context, in *rds.DescribeDBClusterSnapshotsInput, optFns ...func(*rds.Options)) (*rds.DescribeDBClusterSnapshotsOutput, error) {
+	f.calls++
+	if f.calls <= f.failures {
+		return nil, f.err
+	}
+	return f.SnapshotDescriber.DescribeDBClusterSnapshots(ctx, in, optFns...)
+}
+
+func TestWaitForSnapshotsRetriesDescribe(t *testing.T) {
+	throttled := &smithy.GenericAPIError{Code: "Throttling", Message: "Rate exceeded"}
+	denied := errors.New("access denied")
+
+	type testCase struct {
+		failures      int
+		err           error
+		expectedError error
+		expectedCalls int
+	}
+
+	testCases := map[string]testCase{
+		"fails once": {
+			failures:      1,
+			err:           throttled,
+			expectedCalls: 2,
+		},
+		"keeps failing": {
+			failures:      10,
+			err:           throttled,
+			expectedError: throttled,
+			expectedCalls: describeRetries + 1,
+		},
+		"not worth retrying": {
+			failures:      1,
+			err:           denied,
+			expectedError: denied,
+			expectedCalls: 1,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			sd := &flakySnapshotDescriber{
+				SnapshotDescriber: &progressingSnapshotDescriber{statuses: map[string][]string{
+					"testing-my-cluster-1": {"available"},
+				}},
+				failures: tc.failures,
+				err:      tc.err,
+			}
+			bm := &BackupManager{sd: sd, sleep: noSleep}
+
+			err := bm.WaitForSnapshots(context.TODO(), "testing-my-cluster-1")
+			assert.ErrorIs(t, err, tc.expectedError)
+			assert.Equal(t, tc.expectedCalls, sd.calls)
+		})
+	}
+}
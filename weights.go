@@ -0,0 +1,41 @@
+package main
+
+import "context"
+
+// pickWeighted chooses which of the pending jobs to start next: the first
+// whose weight fits in what's left of budget alongside inFlight. A job
+// heavier than the whole budget fits only when nothing else is running, so
+// it still gets its turn. With no budget, the first job always fits.
+func pickWeighted(pending, weights []int, inFlight, budget int) (int, bool) {
+	for i, job := range pending {
+		if budget <= 0 || inFlight == 0 || inFlight+weights[job] <= budget {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// clusterWeights is each cluster's weight under WeightBudget: its allocated
+// storage, or one if that's not known. They're all zero without a budget.
+func (b *BackupManager) clusterWeights(ctx context.Context, clusterIDs []string) []int {
+	weights := make([]int, len(clusterIDs))
+	if b.WeightBudget <= 0 {
+		return weights
+	}
+	for i, clusterID := range clusterIDs {
+		weights[i] = 1
+		clusterIdentifier, err := parseClusterIdentifier(clusterID)
+		if err != nil {
+			continue
+		}
+		info, err := b.clusterInfo(ctx, clusterIdentifier)
+		if err != nil {
+			b.logf("Couldn't look up the size of '%s', counting it as small: %v", clusterIdentifier, err)
+			continue
+		}
+		if info.AllocatedStorage > 1 {
+			weights[i] = int(info.AllocatedStorage)
+		}
+	}
+	return weights
+}
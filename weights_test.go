@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPickWeighted(t *testing.T) {
+	type testCase struct {
+		pending  []int
+		inFlight int
+		budget   int
+		next     int
+		ok       bool
+	}
+
+	// jobs 0 and 1 are huge, 2 and 3 small
+	weights := []int{800, 1200, 100, 50}
+	testCases := map[string]testCase{
+		"no budget takes the first":         {pending: []int{0, 1, 2}, inFlight: 5000, budget: 0, next: 0, ok: true},
+		"the first that fits":               {pending: []int{0, 1, 2, 3}, inFlight: 300, budget: 1000, next: 2, ok: true},
+		"a small one squeezes in":           {pending: []int{1, 3}, inFlight: 900, budget: 1000, next: 1, ok: true},
+		"nothing fits":                      {pending: []int{0, 1}, inFlight: 300, budget: 1000},
+		"too big for the budget, alone":     {pending: []int{1}, inFlight: 0, budget: 1000, next: 0, ok: true},
+		"too big for the budget, not alone": {pending: []int{1}, inFlight: 50, budget: 1000},
+		"exactly fills the budget":          {pending: []int{0}, inFlight: 200, budget: 1000, next: 0, ok: true},
+		"nothing pending":                   {pending: []int{}, budget: 1000},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			next, ok := pickWeighted(tc.pending, weights, tc.inFlight, tc.budget)
+			assert.Equal(t, tc.ok, ok)
+			if tc.ok {
+				assert.Equal(t, tc.next, next)
+			}
+		})
+	}
+}
+
+// weighingSnapshotTaker holds each snapshot for a moment, noting whether
+// more than one at a time ever added up to more than budget, and the most it
+// saw at once.
+type weighingSnapshotTaker struct {
+	*fakeSnapshotTaker
+	mu         sync.Mutex
+	sizes      map[string]int
+	budget     int
+	inFlight   int
+	running    int
+	peak       int
+	overBudget bool
+}
+
+func (w *weighingSnapshotTaker) CreateDBClusterSnapshot(ctx context.Context, in *rds.CreateDBClusterSnapshotInput, optFns ...func(*rds.Options)) (*rds.CreateDBClusterSnapshotOutput, error) {
+	size := w.sizes[aws.ToString(in.DBClusterIdentifier)]
+	w.mu.Lock()
+	w.inFlight += size
+	w.running++
+	if w.running > w.peak {
+		w.peak = w.running
+	}
+	if w.running > 1 && w.inFlight > w.budget {
+		w.overBudget = true
+	}
+	w.mu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	w.mu.Lock()
+	w.inFlight -= size
+	w.running--
+	w.mu.Unlock()
+	return w.fakeSnapshotTaker.CreateDBClusterSnapshot(ctx, in, optFns...)
+}
+
+func TestTriggerSnapshotsWeighted(t *testing.T) {
+	st := &weighingSnapshotTaker{
+		fakeSnapshotTaker: NewFakeSnapshotTaker(),
+		sizes:             map[string]int{"huge-1": 800, "huge-2": 1200, "small-1": 100, "small-2": 100, "small-3": 100},
+		budget:            1000,
+	}
+	clusterIDs := []string{"huge-1", "huge-2", "small-1", "small-2", "small-3"}
+	for _, clusterID := range clusterIDs {
+		cluster := existingCluster(clusterID)
+		cluster.AllocatedStorage = aws.Int32(int32(st.sizes[clusterID]))
+		st.clusters = append(st.clusters, cluster)
+	}
+	// there's no size to find for this one
+	clusterIDs = append(clusterIDs, "unknown")
+
+	bm := NewBackupManager(st, WithPrefix("testing"), WithWeightBudget(1000))
+	results, err := bm.TriggerSnapshots(context.TODO(), clusterIDs...)
+	assert.Nil(t, err)
+	assert.Len(t, results, 6)
+	assert.Equal(t, RunStats{Created: 6}, bm.Stats())
+	// huge-1 and the small ones can run together, but not with huge-2
+	assert.False(t, st.overBudget)
+	assert.Greater(t, st.peak, 1)
+	assert.Equal(t, []int{800, 1200, 100, 100, 100, 1}, bm.clusterWeights(context.TODO(), clusterIDs))
+}